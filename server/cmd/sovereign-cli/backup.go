@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sovereign-im/sovereign/server/internal/backup"
+	"github.com/sovereign-im/sovereign/server/internal/store"
+)
+
+// passphraseEnv holds the backup passphrase when -passphrase-file is not
+// given.
+const passphraseEnv = "SOVEREIGN_BACKUP_PASSPHRASE"
+
+func runBackup(args []string) error {
+	fs := flag.NewFlagSet("backup", flag.ExitOnError)
+	dbPath := fs.String("db", "sovereign.db", "SQLite database to back up")
+	out := fs.String("o", "", "file to write the backup to (default sovereign-<time>.sbk)")
+	compress := fs.Bool("compress", true, "gzip the backup")
+	passFile := fs.String("passphrase-file", "", "encrypt with the passphrase in this file (default $"+passphraseEnv+")")
+	fs.Parse(args)
+
+	passphrase, err := readPassphrase(*passFile)
+	if err != nil {
+		return err
+	}
+	if *out == "" {
+		*out = "sovereign-" + time.Now().UTC().Format("20060102T150405Z") + ".sbk"
+	}
+	// Snapshotting the file directly does not need the server to stop.
+	if _, err := os.Stat(*dbPath); err != nil {
+		return err
+	}
+	snapshot := func(ctx context.Context, path string) error {
+		return store.SnapshotFile(ctx, *dbPath, path)
+	}
+
+	f, err := os.OpenFile(*out, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return err
+	}
+	h, err := backup.Create(context.Background(), snapshot, f, backup.Options{
+		Compress:   *compress,
+		Passphrase: passphrase,
+	})
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(*out)
+		return err
+	}
+
+	fmt.Printf("Backed up %s to %s (schema version %d", *dbPath, *out, h.SchemaVersion)
+	if h.Compressed {
+		fmt.Print(", compressed")
+	}
+	if h.Encrypted {
+		fmt.Print(", encrypted")
+	}
+	fmt.Println(")")
+	return nil
+}
+
+func runRestore(args []string) error {
+	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+	dbPath := fs.String("db", "sovereign.db", "SQLite database to restore to")
+	in := fs.String("i", "", "backup file to restore from")
+	replica := fs.String("replica", "", "replica directory to restore from instead of a backup file")
+	pointInTime := fs.String("point-in-time", "", "with -replica, restore the database as of this RFC 3339 time (default latest)")
+	force := fs.Bool("force", false, "replace an existing database")
+	passFile := fs.String("passphrase-file", "", "decrypt with the passphrase in this file (default $"+passphraseEnv+")")
+	fs.Parse(args)
+
+	if (*in == "") == (*replica == "") {
+		return errors.New("exactly one of -i and -replica is required")
+	}
+	var at time.Time
+	if *pointInTime != "" {
+		if *replica == "" {
+			return errors.New("-point-in-time requires -replica")
+		}
+		var err error
+		if at, err = time.Parse(time.RFC3339, *pointInTime); err != nil {
+			return fmt.Errorf("-point-in-time: %w", err)
+		}
+	}
+	if _, err := os.Stat(*dbPath); err == nil && !*force {
+		return fmt.Errorf("%s exists; stop the server and pass -force to replace it", *dbPath)
+	}
+	// The file must not be replaced under a running server, -force or not.
+	release, err := store.LockFileExclusive(*dbPath)
+	if errors.Is(err, store.ErrInUse) {
+		return fmt.Errorf("%w; stop the server before restoring", err)
+	}
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	if *replica != "" {
+		restored, err := backup.RestoreReplica(context.Background(), *replica, *dbPath, at)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Restored %s as of %s from replica %s\n",
+			*dbPath, restored.UTC().Format(time.RFC3339Nano), *replica)
+		return nil
+	}
+
+	passphrase, err := readPassphrase(*passFile)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(*in)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	h, err := backup.Restore(context.Background(), f, *dbPath, passphrase)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Restored %s from backup taken %s (schema version %d)\n",
+		*dbPath, time.Unix(h.CreatedAt, 0).UTC().Format(time.RFC3339), h.SchemaVersion)
+	return nil
+}
+
+// readPassphrase reads the passphrase from path, or from the environment
+// if path is empty. Passphrases are not taken as flags so that they do not
+// show up in the process list.
+func readPassphrase(path string) (string, error) {
+	if path == "" {
+		return os.Getenv(passphraseEnv), nil
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("read passphrase: %w", err)
+	}
+	passphrase := strings.TrimRight(string(b), "\r\n")
+	if passphrase == "" {
+		return "", fmt.Errorf("passphrase file %s is empty", path)
+	}
+	return passphrase, nil
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/sovereign-im/sovereign/server/internal/store"
+)
+
+func runDB(args []string) error {
+	if len(args) < 1 {
+		return errors.New("usage: sovereign-cli db status|migrate|rollback|check [flags]")
+	}
+	sub := args[0]
+	fs := flag.NewFlagSet("db "+sub, flag.ExitOnError)
+	dbPath := fs.String("db", "sovereign.db", "SQLite database")
+	pgURL := fs.String("postgres", "", "PostgreSQL connection string, instead of -db")
+	to := fs.Int("to", -1, "schema version to migrate or roll back to (default latest for migrate)")
+	dryRun := fs.Bool("dry-run", false, "run the migrations in a transaction that is rolled back")
+	repair := fs.Bool("repair", false, "with check, delete orphaned rows")
+	fs.Parse(args[1:])
+
+	var db *store.Store
+	var err error
+	opts := store.Options{SkipMigrations: true}
+	if *pgURL != "" {
+		db, err = store.OpenPostgres(*pgURL, opts)
+	} else {
+		// Opening a missing SQLite file would create it.
+		if _, err := os.Stat(*dbPath); err != nil {
+			return err
+		}
+		db, err = store.Open(*dbPath, opts)
+	}
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	infos, err := db.Migrations(ctx)
+	if err != nil {
+		return err
+	}
+	current := 0
+	latest := 0
+	for _, m := range infos {
+		if m.AppliedAt != 0 {
+			current = m.Version
+		}
+		if m.Name != "" {
+			latest = m.Version
+		}
+	}
+
+	switch sub {
+	case "status":
+		printStatus(infos, current, latest)
+		return nil
+	case "check":
+		if current != latest {
+			return fmt.Errorf("database is at version %d; migrate it to %d before checking", current, latest)
+		}
+		if *repair {
+			release, err := lockForMaintenance(ctx, db, "repairing it")
+			if err != nil {
+				return err
+			}
+			defer release()
+		}
+		return checkIntegrity(ctx, db, *repair)
+	case "migrate":
+		if *to == -1 {
+			*to = latest
+		}
+		if *to < current {
+			return fmt.Errorf("database is at version %d; use rollback to go back to %d", current, *to)
+		}
+	case "rollback":
+		if *to == -1 {
+			return errors.New("-to is required")
+		}
+		if *to > current {
+			return fmt.Errorf("database is at version %d; use migrate to go forward to %d", current, *to)
+		}
+	default:
+		return fmt.Errorf("unknown db command %q", sub)
+	}
+
+	release, err := lockForMaintenance(ctx, db, "migrating it")
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	backupPath, err := db.MigrateTo(ctx, *to, *dryRun)
+	if backupPath != "" {
+		fmt.Printf("Backed up the database to %s\n", backupPath)
+	}
+	if err != nil {
+		return err
+	}
+	switch {
+	case *to == current:
+		fmt.Printf("Database is already at version %d\n", current)
+	case *dryRun:
+		fmt.Printf("Dry run: version %d to %d would succeed; nothing was changed\n", current, *to)
+	default:
+		fmt.Printf("Database moved from version %d to %d\n", current, *to)
+	}
+	return nil
+}
+
+// lockForMaintenance takes the database lock exclusively, so that no server
+// writes to the database meanwhile. If a server holds the lock, the error
+// wraps store.ErrInUse and says to stop it before doing the maintenance.
+func lockForMaintenance(ctx context.Context, db *store.Store, doing string) (release func() error, err error) {
+	release, err = db.LockExclusive(ctx)
+	if errors.Is(err, store.ErrInUse) {
+		return nil, fmt.Errorf("%w; stop the server before %s", err, doing)
+	}
+	return release, err
+}
+
+func printStatus(infos []store.MigrationInfo, current, latest int) {
+	fmt.Printf("Schema version %d; this build supports up to %d\n", current, latest)
+	for _, m := range infos {
+		name := m.Name
+		if name == "" {
+			name = "(unknown to this build)"
+		}
+		state := "pending"
+		if m.AppliedAt != 0 {
+			state = "applied " + time.Unix(m.AppliedAt, 0).UTC().Format(time.RFC3339)
+		}
+		fmt.Printf("  %3d  %-30s %s\n", m.Version, name, state)
+	}
+	if current > latest {
+		fmt.Println("The database is newer than this build; upgrade the server before starting it.")
+	}
+}
+
+// checkIntegrity prints what CheckIntegrity finds and, with repair, repairs
+// the orphaned rows. It returns an error if problems remain.
+func checkIntegrity(ctx context.Context, db *store.Store, repair bool) error {
+	report, err := db.CheckIntegrity(ctx)
+	if err != nil {
+		return err
+	}
+	for _, line := range report.Corruption {
+		fmt.Printf("corruption: %s\n", line)
+	}
+	for _, v := range report.ForeignKeys {
+		fmt.Printf("foreign key: %d rows of %s refer to missing %s rows\n", v.Count, v.Table, v.Parent)
+	}
+	for _, o := range report.Orphans {
+		fmt.Printf("orphans: %d %s (%s)\n", o.Count, o.Description, o.Check)
+	}
+	if report.OK() {
+		fmt.Println("No problems found")
+		return nil
+	}
+	if !repair {
+		return errors.New("problems found; run with -repair to delete orphaned rows")
+	}
+
+	repaired, err := db.RepairIntegrity(ctx)
+	if err != nil {
+		return err
+	}
+	for _, o := range repaired {
+		fmt.Printf("repaired: %d %s (%s)\n", o.Count, o.Description, o.Check)
+	}
+	if len(report.Corruption) > 0 {
+		return errors.New("the database file is corrupt; restore it from a backup")
+	}
+	report, err = db.CheckIntegrity(ctx)
+	if err != nil {
+		return err
+	}
+	if !report.OK() {
+		return errors.New("problems remain after repair")
+	}
+	fmt.Println("Repaired; no problems remain")
+	return nil
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/sovereign-im/sovereign/server/internal/store"
+)
+
+func runKey(args []string) error {
+	if len(args) < 1 {
+		return errors.New("usage: sovereign-cli key generate|status|rotate [flags]")
+	}
+	sub := args[0]
+	fs := flag.NewFlagSet("key "+sub, flag.ExitOnError)
+	out := fs.String("o", "sovereign.key", "with generate, file to write the master key to")
+	dbPath := fs.String("db", "sovereign.db", "SQLite database")
+	pgURL := fs.String("postgres", "", "PostgreSQL connection string, instead of -db")
+	keyFile := fs.String("key-file", "", "current master key file")
+	passFile := fs.String("passphrase-file", "", "file holding the current master passphrase, instead of -key-file")
+	newKeyFile := fs.String("new-key-file", "", "with rotate, the new master key file")
+	newPassFile := fs.String("new-passphrase-file", "", "with rotate, file holding the new master passphrase, instead of -new-key-file")
+	dataKey := fs.Bool("data-key", false, "with rotate, also replace the data key and re-encrypt every sealed value")
+	fs.Parse(args[1:])
+
+	if sub == "generate" {
+		if err := store.GenerateKeyFile(*out); err != nil {
+			return err
+		}
+		fmt.Printf("Wrote a new master key to %s; keep a copy with your backups\n", *out)
+		return nil
+	}
+
+	var db *store.Store
+	var err error
+	opts := store.Options{SkipMigrations: true}
+	if *pgURL != "" {
+		db, err = store.OpenPostgres(*pgURL, opts)
+	} else {
+		// Opening a missing SQLite file would create it.
+		if _, err := os.Stat(*dbPath); err != nil {
+			return err
+		}
+		db, err = store.Open(*dbPath, opts)
+	}
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	switch sub {
+	case "status":
+		info, err := db.Encryption(ctx)
+		if err != nil {
+			return err
+		}
+		if !info.Enabled {
+			fmt.Println("Encryption at rest is not enabled")
+			return nil
+		}
+		kind := "a key file"
+		if info.Passphrase {
+			kind = "a passphrase"
+		}
+		fmt.Printf("Encrypted at rest with %s since %s; keys last rotated %s\n", kind,
+			time.Unix(info.CreatedAt, 0).UTC().Format(time.RFC3339),
+			time.Unix(info.RotatedAt, 0).UTC().Format(time.RFC3339))
+		return nil
+	case "rotate":
+		oldMK, err := masterKey(*keyFile, *passFile)
+		if err != nil {
+			return fmt.Errorf("current key: %w", err)
+		}
+		newMK, err := masterKey(*newKeyFile, *newPassFile)
+		if err != nil {
+			return fmt.Errorf("new key: %w", err)
+		}
+		// The server would go on writing with the old keys.
+		release, err := db.LockExclusive(ctx)
+		if errors.Is(err, store.ErrInUse) {
+			return errors.New("the database is in use; stop the server before rotating keys")
+		}
+		if err != nil {
+			return err
+		}
+		defer release()
+		// Encryption is only stored at the latest schema version, and
+		// migrating is left to db migrate.
+		infos, err := db.Migrations(ctx)
+		if err != nil {
+			return err
+		}
+		for _, m := range infos {
+			if m.AppliedAt == 0 {
+				return fmt.Errorf("schema version %d is not applied; run sovereign-cli db migrate first", m.Version)
+			}
+		}
+		initialized, err := db.Unlock(ctx, oldMK)
+		if err != nil {
+			return err
+		}
+		if initialized {
+			fmt.Println("Encryption at rest was not enabled; enabled it with the current key")
+		}
+		if err := db.RotateKey(ctx, newMK, *dataKey); err != nil {
+			return err
+		}
+		if *dataKey {
+			fmt.Println("Rotated the master key and the data key; the old master key no longer opens the database")
+		} else {
+			fmt.Println("Rotated the master key; the old master key no longer opens the database")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown key command %q", sub)
+	}
+}
+
+// masterKey reads a master key from a key file or a passphrase file,
+// exactly one of which must be given.
+func masterKey(keyFile, passFile string) (*store.MasterKey, error) {
+	switch {
+	case keyFile != "" && passFile != "":
+		return nil, errors.New("give a key file or a passphrase file, not both")
+	case keyFile != "":
+		return store.MasterKeyFromFile(keyFile)
+	case passFile != "":
+		passphrase, err := readPassphrase(passFile)
+		if err != nil {
+			return nil, err
+		}
+		return store.MasterKeyFromPassphrase(passphrase)
+	default:
+		return nil, errors.New("a key file or a passphrase file is required")
+	}
+}
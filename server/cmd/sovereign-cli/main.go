@@ -7,20 +7,41 @@ import (
 
 func main() {
 	if len(os.Args) < 2 {
-		fmt.Println("Usage: sovereign-cli <command>")
-		fmt.Println()
-		fmt.Println("Commands:")
-		fmt.Println("  setup    Run the interactive setup wizard")
+		usage()
 		os.Exit(1)
 	}
 
+	var err error
 	switch os.Args[1] {
 	case "setup":
 		// TODO: Run setup wizard
 		fmt.Println("Sovereign setup wizard")
 		fmt.Println("This will guide you through setting up your Sovereign server.")
+	case "backup":
+		err = runBackup(os.Args[2:])
+	case "restore":
+		err = runRestore(os.Args[2:])
+	case "db":
+		err = runDB(os.Args[2:])
+	case "key":
+		err = runKey(os.Args[2:])
 	default:
 		fmt.Fprintf(os.Stderr, "Unknown command: %s\n", os.Args[1])
 		os.Exit(1)
 	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", os.Args[1], err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Println("Usage: sovereign-cli <command>")
+	fmt.Println()
+	fmt.Println("Commands:")
+	fmt.Println("  setup    Run the interactive setup wizard")
+	fmt.Println("  backup   Back up the database while the server runs")
+	fmt.Println("  restore  Restore the database from a backup")
+	fmt.Println("  db       Show or change the database schema version (status, migrate, rollback, check)")
+	fmt.Println("  key      Manage the master key for encryption at rest (generate, status, rotate)")
 }
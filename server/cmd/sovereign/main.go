@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"io/fs"
 	"log"
 	"net/http"
@@ -10,7 +11,9 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/sovereign-im/sovereign/server/internal/admin"
 	"github.com/sovereign-im/sovereign/server/internal/auth"
+	"github.com/sovereign-im/sovereign/server/internal/backup"
 	"github.com/sovereign-im/sovereign/server/internal/config"
 	"github.com/sovereign-im/sovereign/server/internal/mls"
 	"github.com/sovereign-im/sovereign/server/internal/store"
@@ -25,30 +28,109 @@ func main() {
 	log.Printf("Sovereign server starting on %s", cfg.ListenAddr)
 
 	// Initialize database.
-	db, err := store.New(cfg.DatabasePath)
+	var db *store.Store
+	var err error
+	// Migrations are run below, so that the pre-migration backup is logged.
+	// The replicator reads changes from the write-ahead log, so it runs the
+	// checkpoints.
+	dbOpts := store.Options{
+		ReadConns:         cfg.DBReadConns,
+		SkipMigrations:    true,
+		ManualCheckpoints: cfg.ReplicaDir != "",
+	}
+	switch cfg.DatabaseDriver {
+	case "", "sqlite":
+		db, err = store.Open(cfg.DatabasePath, dbOpts)
+	case "postgres":
+		db, err = store.OpenPostgres(cfg.DatabaseURL, dbOpts)
+	default:
+		log.Fatalf("Unknown database driver %q", cfg.DatabaseDriver)
+	}
 	if err != nil {
 		log.Fatalf("Failed to open database: %v", err)
 	}
 	defer db.Close()
-	log.Printf("Database opened: %s", cfg.DatabasePath)
+	log.Printf("Database opened: %s", db.Dialect())
+
+	// Held until exit, so that maintenance such as key rotation cannot
+	// run while the server is up.
+	releaseLock, err := db.LockShared(context.Background())
+	if err != nil {
+		log.Fatalf("Failed to lock database: %v", err)
+	}
+	defer releaseLock()
+
+	// A database migrated by a newer build is refused with
+	// store.ErrSchemaTooNew rather than run against an unknown schema.
+	backupPath, err := db.Migrate(context.Background(), false)
+	if backupPath != "" {
+		log.Printf("Backed up database to %s before migrating", backupPath)
+	}
+	if err != nil {
+		log.Fatalf("Failed to migrate database: %v", err)
+	}
+	if err := unlockDatabase(db, cfg); err != nil {
+		log.Fatalf("Failed to unlock database: %v", err)
+	}
 
 	// Initialize auth service.
-	authSvc, err := auth.NewService(db, cfg.RPDisplayName, cfg.RPID, cfg.RPOrigins)
+	authSvc, err := auth.NewService(db, cfg.RPDisplayName, cfg.RPID, cfg.RPOrigins, auth.Policy{
+		UserVerification: cfg.UserVerification,
+		ResidentKey:      cfg.ResidentKey,
+		AllowedAAGUIDs:   cfg.AllowedAAGUIDs,
+	}, auth.SessionLifetime{
+		MaxAge:         cfg.SessionMaxAge,
+		IdleTimeout:    cfg.SessionIdleTimeout,
+		RotateOnResume: cfg.SessionRotateOnResume,
+	})
 	if err != nil {
 		log.Fatalf("Failed to create auth service: %v", err)
 	}
 
 	// Initialize MLS service.
-	mlsSvc := mls.NewService(db)
+	mlsSvc, err := mls.NewService(db, cfg.MinKeyPackages, cfg.UpdateInterval)
+	if err != nil {
+		log.Fatalf("Failed to create MLS service: %v", err)
+	}
 
 	hub := ws.NewHub()
 	go hub.Run()
+	if cfg.UpdateInterval > 0 {
+		go hub.RunUpdateReminders(mlsSvc, time.Hour)
+	}
+
+	// Backups are taken by the server only for SQLite; PostgreSQL has its
+	// own tooling.
+	var backups *backup.Scheduler
+	if db.Dialect() == store.SQLite {
+		backups = backup.NewScheduler(db.Snapshot, cfg.BackupDir, backup.Options{
+			Compress:   cfg.BackupCompress,
+			Passphrase: cfg.BackupPassphrase,
+		}, backup.Schedule{
+			Interval: cfg.BackupInterval,
+			Keep:     cfg.BackupKeep,
+		})
+		go backups.Run()
+	}
+	var replicator *backup.Replicator
+	if db.Dialect() == store.SQLite && cfg.ReplicaDir != "" {
+		replicator = backup.NewReplicator(db, cfg.ReplicaDir, backup.ReplicaOptions{
+			Interval:     cfg.ReplicaInterval,
+			BaseInterval: cfg.ReplicaBaseInterval,
+			Retention:    cfg.ReplicaRetention,
+		})
+		go replicator.Run()
+		log.Printf("Replicating database to %s every %s", cfg.ReplicaDir, cfg.ReplicaInterval)
+	}
 
 	mux := http.NewServeMux()
 
 	// WebSocket endpoint.
 	mux.Handle("/ws", ws.UpgradeHandler(hub, cfg.MaxMessageSize, authSvc, db, mlsSvc))
 
+	// Admin REST API.
+	mux.Handle("/admin/api/", admin.NewHandler(db, authSvc, mlsSvc, backups))
+
 	// Embedded admin UI.
 	adminFS, err := fs.Sub(web.Dist, "dist")
 	if err != nil {
@@ -78,6 +160,12 @@ func main() {
 	log.Printf("Received signal %s, shutting down...", sig)
 
 	hub.Stop()
+	if backups != nil {
+		backups.Stop()
+	}
+	if replicator != nil {
+		replicator.Stop()
+	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
@@ -88,3 +176,42 @@ func main() {
 
 	log.Println("Server stopped")
 }
+
+// unlockDatabase enables encryption at rest with the configured master key.
+// Without one, it checks that the database is not encrypted, since its
+// sealed columns could not be read.
+func unlockDatabase(db *store.Store, cfg config.Config) error {
+	ctx := context.Background()
+	var mk *store.MasterKey
+	var err error
+	switch {
+	case cfg.EncryptionKeyFile != "" && cfg.EncryptionPassphrase != "":
+		return errors.New("set an encryption key file or passphrase, not both")
+	case cfg.EncryptionKeyFile != "":
+		mk, err = store.MasterKeyFromFile(cfg.EncryptionKeyFile)
+	case cfg.EncryptionPassphrase != "":
+		mk, err = store.MasterKeyFromPassphrase(cfg.EncryptionPassphrase)
+	default:
+		info, err := db.Encryption(ctx)
+		if err != nil {
+			return err
+		}
+		if info.Enabled {
+			return store.ErrEncrypted
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	initialized, err := db.Unlock(ctx, mk)
+	if err != nil {
+		return err
+	}
+	if initialized {
+		log.Printf("Encryption at rest enabled; existing data encrypted")
+	} else {
+		log.Printf("Database unlocked")
+	}
+	return nil
+}
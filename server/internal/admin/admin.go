@@ -1,6 +1,419 @@
 package admin
 
-// Handler serves the admin REST API and embedded admin UI.
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sovereign-im/sovereign/server/internal/auth"
+	"github.com/sovereign-im/sovereign/server/internal/backup"
+	"github.com/sovereign-im/sovereign/server/internal/mls"
+	"github.com/sovereign-im/sovereign/server/internal/store"
+)
+
+// Pagination defaults for list endpoints (see docs/api/admin-api.md).
+const (
+	defaultLimit = 20
+	maxLimit     = 100
+)
+
+// sessionCookie is the cookie carrying an admin session token.
+const sessionCookie = "sovereign_admin_session"
+
+// Handler serves the admin REST API under /admin/api/.
 type Handler struct {
-	// TODO: Admin API endpoints
+	store       store.Backend
+	authService *auth.Service
+	mlsService  *mls.Service
+	backups     *backup.Scheduler
+	mux         *http.ServeMux
+}
+
+// NewHandler creates a new admin API handler. backups may be nil when the
+// database cannot be backed up by the server, in which case the backup
+// endpoints respond 503.
+func NewHandler(st store.Backend, authService *auth.Service, mlsService *mls.Service, backups *backup.Scheduler) *Handler {
+	h := &Handler{
+		store:       st,
+		authService: authService,
+		mlsService:  mlsService,
+		backups:     backups,
+		mux:         http.NewServeMux(),
+	}
+
+	h.mux.HandleFunc("GET /admin/api/audit-events", h.requireAdmin(h.handleListAuditEvents))
+	h.mux.HandleFunc("GET /admin/api/auth/lockouts", h.requireAdmin(h.handleListLockouts))
+	h.mux.HandleFunc("GET /admin/api/users/{id}/key-packages", h.requireAdmin(h.handleKeyPackageInventory))
+	h.mux.HandleFunc("GET /admin/api/conversations/{id}/key-updates", h.requireAdmin(h.handleKeyUpdates))
+	h.mux.HandleFunc("GET /admin/api/backups", h.requireAdmin(h.requireBackups(h.handleListBackups)))
+	h.mux.HandleFunc("POST /admin/api/backups", h.requireAdmin(h.requireBackups(h.handleCreateBackup)))
+	h.mux.HandleFunc("PUT /admin/api/backups/schedule", h.requireAdmin(h.requireBackups(h.handleSetBackupSchedule)))
+
+	return h
+}
+
+// ServeHTTP implements http.Handler.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+// requireAdmin wraps a handler so it only runs for authenticated server admins.
+func (h *Handler) requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := sessionToken(r)
+		if token == "" {
+			writeError(w, http.StatusUnauthorized, 1001, "Not authenticated")
+			return
+		}
+
+		info, err := h.authService.ValidateSession(r.Context(), token)
+		if err != nil {
+			switch {
+			case errors.Is(err, auth.ErrSessionExpired):
+				writeError(w, http.StatusUnauthorized, 1002, "Session expired")
+			case errors.Is(err, auth.ErrAccountDisabled):
+				writeError(w, http.StatusForbidden, 2004, "Account disabled")
+			case errors.Is(err, auth.ErrInvalidCredential):
+				writeError(w, http.StatusUnauthorized, 1001, "Not authenticated")
+			default:
+				log.Printf("admin: validate session: %v", err)
+				writeError(w, http.StatusInternalServerError, 9001, "Internal error")
+			}
+			return
+		}
+
+		user, err := h.store.GetUserByID(r.Context(), info.UserID)
+		if err != nil {
+			log.Printf("admin: get user %s: %v", info.UserID, err)
+			writeError(w, http.StatusInternalServerError, 9001, "Internal error")
+			return
+		}
+		if user.Role != "admin" {
+			writeError(w, http.StatusForbidden, 2003, "Not authorized as admin")
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// ============================================================================
+// Audit Log
+// ============================================================================
+
+type auditEventJSON struct {
+	ID        string `json:"event_id"`
+	EventType string `json:"event_type"`
+	UserID    string `json:"user_id,omitempty"`
+	Detail    string `json:"detail,omitempty"`
+	CreatedAt string `json:"created_at"`
+}
+
+func (h *Handler) handleListAuditEvents(w http.ResponseWriter, r *http.Request) {
+	offset, limit := pagination(r)
+	eventType := r.URL.Query().Get("event_type")
+
+	events, err := h.store.ListAuditEvents(r.Context(), eventType, offset, limit)
+	if err != nil {
+		internalError(w, "list audit events", err)
+		return
+	}
+	total, err := h.store.CountAuditEvents(r.Context(), eventType)
+	if err != nil {
+		internalError(w, "count audit events", err)
+		return
+	}
+
+	data := make([]auditEventJSON, len(events))
+	for i, e := range events {
+		data[i] = auditEventJSON{
+			ID:        e.ID,
+			EventType: e.EventType,
+			UserID:    e.UserID,
+			Detail:    e.Detail,
+			CreatedAt: formatTime(e.CreatedAt),
+		}
+	}
+	writeJSON(w, http.StatusOK, pageJSON{
+		Data:       data,
+		Pagination: paginationJSON{Offset: offset, Limit: limit, Total: total},
+	})
+}
+
+// ============================================================================
+// Login Throttling
+// ============================================================================
+
+type lockoutJSON struct {
+	Kind        string `json:"kind"`
+	Value       string `json:"value"`
+	Failures    int    `json:"failures"`
+	LastFailure string `json:"last_failure_at"`
+	LockedUntil string `json:"locked_until"`
+}
+
+func (h *Handler) handleListLockouts(w http.ResponseWriter, r *http.Request) {
+	lockouts := h.authService.Lockouts()
+
+	data := make([]lockoutJSON, len(lockouts))
+	for i, l := range lockouts {
+		kind, value, _ := strings.Cut(l.Key, ":")
+		data[i] = lockoutJSON{
+			Kind:        kind,
+			Value:       value,
+			Failures:    l.Failures,
+			LastFailure: formatTime(l.LastFailure.Unix()),
+			LockedUntil: formatTime(l.LockedUntil.Unix()),
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"data": data})
+}
+
+// ============================================================================
+// MLS Key Packages
+// ============================================================================
+
+type keyPackageSuiteJSON struct {
+	CipherSuite   uint16 `json:"cipher_suite"`
+	Available     int    `json:"available"`
+	HasLastResort bool   `json:"has_last_resort"`
+}
+
+func (h *Handler) handleKeyPackageInventory(w http.ResponseWriter, r *http.Request) {
+	userID := r.PathValue("id")
+	counts, err := h.store.KeyPackageInventory(r.Context(), userID)
+	if err != nil {
+		internalError(w, "key package inventory", err)
+		return
+	}
+
+	total := 0
+	suites := make([]keyPackageSuiteJSON, len(counts))
+	for i, c := range counts {
+		total += c.Available
+		suites[i] = keyPackageSuiteJSON{
+			CipherSuite:   c.CipherSuite,
+			Available:     c.Available,
+			HasLastResort: c.HasLastResort,
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"user_id":       userID,
+		"total":         total,
+		"cipher_suites": suites,
+	})
+}
+
+// ============================================================================
+// MLS Key Updates
+// ============================================================================
+
+type keyUpdateJSON struct {
+	UserID            string  `json:"user_id"`
+	JoinedAt          string  `json:"joined_at"`
+	LastUpdateAt      *string `json:"last_update_at"`
+	LastUpdateEpoch   *int64  `json:"last_update_epoch"`
+	UpdateRequestedAt *string `json:"update_requested_at"`
+	Stale             bool    `json:"stale"`
+}
+
+func (h *Handler) handleKeyUpdates(w http.ResponseWriter, r *http.Request) {
+	convID := r.PathValue("id")
+	updates, err := h.mlsService.MemberUpdates(r.Context(), convID)
+	if err != nil {
+		internalError(w, "key updates", err)
+		return
+	}
+
+	now := time.Now()
+	members := make([]keyUpdateJSON, len(updates))
+	for i, u := range updates {
+		members[i] = keyUpdateJSON{
+			UserID:            u.UserID,
+			JoinedAt:          formatTime(u.JoinedAt),
+			LastUpdateAt:      formatOptionalTime(u.LastUpdateAt),
+			LastUpdateEpoch:   u.LastUpdateEpoch,
+			UpdateRequestedAt: formatOptionalTime(u.UpdateRequestedAt),
+			Stale:             h.mlsService.IsStale(u, now),
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"conversation_id":         convID,
+		"update_interval_seconds": int64(h.mlsService.UpdateInterval().Seconds()),
+		"members":                 members,
+	})
+}
+
+// ============================================================================
+// Backups
+// ============================================================================
+
+type backupFileJSON struct {
+	Name      string `json:"name"`
+	SizeBytes int64  `json:"size_bytes"`
+	CreatedAt string `json:"created_at"`
+}
+
+type backupScheduleJSON struct {
+	IntervalHours int `json:"interval_hours"`
+	Keep          int `json:"keep"`
+}
+
+// requireBackups wraps a handler so it only runs when backups are available.
+func (h *Handler) requireBackups(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if h.backups == nil {
+			writeError(w, http.StatusServiceUnavailable, 9003, "Backups are not available for this database")
+			return
+		}
+		next(w, r)
+	}
+}
+
+func (h *Handler) handleListBackups(w http.ResponseWriter, r *http.Request) {
+	files, err := h.backups.List()
+	if err != nil {
+		internalError(w, "list backups", err)
+		return
+	}
+
+	out := make([]backupFileJSON, len(files))
+	for i, f := range files {
+		out[i] = backupFileJSONFrom(f)
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"schedule": backupScheduleJSONFrom(h.backups.Schedule()),
+		"backups":  out,
+	})
+}
+
+func (h *Handler) handleCreateBackup(w http.ResponseWriter, r *http.Request) {
+	f, err := h.backups.BackupNow(r.Context())
+	if err != nil {
+		internalError(w, "create backup", err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, backupFileJSONFrom(*f))
+}
+
+func (h *Handler) handleSetBackupSchedule(w http.ResponseWriter, r *http.Request) {
+	var req backupScheduleJSON
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, 3001, "Malformed request body")
+		return
+	}
+	if req.IntervalHours < 0 || req.Keep < 0 {
+		writeError(w, http.StatusBadRequest, 3001, "interval_hours and keep must not be negative")
+		return
+	}
+
+	sched := backup.Schedule{
+		Interval: time.Duration(req.IntervalHours) * time.Hour,
+		Keep:     req.Keep,
+	}
+	h.backups.SetSchedule(sched)
+	writeJSON(w, http.StatusOK, backupScheduleJSONFrom(sched))
+}
+
+func backupFileJSONFrom(f backup.File) backupFileJSON {
+	return backupFileJSON{
+		Name:      f.Name,
+		SizeBytes: f.Size,
+		CreatedAt: formatTime(f.CreatedAt.Unix()),
+	}
+}
+
+func backupScheduleJSONFrom(s backup.Schedule) backupScheduleJSON {
+	return backupScheduleJSON{
+		IntervalHours: int(s.Interval / time.Hour),
+		Keep:          s.Keep,
+	}
+}
+
+// ============================================================================
+// Helpers
+// ============================================================================
+
+type pageJSON struct {
+	Data       any            `json:"data"`
+	Pagination paginationJSON `json:"pagination"`
+}
+
+type paginationJSON struct {
+	Offset int `json:"offset"`
+	Limit  int `json:"limit"`
+	Total  int `json:"total"`
+}
+
+type errorJSON struct {
+	Error struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// sessionToken extracts the admin session token from the request cookie or
+// Authorization header.
+func sessionToken(r *http.Request) string {
+	if c, err := r.Cookie(sessionCookie); err == nil && c.Value != "" {
+		return c.Value
+	}
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return strings.TrimSpace(token)
+	}
+	return ""
+}
+
+// pagination parses offset/limit query parameters, applying defaults and bounds.
+func pagination(r *http.Request) (offset, limit int) {
+	offset, _ = strconv.Atoi(r.URL.Query().Get("offset"))
+	if offset < 0 {
+		offset = 0
+	}
+	limit, _ = strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit <= 0 {
+		limit = defaultLimit
+	}
+	if limit > maxLimit {
+		limit = maxLimit
+	}
+	return offset, limit
+}
+
+// formatTime renders a Unix timestamp as ISO 8601 in UTC.
+func formatTime(unix int64) string {
+	return time.Unix(unix, 0).UTC().Format(time.RFC3339)
+}
+
+// formatOptionalTime renders a nullable Unix timestamp, keeping nil as nil.
+func formatOptionalTime(unix *int64) *string {
+	if unix == nil {
+		return nil
+	}
+	t := formatTime(*unix)
+	return &t
+}
+
+func internalError(w http.ResponseWriter, op string, err error) {
+	log.Printf("admin: %s: %v", op, err)
+	writeError(w, http.StatusInternalServerError, 9001, "Internal error")
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("admin: encode response: %v", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status, code int, message string) {
+	var e errorJSON
+	e.Error.Code = code
+	e.Error.Message = message
+	writeJSON(w, status, e)
 }
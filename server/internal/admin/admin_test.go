@@ -0,0 +1,430 @@
+package admin
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sovereign-im/sovereign/server/internal/auth"
+	"github.com/sovereign-im/sovereign/server/internal/backup"
+	"github.com/sovereign-im/sovereign/server/internal/mls"
+	"github.com/sovereign-im/sovereign/server/internal/store"
+)
+
+// newTestHandler creates a Handler backed by an in-memory store with an admin
+// ("admin-token") and a regular member ("member-token").
+func newTestHandler(t *testing.T) (*Handler, *store.Store) {
+	t.Helper()
+	s, err := store.New(":memory:")
+	if err != nil {
+		t.Fatalf("store.New(:memory:) error: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+
+	authSvc, err := auth.NewService(s, "Test Server", "localhost", []string{"http://localhost:8080"}, auth.Policy{}, auth.SessionLifetime{})
+	if err != nil {
+		t.Fatalf("NewService error: %v", err)
+	}
+
+	seedUser(t, s, "admin-id", "admin", "admin", "admin-token")
+	seedUser(t, s, "member-id", "member", "member", "member-token")
+
+	mlsSvc, err := mls.NewService(s, 0, time.Hour)
+	if err != nil {
+		t.Fatalf("mls.NewService error: %v", err)
+	}
+
+	backups := backup.NewScheduler(s.Snapshot, t.TempDir(), backup.Options{}, backup.Schedule{Keep: 7})
+	return NewHandler(s, authSvc, mlsSvc, backups), s
+}
+
+func seedUser(t *testing.T, s *store.Store, userID, username, role, token string) {
+	t.Helper()
+	ctx := context.Background()
+	now := time.Now().Unix()
+
+	u := &store.User{
+		ID:          userID,
+		Username:    username,
+		DisplayName: username,
+		Role:        role,
+		Enabled:     true,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := s.CreateUser(ctx, u); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+
+	h := sha256.Sum256([]byte(token))
+	sess := &store.Session{
+		ID:         "sess-" + userID,
+		UserID:     userID,
+		TokenHash:  h[:],
+		CreatedAt:  now,
+		ExpiresAt:  now + 3600,
+		LastSeenAt: now,
+	}
+	if err := s.CreateSession(ctx, sess); err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+}
+
+func TestRequireAdmin(t *testing.T) {
+	tests := []struct {
+		name       string
+		setup      func(r *http.Request)
+		wantStatus int
+		wantCode   int
+	}{
+		{
+			name:       "no token",
+			setup:      func(*http.Request) {},
+			wantStatus: http.StatusUnauthorized,
+			wantCode:   1001,
+		},
+		{
+			name:       "invalid token",
+			setup:      func(r *http.Request) { r.Header.Set("Authorization", "Bearer bogus") },
+			wantStatus: http.StatusUnauthorized,
+			wantCode:   1001,
+		},
+		{
+			name:       "member is forbidden",
+			setup:      func(r *http.Request) { r.Header.Set("Authorization", "Bearer member-token") },
+			wantStatus: http.StatusForbidden,
+			wantCode:   2003,
+		},
+		{
+			name:       "admin via bearer token",
+			setup:      func(r *http.Request) { r.Header.Set("Authorization", "Bearer admin-token") },
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "admin via cookie",
+			setup: func(r *http.Request) {
+				r.AddCookie(&http.Cookie{Name: sessionCookie, Value: "admin-token"})
+			},
+			wantStatus: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, _ := newTestHandler(t)
+
+			req := httptest.NewRequest(http.MethodGet, "/admin/api/audit-events", nil)
+			tt.setup(req)
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if tt.wantCode == 0 {
+				return
+			}
+			var body errorJSON
+			if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+				t.Fatalf("decode error body: %v", err)
+			}
+			if body.Error.Code != tt.wantCode {
+				t.Errorf("error code = %d, want %d", body.Error.Code, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestListAuditEvents(t *testing.T) {
+	h, s := newTestHandler(t)
+	ctx := context.Background()
+
+	for i, eventType := range []string{"auth.recovery_code_redeemed", "auth.account_recovered"} {
+		e := &store.AuditEvent{EventType: eventType, UserID: "member-id", CreatedAt: int64(1700000000 + i)}
+		if err := s.InsertAuditEvent(ctx, e); err != nil {
+			t.Fatalf("InsertAuditEvent: %v", err)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/api/audit-events?limit=1", nil)
+	req.Header.Set("Authorization", "Bearer admin-token")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+
+	var body struct {
+		Data       []auditEventJSON `json:"data"`
+		Pagination paginationJSON   `json:"pagination"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("decode body: %v", err)
+	}
+	if body.Pagination.Total != 2 || body.Pagination.Limit != 1 {
+		t.Errorf("pagination = %+v, want total 2, limit 1", body.Pagination)
+	}
+	if len(body.Data) != 1 {
+		t.Fatalf("len(data) = %d, want 1", len(body.Data))
+	}
+	got := body.Data[0]
+	if got.EventType != "auth.account_recovered" {
+		t.Errorf("event_type = %q, want newest event", got.EventType)
+	}
+	if got.CreatedAt != "2023-11-14T22:13:21Z" {
+		t.Errorf("created_at = %q, want ISO 8601", got.CreatedAt)
+	}
+}
+
+func TestPagination(t *testing.T) {
+	tests := []struct {
+		query      string
+		wantOffset int
+		wantLimit  int
+	}{
+		{query: "", wantOffset: 0, wantLimit: defaultLimit},
+		{query: "?offset=5&limit=10", wantOffset: 5, wantLimit: 10},
+		{query: "?offset=-1&limit=0", wantOffset: 0, wantLimit: defaultLimit},
+		{query: "?limit=1000", wantOffset: 0, wantLimit: maxLimit},
+		{query: "?offset=abc&limit=xyz", wantOffset: 0, wantLimit: defaultLimit},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/admin/api/audit-events"+tt.query, nil)
+			offset, limit := pagination(r)
+			if offset != tt.wantOffset || limit != tt.wantLimit {
+				t.Errorf("pagination = (%d, %d), want (%d, %d)", offset, limit, tt.wantOffset, tt.wantLimit)
+			}
+		})
+	}
+}
+
+func TestListAuditEventsFilter(t *testing.T) {
+	h, s := newTestHandler(t)
+	ctx := context.Background()
+
+	for _, eventType := range []string{auth.AuditLoginFailed, auth.AuditLoginFailed, auth.AuditLoginThrottled} {
+		if err := s.InsertAuditEvent(ctx, &store.AuditEvent{EventType: eventType, CreatedAt: time.Now().Unix()}); err != nil {
+			t.Fatalf("InsertAuditEvent: %v", err)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/api/audit-events?event_type="+auth.AuditLoginFailed, nil)
+	req.Header.Set("Authorization", "Bearer admin-token")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	var body struct {
+		Data       []auditEventJSON `json:"data"`
+		Pagination paginationJSON   `json:"pagination"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("decode body: %v", err)
+	}
+	if body.Pagination.Total != 2 || len(body.Data) != 2 {
+		t.Fatalf("got %d events (total %d), want 2", len(body.Data), body.Pagination.Total)
+	}
+	for _, e := range body.Data {
+		if e.EventType != auth.AuditLoginFailed {
+			t.Errorf("event_type = %q, want %q", e.EventType, auth.AuditLoginFailed)
+		}
+	}
+}
+
+func TestListLockouts(t *testing.T) {
+	h, _ := newTestHandler(t)
+	ctx := context.Background()
+	client := auth.ClientInfo{IP: "192.0.2.1"}
+
+	for i := 0; i <= auth.LoginFreeAttempts; i++ {
+		challenge, err := h.authService.BeginLogin(ctx, client, "mallory")
+		if err != nil {
+			t.Fatalf("BeginLogin: %v", err)
+		}
+		_, _ = h.authService.FinishLogin(ctx, client, challenge.ChallengeID, &auth.AssertionResponse{})
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/api/auth/lockouts", nil)
+	req.Header.Set("Authorization", "Bearer admin-token")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	var body struct {
+		Data []lockoutJSON `json:"data"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("decode body: %v", err)
+	}
+
+	got := make(map[string]string)
+	for _, l := range body.Data {
+		got[l.Kind] = l.Value
+		if l.Failures != auth.LoginFreeAttempts+1 {
+			t.Errorf("%s failures = %d, want %d", l.Kind, l.Failures, auth.LoginFreeAttempts+1)
+		}
+	}
+	if got["ip"] != "192.0.2.1" || got["user"] != "mallory" {
+		t.Errorf("lockouts = %+v, want ip 192.0.2.1 and user mallory", body.Data)
+	}
+}
+
+func TestKeyPackageInventory(t *testing.T) {
+	h, s := newTestHandler(t)
+	ctx := context.Background()
+	expiresAt := time.Now().Add(time.Hour).Unix()
+
+	kps := []*store.KeyPackage{
+		{KeyPackageData: []byte("kp-1"), ExpiresAt: expiresAt, CipherSuite: 1},
+		{KeyPackageData: []byte("kp-2"), ExpiresAt: expiresAt, CipherSuite: 1},
+		{KeyPackageData: []byte("kp-3"), ExpiresAt: expiresAt, CipherSuite: 2, LastResort: true},
+	}
+	if err := s.StoreKeyPackages(ctx, "member-id", kps); err != nil {
+		t.Fatalf("StoreKeyPackages: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/api/users/member-id/key-packages", nil)
+	req.Header.Set("Authorization", "Bearer admin-token")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	var body struct {
+		UserID       string                `json:"user_id"`
+		Total        int                   `json:"total"`
+		CipherSuites []keyPackageSuiteJSON `json:"cipher_suites"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("decode body: %v", err)
+	}
+
+	want := []keyPackageSuiteJSON{
+		{CipherSuite: 1, Available: 2},
+		{CipherSuite: 2, Available: 0, HasLastResort: true},
+	}
+	if body.UserID != "member-id" || body.Total != 2 || len(body.CipherSuites) != len(want) {
+		t.Fatalf("body = %+v, want member-id with 2 key packages in 2 suites", body)
+	}
+	for i := range want {
+		if body.CipherSuites[i] != want[i] {
+			t.Errorf("cipher_suites[%d] = %+v, want %+v", i, body.CipherSuites[i], want[i])
+		}
+	}
+}
+
+func TestKeyUpdates(t *testing.T) {
+	h, s := newTestHandler(t)
+	ctx := context.Background()
+
+	conv, err := s.CreateConversation(ctx, "Group", "admin-id", []string{"member-id"})
+	if err != nil {
+		t.Fatalf("CreateConversation: %v", err)
+	}
+	epoch := int64(4)
+	if err := s.RecordMemberUpdate(ctx, conv.ID, "admin-id", &epoch); err != nil {
+		t.Fatalf("RecordMemberUpdate: %v", err)
+	}
+	// Ask the member for an update as though their membership were stale.
+	if _, err := s.ClaimStaleMembers(ctx, "member-id", time.Now().Unix()+1); err != nil {
+		t.Fatalf("ClaimStaleMembers: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/api/conversations/"+conv.ID+"/key-updates", nil)
+	req.Header.Set("Authorization", "Bearer admin-token")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	var body struct {
+		ConversationID        string          `json:"conversation_id"`
+		UpdateIntervalSeconds int64           `json:"update_interval_seconds"`
+		Members               []keyUpdateJSON `json:"members"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("decode body: %v", err)
+	}
+	if body.ConversationID != conv.ID || body.UpdateIntervalSeconds != 3600 || len(body.Members) != 2 {
+		t.Fatalf("body = %+v, want %s with a 3600s interval and 2 members", body, conv.ID)
+	}
+
+	got := make(map[string]keyUpdateJSON)
+	for _, m := range body.Members {
+		got[m.UserID] = m
+	}
+	if m := got["admin-id"]; m.LastUpdateAt == nil || m.LastUpdateEpoch == nil || *m.LastUpdateEpoch != 4 || m.Stale {
+		t.Errorf("admin-id = %+v, want updated at epoch 4 and not stale", m)
+	}
+	if m := got["member-id"]; m.LastUpdateAt != nil || m.UpdateRequestedAt == nil {
+		t.Errorf("member-id = %+v, want no update and an update request", m)
+	}
+}
+
+func TestBackups(t *testing.T) {
+	h, _ := newTestHandler(t)
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer admin-token")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do(http.MethodPost, "/admin/api/backups", "")
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("POST status = %d, want 201: %s", rec.Code, rec.Body)
+	}
+	var created backupFileJSON
+	if err := json.NewDecoder(rec.Body).Decode(&created); err != nil {
+		t.Fatalf("decode body: %v", err)
+	}
+	if created.Name == "" || created.SizeBytes == 0 {
+		t.Errorf("created = %+v, want a named, non-empty backup", created)
+	}
+
+	rec = do(http.MethodPut, "/admin/api/backups/schedule", `{"interval_hours": 24, "keep": 3}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("PUT status = %d, want 200: %s", rec.Code, rec.Body)
+	}
+
+	rec = do(http.MethodGet, "/admin/api/backups", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET status = %d, want 200", rec.Code)
+	}
+	var list struct {
+		Schedule backupScheduleJSON `json:"schedule"`
+		Backups  []backupFileJSON   `json:"backups"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&list); err != nil {
+		t.Fatalf("decode body: %v", err)
+	}
+	if list.Schedule != (backupScheduleJSON{IntervalHours: 24, Keep: 3}) {
+		t.Errorf("schedule = %+v, want 24h keeping 3", list.Schedule)
+	}
+	if len(list.Backups) != 1 || list.Backups[0].Name != created.Name {
+		t.Errorf("backups = %+v, want [%s]", list.Backups, created.Name)
+	}
+
+	for _, body := range []string{`not json`, `{"interval_hours": -1, "keep": 3}`} {
+		if rec := do(http.MethodPut, "/admin/api/backups/schedule", body); rec.Code != http.StatusBadRequest {
+			t.Errorf("PUT %s status = %d, want 400", body, rec.Code)
+		}
+	}
+
+	h.backups = nil
+	if rec := do(http.MethodGet, "/admin/api/backups", ""); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("GET without backups status = %d, want 503", rec.Code)
+	}
+}
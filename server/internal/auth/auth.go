@@ -5,11 +5,14 @@ import (
 	"context"
 	"crypto/rand"
 	"crypto/sha256"
+	"encoding/base32"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/go-webauthn/webauthn/webauthn"
@@ -20,19 +23,28 @@ import (
 
 // Sentinel errors for authentication operations.
 var (
-	ErrChallengeExpired  = errors.New("challenge expired")
-	ErrChallengeNotFound = errors.New("challenge not found")
-	ErrUserNotFound      = errors.New("user not found")
-	ErrAccountDisabled   = errors.New("account disabled")
-	ErrSessionExpired    = errors.New("session expired")
-	ErrCloneDetected     = errors.New("sign count did not increase: possible credential clone")
-	ErrInvalidCredential = errors.New("invalid credential")
-	ErrRegistrationFailed = errors.New("registration failed")
+	ErrChallengeExpired    = errors.New("challenge expired")
+	ErrChallengeNotFound   = errors.New("challenge not found")
+	ErrUserNotFound        = errors.New("user not found")
+	ErrAccountDisabled     = errors.New("account disabled")
+	ErrSessionExpired      = errors.New("session expired")
+	ErrCloneDetected       = errors.New("sign count did not increase: possible credential clone")
+	ErrInvalidCredential   = errors.New("invalid credential")
+	ErrRegistrationFailed  = errors.New("registration failed")
+	ErrInvalidRecoveryCode = errors.New("invalid recovery code")
 )
 
+// errUsernameUnavailable is returned for both taken and reserved usernames,
+// so that registration does not reveal which names have accounts.
+var errUsernameUnavailable = fmt.Errorf("username unavailable: %w", ErrRegistrationFailed)
+
 const (
-	// DefaultSessionDuration is the default session lifetime (30 days).
-	DefaultSessionDuration = 30 * 24 * time.Hour
+	// DefaultSessionMaxAge is the default absolute session lifetime (90 days).
+	DefaultSessionMaxAge = 90 * 24 * time.Hour
+
+	// DefaultSessionIdleTimeout is the default lifetime of an unused
+	// session (30 days).
+	DefaultSessionIdleTimeout = 30 * 24 * time.Hour
 
 	// RegistrationChallengeTTL is how long a registration challenge is valid.
 	RegistrationChallengeTTL = 60 * time.Second
@@ -42,16 +54,47 @@ const (
 
 	// SessionTokenBytes is the number of random bytes in a session token.
 	SessionTokenBytes = 32
+
+	// RecoveryCodeCount is the number of recovery codes issued per user.
+	RecoveryCodeCount = 10
+
+	// RecoveryCodeBytes is the number of random bytes in a recovery code.
+	RecoveryCodeBytes = 10
+)
+
+// Audit event types recorded by the auth service.
+const (
+	AuditRecoveryCodeRedeemed = "auth.recovery_code_redeemed"
+	AuditAccountRecovered     = "auth.account_recovered"
+	AuditLoginFailed          = "auth.login_failed"
+	AuditLoginThrottled       = "auth.login_throttled"
+	AuditRegistrationFailed   = "auth.registration_failed"
+	AuditChallengeLimit       = "auth.challenge_limit"
+)
+
+// Challenge types stored in the challenge table.
+const (
+	challengeTypeRegistration = "registration"
+	challengeTypeLogin        = "login"
+	challengeTypeRecovery     = "recovery"
 )
 
 // Service handles WebAuthn/passkey authentication.
 type Service struct {
-	store    *store.Store
+	store    store.Backend
 	webauthn *webauthn.WebAuthn
+	policy   *webauthnPolicy
+	throttle *throttle
+	sessions SessionLifetime
+
+	// decoyKey derives stable fake credentials for unknown usernames so
+	// that BeginLogin does not reveal which accounts exist.
+	decoyKey []byte
 }
 
-// NewService creates a new auth service with the given store and WebAuthn config.
-func NewService(s *store.Store, rpDisplayName, rpID string, rpOrigins []string) (*Service, error) {
+// NewService creates a new auth service with the given store, WebAuthn config,
+// ceremony policy and session lifetime.
+func NewService(s store.Backend, rpDisplayName, rpID string, rpOrigins []string, policy Policy, sessions SessionLifetime) (*Service, error) {
 	wconfig := &webauthn.Config{
 		RPDisplayName: rpDisplayName,
 		RPID:          rpID,
@@ -63,9 +106,25 @@ func NewService(s *store.Store, rpDisplayName, rpID string, rpOrigins []string)
 		return nil, fmt.Errorf("create webauthn: %w", err)
 	}
 
+	wp, err := policy.compile()
+	if err != nil {
+		return nil, fmt.Errorf("webauthn policy: %w", err)
+	}
+
+	// The decoy key is kept in the store so that a decoy's credentials do
+	// not change when the server restarts.
+	decoyKey, err := s.ServerSecret(context.Background(), "auth.decoy_key", 32)
+	if err != nil {
+		return nil, fmt.Errorf("load decoy key: %w", err)
+	}
+
 	return &Service{
 		store:    s,
 		webauthn: w,
+		policy:   wp,
+		throttle: newThrottle(),
+		sessions: sessions.withDefaults(),
+		decoyKey: decoyKey,
 	}, nil
 }
 
@@ -99,10 +158,14 @@ type AssertionResponse struct {
 
 // SessionResult is returned after successful authentication.
 type SessionResult struct {
-	Token       string // raw session token (base64url encoded)
-	UserID      string
-	Username    string
-	DisplayName string
+	Token            string // raw session token (base64url encoded)
+	SessionID        string
+	SessionExpiresAt time.Time
+	UserID           string
+	Username         string
+	DisplayName      string
+	RecoveryCodes    []string // raw recovery codes, only set when newly issued
+	Recovered        bool     // true if this session came from account recovery
 }
 
 // SessionInfo is returned by ValidateSession.
@@ -115,19 +178,48 @@ type SessionInfo struct {
 
 // challengePayload is stored in the challenge table's challenge_data column.
 type challengePayload struct {
-	SessionData webauthn.SessionData `json:"session_data"`
-	DisplayName string               `json:"display_name,omitempty"`
+	SessionData   webauthn.SessionData `json:"session_data"`
+	DisplayName   string               `json:"display_name,omitempty"`
+	RecoveryCodes bool                 `json:"recovery_codes,omitempty"`
 }
 
 // --- Registration Flow ---
 
 // BeginRegistration starts a WebAuthn registration ceremony.
 // Returns credential creation options and a challenge ID for correlation.
-func (svc *Service) BeginRegistration(ctx context.Context, username, displayName string) (*RegistrationChallenge, error) {
-	// Check if username is already taken
-	_, err := svc.store.GetUserByUsername(ctx, username)
+// If recoveryCodes is true, FinishRegistration issues a set of recovery codes.
+//
+// A taken or reserved username fails with the same ErrRegistrationFailed
+// and counts against the client IP. Returns a *ThrottleError if the client
+// is locked out or has too many outstanding challenges.
+func (svc *Service) BeginRegistration(ctx context.Context, client ClientInfo, username, displayName string, recoveryCodes bool) (*RegistrationChallenge, error) {
+	if err := svc.checkThrottle(client, ""); err != nil {
+		return nil, err
+	}
+	username, folded, err := NormalizeUsername(username)
+	if errors.Is(err, errReservedUsername) {
+		svc.recordFailureEvent(ctx, AuditRegistrationFailed, client, "", "username unavailable")
+		return nil, errUsernameUnavailable
+	}
+	if err != nil {
+		return nil, err
+	}
+	displayName, err = NormalizeDisplayName(displayName)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := svc.reserveChallenge(ctx, client); err != nil {
+		return nil, err
+	}
+
+	// Check if username (or a name that folds to the same value) is taken.
+	// Taken and reserved names get the same error, and count as failures
+	// against the client, so that probing for accounts is throttled.
+	_, err = svc.store.GetUserByFoldedUsername(ctx, folded)
 	if err == nil {
-		return nil, fmt.Errorf("username %q already taken: %w", username, ErrRegistrationFailed)
+		svc.recordFailureEvent(ctx, AuditRegistrationFailed, client, "", "username unavailable")
+		return nil, errUsernameUnavailable
 	}
 	if !errors.Is(err, store.ErrNotFound) {
 		return nil, fmt.Errorf("check username: %w", err)
@@ -142,15 +234,16 @@ func (svc *Service) BeginRegistration(ctx context.Context, username, displayName
 	}
 
 	// Generate credential creation options
-	options, sessionData, err := svc.webauthn.BeginRegistration(user)
+	options, sessionData, err := svc.webauthn.BeginRegistration(user, svc.policy.registrationOptions()...)
 	if err != nil {
 		return nil, fmt.Errorf("begin registration: %w", err)
 	}
 
 	// Serialize session data for storage
 	payload := challengePayload{
-		SessionData: *sessionData,
-		DisplayName: displayName,
+		SessionData:   *sessionData,
+		DisplayName:   displayName,
+		RecoveryCodes: recoveryCodes,
 	}
 	payloadData, err := json.Marshal(payload)
 	if err != nil {
@@ -164,7 +257,9 @@ func (svc *Service) BeginRegistration(ctx context.Context, username, displayName
 		ChallengeID:   challengeID,
 		ChallengeData: payloadData,
 		Username:      username,
-		ChallengeType: "registration",
+		ChallengeType: challengeTypeRegistration,
+		ClientIP:      client.IP,
+		ConnID:        client.ConnID,
 		CreatedAt:     now.Unix(),
 		ExpiresAt:     now.Add(RegistrationChallengeTTL).Unix(),
 	}
@@ -233,21 +328,32 @@ func (svc *Service) FinishRegistration(ctx context.Context, challengeID string,
 	if err != nil {
 		return nil, fmt.Errorf("finish registration: %w", err)
 	}
+	if err := svc.policy.checkCredential(credential); err != nil {
+		return nil, err
+	}
+
+	if challenge.ChallengeType == challengeTypeRecovery {
+		return svc.finishRecovery(ctx, &payload, credential)
+	}
 
 	// Persist user, credential, and session
 	userID := string(payload.SessionData.UserID)
 	now := time.Now().Unix()
 
 	storeUser := &store.User{
-		ID:          userID,
-		Username:    challenge.Username,
-		DisplayName: payload.DisplayName,
-		Role:        "member",
-		Enabled:     true,
-		CreatedAt:   now,
-		UpdatedAt:   now,
+		ID:             userID,
+		Username:       challenge.Username,
+		UsernameFolded: FoldUsername(challenge.Username),
+		DisplayName:    payload.DisplayName,
+		Role:           "member",
+		Enabled:        true,
+		CreatedAt:      now,
+		UpdatedAt:      now,
 	}
 	if err := svc.store.CreateUser(ctx, storeUser); err != nil {
+		if errors.Is(err, store.ErrConflict) {
+			return nil, errUsernameUnavailable
+		}
 		return nil, fmt.Errorf("create user: %w", err)
 	}
 
@@ -259,69 +365,77 @@ func (svc *Service) FinishRegistration(ctx context.Context, challengeID string,
 		PublicKey:    credential.PublicKey,
 		SignCount:    int64(credential.Authenticator.SignCount),
 		CreatedAt:    now,
+
+		AAGUID:            credential.Authenticator.AAGUID,
+		AttestationFormat: credential.AttestationType,
 	}
 	if err := svc.store.CreateCredential(ctx, storeCred); err != nil {
 		return nil, fmt.Errorf("create credential: %w", err)
 	}
 
-	token, tokenHash, err := generateSession()
+	sess, token, err := svc.newSession(ctx, userID, credID)
 	if err != nil {
-		return nil, fmt.Errorf("generate session: %w", err)
+		return nil, err
 	}
 
-	sessID := uuid.New().String()
-	storeSession := &store.Session{
-		ID:           sessID,
-		UserID:       userID,
-		CredentialID: credID,
-		TokenHash:    tokenHash,
-		CreatedAt:    now,
-		ExpiresAt:    now + int64(DefaultSessionDuration.Seconds()),
-		LastSeenAt:   now,
+	result := &SessionResult{
+		Token:            token,
+		SessionID:        sess.ID,
+		SessionExpiresAt: time.Unix(sess.ExpiresAt, 0),
+		UserID:           userID,
+		Username:         challenge.Username,
+		DisplayName:      payload.DisplayName,
 	}
-	if err := svc.store.CreateSession(ctx, storeSession); err != nil {
-		return nil, fmt.Errorf("create session: %w", err)
+	if payload.RecoveryCodes {
+		codes, err := svc.GenerateRecoveryCodes(ctx, userID)
+		if err != nil {
+			return nil, fmt.Errorf("generate recovery codes: %w", err)
+		}
+		result.RecoveryCodes = codes
 	}
-
-	return &SessionResult{
-		Token:       token,
-		UserID:      userID,
-		Username:    challenge.Username,
-		DisplayName: payload.DisplayName,
-	}, nil
+	return result, nil
 }
 
 // --- Login Flow ---
 
 // BeginLogin starts a WebAuthn login ceremony for the given username.
 // Returns credential request options and a challenge ID.
-func (svc *Service) BeginLogin(ctx context.Context, username string) (*LoginChallenge, error) {
-	// Look up user
-	user, err := svc.store.GetUserByUsername(ctx, username)
-	if err != nil {
-		if errors.Is(err, store.ErrNotFound) {
-			return nil, ErrUserNotFound
-		}
-		return nil, fmt.Errorf("get user: %w", err)
+//
+// Unknown usernames, disabled users and users without credentials receive a
+// decoy challenge that is indistinguishable from a real one; FinishLogin
+// always rejects it.
+// Returns a *ThrottleError if the client or username is locked out or has
+// too many outstanding challenges.
+func (svc *Service) BeginLogin(ctx context.Context, client ClientInfo, username string) (*LoginChallenge, error) {
+	if err := svc.checkThrottle(client, username); err != nil {
+		return nil, err
 	}
-
-	if !user.Enabled {
-		return nil, ErrAccountDisabled
+	if err := svc.reserveChallenge(ctx, client); err != nil {
+		return nil, err
 	}
 
-	// Get user's credentials
-	creds, err := svc.store.GetCredentialsByUserID(ctx, user.ID)
-	if err != nil {
-		return nil, fmt.Errorf("get credentials: %w", err)
+	// Look up user
+	var waUser *webauthnUser
+	user, err := svc.findUser(ctx, username)
+	switch {
+	case err == nil && user.Enabled:
+		// Get user's credentials
+		creds, err := svc.store.GetCredentialsByUserID(ctx, user.ID)
+		if err != nil {
+			return nil, fmt.Errorf("get credentials: %w", err)
+		}
+		if len(creds) > 0 {
+			waUser = newWebAuthnUser(user, creds)
+		}
+	case err != nil && !errors.Is(err, store.ErrNotFound):
+		return nil, fmt.Errorf("get user: %w", err)
 	}
-	if len(creds) == 0 {
-		return nil, fmt.Errorf("user %q has no credentials: %w", username, ErrInvalidCredential)
+	if waUser == nil {
+		waUser = newDecoyWebAuthnUser(svc.decoyKey, username)
 	}
 
-	waUser := newWebAuthnUser(user, creds)
-
 	// Generate credential request options
-	options, sessionData, err := svc.webauthn.BeginLogin(waUser)
+	options, sessionData, err := svc.webauthn.BeginLogin(waUser, svc.policy.loginOptions()...)
 	if err != nil {
 		return nil, fmt.Errorf("begin login: %w", err)
 	}
@@ -338,7 +452,9 @@ func (svc *Service) BeginLogin(ctx context.Context, username string) (*LoginChal
 		ChallengeID:   challengeID,
 		ChallengeData: payloadData,
 		Username:      username,
-		ChallengeType: "login",
+		ChallengeType: challengeTypeLogin,
+		ClientIP:      client.IP,
+		ConnID:        client.ConnID,
 		CreatedAt:     now.Unix(),
 		ExpiresAt:     now.Add(LoginChallengeTTL).Unix(),
 	}
@@ -359,11 +475,13 @@ func (svc *Service) BeginLogin(ctx context.Context, username string) (*LoginChal
 
 // FinishLogin completes the WebAuthn login ceremony.
 // Validates the assertion, updates sign count, and creates a session.
-func (svc *Service) FinishLogin(ctx context.Context, challengeID string, resp *AssertionResponse) (*SessionResult, error) {
+// Failed assertions count towards the client's and username's throttle.
+func (svc *Service) FinishLogin(ctx context.Context, client ClientInfo, challengeID string, resp *AssertionResponse) (*SessionResult, error) {
 	// Retrieve and validate challenge
 	challenge, err := svc.store.GetChallenge(ctx, challengeID)
 	if err != nil {
 		if errors.Is(err, store.ErrNotFound) {
+			svc.recordFailure(ctx, client, "", "challenge not found")
 			return nil, ErrChallengeNotFound
 		}
 		return nil, fmt.Errorf("get challenge: %w", err)
@@ -382,23 +500,25 @@ func (svc *Service) FinishLogin(ctx context.Context, challengeID string, resp *A
 		return nil, fmt.Errorf("unmarshal challenge payload: %w", err)
 	}
 
-	// Look up user and credentials
-	user, err := svc.store.GetUserByUsername(ctx, challenge.Username)
+	// Look up user and credentials. A decoy challenge for an unknown user
+	// fails the same way as a bad assertion.
+	user, err := svc.findUser(ctx, challenge.Username)
 	if err != nil {
 		if errors.Is(err, store.ErrNotFound) {
-			return nil, ErrUserNotFound
+			svc.recordFailure(ctx, client, challenge.Username, "unknown user")
+			return nil, ErrInvalidCredential
 		}
 		return nil, fmt.Errorf("get user: %w", err)
 	}
 
-	if !user.Enabled {
-		return nil, ErrAccountDisabled
-	}
-
 	creds, err := svc.store.GetCredentialsByUserID(ctx, user.ID)
 	if err != nil {
 		return nil, fmt.Errorf("get credentials: %w", err)
 	}
+	if len(creds) == 0 {
+		svc.recordFailure(ctx, client, challenge.Username, "no credentials")
+		return nil, ErrInvalidCredential
+	}
 
 	waUser := newWebAuthnUser(user, creds)
 
@@ -416,13 +536,22 @@ func (svc *Service) FinishLogin(ctx context.Context, challengeID string, resp *A
 	// Validate with the WebAuthn library
 	credential, err := svc.webauthn.FinishLogin(waUser, payload.SessionData, httpReq)
 	if err != nil {
-		return nil, fmt.Errorf("finish login: %w", err)
+		svc.recordFailure(ctx, client, challenge.Username, "assertion rejected")
+		return nil, fmt.Errorf("finish login: %w: %w", ErrInvalidCredential, err)
 	}
 
 	// Check for credential cloning (sign count didn't increase)
 	if credential.Authenticator.CloneWarning {
+		svc.recordFailure(ctx, client, challenge.Username, "clone detected")
 		return nil, ErrCloneDetected
 	}
+	svc.throttle.reset(userKey(challenge.Username))
+
+	// Only someone who can sign in learns that the account is disabled.
+	// It can only get this far if it was disabled after BeginLogin.
+	if !user.Enabled {
+		return nil, ErrAccountDisabled
+	}
 
 	// Find the matching store credential and update sign count
 	for _, c := range creds {
@@ -435,63 +564,195 @@ func (svc *Service) FinishLogin(ctx context.Context, challengeID string, resp *A
 	}
 
 	// Generate session
-	token, tokenHash, err := generateSession()
+	sess, token, err := svc.newSession(ctx, user.ID, "")
 	if err != nil {
-		return nil, fmt.Errorf("generate session: %w", err)
-	}
-
-	now := time.Now().Unix()
-	sessID := uuid.New().String()
-	storeSession := &store.Session{
-		ID:         sessID,
-		UserID:     user.ID,
-		TokenHash:  tokenHash,
-		CreatedAt:  now,
-		ExpiresAt:  now + int64(DefaultSessionDuration.Seconds()),
-		LastSeenAt: now,
-	}
-	if err := svc.store.CreateSession(ctx, storeSession); err != nil {
-		return nil, fmt.Errorf("create session: %w", err)
+		return nil, err
 	}
 
 	return &SessionResult{
-		Token:       token,
-		UserID:      user.ID,
-		Username:    user.Username,
-		DisplayName: user.DisplayName,
+		Token:            token,
+		SessionID:        sess.ID,
+		SessionExpiresAt: time.Unix(sess.ExpiresAt, 0),
+		UserID:           user.ID,
+		Username:         user.Username,
+		DisplayName:      user.DisplayName,
 	}, nil
 }
 
-// --- Session Management ---
+// --- Recovery Flow ---
+
+// GenerateRecoveryCodes issues a fresh set of recovery codes for a user,
+// invalidating any previously issued codes. The raw codes are returned once
+// and only their hashes are stored.
+func (svc *Service) GenerateRecoveryCodes(ctx context.Context, userID string) ([]string, error) {
+	codes := make([]string, RecoveryCodeCount)
+	hashes := make([][]byte, RecoveryCodeCount)
+	for i := range codes {
+		code, err := generateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes[i] = code
+		hashes[i] = hashSessionToken(normalizeRecoveryCode(code))
+	}
 
-// ValidateSession validates a raw session token. Returns user info if valid.
-// Updates the session's last_seen_at timestamp.
-func (svc *Service) ValidateSession(ctx context.Context, token string) (*SessionInfo, error) {
-	tokenHash := hashSessionToken(token)
+	if err := svc.store.ReplaceRecoveryCodes(ctx, userID, hashes); err != nil {
+		return nil, fmt.Errorf("store recovery codes: %w", err)
+	}
+	return codes, nil
+}
 
-	sess, err := svc.store.GetSessionByTokenHash(ctx, tokenHash)
+// BeginRecovery redeems a recovery code and starts a WebAuthn registration
+// ceremony for the existing user. The code is consumed even if the ceremony
+// is never completed, or the account turns out to be disabled.
+func (svc *Service) BeginRecovery(ctx context.Context, client ClientInfo, username, code string) (*RegistrationChallenge, error) {
+	if err := svc.checkThrottle(client, username); err != nil {
+		return nil, err
+	}
+	if err := svc.reserveChallenge(ctx, client); err != nil {
+		return nil, err
+	}
+
+	user, err := svc.findUser(ctx, username)
 	if err != nil {
 		if errors.Is(err, store.ErrNotFound) {
-			return nil, ErrInvalidCredential
+			svc.recordFailure(ctx, client, username, "invalid recovery code")
+			return nil, ErrInvalidRecoveryCode
 		}
-		return nil, fmt.Errorf("get session: %w", err)
+		return nil, fmt.Errorf("get user: %w", err)
 	}
 
-	if time.Now().Unix() > sess.ExpiresAt {
-		_ = svc.store.DeleteSession(ctx, sess.ID)
-		return nil, ErrSessionExpired
+	codeHash := hashSessionToken(normalizeRecoveryCode(code))
+	if err := svc.store.RedeemRecoveryCode(ctx, user.ID, codeHash); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			svc.recordFailure(ctx, client, username, "invalid recovery code")
+			return nil, ErrInvalidRecoveryCode
+		}
+		return nil, fmt.Errorf("redeem recovery code: %w", err)
+	}
+	svc.audit(ctx, AuditRecoveryCodeRedeemed, user.ID, "")
+
+	// A disabled account is only revealed to someone holding one of its
+	// codes.
+	if !user.Enabled {
+		return nil, ErrAccountDisabled
 	}
 
-	user, err := svc.store.GetUserByID(ctx, sess.UserID)
+	// Register a fresh passkey under the existing user ID.
+	waUser := &webauthnUser{
+		id:          []byte(user.ID),
+		name:        user.Username,
+		displayName: user.DisplayName,
+	}
+	options, sessionData, err := svc.webauthn.BeginRegistration(waUser, svc.policy.registrationOptions()...)
 	if err != nil {
-		return nil, fmt.Errorf("get user: %w", err)
+		return nil, fmt.Errorf("begin registration: %w", err)
+	}
+
+	payloadData, err := json.Marshal(challengePayload{
+		SessionData:   *sessionData,
+		DisplayName:   user.DisplayName,
+		RecoveryCodes: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("marshal challenge payload: %w", err)
 	}
 
+	challengeID := uuid.New().String()
+	now := time.Now()
+	challenge := &store.Challenge{
+		ChallengeID:   challengeID,
+		ChallengeData: payloadData,
+		Username:      user.Username,
+		ChallengeType: challengeTypeRecovery,
+		ClientIP:      client.IP,
+		ConnID:        client.ConnID,
+		CreatedAt:     now.Unix(),
+		ExpiresAt:     now.Add(RegistrationChallengeTTL).Unix(),
+	}
+	if err := svc.store.CreateChallenge(ctx, challenge); err != nil {
+		return nil, fmt.Errorf("store challenge: %w", err)
+	}
+
+	optionsJSON, err := json.Marshal(options)
+	if err != nil {
+		return nil, fmt.Errorf("marshal options: %w", err)
+	}
+
+	return &RegistrationChallenge{
+		ChallengeID:               challengeID,
+		CredentialCreationOptions: optionsJSON,
+	}, nil
+}
+
+// finishRecovery completes a recovery ceremony started by BeginRecovery.
+// All existing sessions and credentials are replaced by the new credential
+// and session, and a new set of recovery codes is issued.
+func (svc *Service) finishRecovery(ctx context.Context, payload *challengePayload, credential *webauthn.Credential) (*SessionResult, error) {
+	user, err := svc.store.GetUserByID(ctx, string(payload.SessionData.UserID))
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("get user: %w", err)
+	}
 	if !user.Enabled {
 		return nil, ErrAccountDisabled
 	}
 
-	_ = svc.store.UpdateSessionLastUsed(ctx, sess.ID)
+	storeSession, token, err := svc.buildSession(user.ID, "")
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().Unix()
+	storeCred := &store.Credential{
+		ID:           uuid.New().String(),
+		UserID:       user.ID,
+		CredentialID: credential.ID,
+		PublicKey:    credential.PublicKey,
+		SignCount:    int64(credential.Authenticator.SignCount),
+		CreatedAt:    now,
+
+		AAGUID:            credential.Authenticator.AAGUID,
+		AttestationFormat: credential.AttestationType,
+	}
+	revoked, removed, err := svc.store.RecoverAccount(ctx, storeCred, storeSession)
+	if err != nil {
+		return nil, fmt.Errorf("recover account: %w", err)
+	}
+	svc.audit(ctx, AuditAccountRecovered, user.ID,
+		fmt.Sprintf("sessions_revoked=%d credentials_removed=%d", revoked, removed))
+
+	codes, err := svc.GenerateRecoveryCodes(ctx, user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("generate recovery codes: %w", err)
+	}
+
+	return &SessionResult{
+		Token:            token,
+		SessionID:        storeSession.ID,
+		SessionExpiresAt: time.Unix(storeSession.ExpiresAt, 0),
+		UserID:           user.ID,
+		Username:         user.Username,
+		DisplayName:      user.DisplayName,
+		RecoveryCodes:    codes,
+		Recovered:        true,
+	}, nil
+}
+
+// --- Session Management ---
+
+// ValidateSession validates a raw session token. Returns user info if valid.
+// Updates the session's last_seen_at timestamp and restarts its idle timeout.
+func (svc *Service) ValidateSession(ctx context.Context, token string) (*SessionInfo, error) {
+	sess, user, err := svc.lookupSession(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().Unix()
+	_ = svc.store.ExtendSession(ctx, sess.ID, now, svc.sessions.expiresAt(sess.CreatedAt, now))
 
 	return &SessionInfo{
 		SessionID:   sess.ID,
@@ -506,6 +767,117 @@ func (svc *Service) RevokeSession(ctx context.Context, sessionID string) error {
 	return svc.store.DeleteSession(ctx, sessionID)
 }
 
+// --- Profile ---
+
+// findUser looks up a user by username, comparing folded forms so that
+// case and compatibility variants match. Falls back to an exact match for
+// accounts created before folding whose folded form collided with another
+// account. Returns store.ErrNotFound if there is no such user.
+func (svc *Service) findUser(ctx context.Context, username string) (*store.User, error) {
+	user, err := svc.store.GetUserByFoldedUsername(ctx, FoldUsername(username))
+	if errors.Is(err, store.ErrNotFound) {
+		return svc.store.GetUserByUsername(ctx, username)
+	}
+	return user, err
+}
+
+// UpdateDisplayName validates and sets a user's display name. Returns the
+// updated user.
+func (svc *Service) UpdateDisplayName(ctx context.Context, userID, displayName string) (*store.User, error) {
+	displayName, err := NormalizeDisplayName(displayName)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := svc.store.GetUserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("get user: %w", err)
+	}
+	if !user.Enabled {
+		return nil, ErrAccountDisabled
+	}
+	if user.DisplayName == displayName {
+		return user, nil
+	}
+
+	user.DisplayName = displayName
+	user.UpdatedAt = time.Now().Unix()
+	if err := svc.store.UpdateUser(ctx, user); err != nil {
+		return nil, fmt.Errorf("update user: %w", err)
+	}
+	return user, nil
+}
+
+// --- Throttling ---
+
+// Lockouts returns the IPs and usernames currently locked out by login
+// throttling, longest lockout first.
+func (svc *Service) Lockouts() []Lockout {
+	return svc.throttle.lockouts()
+}
+
+// checkThrottle returns a *ThrottleError if the client IP or username is
+// locked out.
+func (svc *Service) checkThrottle(client ClientInfo, username string) error {
+	if wait := svc.throttle.check(ipKey(client.IP), userKey(username)); wait > 0 {
+		return &ThrottleError{RetryAfter: wait}
+	}
+	return nil
+}
+
+// reserveChallenge returns a *ThrottleError if the client already has the
+// maximum number of unexpired challenges outstanding.
+func (svc *Service) reserveChallenge(ctx context.Context, client ClientInfo) error {
+	if client.ConnID != "" {
+		n, err := svc.store.CountActiveChallengesByConn(ctx, client.ConnID)
+		if err != nil {
+			return fmt.Errorf("count challenges: %w", err)
+		}
+		if n >= MaxChallengesPerConn {
+			svc.audit(ctx, AuditChallengeLimit, "", fmt.Sprintf("ip=%s conn=%s limit=conn", client.IP, client.ConnID))
+			return &ThrottleError{RetryAfter: RegistrationChallengeTTL}
+		}
+	}
+	if client.IP != "" {
+		n, err := svc.store.CountActiveChallengesByClientIP(ctx, client.IP)
+		if err != nil {
+			return fmt.Errorf("count challenges: %w", err)
+		}
+		if n >= MaxChallengesPerIP {
+			svc.audit(ctx, AuditChallengeLimit, "", fmt.Sprintf("ip=%s conn=%s limit=ip", client.IP, client.ConnID))
+			return &ThrottleError{RetryAfter: RegistrationChallengeTTL}
+		}
+	}
+	return nil
+}
+
+// recordFailure counts a failed attempt against the client IP and username
+// and records it in the audit log. username may be empty.
+func (svc *Service) recordFailure(ctx context.Context, client ClientInfo, username, reason string) {
+	svc.recordFailureEvent(ctx, AuditLoginFailed, client, username, reason)
+}
+
+// recordFailureEvent is recordFailure, audited as event.
+func (svc *Service) recordFailureEvent(ctx context.Context, event string, client ClientInfo, username, reason string) {
+	lockout := svc.throttle.fail(ipKey(client.IP), userKey(username))
+
+	var userID string
+	if username != "" {
+		if u, err := svc.findUser(ctx, username); err == nil {
+			userID = u.ID
+		}
+	}
+	svc.audit(ctx, event, userID,
+		fmt.Sprintf("ip=%s username=%q reason=%s", client.IP, username, reason))
+	if lockout > 0 {
+		svc.audit(ctx, AuditLoginThrottled, userID,
+			fmt.Sprintf("ip=%s username=%q lockout=%s", client.IP, username, lockout))
+	}
+}
+
 // --- Helpers ---
 
 // generateSession creates a new random session token and its SHA-256 hash.
@@ -525,6 +897,47 @@ func hashSessionToken(token string) []byte {
 	return h[:]
 }
 
+// generateRecoveryCode creates a random recovery code formatted as
+// dash-separated groups of four base32 characters.
+func generateRecoveryCode() (string, error) {
+	b := make([]byte, RecoveryCodeBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate random bytes: %w", err)
+	}
+	raw := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b)
+	var groups []string
+	for i := 0; i < len(raw); i += 4 {
+		groups = append(groups, raw[i:min(i+4, len(raw))])
+	}
+	return strings.Join(groups, "-"), nil
+}
+
+// normalizeRecoveryCode strips separators and case so that codes typed by
+// hand hash to the same value as the issued code.
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToUpper(code)
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, code)
+}
+
+// audit records an audit event. Failures are logged, not returned, so that
+// auditing never blocks the operation being audited.
+func (svc *Service) audit(ctx context.Context, eventType, userID, detail string) {
+	e := &store.AuditEvent{
+		EventType: eventType,
+		UserID:    userID,
+		Detail:    detail,
+		CreatedAt: time.Now().Unix(),
+	}
+	if err := svc.store.InsertAuditEvent(ctx, e); err != nil {
+		log.Printf("auth: record audit event %s: %v", eventType, err)
+	}
+}
+
 // buildRegistrationResponseJSON constructs the WebAuthn credential creation
 // response JSON from individual protobuf fields.
 func buildRegistrationResponseJSON(resp *AttestationResponse) ([]byte, error) {
@@ -3,7 +3,12 @@ package auth
 import (
 	"context"
 	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 
@@ -19,7 +24,7 @@ func newTestService(t *testing.T) (*Service, *store.Store) {
 	}
 	t.Cleanup(func() { s.Close() })
 
-	svc, err := NewService(s, "Test Server", "localhost", []string{"http://localhost:8080"})
+	svc, err := NewService(s, "Test Server", "localhost", []string{"http://localhost:8080"}, Policy{}, SessionLifetime{})
 	if err != nil {
 		t.Fatalf("NewService error: %v", err)
 	}
@@ -84,6 +89,7 @@ func TestNewService(t *testing.T) {
 		rpDisplayName string
 		rpID          string
 		rpOrigins     []string
+		policy        Policy
 		wantErr       bool
 	}{
 		{
@@ -92,6 +98,14 @@ func TestNewService(t *testing.T) {
 			rpID:          "localhost",
 			rpOrigins:     []string{"http://localhost:8080"},
 		},
+		{
+			name:          "invalid policy",
+			rpDisplayName: "Test Server",
+			rpID:          "localhost",
+			rpOrigins:     []string{"http://localhost:8080"},
+			policy:        Policy{UserVerification: "always"},
+			wantErr:       true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -102,7 +116,7 @@ func TestNewService(t *testing.T) {
 			}
 			defer s.Close()
 
-			svc, err := NewService(s, tt.rpDisplayName, tt.rpID, tt.rpOrigins)
+			svc, err := NewService(s, tt.rpDisplayName, tt.rpID, tt.rpOrigins, tt.policy, SessionLifetime{})
 			if tt.wantErr {
 				if err == nil {
 					t.Fatal("expected error, got nil")
@@ -139,6 +153,24 @@ func TestBeginRegistration(t *testing.T) {
 			seedUser:    true,
 			wantErr:     true,
 		},
+		{
+			name:        "invalid username fails",
+			username:    "a b",
+			displayName: "Alice",
+			wantErr:     true,
+		},
+		{
+			name:        "reserved username fails",
+			username:    "Admin",
+			displayName: "Alice",
+			wantErr:     true,
+		},
+		{
+			name:        "empty display name fails",
+			username:    "alice",
+			displayName: "   ",
+			wantErr:     true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -150,7 +182,7 @@ func TestBeginRegistration(t *testing.T) {
 				seedUser(t, s, "existing-user", tt.username, tt.displayName)
 			}
 
-			result, err := svc.BeginRegistration(ctx, tt.username, tt.displayName)
+			result, err := svc.BeginRegistration(ctx, ClientInfo{}, tt.username, tt.displayName, false)
 			if tt.wantErr {
 				if err == nil {
 					t.Fatal("expected error, got nil")
@@ -259,9 +291,8 @@ func TestBeginLogin(t *testing.T) {
 			seedUser: true,
 		},
 		{
-			name:     "non-existent user",
+			name:     "non-existent user gets a decoy challenge",
 			username: "nonexistent",
-			wantErr:  ErrUserNotFound,
 		},
 	}
 
@@ -274,7 +305,7 @@ func TestBeginLogin(t *testing.T) {
 				seedUser(t, s, "u1", tt.username, "Display "+tt.username)
 			}
 
-			result, err := svc.BeginLogin(ctx, tt.username)
+			result, err := svc.BeginLogin(ctx, ClientInfo{}, tt.username)
 			if tt.wantErr != nil {
 				if !errors.Is(err, tt.wantErr) {
 					t.Errorf("error = %v, want %v", err, tt.wantErr)
@@ -307,9 +338,28 @@ func TestBeginLoginDisabledUser(t *testing.T) {
 		t.Fatalf("UpdateUser: %v", err)
 	}
 
-	_, err := svc.BeginLogin(ctx, "alice")
-	if !errors.Is(err, ErrAccountDisabled) {
-		t.Errorf("error = %v, want ErrAccountDisabled", err)
+	// A disabled account gets a decoy challenge, like an unknown username.
+	challenge, err := svc.BeginLogin(ctx, ClientInfo{}, "alice")
+	if err != nil {
+		t.Fatalf("BeginLogin: %v", err)
+	}
+	creds, _ := s.GetCredentialsByUserID(ctx, "u1")
+	for _, id := range allowCredentialIDs(t, challenge.CredentialRequestOptions) {
+		for _, c := range creds {
+			if id == base64.RawURLEncoding.EncodeToString(c.CredentialID) {
+				t.Errorf("challenge for a disabled account lists its credential %s", id)
+			}
+		}
+	}
+
+	_, err = svc.FinishLogin(ctx, ClientInfo{}, challenge.ChallengeID, &AssertionResponse{
+		CredentialID:      []byte("cred-id"),
+		AuthenticatorData: []byte("auth-data"),
+		ClientDataJSON:    []byte("{}"),
+		Signature:         []byte("sig"),
+	})
+	if !errors.Is(err, ErrInvalidCredential) {
+		t.Errorf("FinishLogin error = %v, want ErrInvalidCredential", err)
 	}
 }
 
@@ -338,7 +388,7 @@ func TestFinishLoginErrors(t *testing.T) {
 				Signature:         []byte("sig"),
 			}
 
-			_, err := svc.FinishLogin(ctx, tt.challengeID, resp)
+			_, err := svc.FinishLogin(ctx, ClientInfo{}, tt.challengeID, resp)
 			if !errors.Is(err, tt.wantErr) {
 				t.Errorf("error = %v, want %v", err, tt.wantErr)
 			}
@@ -537,3 +587,422 @@ func TestHashSessionToken(t *testing.T) {
 		t.Error("different tokens produced the same hash")
 	}
 }
+
+func TestGenerateRecoveryCodes(t *testing.T) {
+	svc, s := newTestService(t)
+	ctx := context.Background()
+	seedUser(t, s, "u1", "alice", "Alice")
+
+	codes, err := svc.GenerateRecoveryCodes(ctx, "u1")
+	if err != nil {
+		t.Fatalf("GenerateRecoveryCodes: %v", err)
+	}
+	if len(codes) != RecoveryCodeCount {
+		t.Errorf("len(codes) = %d, want %d", len(codes), RecoveryCodeCount)
+	}
+	seen := make(map[string]bool)
+	for _, c := range codes {
+		if seen[c] {
+			t.Errorf("duplicate code %q", c)
+		}
+		seen[c] = true
+	}
+
+	count, err := s.CountRecoveryCodes(ctx, "u1")
+	if err != nil {
+		t.Fatalf("CountRecoveryCodes: %v", err)
+	}
+	if count != RecoveryCodeCount {
+		t.Errorf("stored count = %d, want %d", count, RecoveryCodeCount)
+	}
+}
+
+func TestBeginRecovery(t *testing.T) {
+	tests := []struct {
+		name     string
+		username string
+		code     func(codes []string) string
+		redeems  int
+		disable  bool
+		wantErr  error
+	}{
+		{
+			name:     "valid code",
+			username: "alice",
+			code:     func(codes []string) string { return codes[0] },
+			redeems:  1,
+		},
+		{
+			name:     "code is normalized",
+			username: "alice",
+			code:     func(codes []string) string { return strings.ToLower(strings.ReplaceAll(codes[0], "-", " ")) },
+			redeems:  1,
+		},
+		{
+			name:     "invalid code",
+			username: "alice",
+			code:     func([]string) string { return "AAAA-BBBB-CCCC-DDDD" },
+			redeems:  1,
+			wantErr:  ErrInvalidRecoveryCode,
+		},
+		{
+			name:     "reused code",
+			username: "alice",
+			code:     func(codes []string) string { return codes[0] },
+			redeems:  2,
+			wantErr:  ErrInvalidRecoveryCode,
+		},
+		{
+			name:     "code belongs to another user",
+			username: "bob",
+			code:     func(codes []string) string { return codes[0] },
+			redeems:  1,
+			wantErr:  ErrInvalidRecoveryCode,
+		},
+		{
+			name:     "unknown user",
+			username: "nobody",
+			code:     func(codes []string) string { return codes[0] },
+			redeems:  1,
+			wantErr:  ErrInvalidRecoveryCode,
+		},
+		{
+			name:     "disabled user",
+			username: "alice",
+			code:     func(codes []string) string { return codes[0] },
+			redeems:  1,
+			disable:  true,
+			wantErr:  ErrAccountDisabled,
+		},
+		{
+			name:     "disabled user with invalid code",
+			username: "alice",
+			code:     func([]string) string { return "AAAA-BBBB-CCCC-DDDD" },
+			redeems:  1,
+			disable:  true,
+			wantErr:  ErrInvalidRecoveryCode,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, s := newTestService(t)
+			ctx := context.Background()
+			seedUser(t, s, "u1", "alice", "Alice")
+			seedUser(t, s, "u2", "bob", "Bob")
+
+			codes, err := svc.GenerateRecoveryCodes(ctx, "u1")
+			if err != nil {
+				t.Fatalf("GenerateRecoveryCodes: %v", err)
+			}
+			if tt.disable {
+				u, _ := s.GetUserByID(ctx, "u1")
+				u.Enabled = false
+				if err := s.UpdateUser(ctx, u); err != nil {
+					t.Fatalf("UpdateUser: %v", err)
+				}
+			}
+
+			var result *RegistrationChallenge
+			for i := 0; i < tt.redeems; i++ {
+				result, err = svc.BeginRecovery(ctx, ClientInfo{}, tt.username, tt.code(codes))
+			}
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("error = %v, want %v", err, tt.wantErr)
+				}
+				// Only a bad code counts as a failed attempt.
+				events, _ := s.ListAuditEvents(ctx, AuditLoginFailed, 0, 10)
+				if failed := len(events) > 0; failed != (tt.wantErr == ErrInvalidRecoveryCode) {
+					t.Errorf("failed attempts recorded = %v, want %v", failed, !failed)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			challenge, err := s.GetChallenge(ctx, result.ChallengeID)
+			if err != nil {
+				t.Fatalf("GetChallenge: %v", err)
+			}
+			if challenge.ChallengeType != challengeTypeRecovery {
+				t.Errorf("ChallengeType = %q, want %q", challenge.ChallengeType, challengeTypeRecovery)
+			}
+
+			count, _ := s.CountRecoveryCodes(ctx, "u1")
+			if count != RecoveryCodeCount-1 {
+				t.Errorf("remaining codes = %d, want %d", count, RecoveryCodeCount-1)
+			}
+
+			events, err := s.ListAuditEvents(ctx, "", 0, 10)
+			if err != nil {
+				t.Fatalf("ListAuditEvents: %v", err)
+			}
+			if len(events) != 1 || events[0].EventType != AuditRecoveryCodeRedeemed || events[0].UserID != "u1" {
+				t.Errorf("audit events = %+v, want one %s for u1", events, AuditRecoveryCodeRedeemed)
+			}
+		})
+	}
+}
+
+func TestNormalizeRecoveryCode(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{in: "ABCD-EFGH", want: "ABCDEFGH"},
+		{in: "abcd efgh", want: "ABCDEFGH"},
+		{in: " abcd-EFGH ", want: "ABCDEFGH"},
+	}
+	for _, tt := range tests {
+		if got := normalizeRecoveryCode(tt.in); got != tt.want {
+			t.Errorf("normalizeRecoveryCode(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+// allowCredentialIDs extracts the allowCredentials IDs from request options.
+func allowCredentialIDs(t *testing.T, options []byte) []string {
+	t.Helper()
+	var parsed struct {
+		Response struct {
+			AllowCredentials []struct {
+				ID string `json:"id"`
+			} `json:"allowCredentials"`
+		} `json:"publicKey"`
+	}
+	if err := json.Unmarshal(options, &parsed); err != nil {
+		t.Fatalf("unmarshal options: %v", err)
+	}
+	ids := make([]string, len(parsed.Response.AllowCredentials))
+	for i, c := range parsed.Response.AllowCredentials {
+		ids[i] = c.ID
+	}
+	return ids
+}
+
+func TestBeginLoginDecoy(t *testing.T) {
+	svc, s := newTestService(t)
+	ctx := context.Background()
+	seedUser(t, s, "u1", "alice", "Alice")
+
+	genuine, err := svc.BeginLogin(ctx, ClientInfo{}, "alice")
+	if err != nil {
+		t.Fatalf("BeginLogin(alice): %v", err)
+	}
+	decoy1, err := svc.BeginLogin(ctx, ClientInfo{}, "mallory")
+	if err != nil {
+		t.Fatalf("BeginLogin(mallory): %v", err)
+	}
+	decoy2, err := svc.BeginLogin(ctx, ClientInfo{}, "Mallory")
+	if err != nil {
+		t.Fatalf("BeginLogin(Mallory): %v", err)
+	}
+	other, err := svc.BeginLogin(ctx, ClientInfo{}, "trudy")
+	if err != nil {
+		t.Fatalf("BeginLogin(trudy): %v", err)
+	}
+
+	realIDs := allowCredentialIDs(t, genuine.CredentialRequestOptions)
+	ids1 := allowCredentialIDs(t, decoy1.CredentialRequestOptions)
+	ids2 := allowCredentialIDs(t, decoy2.CredentialRequestOptions)
+	otherIDs := allowCredentialIDs(t, other.CredentialRequestOptions)
+
+	if len(realIDs) != 1 || len(ids1) == 0 {
+		t.Errorf("real user has %d credentials, decoy has %d", len(realIDs), len(ids1))
+	}
+	if !slices.Equal(ids1, ids2) {
+		t.Errorf("decoy credentials not stable: %v vs %v", ids1, ids2)
+	}
+	for _, id := range otherIDs {
+		if slices.Contains(ids1, id) {
+			t.Errorf("decoy credential %s shared between usernames", id)
+		}
+	}
+
+	// The decoy key is kept in the store, so a restarted server shows the
+	// same decoy.
+	restarted, err := NewService(s, "Test Server", "localhost", []string{"http://localhost:8080"}, Policy{}, SessionLifetime{})
+	if err != nil {
+		t.Fatalf("NewService: %v", err)
+	}
+	again, err := restarted.BeginLogin(ctx, ClientInfo{}, "mallory")
+	if err != nil {
+		t.Fatalf("BeginLogin(mallory) after restart: %v", err)
+	}
+	if ids := allowCredentialIDs(t, again.CredentialRequestOptions); !slices.Equal(ids, ids1) {
+		t.Errorf("decoy credentials changed on restart: %v vs %v", ids, ids1)
+	}
+
+	// Finishing a decoy challenge fails like a bad assertion.
+	_, err = svc.FinishLogin(ctx, ClientInfo{}, decoy1.ChallengeID, &AssertionResponse{
+		CredentialID:      []byte("cred-id"),
+		AuthenticatorData: []byte("auth-data"),
+		ClientDataJSON:    []byte("{}"),
+		Signature:         []byte("sig"),
+	})
+	if !errors.Is(err, ErrInvalidCredential) {
+		t.Errorf("FinishLogin(decoy) error = %v, want ErrInvalidCredential", err)
+	}
+}
+
+func TestLoginThrottling(t *testing.T) {
+	svc, s := newTestService(t)
+	ctx := context.Background()
+	seedUser(t, s, "u1", "alice", "Alice")
+	client := ClientInfo{IP: "192.0.2.1"}
+
+	resp := &AssertionResponse{
+		CredentialID:      []byte("cred-id"),
+		AuthenticatorData: []byte("auth-data"),
+		ClientDataJSON:    []byte("{}"),
+		Signature:         []byte("sig"),
+	}
+	for i := 0; i <= LoginFreeAttempts; i++ {
+		challenge, err := svc.BeginLogin(ctx, client, "alice")
+		if err != nil {
+			t.Fatalf("attempt %d: BeginLogin: %v", i+1, err)
+		}
+		if _, err := svc.FinishLogin(ctx, client, challenge.ChallengeID, resp); !errors.Is(err, ErrInvalidCredential) {
+			t.Fatalf("attempt %d: FinishLogin error = %v, want ErrInvalidCredential", i+1, err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		client   ClientInfo
+		username string
+		wantErr  error
+	}{
+		{name: "same IP and username", client: client, username: "alice", wantErr: ErrTooManyAttempts},
+		{name: "same username from another IP", client: ClientInfo{IP: "192.0.2.2"}, username: "ALICE", wantErr: ErrTooManyAttempts},
+		{name: "same IP, other username", client: client, username: "bob", wantErr: ErrTooManyAttempts},
+		{name: "unrelated IP and username", client: ClientInfo{IP: "192.0.2.3"}, username: "bob"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := svc.BeginLogin(ctx, tt.client, tt.username)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	if len(svc.Lockouts()) != 2 {
+		t.Errorf("Lockouts() = %v, want IP and username", svc.Lockouts())
+	}
+
+	// Failures are visible to admins in the audit log.
+	events, err := s.ListAuditEvents(ctx, "", 0, 100)
+	if err != nil {
+		t.Fatalf("ListAuditEvents: %v", err)
+	}
+	var failed, throttled int
+	for _, e := range events {
+		switch e.EventType {
+		case AuditLoginFailed:
+			failed++
+		case AuditLoginThrottled:
+			throttled++
+		}
+	}
+	if failed != LoginFreeAttempts+1 {
+		t.Errorf("%s events = %d, want %d", AuditLoginFailed, failed, LoginFreeAttempts+1)
+	}
+	if throttled != 1 {
+		t.Errorf("%s events = %d, want 1", AuditLoginThrottled, throttled)
+	}
+}
+
+func TestRegistrationUsernameProbing(t *testing.T) {
+	svc, s := newTestService(t)
+	ctx := context.Background()
+	seedUser(t, s, "u1", "alice", "Alice")
+	client := ClientInfo{IP: "192.0.2.1"}
+
+	// Taken and reserved names cannot be told apart.
+	_, taken := svc.BeginRegistration(ctx, client, "alice", "Alice", false)
+	_, reserved := svc.BeginRegistration(ctx, client, "admin", "Admin", false)
+	if !errors.Is(taken, ErrRegistrationFailed) || !errors.Is(reserved, ErrRegistrationFailed) {
+		t.Fatalf("errors = %v, %v; want ErrRegistrationFailed", taken, reserved)
+	}
+	if taken.Error() != reserved.Error() {
+		t.Errorf("taken error %q differs from reserved error %q", taken, reserved)
+	}
+
+	// Each probe counts against the client IP.
+	for i := 2; i <= LoginFreeAttempts; i++ {
+		if _, err := svc.BeginRegistration(ctx, client, "alice", "Alice", false); !errors.Is(err, ErrRegistrationFailed) {
+			t.Fatalf("attempt %d: error = %v, want ErrRegistrationFailed", i+1, err)
+		}
+	}
+	if _, err := svc.BeginRegistration(ctx, client, "carol", "Carol", false); !errors.Is(err, ErrTooManyAttempts) {
+		t.Errorf("after %d probes: error = %v, want ErrTooManyAttempts", LoginFreeAttempts+1, err)
+	}
+	if _, err := svc.BeginRegistration(ctx, ClientInfo{IP: "192.0.2.2"}, "carol", "Carol", false); err != nil {
+		t.Errorf("other IP: %v", err)
+	}
+}
+
+func TestChallengeLimits(t *testing.T) {
+	tests := []struct {
+		name    string
+		clients func(i int) ClientInfo
+		limit   int
+	}{
+		{
+			name:    "per connection",
+			clients: func(int) ClientInfo { return ClientInfo{IP: "192.0.2.1", ConnID: "conn-1"} },
+			limit:   MaxChallengesPerConn,
+		},
+		{
+			name:    "per IP",
+			clients: func(i int) ClientInfo { return ClientInfo{IP: "192.0.2.1", ConnID: fmt.Sprintf("conn-%d", i)} },
+			limit:   MaxChallengesPerIP,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, s := newTestService(t)
+			ctx := context.Background()
+
+			for i := 0; i < tt.limit; i++ {
+				if _, err := svc.BeginRegistration(ctx, tt.clients(i), fmt.Sprintf("user%d", i), "User", false); err != nil {
+					t.Fatalf("challenge %d: %v", i+1, err)
+				}
+			}
+			_, err := svc.BeginLogin(ctx, tt.clients(tt.limit), "alice")
+			if !errors.Is(err, ErrTooManyAttempts) {
+				t.Fatalf("error = %v, want ErrTooManyAttempts", err)
+			}
+
+			events, _ := s.ListAuditEvents(ctx, "", 0, 10)
+			if len(events) != 1 || events[0].EventType != AuditChallengeLimit {
+				t.Errorf("audit events = %+v, want one %s", events, AuditChallengeLimit)
+			}
+		})
+	}
+}
+
+func TestDecoyCredentialsVary(t *testing.T) {
+	key := []byte("decoy key")
+	counts := make(map[int]bool)
+	lengths := make(map[int]bool)
+	for i := range 200 {
+		user := newDecoyWebAuthnUser(key, fmt.Sprintf("user%d", i))
+		counts[len(user.credentials)] = true
+		for _, c := range user.credentials {
+			lengths[len(c.ID)] = true
+		}
+	}
+	if len(counts) < 2 {
+		t.Errorf("decoy credential counts = %v, want some variety", counts)
+	}
+	for _, n := range decoyCredentialIDLengths {
+		if !lengths[n] {
+			t.Errorf("no decoy credential ID of %d bytes in %v", n, lengths)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package auth
+
+import (
+	"fmt"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
+)
+
+// Policy configures the requirements applied to WebAuthn ceremonies.
+// The zero value applies the WebAuthn library defaults.
+type Policy struct {
+	// UserVerification is "required", "preferred" or "discouraged".
+	// Empty means "preferred".
+	UserVerification string
+
+	// ResidentKey is "required", "preferred" or "discouraged".
+	// Empty leaves the choice to the authenticator.
+	ResidentKey string
+
+	// AllowedAAGUIDs lists the authenticator models (as UUID strings) that may
+	// register credentials. Empty allows any authenticator. When set, direct
+	// attestation is requested and "none" attestation is rejected, since its
+	// AAGUID cannot be trusted. Attestation certificates are not checked
+	// against a metadata service, so this restricts honest clients rather
+	// than providing a hard guarantee.
+	AllowedAAGUIDs []string
+}
+
+// webauthnPolicy is a validated Policy in the WebAuthn library's types.
+type webauthnPolicy struct {
+	userVerification protocol.UserVerificationRequirement
+	residentKey      protocol.ResidentKeyRequirement
+	allowedAAGUIDs   map[uuid.UUID]bool
+}
+
+// compile validates the policy and converts it to library types.
+func (p Policy) compile() (*webauthnPolicy, error) {
+	wp := &webauthnPolicy{}
+
+	switch uv := protocol.UserVerificationRequirement(p.UserVerification); uv {
+	case "", protocol.VerificationRequired, protocol.VerificationPreferred, protocol.VerificationDiscouraged:
+		wp.userVerification = uv
+	default:
+		return nil, fmt.Errorf("invalid user verification requirement %q", p.UserVerification)
+	}
+
+	switch rk := protocol.ResidentKeyRequirement(p.ResidentKey); rk {
+	case "", protocol.ResidentKeyRequirementRequired, protocol.ResidentKeyRequirementPreferred, protocol.ResidentKeyRequirementDiscouraged:
+		wp.residentKey = rk
+	default:
+		return nil, fmt.Errorf("invalid resident key requirement %q", p.ResidentKey)
+	}
+
+	if len(p.AllowedAAGUIDs) > 0 {
+		wp.allowedAAGUIDs = make(map[uuid.UUID]bool, len(p.AllowedAAGUIDs))
+		for _, s := range p.AllowedAAGUIDs {
+			id, err := uuid.Parse(s)
+			if err != nil {
+				return nil, fmt.Errorf("invalid AAGUID %q: %w", s, err)
+			}
+			wp.allowedAAGUIDs[id] = true
+		}
+	}
+
+	return wp, nil
+}
+
+// registrationOptions returns the options applied in BeginRegistration and
+// BeginRecovery.
+func (wp *webauthnPolicy) registrationOptions() []webauthn.RegistrationOption {
+	var opts []webauthn.RegistrationOption
+	if wp.userVerification != "" || wp.residentKey != "" {
+		sel := protocol.AuthenticatorSelection{UserVerification: wp.userVerification}
+		opts = append(opts, webauthn.WithAuthenticatorSelection(sel))
+	}
+	if wp.residentKey != "" {
+		opts = append(opts, webauthn.WithResidentKeyRequirement(wp.residentKey))
+	}
+	if wp.allowedAAGUIDs != nil {
+		opts = append(opts, webauthn.WithConveyancePreference(protocol.PreferDirectAttestation))
+	}
+	return opts
+}
+
+// loginOptions returns the options applied in BeginLogin.
+func (wp *webauthnPolicy) loginOptions() []webauthn.LoginOption {
+	if wp.userVerification == "" {
+		return nil
+	}
+	return []webauthn.LoginOption{webauthn.WithUserVerification(wp.userVerification)}
+}
+
+// checkCredential enforces the AAGUID allowlist on a newly created credential.
+func (wp *webauthnPolicy) checkCredential(cred *webauthn.Credential) error {
+	if wp.allowedAAGUIDs == nil {
+		return nil
+	}
+	if cred.AttestationType == "" || cred.AttestationType == string(protocol.AttestationFormatNone) {
+		return fmt.Errorf("attestation required by authenticator policy: %w", ErrRegistrationFailed)
+	}
+	id, err := uuid.FromBytes(cred.Authenticator.AAGUID)
+	if err != nil || !wp.allowedAAGUIDs[id] {
+		return fmt.Errorf("authenticator %x not allowed by policy: %w", cred.Authenticator.AAGUID, ErrRegistrationFailed)
+	}
+	return nil
+}
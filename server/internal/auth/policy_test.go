@@ -0,0 +1,187 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
+
+	"github.com/sovereign-im/sovereign/server/internal/store"
+)
+
+// newPolicyTestService creates an auth Service with the given policy.
+func newPolicyTestService(t *testing.T, policy Policy) (*Service, *store.Store) {
+	t.Helper()
+	s, err := store.New(":memory:")
+	if err != nil {
+		t.Fatalf("store.New(:memory:) error: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+
+	svc, err := NewService(s, "Test Server", "localhost", []string{"http://localhost:8080"}, policy, SessionLifetime{})
+	if err != nil {
+		t.Fatalf("NewService error: %v", err)
+	}
+	return svc, s
+}
+
+func TestPolicyCompile(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  Policy
+		wantErr bool
+	}{
+		{name: "zero value", policy: Policy{}},
+		{
+			name: "all options",
+			policy: Policy{
+				UserVerification: "required",
+				ResidentKey:      "required",
+				AllowedAAGUIDs:   []string{"ee882879-721c-4913-9775-3dfcce97072a"},
+			},
+		},
+		{name: "invalid user verification", policy: Policy{UserVerification: "always"}, wantErr: true},
+		{name: "invalid resident key", policy: Policy{ResidentKey: "yes"}, wantErr: true},
+		{name: "invalid AAGUID", policy: Policy{AllowedAAGUIDs: []string{"not-a-uuid"}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.policy.compile()
+			if tt.wantErr && err == nil {
+				t.Fatal("expected error, got nil")
+			}
+			if !tt.wantErr && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestPolicyRegistrationOptions(t *testing.T) {
+	tests := []struct {
+		name            string
+		policy          Policy
+		wantUV          string
+		wantResidentKey string
+		wantAttestation string
+	}{
+		{
+			name:   "defaults",
+			policy: Policy{},
+		},
+		{
+			name:            "required",
+			policy:          Policy{UserVerification: "required", ResidentKey: "required"},
+			wantUV:          "required",
+			wantResidentKey: "required",
+		},
+		{
+			name:            "allowlist requests direct attestation",
+			policy:          Policy{AllowedAAGUIDs: []string{"ee882879-721c-4913-9775-3dfcce97072a"}},
+			wantAttestation: "direct",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, _ := newPolicyTestService(t, tt.policy)
+
+			result, err := svc.BeginRegistration(context.Background(), ClientInfo{}, "alice", "Alice", false)
+			if err != nil {
+				t.Fatalf("BeginRegistration: %v", err)
+			}
+
+			var opts struct {
+				PublicKey struct {
+					AuthenticatorSelection struct {
+						UserVerification string `json:"userVerification"`
+						ResidentKey      string `json:"residentKey"`
+					} `json:"authenticatorSelection"`
+					Attestation string `json:"attestation"`
+				} `json:"publicKey"`
+			}
+			if err := json.Unmarshal(result.CredentialCreationOptions, &opts); err != nil {
+				t.Fatalf("unmarshal options: %v", err)
+			}
+
+			sel := opts.PublicKey.AuthenticatorSelection
+			if tt.wantUV != "" && sel.UserVerification != tt.wantUV {
+				t.Errorf("userVerification = %q, want %q", sel.UserVerification, tt.wantUV)
+			}
+			if tt.wantResidentKey != "" && sel.ResidentKey != tt.wantResidentKey {
+				t.Errorf("residentKey = %q, want %q", sel.ResidentKey, tt.wantResidentKey)
+			}
+			if tt.wantAttestation != "" && opts.PublicKey.Attestation != tt.wantAttestation {
+				t.Errorf("attestation = %q, want %q", opts.PublicKey.Attestation, tt.wantAttestation)
+			}
+		})
+	}
+}
+
+func TestPolicyLoginOptions(t *testing.T) {
+	svc, s := newPolicyTestService(t, Policy{UserVerification: "required"})
+	seedUser(t, s, "user-1", "alice", "Alice")
+
+	result, err := svc.BeginLogin(context.Background(), ClientInfo{}, "alice")
+	if err != nil {
+		t.Fatalf("BeginLogin: %v", err)
+	}
+
+	var opts struct {
+		PublicKey struct {
+			UserVerification string `json:"userVerification"`
+		} `json:"publicKey"`
+	}
+	if err := json.Unmarshal(result.CredentialRequestOptions, &opts); err != nil {
+		t.Fatalf("unmarshal options: %v", err)
+	}
+	if opts.PublicKey.UserVerification != "required" {
+		t.Errorf("userVerification = %q, want %q", opts.PublicKey.UserVerification, "required")
+	}
+}
+
+func TestPolicyCheckCredential(t *testing.T) {
+	allowed := uuid.MustParse("ee882879-721c-4913-9775-3dfcce97072a")
+	other := uuid.MustParse("cb69481e-8ff7-4039-93ec-0a2729a154a8")
+
+	tests := []struct {
+		name        string
+		allowlist   []string
+		attestation string
+		aaguid      uuid.UUID
+		wantErr     bool
+	}{
+		{name: "no allowlist", attestation: "none", aaguid: other},
+		{name: "allowed", allowlist: []string{allowed.String()}, attestation: "packed", aaguid: allowed},
+		{name: "not allowed", allowlist: []string{allowed.String()}, attestation: "packed", aaguid: other, wantErr: true},
+		{name: "none attestation", allowlist: []string{allowed.String()}, attestation: "none", aaguid: allowed, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wp, err := Policy{AllowedAAGUIDs: tt.allowlist}.compile()
+			if err != nil {
+				t.Fatalf("compile: %v", err)
+			}
+
+			cred := &webauthn.Credential{
+				AttestationType: tt.attestation,
+				Authenticator:   webauthn.Authenticator{AAGUID: tt.aaguid[:]},
+			}
+			err = wp.checkCredential(cred)
+			if tt.wantErr {
+				if !errors.Is(err, ErrRegistrationFailed) {
+					t.Errorf("error = %v, want %v", err, ErrRegistrationFailed)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,183 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/sovereign-im/sovereign/server/internal/store"
+)
+
+// SessionLifetime configures when sessions expire. A session expires
+// MaxAge after it was created, or IdleTimeout after it was last used,
+// whichever comes first. Zero durations use the package defaults.
+type SessionLifetime struct {
+	MaxAge      time.Duration
+	IdleTimeout time.Duration
+
+	// RotateOnResume issues a new session token each time a session is
+	// resumed; the previous token stops working.
+	RotateOnResume bool
+}
+
+// withDefaults fills in zero durations.
+func (l SessionLifetime) withDefaults() SessionLifetime {
+	if l.MaxAge <= 0 {
+		l.MaxAge = DefaultSessionMaxAge
+	}
+	if l.IdleTimeout <= 0 {
+		l.IdleTimeout = DefaultSessionIdleTimeout
+	}
+	return l
+}
+
+// expiresAt returns the expiry of a session created at createdAt and last
+// used at lastSeenAt, both Unix seconds.
+func (l SessionLifetime) expiresAt(createdAt, lastSeenAt int64) int64 {
+	return min(createdAt+int64(l.MaxAge.Seconds()), lastSeenAt+int64(l.IdleTimeout.Seconds()))
+}
+
+// newSession creates and stores a session for userID. credentialID may be
+// empty. Returns the stored session and its raw token.
+func (svc *Service) newSession(ctx context.Context, userID, credentialID string) (*store.Session, string, error) {
+	sess, token, err := svc.buildSession(userID, credentialID)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := svc.store.CreateSession(ctx, sess); err != nil {
+		return nil, "", fmt.Errorf("create session: %w", err)
+	}
+	return sess, token, nil
+}
+
+// buildSession generates a session token and the session row for it,
+// without storing it.
+func (svc *Service) buildSession(userID, credentialID string) (*store.Session, string, error) {
+	token, tokenHash, err := generateSession()
+	if err != nil {
+		return nil, "", fmt.Errorf("generate session: %w", err)
+	}
+	now := time.Now().Unix()
+	return &store.Session{
+		ID:           uuid.New().String(),
+		UserID:       userID,
+		CredentialID: credentialID,
+		TokenHash:    tokenHash,
+		CreatedAt:    now,
+		ExpiresAt:    svc.sessions.expiresAt(now, now),
+		LastSeenAt:   now,
+	}, token, nil
+}
+
+// lookupSession finds the session for a raw token and checks that it has
+// not expired and that its user is enabled.
+func (svc *Service) lookupSession(ctx context.Context, token string) (*store.Session, *store.User, error) {
+	sess, err := svc.store.GetSessionByTokenHash(ctx, hashSessionToken(token))
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, nil, ErrInvalidCredential
+		}
+		return nil, nil, fmt.Errorf("get session: %w", err)
+	}
+
+	if svc.sessionExpired(ctx, sess, time.Now().Unix()) {
+		return nil, nil, ErrSessionExpired
+	}
+
+	user, err := svc.store.GetUserByID(ctx, sess.UserID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("get user: %w", err)
+	}
+	if !user.Enabled {
+		return nil, nil, ErrAccountDisabled
+	}
+	return sess, user, nil
+}
+
+// sessionExpired reports whether sess has expired at now, under either its
+// stored expiry or the current lifetime settings. Expired sessions are
+// deleted.
+func (svc *Service) sessionExpired(ctx context.Context, sess *store.Session, now int64) bool {
+	if now < sess.ExpiresAt && now < svc.sessions.expiresAt(sess.CreatedAt, sess.LastSeenAt) {
+		return false
+	}
+	_ = svc.store.DeleteSession(ctx, sess.ID)
+	return true
+}
+
+// ResumeSession authenticates a connection with an existing session token.
+// The session's idle timeout is restarted. If RotateOnResume is set, the
+// token is replaced and the new one is returned in SessionResult.Token;
+// otherwise Token is empty.
+func (svc *Service) ResumeSession(ctx context.Context, token string) (*SessionResult, error) {
+	sess, user, err := svc.lookupSession(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().Unix()
+	expiresAt := svc.sessions.expiresAt(sess.CreatedAt, now)
+	result := &SessionResult{
+		SessionID:        sess.ID,
+		SessionExpiresAt: time.Unix(expiresAt, 0),
+		UserID:           user.ID,
+		Username:         user.Username,
+		DisplayName:      user.DisplayName,
+	}
+
+	if !svc.sessions.RotateOnResume {
+		if err := svc.store.ExtendSession(ctx, sess.ID, now, expiresAt); err != nil {
+			return nil, fmt.Errorf("extend session: %w", err)
+		}
+		return result, nil
+	}
+
+	newToken, newHash, err := generateSession()
+	if err != nil {
+		return nil, fmt.Errorf("generate session: %w", err)
+	}
+	if err := svc.store.RotateSessionToken(ctx, sess.ID, sess.TokenHash, newHash, now, expiresAt); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			// Rotated or revoked concurrently; the presented token is stale.
+			return nil, ErrInvalidCredential
+		}
+		return nil, fmt.Errorf("rotate session token: %w", err)
+	}
+	result.Token = newToken
+	return result, nil
+}
+
+// RefreshSession is called for a live connection when its session is due
+// to expire. lastActivity is the last time the connection was used; if it
+// is later than the session's last use, the idle timeout is restarted from
+// it. Returns the new expiry, ErrSessionExpired if the session has expired,
+// or ErrInvalidCredential if it no longer exists.
+func (svc *Service) RefreshSession(ctx context.Context, sessionID string, lastActivity time.Time) (time.Time, error) {
+	sess, err := svc.store.GetSessionByID(ctx, sessionID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return time.Time{}, ErrInvalidCredential
+		}
+		return time.Time{}, fmt.Errorf("get session: %w", err)
+	}
+
+	lastSeen := max(sess.LastSeenAt, lastActivity.Unix())
+	expiresAt := svc.sessions.expiresAt(sess.CreatedAt, lastSeen)
+	if time.Now().Unix() >= expiresAt {
+		_ = svc.store.DeleteSession(ctx, sess.ID)
+		return time.Time{}, ErrSessionExpired
+	}
+
+	if lastSeen != sess.LastSeenAt || expiresAt != sess.ExpiresAt {
+		if err := svc.store.ExtendSession(ctx, sess.ID, lastSeen, expiresAt); err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				return time.Time{}, ErrInvalidCredential
+			}
+			return time.Time{}, fmt.Errorf("extend session: %w", err)
+		}
+	}
+	return time.Unix(expiresAt, 0), nil
+}
@@ -0,0 +1,219 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sovereign-im/sovereign/server/internal/store"
+)
+
+// newSessionTestService creates an auth Service with the given session
+// lifetime and a seeded user "u1".
+func newSessionTestService(t *testing.T, lifetime SessionLifetime) (*Service, *store.Store) {
+	t.Helper()
+	s, err := store.New(":memory:")
+	if err != nil {
+		t.Fatalf("store.New(:memory:) error: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+
+	svc, err := NewService(s, "Test Server", "localhost", []string{"http://localhost:8080"}, Policy{}, lifetime)
+	if err != nil {
+		t.Fatalf("NewService error: %v", err)
+	}
+	seedUser(t, s, "u1", "alice", "Alice")
+	return svc, s
+}
+
+// seedAgedSession creates a session for u1 that was created and last used
+// the given durations ago.
+func seedAgedSession(t *testing.T, s *store.Store, token string, age, idle time.Duration) {
+	t.Helper()
+	now := time.Now()
+	sess := &store.Session{
+		ID:         "s1",
+		UserID:     "u1",
+		TokenHash:  hashSessionToken(token),
+		CreatedAt:  now.Add(-age).Unix(),
+		ExpiresAt:  now.Add(24 * time.Hour).Unix(),
+		LastSeenAt: now.Add(-idle).Unix(),
+	}
+	if err := s.CreateSession(context.Background(), sess); err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+}
+
+func TestSessionLifetimeExpiresAt(t *testing.T) {
+	l := SessionLifetime{MaxAge: 100 * time.Second, IdleTimeout: 10 * time.Second}
+
+	tests := []struct {
+		name      string
+		createdAt int64
+		lastSeen  int64
+		want      int64
+	}{
+		{name: "idle timeout first", createdAt: 1000, lastSeen: 1000, want: 1010},
+		{name: "max age first", createdAt: 1000, lastSeen: 1095, want: 1100},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := l.expiresAt(tt.createdAt, tt.lastSeen); got != tt.want {
+				t.Errorf("expiresAt(%d, %d) = %d, want %d", tt.createdAt, tt.lastSeen, got, tt.want)
+			}
+		})
+	}
+
+	d := SessionLifetime{}.withDefaults()
+	if d.MaxAge != DefaultSessionMaxAge || d.IdleTimeout != DefaultSessionIdleTimeout {
+		t.Errorf("withDefaults() = %+v, want package defaults", d)
+	}
+}
+
+func TestValidateSessionLifetime(t *testing.T) {
+	lifetime := SessionLifetime{MaxAge: 24 * time.Hour, IdleTimeout: time.Hour}
+
+	tests := []struct {
+		name    string
+		age     time.Duration
+		idle    time.Duration
+		wantErr error
+	}{
+		{name: "active", age: 2 * time.Hour, idle: 10 * time.Minute},
+		{name: "idle too long", age: 2 * time.Hour, idle: 2 * time.Hour, wantErr: ErrSessionExpired},
+		{name: "past max age", age: 25 * time.Hour, idle: time.Minute, wantErr: ErrSessionExpired},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, s := newSessionTestService(t, lifetime)
+			ctx := context.Background()
+			seedAgedSession(t, s, "token-1", tt.age, tt.idle)
+
+			_, err := svc.ValidateSession(ctx, "token-1")
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("error = %v, want %v", err, tt.wantErr)
+				}
+				if _, err := s.GetSessionByID(ctx, "s1"); !errors.Is(err, store.ErrNotFound) {
+					t.Errorf("expired session not deleted: %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			sess, err := s.GetSessionByID(ctx, "s1")
+			if err != nil {
+				t.Fatalf("GetSessionByID: %v", err)
+			}
+			want := time.Now().Add(lifetime.IdleTimeout).Unix()
+			if sess.ExpiresAt < want-2 || sess.ExpiresAt > want+2 {
+				t.Errorf("ExpiresAt = %d, want approximately %d", sess.ExpiresAt, want)
+			}
+		})
+	}
+}
+
+func TestResumeSession(t *testing.T) {
+	tests := []struct {
+		name   string
+		rotate bool
+	}{
+		{name: "without rotation"},
+		{name: "with rotation", rotate: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, s := newSessionTestService(t, SessionLifetime{RotateOnResume: tt.rotate})
+			ctx := context.Background()
+			seedAgedSession(t, s, "token-1", time.Hour, time.Hour)
+
+			result, err := svc.ResumeSession(ctx, "token-1")
+			if err != nil {
+				t.Fatalf("ResumeSession: %v", err)
+			}
+			if result.SessionID != "s1" || result.UserID != "u1" {
+				t.Errorf("SessionID, UserID = %q, %q, want s1, u1", result.SessionID, result.UserID)
+			}
+			if !result.SessionExpiresAt.After(time.Now()) {
+				t.Errorf("SessionExpiresAt = %v, want in the future", result.SessionExpiresAt)
+			}
+
+			_, oldErr := svc.ResumeSession(ctx, "token-1")
+			if !tt.rotate {
+				if result.Token != "" {
+					t.Errorf("Token = %q, want empty", result.Token)
+				}
+				if oldErr != nil {
+					t.Errorf("resume again: %v", oldErr)
+				}
+				return
+			}
+
+			if result.Token == "" || result.Token == "token-1" {
+				t.Fatalf("Token = %q, want a new token", result.Token)
+			}
+			if !errors.Is(oldErr, ErrInvalidCredential) {
+				t.Errorf("old token: error = %v, want %v", oldErr, ErrInvalidCredential)
+			}
+			if _, err := svc.ResumeSession(ctx, result.Token); err != nil {
+				t.Errorf("new token: %v", err)
+			}
+		})
+	}
+}
+
+func TestRefreshSession(t *testing.T) {
+	lifetime := SessionLifetime{MaxAge: 24 * time.Hour, IdleTimeout: time.Hour}
+
+	tests := []struct {
+		name         string
+		seed         bool
+		idle         time.Duration
+		lastActivity time.Duration // ago
+		wantErr      error
+	}{
+		{name: "active connection extends", seed: true, idle: 2 * time.Hour, lastActivity: time.Minute},
+		{name: "idle connection expires", seed: true, idle: 2 * time.Hour, lastActivity: 2 * time.Hour, wantErr: ErrSessionExpired},
+		{name: "revoked session", wantErr: ErrInvalidCredential},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, s := newSessionTestService(t, lifetime)
+			ctx := context.Background()
+			if tt.seed {
+				seedAgedSession(t, s, "token-1", 3*time.Hour, tt.idle)
+			}
+
+			lastActivity := time.Now().Add(-tt.lastActivity)
+			expiresAt, err := svc.RefreshSession(ctx, "s1", lastActivity)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			want := lastActivity.Add(lifetime.IdleTimeout).Unix()
+			if expiresAt.Unix() != want {
+				t.Errorf("expiresAt = %d, want %d", expiresAt.Unix(), want)
+			}
+			sess, err := s.GetSessionByID(ctx, "s1")
+			if err != nil {
+				t.Fatalf("GetSessionByID: %v", err)
+			}
+			if sess.ExpiresAt != want || sess.LastSeenAt != lastActivity.Unix() {
+				t.Errorf("stored ExpiresAt, LastSeenAt = %d, %d, want %d, %d", sess.ExpiresAt, sess.LastSeenAt, want, lastActivity.Unix())
+			}
+		})
+	}
+}
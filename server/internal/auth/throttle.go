@@ -0,0 +1,223 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrTooManyAttempts is returned when an authentication attempt is refused
+// because of throttling or because too many challenges are outstanding.
+// The returned error is a *ThrottleError carrying the retry delay.
+var ErrTooManyAttempts = errors.New("too many authentication attempts")
+
+const (
+	// LoginFreeAttempts is the number of failures allowed per IP or username
+	// before backoff is applied.
+	LoginFreeAttempts = 5
+
+	// LoginBackoffBase is the lockout applied on the first failure past
+	// LoginFreeAttempts. Each further failure doubles it.
+	LoginBackoffBase = time.Second
+
+	// LoginBackoffMax caps the lockout duration.
+	LoginBackoffMax = 15 * time.Minute
+
+	// LoginFailureWindow is how long a failure is remembered. A key with no
+	// failures for this long starts over with LoginFreeAttempts.
+	LoginFailureWindow = 15 * time.Minute
+
+	// MaxChallengesPerConn caps unexpired challenges issued on one connection.
+	MaxChallengesPerConn = 3
+
+	// MaxChallengesPerIP caps unexpired challenges issued to one client IP.
+	MaxChallengesPerIP = 20
+
+	// throttleSweepThreshold is the number of tracked keys above which stale
+	// entries are pruned.
+	throttleSweepThreshold = 10000
+)
+
+// ThrottleError is returned (wrapping ErrTooManyAttempts) when an attempt is
+// refused. RetryAfter is the minimum time the client should wait.
+type ThrottleError struct {
+	RetryAfter time.Duration
+}
+
+func (e *ThrottleError) Error() string {
+	return fmt.Sprintf("%v: retry after %v", ErrTooManyAttempts, e.RetryAfter)
+}
+
+func (e *ThrottleError) Unwrap() error {
+	return ErrTooManyAttempts
+}
+
+// ClientInfo identifies the origin of an authentication attempt. Empty fields
+// are not throttled.
+type ClientInfo struct {
+	IP     string // remote IP address
+	ConnID string // WebSocket connection ID
+}
+
+// Lockout describes a throttled IP or username.
+type Lockout struct {
+	Key         string // "ip:<addr>" or "user:<username>"
+	Failures    int
+	LastFailure time.Time
+	LockedUntil time.Time
+}
+
+// throttle tracks authentication failures per key and applies exponential
+// backoff once a key exceeds LoginFreeAttempts. State is kept in memory and
+// is lost on restart.
+type throttle struct {
+	mu      sync.Mutex
+	entries map[string]*throttleEntry
+	now     func() time.Time
+}
+
+type throttleEntry struct {
+	failures    int
+	lastFailure time.Time
+	lockedUntil time.Time
+}
+
+func newThrottle() *throttle {
+	return &throttle{
+		entries: make(map[string]*throttleEntry),
+		now:     time.Now,
+	}
+}
+
+// ipKey and userKey build throttle keys. Usernames are folded so that case
+// and compatibility variations share one budget.
+func ipKey(ip string) string {
+	if ip == "" {
+		return ""
+	}
+	return "ip:" + ip
+}
+
+func userKey(username string) string {
+	if username == "" {
+		return ""
+	}
+	return "user:" + FoldUsername(username)
+}
+
+// check returns the remaining lockout across the given keys, or zero if none
+// of them is locked. Empty keys are ignored.
+func (t *throttle) check(keys ...string) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	var wait time.Duration
+	for _, k := range keys {
+		e, ok := t.entries[k]
+		if !ok || k == "" {
+			continue
+		}
+		if d := e.lockedUntil.Sub(now); d > wait {
+			wait = d
+		}
+	}
+	return wait
+}
+
+// fail records a failure for each key and returns the longest lockout
+// started as a result, or zero if all keys are still within their free
+// attempts. Empty keys are ignored.
+func (t *throttle) fail(keys ...string) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	if len(t.entries) >= throttleSweepThreshold {
+		t.sweep(now)
+	}
+
+	var lockout time.Duration
+	for _, k := range keys {
+		if k == "" {
+			continue
+		}
+		e, ok := t.entries[k]
+		if !ok || now.Sub(e.lastFailure) > LoginFailureWindow {
+			e = &throttleEntry{}
+			t.entries[k] = e
+		}
+		e.failures++
+		e.lastFailure = now
+
+		if d := backoff(e.failures); d > 0 {
+			e.lockedUntil = now.Add(d)
+			if d > lockout {
+				lockout = d
+			}
+		}
+	}
+	return lockout
+}
+
+// reset forgets all failures for the given keys.
+func (t *throttle) reset(keys ...string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, k := range keys {
+		delete(t.entries, k)
+	}
+}
+
+// lockouts returns all currently locked keys, longest lockout first.
+func (t *throttle) lockouts() []Lockout {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	var out []Lockout
+	for k, e := range t.entries {
+		if e.lockedUntil.After(now) {
+			out = append(out, Lockout{
+				Key:         k,
+				Failures:    e.failures,
+				LastFailure: e.lastFailure,
+				LockedUntil: e.lockedUntil,
+			})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].LockedUntil.Equal(out[j].LockedUntil) {
+			return out[i].LockedUntil.After(out[j].LockedUntil)
+		}
+		return out[i].Key < out[j].Key
+	})
+	return out
+}
+
+// sweep removes entries whose failures have aged out. Caller holds t.mu.
+func (t *throttle) sweep(now time.Time) {
+	for k, e := range t.entries {
+		if now.Sub(e.lastFailure) > LoginFailureWindow && !e.lockedUntil.After(now) {
+			delete(t.entries, k)
+		}
+	}
+}
+
+// backoff returns the lockout for the given failure count.
+func backoff(failures int) time.Duration {
+	over := failures - LoginFreeAttempts
+	if over <= 0 {
+		return 0
+	}
+	d := LoginBackoffBase
+	for i := 1; i < over; i++ {
+		d *= 2
+		if d >= LoginBackoffMax {
+			return LoginBackoffMax
+		}
+	}
+	return d
+}
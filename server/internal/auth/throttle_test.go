@@ -0,0 +1,111 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{failures: 0, want: 0},
+		{failures: LoginFreeAttempts, want: 0},
+		{failures: LoginFreeAttempts + 1, want: LoginBackoffBase},
+		{failures: LoginFreeAttempts + 2, want: 2 * LoginBackoffBase},
+		{failures: LoginFreeAttempts + 4, want: 8 * LoginBackoffBase},
+		{failures: LoginFreeAttempts + 100, want: LoginBackoffMax},
+	}
+	for _, tt := range tests {
+		if got := backoff(tt.failures); got != tt.want {
+			t.Errorf("backoff(%d) = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestThrottle(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	th := newThrottle()
+	th.now = func() time.Time { return now }
+
+	keys := []string{ipKey("192.0.2.1"), userKey("Alice")}
+
+	for i := 0; i < LoginFreeAttempts; i++ {
+		if d := th.fail(keys...); d != 0 {
+			t.Fatalf("failure %d: lockout = %v, want 0", i+1, d)
+		}
+	}
+	if d := th.check(keys...); d != 0 {
+		t.Fatalf("check within free attempts = %v, want 0", d)
+	}
+
+	if d := th.fail(keys...); d != LoginBackoffBase {
+		t.Fatalf("lockout = %v, want %v", d, LoginBackoffBase)
+	}
+	if d := th.check(userKey("alice")); d != LoginBackoffBase {
+		t.Errorf("check is case-insensitive: got %v, want %v", d, LoginBackoffBase)
+	}
+	if d := th.check(ipKey("192.0.2.2")); d != 0 {
+		t.Errorf("unrelated IP locked for %v", d)
+	}
+
+	got := th.lockouts()
+	if len(got) != 2 {
+		t.Fatalf("len(lockouts) = %d, want 2", len(got))
+	}
+	if got[0].Failures != LoginFreeAttempts+1 {
+		t.Errorf("Failures = %d, want %d", got[0].Failures, LoginFreeAttempts+1)
+	}
+
+	// Lockout expires.
+	now = now.Add(LoginBackoffBase)
+	if d := th.check(keys...); d != 0 {
+		t.Errorf("check after lockout = %v, want 0", d)
+	}
+	if len(th.lockouts()) != 0 {
+		t.Error("lockouts not empty after expiry")
+	}
+
+	// Next failure doubles.
+	if d := th.fail(keys...); d != 2*LoginBackoffBase {
+		t.Errorf("second lockout = %v, want %v", d, 2*LoginBackoffBase)
+	}
+
+	// Reset clears one key only.
+	th.reset(userKey("alice"))
+	if d := th.check(userKey("alice")); d != 0 {
+		t.Errorf("check after reset = %v, want 0", d)
+	}
+	if d := th.check(ipKey("192.0.2.1")); d == 0 {
+		t.Error("IP lockout cleared by username reset")
+	}
+
+	// Failures age out after the window.
+	now = now.Add(LoginFailureWindow + time.Second)
+	if d := th.fail(keys...); d != 0 {
+		t.Errorf("lockout after window = %v, want 0", d)
+	}
+}
+
+func TestThrottleIgnoresEmptyKeys(t *testing.T) {
+	th := newThrottle()
+	for i := 0; i < LoginFreeAttempts+3; i++ {
+		th.fail(ipKey(""), userKey(""))
+	}
+	if len(th.entries) != 0 {
+		t.Errorf("entries = %d, want 0", len(th.entries))
+	}
+}
+
+func TestThrottleError(t *testing.T) {
+	var err error = &ThrottleError{RetryAfter: 2 * time.Second}
+	if !errors.Is(err, ErrTooManyAttempts) {
+		t.Error("ThrottleError does not match ErrTooManyAttempts")
+	}
+	var te *ThrottleError
+	if !errors.As(err, &te) || te.RetryAfter != 2*time.Second {
+		t.Errorf("errors.As = %v, want RetryAfter 2s", te)
+	}
+}
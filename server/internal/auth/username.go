@@ -0,0 +1,112 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+var (
+	ErrInvalidUsername    = errors.New("invalid username")
+	ErrInvalidDisplayName = errors.New("invalid display name")
+)
+
+// errReservedUsername is wrapped by the error NormalizeUsername returns for
+// a reserved name, so that registration can report it like a taken one.
+var errReservedUsername = errors.New("reserved")
+
+const (
+	// MinUsernameLength and MaxUsernameLength bound a username in characters.
+	MinUsernameLength = 3
+	MaxUsernameLength = 32
+
+	// MaxDisplayNameLength bounds a display name in characters.
+	MaxDisplayNameLength = 64
+)
+
+// reservedUsernames cannot be registered. Entries are in folded form.
+var reservedUsernames = map[string]bool{
+	"admin":         true,
+	"administrator": true,
+	"root":          true,
+	"system":        true,
+	"sovereign":     true,
+	"server":        true,
+	"support":       true,
+	"security":      true,
+	"moderator":     true,
+	"help":          true,
+	"info":          true,
+	"everyone":      true,
+	"null":          true,
+	"undefined":     true,
+}
+
+// FoldUsername returns the canonical form of a username used for uniqueness
+// checks and lookups: NFKC-normalized and case-folded. It does not validate.
+func FoldUsername(username string) string {
+	return cases.Fold().String(norm.NFKC.String(username))
+}
+
+// NormalizeUsername validates a username and returns it NFKC-normalized,
+// along with its folded form. Usernames are 3-32 characters of ASCII
+// letters, digits, '.', '_' and '-', must start and end with a letter or
+// digit, and must not be reserved. Because NFKC maps compatibility
+// characters such as fullwidth letters to ASCII, look-alike names fold to
+// the same value as the names they imitate or are rejected.
+func NormalizeUsername(username string) (normalized, folded string, err error) {
+	normalized = norm.NFKC.String(username)
+
+	n := utf8.RuneCountInString(normalized)
+	if n < MinUsernameLength || n > MaxUsernameLength {
+		return "", "", fmt.Errorf("%w: must be %d to %d characters", ErrInvalidUsername, MinUsernameLength, MaxUsernameLength)
+	}
+	for i, r := range normalized {
+		switch {
+		case r < utf8.RuneSelf && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+		case r == '.' || r == '_' || r == '-':
+			if i == 0 || i == len(normalized)-1 {
+				return "", "", fmt.Errorf("%w: must start and end with a letter or digit", ErrInvalidUsername)
+			}
+		default:
+			return "", "", fmt.Errorf("%w: character %q not allowed", ErrInvalidUsername, r)
+		}
+	}
+
+	folded = cases.Fold().String(normalized)
+	if reservedUsernames[folded] {
+		return "", "", fmt.Errorf("%w: %q is %w", ErrInvalidUsername, normalized, errReservedUsername)
+	}
+	return normalized, folded, nil
+}
+
+// NormalizeDisplayName validates a display name and returns it
+// NFC-normalized with surrounding whitespace removed. Display names are
+// 1-64 characters and must not contain control or formatting characters
+// (which include bidirectional overrides), other than the zero-width joiner
+// used in emoji sequences.
+func NormalizeDisplayName(displayName string) (string, error) {
+	if !utf8.ValidString(displayName) {
+		return "", fmt.Errorf("%w: not valid UTF-8", ErrInvalidDisplayName)
+	}
+	normalized := strings.TrimSpace(norm.NFC.String(displayName))
+
+	n := utf8.RuneCountInString(normalized)
+	if n == 0 || n > MaxDisplayNameLength {
+		return "", fmt.Errorf("%w: must be 1 to %d characters", ErrInvalidDisplayName, MaxDisplayNameLength)
+	}
+	for _, r := range normalized {
+		if r == '\u200d' {
+			continue
+		}
+		if unicode.IsControl(r) || unicode.Is(unicode.Cf, r) {
+			return "", fmt.Errorf("%w: character %U not allowed", ErrInvalidDisplayName, r)
+		}
+	}
+	return normalized, nil
+}
@@ -0,0 +1,164 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeUsername(t *testing.T) {
+	tests := []struct {
+		name           string
+		input          string
+		wantNormalized string
+		wantFolded     string
+		wantErr        bool
+	}{
+		{name: "simple", input: "alice", wantNormalized: "alice", wantFolded: "alice"},
+		{name: "mixed case kept, folded lower", input: "Alice_B", wantNormalized: "Alice_B", wantFolded: "alice_b"},
+		{name: "fullwidth folds to ASCII", input: "Ａｌｉｃｅ", wantNormalized: "Alice", wantFolded: "alice"},
+		{name: "punctuation inside", input: "a.b-c_d", wantNormalized: "a.b-c_d", wantFolded: "a.b-c_d"},
+		{name: "too short", input: "ab", wantErr: true},
+		{name: "too long", input: strings.Repeat("a", MaxUsernameLength+1), wantErr: true},
+		{name: "space", input: "al ice", wantErr: true},
+		{name: "cyrillic look-alike", input: "\u0430lice", wantErr: true},
+		{name: "leading punctuation", input: ".alice", wantErr: true},
+		{name: "trailing punctuation", input: "alice-", wantErr: true},
+		{name: "reserved", input: "Administrator", wantErr: true},
+		{name: "reserved fullwidth", input: "ＲＯＯＴ", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			normalized, folded, err := NormalizeUsername(tt.input)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidUsername) {
+					t.Errorf("error = %v, want %v", err, ErrInvalidUsername)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if normalized != tt.wantNormalized || folded != tt.wantFolded {
+				t.Errorf("NormalizeUsername(%q) = %q, %q, want %q, %q", tt.input, normalized, folded, tt.wantNormalized, tt.wantFolded)
+			}
+		})
+	}
+}
+
+func TestNormalizeDisplayName(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{name: "simple", input: "Alice Wonderland", want: "Alice Wonderland"},
+		{name: "trimmed", input: "  Alice  ", want: "Alice"},
+		{name: "unicode", input: "Zoë 🦊", want: "Zoë 🦊"},
+		{name: "NFC composed", input: "Zoe\u0308", want: "Zo\u00eb"},
+		{name: "emoji ZWJ sequence", input: "\U0001F469\u200d\U0001F4BB", want: "\U0001F469\u200d\U0001F4BB"},
+		{name: "empty", input: "", wantErr: true},
+		{name: "whitespace only", input: " \t ", wantErr: true},
+		{name: "too long", input: strings.Repeat("x", MaxDisplayNameLength+1), wantErr: true},
+		{name: "control character", input: "Ali\nce", wantErr: true},
+		{name: "bidi override", input: "Alice\u202egnp.exe", wantErr: true},
+		{name: "invalid UTF-8", input: "Alice\xff", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NormalizeDisplayName(tt.input)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidDisplayName) {
+					t.Errorf("error = %v, want %v", err, ErrInvalidDisplayName)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("NormalizeDisplayName(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBeginRegistrationFoldedDuplicate(t *testing.T) {
+	svc, s := newTestService(t)
+	seedUser(t, s, "u1", "alice", "Alice")
+
+	_, err := svc.BeginRegistration(context.Background(), ClientInfo{}, "ＡＬＩＣＥ", "Impostor", false)
+	if !errors.Is(err, ErrRegistrationFailed) {
+		t.Errorf("error = %v, want %v", err, ErrRegistrationFailed)
+	}
+}
+
+func TestBeginLoginFoldedUsername(t *testing.T) {
+	svc, s := newTestService(t)
+	seedUser(t, s, "u1", "alice", "Alice")
+
+	result, err := svc.BeginLogin(context.Background(), ClientInfo{}, "ALICE")
+	if err != nil {
+		t.Fatalf("BeginLogin: %v", err)
+	}
+	// The real user's credential is offered, not a decoy.
+	if !strings.Contains(string(result.CredentialRequestOptions), "d2ViYXV0aG4tY3JlZC1pZC11MQ") {
+		t.Errorf("options do not reference the seeded credential: %s", result.CredentialRequestOptions)
+	}
+}
+
+func TestUpdateDisplayName(t *testing.T) {
+	tests := []struct {
+		name        string
+		userID      string
+		displayName string
+		disabled    bool
+		want        string
+		wantErr     error
+	}{
+		{name: "success", userID: "u1", displayName: "  Alice W. ", want: "Alice W."},
+		{name: "invalid", userID: "u1", displayName: "", wantErr: ErrInvalidDisplayName},
+		{name: "unknown user", userID: "nobody", displayName: "X", wantErr: ErrUserNotFound},
+		{name: "disabled user", userID: "u1", displayName: "X", disabled: true, wantErr: ErrAccountDisabled},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, s := newTestService(t)
+			ctx := context.Background()
+			seedUser(t, s, "u1", "alice", "Alice")
+			if tt.disabled {
+				u, _ := s.GetUserByID(ctx, "u1")
+				u.Enabled = false
+				if err := s.UpdateUser(ctx, u); err != nil {
+					t.Fatalf("UpdateUser: %v", err)
+				}
+			}
+
+			user, err := svc.UpdateDisplayName(ctx, tt.userID, tt.displayName)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if user.DisplayName != tt.want {
+				t.Errorf("DisplayName = %q, want %q", user.DisplayName, tt.want)
+			}
+			stored, err := s.GetUserByID(ctx, tt.userID)
+			if err != nil {
+				t.Fatalf("GetUserByID: %v", err)
+			}
+			if stored.DisplayName != tt.want {
+				t.Errorf("stored DisplayName = %q, want %q", stored.DisplayName, tt.want)
+			}
+		})
+	}
+}
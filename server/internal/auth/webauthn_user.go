@@ -1,7 +1,12 @@
 package auth
 
 import (
+	"crypto/hmac"
+	"crypto/sha512"
+	"fmt"
+
 	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
 
 	"github.com/sovereign-im/sovereign/server/internal/store"
 )
@@ -44,6 +49,46 @@ func newWebAuthnUser(user *store.User, creds []*store.Credential) *webauthnUser
 	}
 }
 
+// decoyCredentialIDLengths are the credential ID lengths of common
+// authenticators: security keys use 16, 32, 48 or 64 bytes, and platform
+// authenticators mostly 16 or 20.
+var decoyCredentialIDLengths = []int{16, 20, 32, 48, 64}
+
+// newDecoyWebAuthnUser creates a fake user with fake credentials for an
+// unknown username. Like a real user it may have several credentials, of
+// the ID lengths real authenticators use. Everything is derived from key and
+// the case-folded username, so repeated login attempts for the same name see
+// the same credentials.
+func newDecoyWebAuthnUser(key []byte, username string) *webauthnUser {
+	// derive returns 64 bytes, enough for the longest credential ID.
+	derive := func(label string) []byte {
+		mac := hmac.New(sha512.New, key)
+		mac.Write([]byte(label))
+		mac.Write([]byte{0})
+		mac.Write([]byte(FoldUsername(username)))
+		return mac.Sum(nil)
+	}
+
+	seed := derive("user")
+	id, _ := uuid.FromBytes(seed[:16])
+	// Most users have one passkey; some have a second or third.
+	count := 1
+	if seed[16] < 64 {
+		count = 2 + int(seed[17]&1)
+	}
+	creds := make([]webauthn.Credential, count)
+	for i := range creds {
+		n := decoyCredentialIDLengths[int(seed[18+i])%len(decoyCredentialIDLengths)]
+		creds[i] = webauthn.Credential{ID: derive(fmt.Sprintf("credential %d", i))[:n]}
+	}
+	return &webauthnUser{
+		id:          []byte(id.String()),
+		name:        username,
+		displayName: username,
+		credentials: creds,
+	}
+}
+
 // storeCredToWebAuthn converts a store.Credential to a webauthn.Credential.
 func storeCredToWebAuthn(c *store.Credential) webauthn.Credential {
 	return webauthn.Credential{
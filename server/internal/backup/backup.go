@@ -0,0 +1,202 @@
+// Package backup writes and restores backups of a SQLite database: a
+// consistent snapshot taken while the server runs, optionally compressed
+// and encrypted with a passphrase.
+package backup
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/sovereign-im/sovereign/server/internal/store"
+)
+
+// Options control how a backup is written.
+type Options struct {
+	Compress   bool   // gzip the snapshot
+	Passphrase string // encrypt with a key derived from this; empty for none
+}
+
+// SnapshotFunc writes a consistent copy of the database to path, which
+// does not exist yet. (*store.Store).Snapshot and store.SnapshotFile, bound
+// to a database path, are both SnapshotFuncs.
+type SnapshotFunc func(ctx context.Context, path string) error
+
+// Create takes a snapshot and writes it to w as a backup.
+func Create(ctx context.Context, snapshot SnapshotFunc, w io.Writer, opts Options) (*Header, error) {
+	dir, err := os.MkdirTemp("", "sovereign-backup-")
+	if err != nil {
+		return nil, fmt.Errorf("create temp dir: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "snapshot.db")
+	if err := snapshot(ctx, path); err != nil {
+		return nil, err
+	}
+	version, err := store.CheckSnapshot(ctx, path)
+	if err != nil {
+		return nil, fmt.Errorf("check snapshot: %w", err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open snapshot: %w", err)
+	}
+	defer f.Close()
+
+	h := &Header{
+		SchemaVersion: version,
+		CreatedAt:     time.Now().Unix(),
+		Compressed:    opts.Compress,
+	}
+	if err := encode(w, f, h, opts.Passphrase); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+func encode(w io.Writer, r io.Reader, h *Header, passphrase string) error {
+	if passphrase != "" {
+		if err := newEncryptedHeader(h); err != nil {
+			return fmt.Errorf("generate salt: %w", err)
+		}
+	}
+	raw := h.marshal()
+	if _, err := w.Write(raw); err != nil {
+		return fmt.Errorf("write header: %w", err)
+	}
+
+	// Each layer is closed innermost first so that everything is flushed.
+	var closers []io.Closer
+	body := w
+	if h.Encrypted {
+		aead, err := h.aead(passphrase)
+		if err != nil {
+			return fmt.Errorf("derive key: %w", err)
+		}
+		sw := newSealWriter(body, h, aead, raw)
+		closers = append(closers, sw)
+		body = sw
+	}
+	if h.Compressed {
+		zw := gzip.NewWriter(body)
+		closers = append(closers, zw)
+		body = zw
+	}
+
+	if _, err := io.Copy(body, r); err != nil {
+		return fmt.Errorf("write backup: %w", err)
+	}
+	for i := len(closers) - 1; i >= 0; i-- {
+		if err := closers[i].Close(); err != nil {
+			return fmt.Errorf("write backup: %w", err)
+		}
+	}
+	return nil
+}
+
+// ReadHeader reads the header of the backup in r without decrypting it.
+func ReadHeader(r io.Reader) (*Header, error) {
+	h, _, err := readHeader(r)
+	return h, err
+}
+
+// decode returns a reader of the snapshot in the backup body that follows
+// h in r.
+func decode(r io.Reader, h *Header, raw []byte, passphrase string) (io.Reader, error) {
+	body := r
+	if h.Encrypted {
+		if passphrase == "" {
+			return nil, ErrPassphraseRequired
+		}
+		aead, err := h.aead(passphrase)
+		if err != nil {
+			return nil, fmt.Errorf("derive key: %w", err)
+		}
+		body = newOpenReader(body, h, aead, raw)
+	}
+	if h.Compressed {
+		zr, err := gzip.NewReader(body)
+		if err != nil {
+			if errors.Is(err, ErrDecrypt) {
+				return nil, err
+			}
+			return nil, fmt.Errorf("%w: %v", ErrDecrypt, err)
+		}
+		body = zr
+	}
+	return body, nil
+}
+
+// Restore replaces the SQLite database at dbPath with the one in the
+// backup read from r. The server must not be running. The snapshot is
+// written next to dbPath and checked before it replaces anything, so a
+// failed restore leaves the existing database as it was. A backup with a
+// schema newer than this build supports is refused with
+// store.ErrSchemaTooNew; an older one is migrated when the server starts.
+func Restore(ctx context.Context, r io.Reader, dbPath, passphrase string) (*Header, error) {
+	h, raw, err := readHeader(r)
+	if err != nil {
+		return nil, err
+	}
+	if h.SchemaVersion > store.LatestSchemaVersion() {
+		return h, fmt.Errorf("backup schema version %d, supported %d: %w",
+			h.SchemaVersion, store.LatestSchemaVersion(), store.ErrSchemaTooNew)
+	}
+	body, err := decode(r, h, raw, passphrase)
+	if err != nil {
+		return h, err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(dbPath), filepath.Base(dbPath)+".restore-*")
+	if err != nil {
+		return h, fmt.Errorf("create temp file: %w", err)
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath)
+
+	if _, err := io.Copy(tmp, body); err != nil {
+		tmp.Close()
+		return h, fmt.Errorf("read backup: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return h, fmt.Errorf("sync restored database: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return h, fmt.Errorf("close restored database: %w", err)
+	}
+
+	version, err := store.CheckSnapshot(ctx, tmpPath)
+	if err != nil {
+		return h, fmt.Errorf("check restored database: %w", err)
+	}
+	if version != h.SchemaVersion {
+		return h, fmt.Errorf("restored database has schema version %d, header says %d", version, h.SchemaVersion)
+	}
+
+	if err := replaceDatabase(tmpPath, dbPath); err != nil {
+		return h, err
+	}
+	return h, nil
+}
+
+// replaceDatabase moves the restored database at tmpPath over dbPath.
+func replaceDatabase(tmpPath, dbPath string) error {
+	// A WAL left by the old database would be applied to the new one.
+	for _, suffix := range []string{"-wal", "-shm"} {
+		if err := os.Remove(dbPath + suffix); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("remove %s: %w", dbPath+suffix, err)
+		}
+	}
+	if err := os.Rename(tmpPath, dbPath); err != nil {
+		return fmt.Errorf("replace database: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,237 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sovereign-im/sovereign/server/internal/store"
+)
+
+// newTestStore returns a store with a user, and enough padding to span
+// several encrypted chunks. Checkpoints are manual, as when the server
+// replicates.
+func newTestStore(t *testing.T) *store.Store {
+	t.Helper()
+	s, err := store.Open(filepath.Join(t.TempDir(), "live.db"), store.Options{ManualCheckpoints: true})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+
+	ctx := context.Background()
+	if err := s.CreateUser(ctx, &store.User{
+		ID:          "user-1",
+		Username:    "alice",
+		DisplayName: string(bytes.Repeat([]byte("a"), 3*chunkSize)),
+		Role:        "member",
+		Enabled:     true,
+		CreatedAt:   time.Now().Unix(),
+		UpdatedAt:   time.Now().Unix(),
+	}); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	return s
+}
+
+func TestCreateRestore(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+
+	tests := []struct {
+		name string
+		opts Options
+	}{
+		{name: "plain", opts: Options{}},
+		{name: "compressed", opts: Options{Compress: true}},
+		{name: "encrypted", opts: Options{Passphrase: "correct horse"}},
+		{name: "compressed and encrypted", opts: Options{Compress: true, Passphrase: "correct horse"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			h, err := Create(ctx, s.Snapshot, &buf, tt.opts)
+			if err != nil {
+				t.Fatalf("Create: %v", err)
+			}
+			if h.SchemaVersion != store.LatestSchemaVersion() {
+				t.Errorf("SchemaVersion = %d, want %d", h.SchemaVersion, store.LatestSchemaVersion())
+			}
+			if h.Compressed != tt.opts.Compress || h.Encrypted != (tt.opts.Passphrase != "") {
+				t.Errorf("header = %+v, want options %+v", h, tt.opts)
+			}
+			if tt.opts.Passphrase != "" && bytes.Contains(buf.Bytes(), []byte("alice")) {
+				t.Error("encrypted backup contains plaintext")
+			}
+
+			got, err := ReadHeader(bytes.NewReader(buf.Bytes()))
+			if err != nil {
+				t.Fatalf("ReadHeader: %v", err)
+			}
+			if got.SchemaVersion != h.SchemaVersion || got.CreatedAt != h.CreatedAt {
+				t.Errorf("ReadHeader = %+v, want %+v", got, h)
+			}
+
+			dbPath := filepath.Join(t.TempDir(), "restored.db")
+			if _, err := Restore(ctx, bytes.NewReader(buf.Bytes()), dbPath, tt.opts.Passphrase); err != nil {
+				t.Fatalf("Restore: %v", err)
+			}
+			restored, err := store.New(dbPath)
+			if err != nil {
+				t.Fatalf("open restored: %v", err)
+			}
+			defer restored.Close()
+			u, err := restored.GetUserByID(ctx, "user-1")
+			if err != nil {
+				t.Fatalf("GetUserByID: %v", err)
+			}
+			if u.Username != "alice" || len(u.DisplayName) != 3*chunkSize {
+				t.Errorf("restored user = %q with %d byte display name", u.Username, len(u.DisplayName))
+			}
+		})
+	}
+}
+
+func TestRestoreRejects(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+
+	var encrypted bytes.Buffer
+	if _, err := Create(ctx, s.Snapshot, &encrypted, Options{Compress: true, Passphrase: "secret"}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	var plain bytes.Buffer
+	if _, err := Create(ctx, s.Snapshot, &plain, Options{}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	flip := func(b []byte, i int) []byte {
+		c := bytes.Clone(b)
+		c[i] ^= 1
+		return c
+	}
+	newer := bytes.Clone(plain.Bytes())
+	newer[9]++ // low byte of schema_version
+
+	tests := []struct {
+		name       string
+		data       []byte
+		passphrase string
+		wantErr    error
+	}{
+		{name: "not a backup", data: []byte("SQLite format 3\x00 and then some"), wantErr: ErrNotBackup},
+		{name: "empty", data: nil, wantErr: ErrNotBackup},
+		{name: "no passphrase", data: encrypted.Bytes(), wantErr: ErrPassphraseRequired},
+		{name: "wrong passphrase", data: encrypted.Bytes(), passphrase: "guess", wantErr: ErrDecrypt},
+		{name: "tampered body", data: flip(encrypted.Bytes(), encrypted.Len()-1), passphrase: "secret", wantErr: ErrDecrypt},
+		{name: "tampered header", data: flip(encrypted.Bytes(), 10), passphrase: "secret", wantErr: ErrDecrypt},
+		{name: "truncated", data: encrypted.Bytes()[:encrypted.Len()-100], passphrase: "secret", wantErr: ErrDecrypt},
+		{name: "schema too new", data: newer, wantErr: store.ErrSchemaTooNew},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dbPath := filepath.Join(t.TempDir(), "sovereign.db")
+			if err := os.WriteFile(dbPath, []byte("existing"), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			_, err := Restore(ctx, bytes.NewReader(tt.data), dbPath, tt.passphrase)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Restore error = %v, want %v", err, tt.wantErr)
+			}
+			// A failed restore leaves the database alone.
+			got, err := os.ReadFile(dbPath)
+			if err != nil || string(got) != "existing" {
+				t.Errorf("database after failed restore = %q, %v", got, err)
+			}
+			entries, _ := os.ReadDir(filepath.Dir(dbPath))
+			if len(entries) != 1 {
+				t.Errorf("restore left %d files behind", len(entries)-1)
+			}
+		})
+	}
+}
+
+func TestSchedulerBackupNow(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+	dir := filepath.Join(t.TempDir(), "backups")
+
+	sched := NewScheduler(s.Snapshot, dir, Options{Compress: true}, Schedule{Keep: 2})
+	files, err := sched.List()
+	if err != nil || len(files) != 0 {
+		t.Fatalf("List before any backup = %v, %v", files, err)
+	}
+
+	// Backups are named by the second they are taken in, so seed older
+	// ones rather than sleeping.
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"sovereign-20200101T000000Z.sbk", "sovereign-20210101T000000Z.sbk", "unrelated.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	f, err := sched.BackupNow(ctx)
+	if err != nil {
+		t.Fatalf("BackupNow: %v", err)
+	}
+	if f.Size == 0 {
+		t.Error("backup is empty")
+	}
+
+	files, err = sched.List()
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(files) != 2 || files[0].Name != f.Name || files[1].Name != "sovereign-20210101T000000Z.sbk" {
+		t.Errorf("List after pruning = %+v", files)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "unrelated.txt")); err != nil {
+		t.Errorf("pruning removed an unrelated file: %v", err)
+	}
+
+	r, err := os.Open(filepath.Join(dir, f.Name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if _, err := Restore(ctx, r, filepath.Join(t.TempDir(), "restored.db"), ""); err != nil {
+		t.Errorf("Restore scheduled backup: %v", err)
+	}
+}
+
+func TestSchedulerRun(t *testing.T) {
+	s := newTestStore(t)
+	dir := t.TempDir()
+
+	sched := NewScheduler(s.Snapshot, dir, Options{}, Schedule{})
+	go sched.Run()
+	defer sched.Stop()
+
+	sched.SetSchedule(Schedule{Interval: 10 * time.Millisecond, Keep: 1})
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		files, err := sched.List()
+		if err != nil {
+			t.Fatalf("List: %v", err)
+		}
+		if len(files) > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("no scheduled backup was taken")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := sched.Schedule(); got.Interval != 10*time.Millisecond || got.Keep != 1 {
+		t.Errorf("Schedule = %+v", got)
+	}
+}
@@ -0,0 +1,308 @@
+package backup
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// A backup file is a header followed by the body: the SQLite snapshot,
+// gzip-compressed if flagCompressed is set, then encrypted if
+// flagEncrypted is set.
+//
+// The header is
+//
+//	magic          [4]byte  "SVBK"
+//	format         uint8    formatVersion
+//	flags          uint8
+//	schema_version uint32
+//	created_at     int64    Unix seconds
+//
+// and, for encrypted backups,
+//
+//	salt           [16]byte
+//	argon2_time    uint32
+//	argon2_memory  uint32   KiB
+//	argon2_threads uint8
+//	nonce_prefix   [7]byte
+//
+// all big-endian. An encrypted body is a sequence of AES-256-GCM sealed
+// chunks of chunkSize plaintext bytes, the last one shorter and possibly
+// empty. The key is derived from the passphrase with Argon2id. Chunk i has
+// the nonce nonce_prefix || uint32(i) || last, where last is 1 for the
+// final chunk and 0 otherwise, and the whole header as additional data, so
+// chunks cannot be reordered, dropped or truncated and the header cannot
+// be altered without detection.
+
+const (
+	magic         = "SVBK"
+	formatVersion = 1
+
+	flagCompressed = 1 << 0
+	flagEncrypted  = 1 << 1
+
+	baseHeaderSize = 4 + 1 + 1 + 4 + 8
+	kdfHeaderSize  = 16 + 4 + 4 + 1 + 7
+
+	chunkSize = 64 * 1024
+)
+
+// Argon2id parameters for new backups.
+const (
+	argonTime    = 3
+	argonMemory  = 64 * 1024
+	argonThreads = 4
+)
+
+// Errors returned when reading a backup.
+var (
+	ErrNotBackup          = errors.New("not a sovereign backup")
+	ErrPassphraseRequired = errors.New("backup is encrypted; passphrase required")
+	ErrDecrypt            = errors.New("wrong passphrase or corrupted backup")
+)
+
+// Header describes a backup file.
+type Header struct {
+	SchemaVersion int
+	CreatedAt     int64
+	Compressed    bool
+	Encrypted     bool
+
+	salt        [16]byte
+	argonTime   uint32
+	argonMemory uint32
+	argonThr    uint8
+	noncePrefix [7]byte
+}
+
+func (h *Header) marshal() []byte {
+	b := make([]byte, 0, baseHeaderSize+kdfHeaderSize)
+	b = append(b, magic...)
+	b = append(b, formatVersion)
+	var flags byte
+	if h.Compressed {
+		flags |= flagCompressed
+	}
+	if h.Encrypted {
+		flags |= flagEncrypted
+	}
+	b = append(b, flags)
+	b = binary.BigEndian.AppendUint32(b, uint32(h.SchemaVersion))
+	b = binary.BigEndian.AppendUint64(b, uint64(h.CreatedAt))
+	if h.Encrypted {
+		b = append(b, h.salt[:]...)
+		b = binary.BigEndian.AppendUint32(b, h.argonTime)
+		b = binary.BigEndian.AppendUint32(b, h.argonMemory)
+		b = append(b, h.argonThr)
+		b = append(b, h.noncePrefix[:]...)
+	}
+	return b
+}
+
+// readHeader reads a header from r and returns it with its encoding.
+func readHeader(r io.Reader) (*Header, []byte, error) {
+	raw := make([]byte, baseHeaderSize, baseHeaderSize+kdfHeaderSize)
+	if _, err := io.ReadFull(r, raw); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, nil, ErrNotBackup
+		}
+		return nil, nil, fmt.Errorf("read header: %w", err)
+	}
+	if string(raw[:4]) != magic {
+		return nil, nil, ErrNotBackup
+	}
+	if raw[4] != formatVersion {
+		return nil, nil, fmt.Errorf("backup format version %d not supported", raw[4])
+	}
+	flags := raw[5]
+	if flags&^(flagCompressed|flagEncrypted) != 0 {
+		return nil, nil, fmt.Errorf("unknown backup flags %#x", flags)
+	}
+	h := &Header{
+		SchemaVersion: int(binary.BigEndian.Uint32(raw[6:10])),
+		CreatedAt:     int64(binary.BigEndian.Uint64(raw[10:18])),
+		Compressed:    flags&flagCompressed != 0,
+		Encrypted:     flags&flagEncrypted != 0,
+	}
+	if !h.Encrypted {
+		return h, raw, nil
+	}
+
+	raw = raw[:baseHeaderSize+kdfHeaderSize]
+	if _, err := io.ReadFull(r, raw[baseHeaderSize:]); err != nil {
+		return nil, nil, ErrNotBackup
+	}
+	kdf := raw[baseHeaderSize:]
+	copy(h.salt[:], kdf[0:16])
+	h.argonTime = binary.BigEndian.Uint32(kdf[16:20])
+	h.argonMemory = binary.BigEndian.Uint32(kdf[20:24])
+	h.argonThr = kdf[24]
+	copy(h.noncePrefix[:], kdf[25:32])
+	// Refuse parameters that would make opening the backup a denial of
+	// service, or that no build of this server writes.
+	if h.argonTime == 0 || h.argonTime > 16 || h.argonMemory == 0 || h.argonMemory > 1<<20 || h.argonThr == 0 {
+		return nil, nil, fmt.Errorf("unsupported key derivation parameters")
+	}
+	return h, raw, nil
+}
+
+// newEncryptedHeader returns the key derivation parameters of a new
+// encrypted backup with fresh random salt and nonce prefix.
+func newEncryptedHeader(h *Header) error {
+	h.Encrypted = true
+	h.argonTime = argonTime
+	h.argonMemory = argonMemory
+	h.argonThr = argonThreads
+	if _, err := rand.Read(h.salt[:]); err != nil {
+		return err
+	}
+	_, err := rand.Read(h.noncePrefix[:])
+	return err
+}
+
+func (h *Header) aead(passphrase string) (cipher.AEAD, error) {
+	key := argon2.IDKey([]byte(passphrase), h.salt[:], h.argonTime, h.argonMemory, h.argonThr, 32)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func (h *Header) nonce(i uint32, last bool) []byte {
+	n := make([]byte, 12)
+	copy(n, h.noncePrefix[:])
+	binary.BigEndian.PutUint32(n[7:11], i)
+	if last {
+		n[11] = 1
+	}
+	return n
+}
+
+// sealWriter encrypts what is written to it in chunks. Close seals the
+// final chunk; without it the backup cannot be read.
+type sealWriter struct {
+	w      io.Writer
+	h      *Header
+	aead   cipher.AEAD
+	ad     []byte
+	buf    []byte
+	out    []byte
+	n      uint32
+	closed bool
+}
+
+func newSealWriter(w io.Writer, h *Header, aead cipher.AEAD, ad []byte) *sealWriter {
+	return &sealWriter{
+		w:    w,
+		h:    h,
+		aead: aead,
+		ad:   ad,
+		buf:  make([]byte, 0, chunkSize),
+		out:  make([]byte, 0, chunkSize+aead.Overhead()),
+	}
+}
+
+func (s *sealWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		// A full chunk is only sealed once more data arrives, since the
+		// last chunk is sealed differently.
+		if len(s.buf) == chunkSize {
+			if err := s.seal(false); err != nil {
+				return written, err
+			}
+		}
+		n := copy(s.buf[len(s.buf):chunkSize], p)
+		s.buf = s.buf[:len(s.buf)+n]
+		p = p[n:]
+		written += n
+	}
+	return written, nil
+}
+
+func (s *sealWriter) seal(last bool) error {
+	if s.n == ^uint32(0) {
+		return errors.New("backup too large")
+	}
+	s.out = s.aead.Seal(s.out[:0], s.h.nonce(s.n, last), s.buf, s.ad)
+	s.n++
+	s.buf = s.buf[:0]
+	_, err := s.w.Write(s.out)
+	return err
+}
+
+func (s *sealWriter) Close() error {
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	return s.seal(true)
+}
+
+// openReader decrypts a body written by sealWriter.
+type openReader struct {
+	r    *bufio.Reader
+	h    *Header
+	aead cipher.AEAD
+	ad   []byte
+	in   []byte
+	buf  []byte // decrypted bytes not yet read
+	n    uint32
+	done bool
+}
+
+func newOpenReader(r io.Reader, h *Header, aead cipher.AEAD, ad []byte) *openReader {
+	return &openReader{
+		r:    bufio.NewReaderSize(r, chunkSize+aead.Overhead()+1),
+		h:    h,
+		aead: aead,
+		ad:   ad,
+		in:   make([]byte, chunkSize+aead.Overhead()),
+	}
+}
+
+func (o *openReader) Read(p []byte) (int, error) {
+	for len(o.buf) == 0 {
+		if o.done {
+			return 0, io.EOF
+		}
+		if err := o.open(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, o.buf)
+	o.buf = o.buf[n:]
+	return n, nil
+}
+
+func (o *openReader) open() error {
+	n, err := io.ReadFull(o.r, o.in)
+	last := false
+	switch {
+	case errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF):
+		last = true
+	case err != nil:
+		return fmt.Errorf("read backup: %w", err)
+	default:
+		// A full-size chunk is the last one if nothing follows it.
+		if _, err := o.r.Peek(1); errors.Is(err, io.EOF) {
+			last = true
+		}
+	}
+	plain, err := o.aead.Open(o.in[:0], o.h.nonce(o.n, last), o.in[:n], o.ad)
+	if err != nil {
+		return ErrDecrypt
+	}
+	o.n++
+	o.buf = plain
+	o.done = last
+	return nil
+}
@@ -0,0 +1,598 @@
+package backup
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sovereign-im/sovereign/server/internal/store"
+)
+
+// A replica is a directory of segments, each holding the database pages
+// that changed since the previous segment. A base segment holds every page
+// and starts a chain that the following incremental segments apply to, so
+// the database as of any segment can be rebuilt from the last base before
+// it. Segments are written every ReplicaOptions.Interval, which bounds how
+// much is lost with the database and how fine-grained point-in-time
+// restores are. An incremental segment is made of the pages committed to
+// the write-ahead log since the previous one (see wal.go), so its cost
+// follows the writes rather than the size of the database. A base, or a
+// segment after the log could not be followed, compares a copy of every
+// page with the hashes kept from the previous segment instead.
+//
+// A segment file is named by its sequence number and holds the header
+//
+//	magic      [4]byte  "SVRP"
+//	format     uint8    segmentFormat
+//	kind       uint8    segmentBase or segmentIncremental
+//	page_size  uint32
+//	page_count uint32   database size in pages after this segment
+//	seq        uint64
+//	created_at int64    Unix milliseconds
+//
+// big-endian, followed by a gzip stream of (page number uint32, page)
+// records. The gzip checksum catches corrupted and truncated segments.
+
+const (
+	segmentMagic      = "SVRP"
+	segmentFormat     = 1
+	segmentHeaderSize = 4 + 1 + 1 + 4 + 4 + 8 + 8
+	segmentSuffix     = ".seg"
+
+	segmentBase        = 1
+	segmentIncremental = 2
+)
+
+// DefaultReplicaInterval is the time between segments when
+// ReplicaOptions.Interval is zero.
+const DefaultReplicaInterval = time.Minute
+
+// ErrNoRestorePoint is returned when a replica has no segment at or before
+// the requested time.
+var ErrNoRestorePoint = errors.New("no restore point")
+
+// ReplicaOptions control how a Replicator writes segments.
+type ReplicaOptions struct {
+	Interval     time.Duration // between segments; zero means DefaultReplicaInterval
+	BaseInterval time.Duration // a new base segment is written at least this often; 0 only at start
+	Retention    time.Duration // restore points older than this are deleted; 0 keeps all
+}
+
+// PageSource is the SQLite database a Replicator copies. A *store.Store
+// opened with store.Options.ManualCheckpoints is one.
+type PageSource interface {
+	// CopyPages writes a page-for-page copy of the database to path, which
+	// does not exist yet.
+	CopyPages(ctx context.Context, path string) error
+	// WithWritesPaused calls fn with the paths of the database file and
+	// its write-ahead log while nothing can be committed.
+	WithWritesPaused(ctx context.Context, fn func(dbPath, walPath string) error) error
+	// Checkpoint copies committed pages from the log into the database
+	// file.
+	Checkpoint(ctx context.Context) error
+}
+
+type segment struct {
+	name      string
+	kind      byte
+	pageSize  int
+	pageCount int
+	seq       uint64
+	createdAt time.Time
+}
+
+// Replicator continuously copies the database to a replica directory.
+type Replicator struct {
+	src  PageSource
+	dir  string
+	opts ReplicaOptions
+	done chan struct{}
+
+	mu       sync.Mutex // held while a segment is written; guards the fields below
+	started  bool
+	nextSeq  uint64
+	pageSize int
+	hashes   [][sha256.Size]byte // of each page as of the last segment
+	lastBase time.Time
+	wal      *walCursor // where the last segment ends in the log
+	walRead  bool       // whether wal is valid
+}
+
+// NewReplicator returns a Replicator that writes segments of the database
+// src to dir.
+func NewReplicator(src PageSource, dir string, opts ReplicaOptions) *Replicator {
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultReplicaInterval
+	}
+	return &Replicator{
+		src:  src,
+		dir:  dir,
+		opts: opts,
+		done: make(chan struct{}),
+	}
+}
+
+// Run writes a segment every interval until Stop is called. It should be
+// called in a goroutine.
+func (r *Replicator) Run() {
+	ticker := time.NewTicker(r.opts.Interval)
+	defer ticker.Stop()
+	for {
+		if err := r.Sync(context.Background()); err != nil {
+			log.Printf("Replication failed: %v", err)
+		}
+		select {
+		case <-ticker.C:
+		case <-r.done:
+			return
+		}
+	}
+}
+
+// Stop stops Run.
+func (r *Replicator) Stop() {
+	close(r.done)
+}
+
+// Sync writes a segment with the pages that changed since the last one,
+// unless none did. The first segment a Replicator writes is a base, since
+// it does not know what earlier processes wrote.
+func (r *Replicator) Sync(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.started {
+		if err := os.MkdirAll(r.dir, 0o700); err != nil {
+			return fmt.Errorf("create replica dir: %w", err)
+		}
+		segs, err := listSegments(r.dir)
+		if err != nil {
+			return err
+		}
+		if len(segs) > 0 {
+			r.nextSeq = segs[len(segs)-1].seq + 1
+		}
+		r.started = true
+	}
+
+	now := time.Now()
+	base := r.hashes == nil ||
+		(r.opts.BaseInterval > 0 && now.Sub(r.lastBase) >= r.opts.BaseInterval)
+
+	// Read the log from where the last segment ended, with writes paused so
+	// that it ends with a whole transaction. Checkpointing before they
+	// resume keeps the log short, and cannot lose a frame to a restart
+	// since every frame has been read.
+	var changes *walChanges
+	var pages []walPage
+	var cur *walCursor
+	fromWAL := false
+	followed := r.walRead
+	r.walRead = false // until a segment covers the log up to cur
+	err := r.src.WithWritesPaused(ctx, func(_, walPath string) error {
+		var err error
+		changes, cur, err = readWAL(walPath, r.wal)
+		if err != nil {
+			return err
+		}
+		fromWAL = !base && followed && changes != nil && (cur == nil || cur.pageSize == r.pageSize)
+		if fromWAL {
+			if pages, err = readWALPages(walPath, changes, r.pageSize); err != nil {
+				return err
+			}
+		}
+		return r.src.Checkpoint(ctx)
+	})
+	if err != nil {
+		return err
+	}
+
+	if fromWAL {
+		err = r.syncPages(now, pages, changes.pageCount)
+	} else {
+		err = r.syncCopy(ctx, now, base)
+	}
+	if err != nil {
+		return err
+	}
+	r.wal, r.walRead = cur, true
+	return nil
+}
+
+// syncPages writes an incremental segment of pages read from the log,
+// leaving out those that did not change after all. pageCount is the
+// database size after them, or zero if nothing was committed.
+func (r *Replicator) syncPages(now time.Time, pages []walPage, pageCount int) error {
+	if pageCount == 0 {
+		return nil
+	}
+	hashes := make([][sha256.Size]byte, pageCount)
+	copy(hashes, r.hashes)
+	seg := r.newSegment(now, segmentIncremental, r.pageSize, pageCount)
+	changed := 0
+	err := r.writeSegment(seg, func(put func(pgno int, page []byte) error) error {
+		for _, p := range pages {
+			i := int(p.pgno) - 1
+			h := sha256.Sum256(p.data)
+			if i < len(r.hashes) && h == r.hashes[i] {
+				continue
+			}
+			hashes[i] = h
+			if err := put(int(p.pgno), p.data); err != nil {
+				return err
+			}
+			changed++
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return r.commitSegment(now, seg, hashes, changed)
+}
+
+// syncCopy writes a segment of the pages of a copy of the database that
+// differ from the last segment, or all of them for a base.
+func (r *Replicator) syncCopy(ctx context.Context, now time.Time, base bool) error {
+	tmp := filepath.Join(r.dir, "copy.db.tmp")
+	os.Remove(tmp)
+	defer os.Remove(tmp)
+	defer os.Remove(tmp + "-journal")
+	if err := r.src.CopyPages(ctx, tmp); err != nil {
+		return err
+	}
+
+	f, err := os.Open(tmp)
+	if err != nil {
+		return fmt.Errorf("open copy: %w", err)
+	}
+	defer f.Close()
+	pageSize, pageCount, err := pageGeometry(f)
+	if err != nil {
+		return err
+	}
+
+	kind := byte(segmentIncremental)
+	if base || pageSize != r.pageSize {
+		kind = segmentBase
+	}
+	seg := r.newSegment(now, kind, pageSize, pageCount)
+	hashes := make([][sha256.Size]byte, pageCount)
+	changed := 0
+	err = r.writeSegment(seg, func(put func(pgno int, page []byte) error) error {
+		pages := bufio.NewReaderSize(f, pageSize)
+		page := make([]byte, pageSize)
+		for i := range hashes {
+			if _, err := io.ReadFull(pages, page); err != nil {
+				return fmt.Errorf("read copy: %w", err)
+			}
+			hashes[i] = sha256.Sum256(page)
+			if kind == segmentIncremental && i < len(r.hashes) && hashes[i] == r.hashes[i] {
+				continue
+			}
+			if err := put(i+1, page); err != nil {
+				return err
+			}
+			changed++
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return r.commitSegment(now, seg, hashes, changed)
+}
+
+func (r *Replicator) newSegment(now time.Time, kind byte, pageSize, pageCount int) *segment {
+	return &segment{
+		name:      segmentName(r.nextSeq),
+		kind:      kind,
+		pageSize:  pageSize,
+		pageCount: pageCount,
+		seq:       r.nextSeq,
+		createdAt: now,
+	}
+}
+
+// writeSegment writes seg to a temporary file next to its path, with the
+// pages fill passes to put.
+func (r *Replicator) writeSegment(seg *segment, fill func(put func(pgno int, page []byte) error) error) (err error) {
+	path := filepath.Join(r.dir, seg.name+".tmp")
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return fmt.Errorf("create segment: %w", err)
+	}
+	defer f.Close()
+	defer func() {
+		if err != nil {
+			os.Remove(path)
+		}
+	}()
+
+	if _, err := f.Write(seg.marshal()); err != nil {
+		return fmt.Errorf("write segment: %w", err)
+	}
+	zw := gzip.NewWriter(f)
+	err = fill(func(pgno int, page []byte) error {
+		var rec [4]byte
+		binary.BigEndian.PutUint32(rec[:], uint32(pgno))
+		if _, err := zw.Write(rec[:]); err != nil {
+			return fmt.Errorf("write segment: %w", err)
+		}
+		if _, err := zw.Write(page); err != nil {
+			return fmt.Errorf("write segment: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("write segment: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		return fmt.Errorf("sync segment: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("close segment: %w", err)
+	}
+	return nil
+}
+
+// commitSegment moves the segment written by writeSegment into place,
+// unless it is an increment without changes, and records hashes as the
+// pages as of it.
+func (r *Replicator) commitSegment(now time.Time, seg *segment, hashes [][sha256.Size]byte, changed int) error {
+	tmp := filepath.Join(r.dir, seg.name+".tmp")
+	defer os.Remove(tmp)
+	if changed == 0 && seg.kind == segmentIncremental && seg.pageCount == len(r.hashes) {
+		return nil
+	}
+	if err := os.Rename(tmp, filepath.Join(r.dir, seg.name)); err != nil {
+		return fmt.Errorf("rename segment: %w", err)
+	}
+
+	r.nextSeq++
+	r.pageSize = seg.pageSize
+	r.hashes = hashes
+	if seg.kind == segmentBase {
+		r.lastBase = now
+	}
+	if err := r.prune(now); err != nil {
+		log.Printf("Failed to delete old replica segments: %v", err)
+	}
+	return nil
+}
+
+// prune deletes the segments that are only needed to restore to points
+// older than the retention period: everything before the last base
+// written before it.
+func (r *Replicator) prune(now time.Time) error {
+	if r.opts.Retention <= 0 {
+		return nil
+	}
+	segs, err := listSegments(r.dir)
+	if err != nil {
+		return err
+	}
+	cutoff := now.Add(-r.opts.Retention)
+	keepFrom := -1
+	for i, seg := range segs {
+		if seg.kind == segmentBase && !seg.createdAt.After(cutoff) {
+			keepFrom = i
+		}
+	}
+	for _, seg := range segs[:max(keepFrom, 0)] {
+		if err := os.Remove(filepath.Join(r.dir, seg.name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RestoreReplica replaces the SQLite database at dbPath with the database
+// as of the last segment in the replica directory written at or before
+// the time at, or the latest if at is zero, and returns that segment's
+// time. The server must not be running. As with Restore, a failed restore
+// leaves the existing database as it was.
+func RestoreReplica(ctx context.Context, dir, dbPath string, at time.Time) (time.Time, error) {
+	segs, err := listSegments(dir)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if len(segs) == 0 {
+		return time.Time{}, fmt.Errorf("replica %s is empty: %w", dir, ErrNoRestorePoint)
+	}
+
+	last := len(segs) - 1
+	if !at.IsZero() {
+		last = -1
+		for i, seg := range segs {
+			if !seg.createdAt.After(at) {
+				last = i
+			}
+		}
+	}
+	first := -1
+	for i := last; i >= 0; i-- {
+		if segs[i].kind == segmentBase {
+			first = i
+			break
+		}
+	}
+	if first < 0 {
+		earliest := "none"
+		for _, seg := range segs {
+			if seg.kind == segmentBase {
+				earliest = seg.createdAt.UTC().Format(time.RFC3339Nano)
+				break
+			}
+		}
+		return time.Time{}, fmt.Errorf("%w at or before %s; earliest is %s",
+			ErrNoRestorePoint, at.UTC().Format(time.RFC3339), earliest)
+	}
+	chain := segs[first : last+1]
+
+	tmp, err := os.CreateTemp(filepath.Dir(dbPath), filepath.Base(dbPath)+".restore-*")
+	if err != nil {
+		return time.Time{}, fmt.Errorf("create temp file: %w", err)
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath)
+
+	for i, seg := range chain {
+		if i > 0 && (seg.seq != chain[i-1].seq+1 || seg.pageSize != chain[0].pageSize) {
+			tmp.Close()
+			return time.Time{}, fmt.Errorf("replica is missing segments before %s", seg.name)
+		}
+		if err := applySegment(tmp, filepath.Join(dir, seg.name), seg); err != nil {
+			tmp.Close()
+			return time.Time{}, fmt.Errorf("segment %s: %w", seg.name, err)
+		}
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return time.Time{}, fmt.Errorf("sync restored database: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return time.Time{}, fmt.Errorf("close restored database: %w", err)
+	}
+
+	if _, err := store.CheckSnapshot(ctx, tmpPath); err != nil {
+		return time.Time{}, fmt.Errorf("check restored database: %w", err)
+	}
+	if err := replaceDatabase(tmpPath, dbPath); err != nil {
+		return time.Time{}, err
+	}
+	return chain[len(chain)-1].createdAt, nil
+}
+
+func applySegment(db *os.File, path string, seg segment) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := f.Seek(segmentHeaderSize, io.SeekStart); err != nil {
+		return err
+	}
+	zr, err := gzip.NewReader(bufio.NewReader(f))
+	if err != nil {
+		return err
+	}
+
+	rec := make([]byte, 4+seg.pageSize)
+	for {
+		if _, err := io.ReadFull(zr, rec); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return err
+		}
+		pgno := int(binary.BigEndian.Uint32(rec))
+		if pgno < 1 || pgno > seg.pageCount {
+			return fmt.Errorf("page %d out of range", pgno)
+		}
+		if _, err := db.WriteAt(rec[4:], int64(pgno-1)*int64(seg.pageSize)); err != nil {
+			return err
+		}
+	}
+	return db.Truncate(int64(seg.pageCount) * int64(seg.pageSize))
+}
+
+// pageGeometry returns the page size and page count of the SQLite database
+// file f.
+func pageGeometry(f *os.File) (pageSize, pageCount int, err error) {
+	var hdr [18]byte
+	if _, err := f.ReadAt(hdr[:], 0); err != nil {
+		return 0, 0, fmt.Errorf("read database header: %w", err)
+	}
+	if string(hdr[:16]) != "SQLite format 3\x00" {
+		return 0, 0, errors.New("copy is not a SQLite database")
+	}
+	pageSize = int(binary.BigEndian.Uint16(hdr[16:18]))
+	if pageSize == 1 {
+		pageSize = 65536
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return 0, 0, fmt.Errorf("stat copy: %w", err)
+	}
+	if pageSize < 512 || info.Size()%int64(pageSize) != 0 {
+		return 0, 0, fmt.Errorf("copy size %d is not a multiple of page size %d", info.Size(), pageSize)
+	}
+	return pageSize, int(info.Size() / int64(pageSize)), nil
+}
+
+func segmentName(seq uint64) string {
+	return fmt.Sprintf("%016d%s", seq, segmentSuffix)
+}
+
+func (s *segment) marshal() []byte {
+	b := make([]byte, 0, segmentHeaderSize)
+	b = append(b, segmentMagic...)
+	b = append(b, segmentFormat, s.kind)
+	b = binary.BigEndian.AppendUint32(b, uint32(s.pageSize))
+	b = binary.BigEndian.AppendUint32(b, uint32(s.pageCount))
+	b = binary.BigEndian.AppendUint64(b, s.seq)
+	b = binary.BigEndian.AppendUint64(b, uint64(s.createdAt.UnixMilli()))
+	return b
+}
+
+func readSegmentHeader(path string) (*segment, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var b [segmentHeaderSize]byte
+	if _, err := io.ReadFull(f, b[:]); err != nil {
+		return nil, fmt.Errorf("%s: not a replica segment", path)
+	}
+	if string(b[:4]) != segmentMagic || b[4] != segmentFormat ||
+		(b[5] != segmentBase && b[5] != segmentIncremental) {
+		return nil, fmt.Errorf("%s: not a replica segment", path)
+	}
+	return &segment{
+		name:      filepath.Base(path),
+		kind:      b[5],
+		pageSize:  int(binary.BigEndian.Uint32(b[6:10])),
+		pageCount: int(binary.BigEndian.Uint32(b[10:14])),
+		seq:       binary.BigEndian.Uint64(b[14:22]),
+		createdAt: time.UnixMilli(int64(binary.BigEndian.Uint64(b[22:30]))),
+	}, nil
+}
+
+// listSegments returns the segments in dir in sequence order.
+func listSegments(dir string) ([]segment, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("list replica: %w", err)
+	}
+	var segs []segment
+	for _, e := range entries {
+		if !e.Type().IsRegular() || !strings.HasSuffix(e.Name(), segmentSuffix) {
+			continue
+		}
+		seg, err := readSegmentHeader(filepath.Join(dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		segs = append(segs, *seg)
+	}
+	sort.Slice(segs, func(i, j int) bool { return segs[i].seq < segs[j].seq })
+	return segs, nil
+}
@@ -0,0 +1,295 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sovereign-im/sovereign/server/internal/store"
+)
+
+func setDisplayName(t *testing.T, s *store.Store, name string) {
+	t.Helper()
+	if _, err := s.DB().Exec(`UPDATE "user" SET display_name = ? WHERE id = 'user-1'`, name); err != nil {
+		t.Fatalf("update display name: %v", err)
+	}
+}
+
+func restoredDisplayName(t *testing.T, dbPath string) string {
+	t.Helper()
+	s, err := store.New(dbPath)
+	if err != nil {
+		t.Fatalf("open restored: %v", err)
+	}
+	defer s.Close()
+	u, err := s.GetUserByID(context.Background(), "user-1")
+	if err != nil {
+		t.Fatalf("GetUserByID: %v", err)
+	}
+	return u.DisplayName
+}
+
+// syncAt syncs and returns a time at or after the segment written.
+func syncAt(t *testing.T, r *Replicator) time.Time {
+	t.Helper()
+	if err := r.Sync(context.Background()); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	at := time.Now()
+	// Segment times have millisecond resolution.
+	time.Sleep(2 * time.Millisecond)
+	return at
+}
+
+func TestReplicaPointInTime(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+	dir := t.TempDir()
+	before := time.Now().Add(-time.Second)
+
+	r := NewReplicator(s, dir, ReplicaOptions{})
+	setDisplayName(t, s, "v1")
+	t1 := syncAt(t, r)
+
+	setDisplayName(t, s, "v2")
+	// Grow the database so that an increment adds pages.
+	for i := range 200 {
+		e := &store.AuditEvent{EventType: "test", Detail: fmt.Sprintf("event %d", i), CreatedAt: time.Now().Unix()}
+		if err := s.InsertAuditEvent(ctx, e); err != nil {
+			t.Fatalf("InsertAuditEvent: %v", err)
+		}
+	}
+	t2 := syncAt(t, r)
+	syncAt(t, r) // nothing changed
+
+	setDisplayName(t, s, "v3")
+	syncAt(t, r)
+
+	segs, err := listSegments(dir)
+	if err != nil {
+		t.Fatalf("listSegments: %v", err)
+	}
+	if len(segs) != 3 || segs[0].kind != segmentBase || segs[1].kind != segmentIncremental || segs[2].kind != segmentIncremental {
+		t.Fatalf("segments = %+v, want a base and two increments", segs)
+	}
+	baseInfo, _ := os.Stat(filepath.Join(dir, segs[0].name))
+	lastInfo, _ := os.Stat(filepath.Join(dir, segs[2].name))
+	if lastInfo.Size() >= baseInfo.Size() {
+		t.Errorf("increment is %d bytes, base %d; want only changed pages", lastInfo.Size(), baseInfo.Size())
+	}
+
+	tests := []struct {
+		name string
+		at   time.Time
+		want string
+	}{
+		{name: "first", at: t1, want: "v1"},
+		{name: "second", at: t2, want: "v2"},
+		{name: "latest", at: time.Time{}, want: "v3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dbPath := filepath.Join(t.TempDir(), "restored.db")
+			if _, err := RestoreReplica(ctx, dir, dbPath, tt.at); err != nil {
+				t.Fatalf("RestoreReplica: %v", err)
+			}
+			if got := restoredDisplayName(t, dbPath); got != tt.want {
+				t.Errorf("display name = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	dbPath := filepath.Join(t.TempDir(), "restored.db")
+	if _, err := RestoreReplica(ctx, dir, dbPath, before); !errors.Is(err, ErrNoRestorePoint) {
+		t.Errorf("RestoreReplica before the first segment error = %v, want ErrNoRestorePoint", err)
+	}
+
+	// A new replicator, as after a restart, starts with a base.
+	r2 := NewReplicator(s, dir, ReplicaOptions{})
+	syncAt(t, r2)
+	segs, _ = listSegments(dir)
+	if last := segs[len(segs)-1]; last.seq != 3 || last.kind != segmentBase {
+		t.Errorf("segment after restart = %+v, want base 3", last)
+	}
+}
+
+// countingSource counts the full copies a Replicator takes.
+type countingSource struct {
+	*store.Store
+	copies int
+}
+
+func (c *countingSource) CopyPages(ctx context.Context, path string) error {
+	c.copies++
+	return c.Store.CopyPages(ctx, path)
+}
+
+func TestReplicaReadsLog(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+	src := &countingSource{Store: s}
+	dir := t.TempDir()
+
+	insertEvents := func(n int) {
+		t.Helper()
+		for i := range n {
+			e := &store.AuditEvent{EventType: "test", Detail: fmt.Sprintf("event %d", i), CreatedAt: time.Now().Unix()}
+			if err := s.InsertAuditEvent(ctx, e); err != nil {
+				t.Fatalf("InsertAuditEvent: %v", err)
+			}
+		}
+	}
+	checkRestore := func(wantName string, wantEvents int) {
+		t.Helper()
+		dbPath := filepath.Join(t.TempDir(), "restored.db")
+		if _, err := RestoreReplica(ctx, dir, dbPath, time.Time{}); err != nil {
+			t.Fatalf("RestoreReplica: %v", err)
+		}
+		if got := restoredDisplayName(t, dbPath); got != wantName {
+			t.Errorf("display name = %q, want %q", got, wantName)
+		}
+		restored, err := store.New(dbPath)
+		if err != nil {
+			t.Fatalf("open restored: %v", err)
+		}
+		defer restored.Close()
+		if n, err := restored.CountAuditEvents(ctx, "test"); err != nil || n != wantEvents {
+			t.Errorf("restored audit events = %d, %v; want %d", n, err, wantEvents)
+		}
+	}
+
+	r := NewReplicator(src, dir, ReplicaOptions{})
+	syncAt(t, r)
+	if src.copies != 1 {
+		t.Fatalf("copies after the base = %d, want 1", src.copies)
+	}
+
+	// Each sync checkpoints, so the log is restarted by the next write;
+	// following it across restarts needs no copy.
+	for i := range 3 {
+		setDisplayName(t, s, fmt.Sprintf("v%d", i))
+		insertEvents(100)
+		syncAt(t, r)
+	}
+	syncAt(t, r) // nothing changed
+	if src.copies != 1 {
+		t.Errorf("copies = %d, want increments read from the log", src.copies)
+	}
+	segs, _ := listSegments(dir)
+	if len(segs) != 4 {
+		t.Errorf("segments = %d, want a base and three increments", len(segs))
+	}
+	checkRestore("v2", 300)
+
+	// A checkpoint the replicator did not run can take frames it has not
+	// read, so the next segment is made from a copy.
+	setDisplayName(t, s, "v3")
+	if _, err := s.DB().Exec(`PRAGMA wal_checkpoint(TRUNCATE)`); err != nil {
+		t.Fatalf("checkpoint: %v", err)
+	}
+	syncAt(t, r)
+	if src.copies != 2 {
+		t.Errorf("copies after an outside checkpoint = %d, want 2", src.copies)
+	}
+	setDisplayName(t, s, "v4")
+	insertEvents(10)
+	syncAt(t, r)
+	if src.copies != 2 {
+		t.Errorf("copies after catching up = %d, want 2", src.copies)
+	}
+	checkRestore("v4", 310)
+}
+
+func TestReplicaRetention(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+	dir := t.TempDir()
+
+	r := NewReplicator(s, dir, ReplicaOptions{BaseInterval: time.Nanosecond, Retention: time.Nanosecond})
+	for i := range 3 {
+		setDisplayName(t, s, fmt.Sprintf("v%d", i))
+		syncAt(t, r)
+	}
+
+	segs, err := listSegments(dir)
+	if err != nil {
+		t.Fatalf("listSegments: %v", err)
+	}
+	if len(segs) != 1 || segs[0].seq != 2 {
+		t.Errorf("segments = %+v, want only the latest base", segs)
+	}
+	dbPath := filepath.Join(t.TempDir(), "restored.db")
+	if _, err := RestoreReplica(ctx, dir, dbPath, time.Time{}); err != nil {
+		t.Fatalf("RestoreReplica: %v", err)
+	}
+	if got := restoredDisplayName(t, dbPath); got != "v2" {
+		t.Errorf("display name = %q, want v2", got)
+	}
+}
+
+func TestRestoreReplicaRejects(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+
+	newReplica := func(t *testing.T) (string, []segment) {
+		dir := t.TempDir()
+		r := NewReplicator(s, dir, ReplicaOptions{})
+		for i := range 3 {
+			setDisplayName(t, s, fmt.Sprintf("v%d", i))
+			syncAt(t, r)
+		}
+		segs, err := listSegments(dir)
+		if err != nil || len(segs) != 3 {
+			t.Fatalf("listSegments = %d segments, %v", len(segs), err)
+		}
+		return dir, segs
+	}
+
+	tests := []struct {
+		name   string
+		damage func(t *testing.T, dir string, segs []segment)
+	}{
+		{
+			name: "missing increment",
+			damage: func(t *testing.T, dir string, segs []segment) {
+				os.Remove(filepath.Join(dir, segs[1].name))
+			},
+		},
+		{
+			name: "truncated segment",
+			damage: func(t *testing.T, dir string, segs []segment) {
+				path := filepath.Join(dir, segs[2].name)
+				info, _ := os.Stat(path)
+				os.Truncate(path, info.Size()-10)
+			},
+		},
+		{
+			name: "missing base",
+			damage: func(t *testing.T, dir string, segs []segment) {
+				os.Remove(filepath.Join(dir, segs[0].name))
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, segs := newReplica(t)
+			tt.damage(t, dir, segs)
+
+			dbPath := filepath.Join(t.TempDir(), "sovereign.db")
+			if err := os.WriteFile(dbPath, []byte("existing"), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := RestoreReplica(ctx, dir, dbPath, time.Time{}); err == nil {
+				t.Fatal("RestoreReplica succeeded")
+			}
+			if got, err := os.ReadFile(dbPath); err != nil || string(got) != "existing" {
+				t.Errorf("database after failed restore = %q, %v", got, err)
+			}
+		})
+	}
+}
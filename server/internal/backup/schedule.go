@@ -0,0 +1,226 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	filePrefix = "sovereign-"
+	fileSuffix = ".sbk"
+	fileTime   = "20060102T150405Z"
+)
+
+// Schedule is how often the Scheduler backs up and how many backups it
+// keeps.
+type Schedule struct {
+	Interval time.Duration // 0 disables scheduled backups
+	Keep     int           // older backups are deleted; 0 keeps all
+}
+
+// File describes a backup in the Scheduler's directory.
+type File struct {
+	Name      string
+	Size      int64
+	CreatedAt time.Time
+}
+
+// Scheduler writes backups to a directory, on a schedule and on demand.
+type Scheduler struct {
+	snapshot SnapshotFunc
+	dir      string
+	opts     Options
+
+	mu       sync.Mutex // guards schedule and last
+	schedule Schedule
+	last     time.Time
+
+	running sync.Mutex // held while a backup is written
+	changed chan struct{}
+	done    chan struct{}
+}
+
+// NewScheduler returns a Scheduler that writes backups of snapshot to dir.
+func NewScheduler(snapshot SnapshotFunc, dir string, opts Options, schedule Schedule) *Scheduler {
+	return &Scheduler{
+		snapshot: snapshot,
+		dir:      dir,
+		opts:     opts,
+		schedule: schedule,
+		changed:  make(chan struct{}, 1),
+		done:     make(chan struct{}),
+	}
+}
+
+// Run backs up on the schedule until Stop is called. It should be called
+// in a goroutine.
+func (s *Scheduler) Run() {
+	timer := time.NewTimer(0)
+	if !timer.Stop() {
+		<-timer.C
+	}
+	defer timer.Stop()
+
+	for {
+		s.mu.Lock()
+		sched, last := s.schedule, s.last
+		s.mu.Unlock()
+
+		var wait <-chan time.Time
+		if sched.Interval > 0 {
+			next := time.Until(last.Add(sched.Interval))
+			if last.IsZero() {
+				next = sched.Interval
+			}
+			timer.Reset(max(next, 0))
+			wait = timer.C
+		}
+
+		select {
+		case <-wait:
+			if _, err := s.BackupNow(context.Background()); err != nil {
+				log.Printf("Scheduled backup failed: %v", err)
+				// Don't retry in a tight loop.
+				s.mu.Lock()
+				s.last = time.Now()
+				s.mu.Unlock()
+			}
+		case <-s.changed:
+		case <-s.done:
+			return
+		}
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+	}
+}
+
+// Stop stops Run.
+func (s *Scheduler) Stop() {
+	close(s.done)
+}
+
+// Schedule returns the current schedule.
+func (s *Scheduler) Schedule() Schedule {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.schedule
+}
+
+// SetSchedule changes the schedule. The next backup is due one interval
+// after the last.
+func (s *Scheduler) SetSchedule(sched Schedule) {
+	s.mu.Lock()
+	s.schedule = sched
+	s.mu.Unlock()
+	select {
+	case s.changed <- struct{}{}:
+	default:
+	}
+}
+
+// BackupNow writes a backup, then deletes the oldest beyond the number to
+// keep. Only one backup is written at a time.
+func (s *Scheduler) BackupNow(ctx context.Context) (*File, error) {
+	s.running.Lock()
+	defer s.running.Unlock()
+
+	if err := os.MkdirAll(s.dir, 0o700); err != nil {
+		return nil, fmt.Errorf("create backup dir: %w", err)
+	}
+	now := time.Now().UTC()
+	name := filePrefix + now.Format(fileTime) + fileSuffix
+	path := filepath.Join(s.dir, name)
+
+	f, err := os.OpenFile(path+".tmp", os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("create backup file: %w", err)
+	}
+	defer os.Remove(path + ".tmp")
+	if _, err := Create(ctx, s.snapshot, f, s.opts); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("sync backup file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return nil, fmt.Errorf("close backup file: %w", err)
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return nil, fmt.Errorf("rename backup file: %w", err)
+	}
+
+	s.mu.Lock()
+	s.last = now
+	keep := s.schedule.Keep
+	s.mu.Unlock()
+
+	if err := s.prune(keep); err != nil {
+		log.Printf("Failed to delete old backups: %v", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("stat backup file: %w", err)
+	}
+	return &File{Name: name, Size: info.Size(), CreatedAt: now}, nil
+}
+
+// List returns the backups in the directory, newest first.
+func (s *Scheduler) List() ([]File, error) {
+	entries, err := os.ReadDir(s.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return []File{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("list backups: %w", err)
+	}
+
+	files := []File{}
+	for _, e := range entries {
+		name := e.Name()
+		if !e.Type().IsRegular() || !strings.HasPrefix(name, filePrefix) || !strings.HasSuffix(name, fileSuffix) {
+			continue
+		}
+		created, err := time.Parse(fileTime, strings.TrimSuffix(strings.TrimPrefix(name, filePrefix), fileSuffix))
+		if err != nil {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, File{Name: name, Size: info.Size(), CreatedAt: created})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].CreatedAt.After(files[j].CreatedAt) })
+	return files, nil
+}
+
+func (s *Scheduler) prune(keep int) error {
+	if keep <= 0 {
+		return nil
+	}
+	files, err := s.List()
+	if err != nil {
+		return err
+	}
+	for _, f := range files[min(keep, len(files)):] {
+		if err := os.Remove(filepath.Join(s.dir, f.Name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,212 @@
+package backup
+
+import (
+	"bufio"
+	"cmp"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+)
+
+// A Replicator learns which pages changed from SQLite's write-ahead log
+// (https://www.sqlite.org/fileformat.html#the_write_ahead_log). The log
+// starts with the header
+//
+//	magic      uint32  walMagic, or walMagic|1 for big-endian checksums
+//	version    uint32
+//	page_size  uint32
+//	ckpt_seq   uint32  incremented each time the log is restarted
+//	salt       [2]uint32
+//	checksum   [2]uint32
+//
+// followed by frames of a 24-byte header (page number, database size in
+// pages for the last frame of a transaction or zero, salt, cumulative
+// checksum) and a page. A frame is valid while its salt matches the header
+// and its checksum matches; the first invalid frame ends the log, since a
+// restarted log is written over the old one.
+//
+// The log only grows between checkpoints, and is only restarted after a
+// checkpoint has copied all of it into the database file. With automatic
+// checkpoints off (store.Options.ManualCheckpoints) the Replicator runs
+// every checkpoint itself, after reading the log to its end, so no frame
+// is restarted away unread.
+
+const (
+	walMagic           = 0x377f0682
+	walHeaderSize      = 32
+	walFrameHeaderSize = 24
+)
+
+// walCursor is how far a WAL has been read.
+type walCursor struct {
+	ckptSeq  uint32
+	salt     [2]uint32
+	pageSize int
+	frames   int64     // committed frames read
+	checksum [2]uint32 // of the header and those frames
+}
+
+// walChanges are the transactions committed to a WAL after a cursor.
+type walChanges struct {
+	pages     map[uint32]int64 // page number to the offset of its last frame
+	pageCount int              // database size after the last commit
+}
+
+// readWAL reads the transactions committed to the WAL at path after cur.
+// cur is nil when the log has not been read, or was empty when it last
+// was. It returns nil changes when frames may have been missed since cur,
+// because the log was restarted or rewritten by someone else; the cursor
+// it returns is at the end of the log either way. The caller must hold the
+// write lock, so that nothing is appended meanwhile.
+func readWAL(path string, cur *walCursor) (*walChanges, *walCursor, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return emptyWAL(cur), nil, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("open WAL: %w", err)
+	}
+	defer f.Close()
+
+	var hdr [walHeaderSize]byte
+	if _, err := io.ReadFull(f, hdr[:]); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			// A log truncated by a checkpoint is rewritten from the start
+			// by the next transaction.
+			return emptyWAL(cur), nil, nil
+		}
+		return nil, nil, fmt.Errorf("read WAL header: %w", err)
+	}
+	magic := binary.BigEndian.Uint32(hdr[0:4])
+	if magic&^1 != walMagic {
+		return nil, nil, errors.New("not a SQLite WAL")
+	}
+	order := binary.ByteOrder(binary.LittleEndian)
+	if magic&1 != 0 {
+		order = binary.BigEndian
+	}
+	start := &walCursor{
+		ckptSeq: binary.BigEndian.Uint32(hdr[12:16]),
+		salt: [2]uint32{
+			binary.BigEndian.Uint32(hdr[16:20]),
+			binary.BigEndian.Uint32(hdr[20:24]),
+		},
+		pageSize: int(binary.BigEndian.Uint32(hdr[8:12])),
+	}
+	start.checksum = walChecksum(order, hdr[:24], [2]uint32{})
+	if start.checksum != [2]uint32{binary.BigEndian.Uint32(hdr[24:28]), binary.BigEndian.Uint32(hdr[28:32])} {
+		// SQLite ignores a log whose header is damaged.
+		return emptyWAL(cur), nil, nil
+	}
+
+	// Pick up where cur left off in the same log, or read all of a log
+	// restarted once since, which only the Replicator's own checkpoint
+	// allows. Anything else means frames may have been copied into the
+	// database file and written over without being read.
+	complete := true
+	switch {
+	case cur == nil:
+	case start.ckptSeq == cur.ckptSeq && start.salt == cur.salt && start.pageSize == cur.pageSize:
+		start = cur
+	case start.ckptSeq == cur.ckptSeq+1:
+	default:
+		complete = false
+	}
+
+	changes := &walChanges{pages: make(map[uint32]int64)}
+	next := *start
+	pending := make(map[uint32]int64)
+	frameSize := int64(walFrameHeaderSize + start.pageSize)
+	if _, err := f.Seek(walHeaderSize+start.frames*frameSize, io.SeekStart); err != nil {
+		return nil, nil, fmt.Errorf("read WAL: %w", err)
+	}
+	r := bufio.NewReaderSize(f, int(frameSize))
+	frame := make([]byte, frameSize)
+	checksum := start.checksum
+	for i := start.frames; ; i++ {
+		if _, err := io.ReadFull(r, frame); err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				break
+			}
+			return nil, nil, fmt.Errorf("read WAL: %w", err)
+		}
+		if binary.BigEndian.Uint32(frame[8:12]) != start.salt[0] ||
+			binary.BigEndian.Uint32(frame[12:16]) != start.salt[1] {
+			break
+		}
+		checksum = walChecksum(order, frame[:8], checksum)
+		checksum = walChecksum(order, frame[walFrameHeaderSize:], checksum)
+		if checksum != [2]uint32{binary.BigEndian.Uint32(frame[16:20]), binary.BigEndian.Uint32(frame[20:24])} {
+			break
+		}
+		pending[binary.BigEndian.Uint32(frame[0:4])] = walHeaderSize + i*frameSize
+		if pageCount := binary.BigEndian.Uint32(frame[4:8]); pageCount != 0 {
+			for pgno, off := range pending {
+				changes.pages[pgno] = off
+			}
+			clear(pending)
+			changes.pageCount = int(pageCount)
+			next.frames = i + 1
+			next.checksum = checksum
+		}
+	}
+	if !complete {
+		return nil, &next, nil
+	}
+	return changes, &next, nil
+}
+
+// emptyWAL returns the changes in a log that is empty or missing: none, if
+// it was empty when cur was read as well.
+func emptyWAL(cur *walCursor) *walChanges {
+	if cur != nil && cur.frames > 0 {
+		return nil
+	}
+	return &walChanges{}
+}
+
+// walChecksum extends the WAL checksum s over b, whose length is a
+// multiple of 8.
+func walChecksum(order binary.ByteOrder, b []byte, s [2]uint32) [2]uint32 {
+	for i := 0; i+8 <= len(b); i += 8 {
+		s[0] += order.Uint32(b[i:]) + s[1]
+		s[1] += order.Uint32(b[i+4:]) + s[0]
+	}
+	return s
+}
+
+// walPage is the last version of a page in a WAL.
+type walPage struct {
+	pgno uint32
+	data []byte
+}
+
+// readWALPages reads the pages in changes from the WAL at path, in page
+// number order, leaving out those beyond the end of the database.
+func readWALPages(path string, changes *walChanges, pageSize int) ([]walPage, error) {
+	if len(changes.pages) == 0 {
+		return nil, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open WAL: %w", err)
+	}
+	defer f.Close()
+
+	pages := make([]walPage, 0, len(changes.pages))
+	for pgno, off := range changes.pages {
+		if int(pgno) > changes.pageCount {
+			continue
+		}
+		data := make([]byte, pageSize)
+		if _, err := f.ReadAt(data, off+walFrameHeaderSize); err != nil {
+			return nil, fmt.Errorf("read WAL: %w", err)
+		}
+		pages = append(pages, walPage{pgno: pgno, data: data})
+	}
+	slices.SortFunc(pages, func(a, b walPage) int { return cmp.Compare(a.pgno, b.pgno) })
+	return pages, nil
+}
@@ -1,10 +1,15 @@
 package config
 
+import "time"
+
 // Config holds the server configuration.
 type Config struct {
 	ServerName      string
 	ListenAddr      string
-	DatabasePath    string
+	DatabaseDriver  string // "sqlite" (default) or "postgres"
+	DatabasePath    string // SQLite database file
+	DatabaseURL     string // PostgreSQL connection string, used when DatabaseDriver is "postgres"
+	DBReadConns     int    // Read-only database connections; writes use one separate connection
 	MaxMessageSize  int
 	RateLimitPerSec int
 
@@ -12,18 +17,68 @@ type Config struct {
 	RPDisplayName string   // Relying Party display name
 	RPID          string   // Relying Party ID (domain)
 	RPOrigins     []string // Allowed origins for WebAuthn ceremonies
+
+	// WebAuthn policy
+	UserVerification string   // "required", "preferred" or "discouraged"
+	ResidentKey      string   // "required", "preferred" or "discouraged"
+	AllowedAAGUIDs   []string // Authenticator models allowed to register; empty allows any
+
+	// Session lifetime
+	SessionMaxAge         time.Duration // Absolute lifetime from login
+	SessionIdleTimeout    time.Duration // Lifetime without activity
+	SessionRotateOnResume bool          // Issue a new token when a session is resumed
+
+	// MLS
+	MinKeyPackages int           // Users below this many KeyPackages are asked to upload more; 0 disables
+	UpdateInterval time.Duration // Members who have not updated their leaf key for this long are asked to; 0 disables
+
+	// Backups (SQLite only)
+	BackupDir        string        // Directory scheduled and admin-triggered backups are written to
+	BackupInterval   time.Duration // Time between scheduled backups; 0 disables
+	BackupKeep       int           // Number of backups to keep; 0 keeps all
+	BackupCompress   bool          // gzip backups
+	BackupPassphrase string        // Encrypt backups with a key derived from this; empty for none
+
+	// Encryption at rest of sensitive columns. At most one of these is set;
+	// with neither, an encrypted database is refused at startup.
+	EncryptionKeyFile    string // File holding the master key, as written by sovereign-cli key generate
+	EncryptionPassphrase string // Derive the master key from this instead of a key file
+
+	// Continuous replication (SQLite only)
+	ReplicaDir          string        // Directory changed pages are shipped to; empty disables
+	ReplicaInterval     time.Duration // Time between shipments, and so the resolution of point-in-time restores
+	ReplicaBaseInterval time.Duration // A full copy is shipped at least this often
+	ReplicaRetention    time.Duration // How far back point-in-time restores can go; 0 keeps everything
 }
 
 // DefaultConfig returns a Config with sensible defaults.
 func DefaultConfig() Config {
 	return Config{
-		ServerName:      "sovereign",
-		ListenAddr:      ":8080",
-		DatabasePath:    "sovereign.db",
-		MaxMessageSize:  65536, // 64KB
-		RateLimitPerSec: 30,
-		RPDisplayName:   "Sovereign",
-		RPID:            "localhost",
-		RPOrigins:       []string{"http://localhost:8080"},
+		ServerName:       "sovereign",
+		ListenAddr:       ":8080",
+		DatabaseDriver:   "sqlite",
+		DatabasePath:     "sovereign.db",
+		DBReadConns:      4,
+		MaxMessageSize:   65536, // 64KB
+		RateLimitPerSec:  30,
+		RPDisplayName:    "Sovereign",
+		RPID:             "localhost",
+		RPOrigins:        []string{"http://localhost:8080"},
+		UserVerification: "preferred",
+		ResidentKey:      "discouraged",
+
+		SessionMaxAge:      90 * 24 * time.Hour,
+		SessionIdleTimeout: 30 * 24 * time.Hour,
+
+		MinKeyPackages: 5,
+		UpdateInterval: 7 * 24 * time.Hour,
+
+		BackupDir:      "backups",
+		BackupKeep:     7,
+		BackupCompress: true,
+
+		ReplicaInterval:     time.Minute,
+		ReplicaBaseInterval: 24 * time.Hour,
+		ReplicaRetention:    7 * 24 * time.Hour,
 	}
 }
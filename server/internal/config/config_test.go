@@ -1,6 +1,9 @@
 package config
 
-import "testing"
+import (
+	"testing"
+	"time"
+)
 
 func TestDefaultConfig(t *testing.T) {
 	tests := []struct {
@@ -18,6 +21,11 @@ func TestDefaultConfig(t *testing.T) {
 			get:  func(c Config) any { return c.ListenAddr },
 			want: ":8080",
 		},
+		{
+			name: "DatabaseDriver",
+			get:  func(c Config) any { return c.DatabaseDriver },
+			want: "sqlite",
+		},
 		{
 			name: "DatabasePath",
 			get:  func(c Config) any { return c.DatabasePath },
@@ -33,6 +41,81 @@ func TestDefaultConfig(t *testing.T) {
 			get:  func(c Config) any { return c.RateLimitPerSec },
 			want: 30,
 		},
+		{
+			name: "UserVerification",
+			get:  func(c Config) any { return c.UserVerification },
+			want: "preferred",
+		},
+		{
+			name: "ResidentKey",
+			get:  func(c Config) any { return c.ResidentKey },
+			want: "discouraged",
+		},
+		{
+			name: "SessionMaxAge",
+			get:  func(c Config) any { return c.SessionMaxAge },
+			want: 90 * 24 * time.Hour,
+		},
+		{
+			name: "SessionIdleTimeout",
+			get:  func(c Config) any { return c.SessionIdleTimeout },
+			want: 30 * 24 * time.Hour,
+		},
+		{
+			name: "DBReadConns",
+			get:  func(c Config) any { return c.DBReadConns },
+			want: 4,
+		},
+		{
+			name: "MinKeyPackages",
+			get:  func(c Config) any { return c.MinKeyPackages },
+			want: 5,
+		},
+		{
+			name: "UpdateInterval",
+			get:  func(c Config) any { return c.UpdateInterval },
+			want: 7 * 24 * time.Hour,
+		},
+		{
+			name: "BackupDir",
+			get:  func(c Config) any { return c.BackupDir },
+			want: "backups",
+		},
+		{
+			name: "BackupInterval",
+			get:  func(c Config) any { return c.BackupInterval },
+			want: time.Duration(0),
+		},
+		{
+			name: "BackupKeep",
+			get:  func(c Config) any { return c.BackupKeep },
+			want: 7,
+		},
+		{
+			name: "BackupCompress",
+			get:  func(c Config) any { return c.BackupCompress },
+			want: true,
+		},
+		{
+			name: "ReplicaDir",
+			get:  func(c Config) any { return c.ReplicaDir },
+			want: "",
+		},
+		{
+			name: "ReplicaInterval",
+			get:  func(c Config) any { return c.ReplicaInterval },
+			want: time.Minute,
+		},
+		{
+			name: "ReplicaBaseInterval",
+			get:  func(c Config) any { return c.ReplicaBaseInterval },
+			want: 24 * time.Hour,
+		},
+		{
+			name: "ReplicaRetention",
+			get:  func(c Config) any { return c.ReplicaRetention },
+			want: 7 * 24 * time.Hour,
+		},
 	}
 
 	cfg := DefaultConfig()
@@ -0,0 +1,94 @@
+package mls
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sovereign-im/sovereign/server/internal/store"
+)
+
+// DeliveryTokenLifetime is how long a delivery token is issued for. Every
+// member asking for a token in the same period receives the same one, so
+// tokens do not tell the server which member sent a message.
+const DeliveryTokenLifetime = time.Hour
+
+// deliveryTokenLabel separates delivery token MACs from any other use of
+// the key.
+const deliveryTokenLabel = "sovereign delivery token v1"
+
+// IssueDeliveryToken returns a delivery token proving membership of a
+// sealed-sender conversation, and the Unix time it expires at. Tokens are
+// valid until they expire or a member leaves the conversation. Returns
+// ErrNotMember unless userID is a member and ErrSealedSenderDisabled if
+// the conversation does not use sealed sender.
+func (s *Service) IssueDeliveryToken(ctx context.Context, conversationID, userID string) ([]byte, int64, error) {
+	if err := s.checkMember(ctx, conversationID, userID); err != nil {
+		return nil, 0, err
+	}
+	conv, err := s.store.GetConversation(ctx, conversationID)
+	if err != nil {
+		return nil, 0, fmt.Errorf("get conversation: %w", err)
+	}
+	if !conv.SealedSender {
+		return nil, 0, ErrSealedSenderDisabled
+	}
+
+	// Tokens expire at the end of a lifetime-aligned period. Late in a
+	// period, hand out the next period's token so it stays usable.
+	lifetime := int64(DeliveryTokenLifetime / time.Second)
+	now := time.Now().Unix()
+	expiresAt := (now/lifetime + 1) * lifetime
+	if expiresAt-now < lifetime/2 {
+		expiresAt += lifetime
+	}
+	return s.deliveryToken(conv, expiresAt), expiresAt, nil
+}
+
+// VerifyDeliveryToken checks a token from IssueDeliveryToken for a
+// sealed-sender conversation. Returns ErrInvalidDeliveryToken if the token
+// is malformed, expired, for another conversation or was issued before a
+// member left, and ErrSealedSenderDisabled if the conversation does not
+// use sealed sender.
+func (s *Service) VerifyDeliveryToken(ctx context.Context, conversationID string, token []byte) error {
+	conv, err := s.store.GetConversation(ctx, conversationID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return ErrInvalidDeliveryToken
+		}
+		return fmt.Errorf("get conversation: %w", err)
+	}
+	if !conv.SealedSender {
+		return ErrSealedSenderDisabled
+	}
+	if len(token) != 8+sha256.Size {
+		return ErrInvalidDeliveryToken
+	}
+	expiresAt := int64(binary.BigEndian.Uint64(token))
+	if expiresAt <= time.Now().Unix() {
+		return ErrInvalidDeliveryToken
+	}
+	if !hmac.Equal(token, s.deliveryToken(conv, expiresAt)) {
+		return ErrInvalidDeliveryToken
+	}
+	return nil
+}
+
+// deliveryToken returns the token for conv that expires at expiresAt: the
+// expiry followed by a MAC over it, the conversation ID and the
+// conversation's member generation.
+func (s *Service) deliveryToken(conv *store.Conversation, expiresAt int64) []byte {
+	mac := hmac.New(sha256.New, s.deliveryKey)
+	mac.Write([]byte(deliveryTokenLabel))
+	mac.Write(binary.BigEndian.AppendUint32(nil, uint32(len(conv.ID))))
+	mac.Write([]byte(conv.ID))
+	mac.Write(binary.BigEndian.AppendUint64(nil, uint64(conv.MemberGeneration)))
+	mac.Write(binary.BigEndian.AppendUint64(nil, uint64(expiresAt)))
+
+	token := binary.BigEndian.AppendUint64(nil, uint64(expiresAt))
+	return mac.Sum(token)
+}
@@ -0,0 +1,108 @@
+package mls
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sovereign-im/sovereign/server/internal/store"
+)
+
+func TestDeliveryToken(t *testing.T) {
+	svc, s := newTestService(t)
+	ctx := context.Background()
+
+	sealed, err := s.CreateConversationWithOptions(ctx, "Sealed", "alice", []string{"bob", "carol"}, store.ConversationOptions{SealedSender: true})
+	if err != nil {
+		t.Fatalf("CreateConversationWithOptions: %v", err)
+	}
+	other, err := s.CreateConversationWithOptions(ctx, "Other", "alice", nil, store.ConversationOptions{SealedSender: true})
+	if err != nil {
+		t.Fatalf("CreateConversationWithOptions: %v", err)
+	}
+	plain, err := s.CreateConversation(ctx, "Plain", "alice", nil)
+	if err != nil {
+		t.Fatalf("CreateConversation: %v", err)
+	}
+
+	token, expiresAt, err := svc.IssueDeliveryToken(ctx, sealed.ID, "alice")
+	if err != nil {
+		t.Fatalf("IssueDeliveryToken: %v", err)
+	}
+	if remaining := time.Until(time.Unix(expiresAt, 0)); remaining < DeliveryTokenLifetime/2 || remaining > 3*DeliveryTokenLifetime/2 {
+		t.Errorf("token expires in %v, want between half and one and a half lifetimes", remaining)
+	}
+	bobToken, _, err := svc.IssueDeliveryToken(ctx, sealed.ID, "bob")
+	if err != nil {
+		t.Fatalf("IssueDeliveryToken: %v", err)
+	}
+	if !bytes.Equal(token, bobToken) {
+		t.Error("members received different tokens for the same period")
+	}
+
+	if _, _, err := svc.IssueDeliveryToken(ctx, sealed.ID, "mallory"); !errors.Is(err, ErrNotMember) {
+		t.Errorf("IssueDeliveryToken for non-member: err = %v, want ErrNotMember", err)
+	}
+	if _, _, err := svc.IssueDeliveryToken(ctx, plain.ID, "alice"); !errors.Is(err, ErrSealedSenderDisabled) {
+		t.Errorf("IssueDeliveryToken for plain conversation: err = %v, want ErrSealedSenderDisabled", err)
+	}
+
+	tampered := append([]byte(nil), token...)
+	tampered[len(tampered)-1] ^= 1
+
+	tests := []struct {
+		name           string
+		conversationID string
+		token          []byte
+		wantErr        error
+	}{
+		{name: "valid", conversationID: sealed.ID, token: token},
+		{name: "tampered", conversationID: sealed.ID, token: tampered, wantErr: ErrInvalidDeliveryToken},
+		{name: "truncated", conversationID: sealed.ID, token: token[:8], wantErr: ErrInvalidDeliveryToken},
+		{name: "missing", conversationID: sealed.ID, token: nil, wantErr: ErrInvalidDeliveryToken},
+		{name: "other conversation", conversationID: other.ID, token: token, wantErr: ErrInvalidDeliveryToken},
+		{name: "unknown conversation", conversationID: "nonexistent", token: token, wantErr: ErrInvalidDeliveryToken},
+		{name: "plain conversation", conversationID: plain.ID, token: token, wantErr: ErrSealedSenderDisabled},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := svc.VerifyDeliveryToken(ctx, tt.conversationID, tt.token)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("VerifyDeliveryToken: err = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("VerifyDeliveryToken: %v", err)
+			}
+		})
+	}
+
+	// Removing a member revokes the tokens issued before.
+	if err := s.RemoveMember(ctx, sealed.ID, "carol"); err != nil {
+		t.Fatalf("RemoveMember: %v", err)
+	}
+	if err := svc.VerifyDeliveryToken(ctx, sealed.ID, token); !errors.Is(err, ErrInvalidDeliveryToken) {
+		t.Errorf("VerifyDeliveryToken after removal: err = %v, want ErrInvalidDeliveryToken", err)
+	}
+	token, _, err = svc.IssueDeliveryToken(ctx, sealed.ID, "alice")
+	if err != nil {
+		t.Fatalf("IssueDeliveryToken: %v", err)
+	}
+	if err := svc.VerifyDeliveryToken(ctx, sealed.ID, token); err != nil {
+		t.Errorf("VerifyDeliveryToken for new token: %v", err)
+	}
+
+	// Tokens outlive a restart, since the key is kept in the store.
+	restarted, err := NewService(s, 0, 0)
+	if err != nil {
+		t.Fatalf("NewService: %v", err)
+	}
+	if err := restarted.VerifyDeliveryToken(ctx, sealed.ID, token); err != nil {
+		t.Errorf("VerifyDeliveryToken after restart: %v", err)
+	}
+}
@@ -0,0 +1,108 @@
+package mls
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// GroupInfo is a parsed RFC 9420 GroupInfo (Section 12.4.3). The server
+// reads the group context to route and order GroupInfos; it cannot check
+// the signature, since the signer's key is in the ratchet tree.
+type GroupInfo struct {
+	Version                 uint16
+	CipherSuite             uint16
+	GroupID                 []byte
+	Epoch                   uint64
+	TreeHash                []byte
+	ConfirmedTranscriptHash []byte
+	ContextExtensions       []Extension
+
+	Extensions      []Extension
+	ConfirmationTag []byte
+	Signer          uint32
+	Signature       []byte
+}
+
+// ParseGroupInfo decodes a GroupInfo wrapped in a TLS-encoded MLSMessage,
+// the form in which GroupInfos are distributed. Errors wrap
+// ErrInvalidGroupInfo.
+func ParseGroupInfo(data []byte) (*GroupInfo, error) {
+	r := &reader{data: data}
+	gi := &GroupInfo{}
+
+	version, err := r.uint16()
+	if err != nil {
+		return nil, invalidGroupInfo(fieldError("version", err))
+	}
+	if version != ProtocolVersionMLS10 {
+		return nil, invalidGroupInfo(fmt.Errorf("unsupported protocol version %d", version))
+	}
+	wireFormat, err := r.uint16()
+	if err != nil {
+		return nil, invalidGroupInfo(fieldError("wire_format", err))
+	}
+	if wireFormat != WireFormatGroupInfo {
+		return nil, invalidGroupInfo(fmt.Errorf("wire_format is %d, want group_info", wireFormat))
+	}
+
+	if err := parseGroupContext(r, gi); err != nil {
+		return nil, invalidGroupInfo(fieldError("group_context", err))
+	}
+	if gi.Version != version {
+		return nil, invalidGroupInfo(errors.New("group_context version does not match message version"))
+	}
+	if gi.Epoch > math.MaxInt64 {
+		return nil, invalidGroupInfo(errors.New("epoch out of range"))
+	}
+	if gi.Extensions, err = parseExtensions(r); err != nil {
+		return nil, invalidGroupInfo(fieldError("extensions", err))
+	}
+	if gi.ConfirmationTag, err = r.vector(); err != nil {
+		return nil, invalidGroupInfo(fieldError("confirmation_tag", err))
+	}
+	if gi.Signer, err = r.uint32(); err != nil {
+		return nil, invalidGroupInfo(fieldError("signer", err))
+	}
+	if gi.Signature, err = r.vector(); err != nil {
+		return nil, invalidGroupInfo(fieldError("signature", err))
+	}
+	if !r.empty() {
+		return nil, invalidGroupInfo(errors.New("trailing data"))
+	}
+	return gi, nil
+}
+
+func parseGroupContext(r *reader, gi *GroupInfo) error {
+	var err error
+	if gi.Version, err = r.uint16(); err != nil {
+		return fieldError("version", err)
+	}
+	if gi.CipherSuite, err = r.uint16(); err != nil {
+		return fieldError("cipher_suite", err)
+	}
+	if gi.GroupID, err = r.vector(); err != nil {
+		return fieldError("group_id", err)
+	}
+	if len(gi.GroupID) == 0 {
+		return errors.New("empty group_id")
+	}
+	if gi.Epoch, err = r.uint64(); err != nil {
+		return fieldError("epoch", err)
+	}
+	if gi.TreeHash, err = r.vector(); err != nil {
+		return fieldError("tree_hash", err)
+	}
+	if gi.ConfirmedTranscriptHash, err = r.vector(); err != nil {
+		return fieldError("confirmed_transcript_hash", err)
+	}
+	if gi.ContextExtensions, err = parseExtensions(r); err != nil {
+		return fieldError("extensions", err)
+	}
+	return nil
+}
+
+// invalidGroupInfo wraps err so that it matches ErrInvalidGroupInfo.
+func invalidGroupInfo(err error) error {
+	return fmt.Errorf("%w: %w", ErrInvalidGroupInfo, err)
+}
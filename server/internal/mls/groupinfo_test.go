@@ -0,0 +1,112 @@
+package mls
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/sovereign-im/sovereign/server/internal/mls/mlstest"
+)
+
+func TestParseGroupInfo(t *testing.T) {
+	groupID := []byte("group-1")
+	valid := mlstest.NewGroupInfo(t, mlstest.GroupInfoOptions{GroupID: groupID, Epoch: 7})
+
+	wrongWireFormat := append([]byte(nil), valid...)
+	wrongWireFormat[3] = 1 // mls_public_message
+
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr bool
+	}{
+		{name: "valid", data: valid},
+		{name: "empty", data: nil, wantErr: true},
+		{name: "wrong wire format", data: wrongWireFormat, wantErr: true},
+		{name: "truncated", data: valid[:len(valid)-1], wantErr: true},
+		{name: "trailing data", data: append(append([]byte(nil), valid...), 0), wantErr: true},
+		{name: "empty group id", data: mlstest.NewGroupInfo(t, mlstest.GroupInfoOptions{GroupID: []byte{}}), wantErr: true},
+		{name: "epoch out of range", data: mlstest.NewGroupInfo(t, mlstest.GroupInfoOptions{Epoch: 1 << 63}), wantErr: true},
+		{name: "key package", data: mlstest.NewKeyPackage(t, mlstest.KeyPackageOptions{}), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gi, err := ParseGroupInfo(tt.data)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidGroupInfo) {
+					t.Fatalf("ParseGroupInfo: err = %v, want ErrInvalidGroupInfo", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseGroupInfo: %v", err)
+			}
+			if !bytes.Equal(gi.GroupID, groupID) || gi.Epoch != 7 {
+				t.Errorf("ParseGroupInfo = group %q epoch %d, want %q epoch 7", gi.GroupID, gi.Epoch, groupID)
+			}
+		})
+	}
+}
+
+func TestPublishGroupInfo(t *testing.T) {
+	svc, s := newTestService(t)
+	ctx := context.Background()
+
+	conv, err := s.CreateConversation(ctx, "Group", "alice", []string{"bob"})
+	if err != nil {
+		t.Fatalf("CreateConversation: %v", err)
+	}
+	groupID := []byte("group-1")
+	groupInfo := func(epoch uint64) []byte {
+		return mlstest.NewGroupInfo(t, mlstest.GroupInfoOptions{GroupID: groupID, Epoch: epoch})
+	}
+
+	if _, _, err := svc.GroupInfo(ctx, conv.ID, "bob"); !errors.Is(err, ErrNoGroupInfo) {
+		t.Fatalf("GroupInfo before publish: err = %v, want ErrNoGroupInfo", err)
+	}
+
+	tests := []struct {
+		name    string
+		userID  string
+		data    []byte
+		wantErr error
+	}{
+		{name: "member publishes", userID: "alice", data: groupInfo(2)},
+		{name: "other member publishes later epoch", userID: "bob", data: groupInfo(3)},
+		{name: "non-member", userID: "mallory", data: groupInfo(4), wantErr: ErrNotMember},
+		{name: "earlier epoch", userID: "alice", data: groupInfo(1), wantErr: ErrStaleGroupInfo},
+		{name: "different group", userID: "alice", data: mlstest.NewGroupInfo(t, mlstest.GroupInfoOptions{Epoch: 5}), wantErr: ErrInvalidGroupInfo},
+		{name: "malformed", userID: "alice", data: []byte("group-info"), wantErr: ErrInvalidGroupInfo},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := svc.PublishGroupInfo(ctx, conv.ID, tt.userID, tt.data)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("PublishGroupInfo: err = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("PublishGroupInfo: %v", err)
+			}
+			got, _, err := svc.GroupInfo(ctx, conv.ID, "alice")
+			if err != nil {
+				t.Fatalf("GroupInfo: %v", err)
+			}
+			if !bytes.Equal(got, tt.data) {
+				t.Error("GroupInfo did not return the published GroupInfo")
+			}
+		})
+	}
+
+	if _, epoch, err := svc.GroupInfo(ctx, conv.ID, "bob"); err != nil || epoch != 3 {
+		t.Errorf("GroupInfo = epoch %d, err %v; want epoch 3", epoch, err)
+	}
+	if _, _, err := svc.GroupInfo(ctx, conv.ID, "mallory"); !errors.Is(err, ErrNotMember) {
+		t.Errorf("GroupInfo for non-member: err = %v, want ErrNotMember", err)
+	}
+}
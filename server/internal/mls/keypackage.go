@@ -0,0 +1,348 @@
+package mls
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"fmt"
+	"hash"
+	"math"
+	"math/big"
+	"time"
+)
+
+// ProtocolVersionMLS10 is the only MLS protocol version, mls10.
+const ProtocolVersionMLS10 = 1
+
+// Cipher suites registered by RFC 9420 Section 17.1.
+const (
+	CipherSuiteX25519AES128Ed25519   = 0x0001
+	CipherSuiteP256AES128P256        = 0x0002
+	CipherSuiteX25519ChaCha20Ed25519 = 0x0003
+	CipherSuiteX448AES256Ed448       = 0x0004
+	CipherSuiteP521AES256P521        = 0x0005
+	CipherSuiteX448ChaCha20Ed448     = 0x0006
+	CipherSuiteP384AES256P384        = 0x0007
+)
+
+// Credential types (RFC 9420 Section 5.3).
+const (
+	CredentialTypeBasic = 1
+	CredentialTypeX509  = 2
+)
+
+// Leaf node sources (RFC 9420 Section 7.2).
+const (
+	LeafNodeSourceKeyPackage = 1
+	LeafNodeSourceUpdate     = 2
+	LeafNodeSourceCommit     = 3
+)
+
+// lifetimeSkew is how far in the future a KeyPackage's not_before may be,
+// to tolerate client clock skew.
+const lifetimeSkew = time.Hour
+
+// KeyPackage is a parsed RFC 9420 KeyPackage. The server checks its
+// structure, lifetime and signatures but does not use its keys.
+type KeyPackage struct {
+	Version     uint16
+	CipherSuite uint16
+	InitKey     []byte
+	LeafNode    LeafNode
+	Extensions  []Extension
+	Signature   []byte
+
+	// tbs is the encoding of the fields covered by Signature.
+	tbs []byte
+}
+
+// LeafNode is the leaf node of a KeyPackage.
+type LeafNode struct {
+	EncryptionKey []byte
+	SignatureKey  []byte
+	Credential    Credential
+	Capabilities  Capabilities
+	Source        uint8
+
+	// NotBefore and NotAfter are the lifetime, in Unix seconds, of a leaf
+	// node whose Source is LeafNodeSourceKeyPackage.
+	NotBefore uint64
+	NotAfter  uint64
+
+	// ParentHash is set for a leaf node whose Source is LeafNodeSourceCommit.
+	ParentHash []byte
+
+	Extensions []Extension
+	Signature  []byte
+
+	// tbs is the encoding of the fields covered by Signature. It is only
+	// the complete LeafNodeTBS for key package leaf nodes, which are not
+	// bound to a group.
+	tbs []byte
+}
+
+// Credential identifies the owner of a leaf node.
+type Credential struct {
+	Type uint16
+
+	// Identity is set for basic credentials.
+	Identity []byte
+
+	// Certificates is the DER certificate chain of an X.509 credential.
+	Certificates [][]byte
+}
+
+// Capabilities lists what the owner of a leaf node's client supports.
+type Capabilities struct {
+	Versions     []uint16
+	CipherSuites []uint16
+	Extensions   []uint16
+	Proposals    []uint16
+	Credentials  []uint16
+}
+
+// Extension is an MLS extension.
+type Extension struct {
+	Type uint16
+	Data []byte
+}
+
+// ParseKeyPackage decodes a TLS-encoded KeyPackage. It checks structure
+// only; use Verify to check the lifetime and signatures.
+func ParseKeyPackage(data []byte) (*KeyPackage, error) {
+	r := &reader{data: data}
+	kp := &KeyPackage{}
+	var err error
+
+	if kp.Version, err = r.uint16(); err != nil {
+		return nil, invalidKeyPackage(fieldError("version", err))
+	}
+	if kp.CipherSuite, err = r.uint16(); err != nil {
+		return nil, invalidKeyPackage(fieldError("cipher_suite", err))
+	}
+	if kp.InitKey, err = r.vector(); err != nil {
+		return nil, invalidKeyPackage(fieldError("init_key", err))
+	}
+	if err := parseLeafNode(r, &kp.LeafNode); err != nil {
+		return nil, invalidKeyPackage(fieldError("leaf_node", err))
+	}
+	if kp.Extensions, err = parseExtensions(r); err != nil {
+		return nil, invalidKeyPackage(fieldError("extensions", err))
+	}
+	kp.tbs = data[:r.offset()]
+	if kp.Signature, err = r.vector(); err != nil {
+		return nil, invalidKeyPackage(fieldError("signature", err))
+	}
+	if !r.empty() {
+		return nil, invalidKeyPackage(errors.New("trailing data"))
+	}
+	return kp, nil
+}
+
+func parseLeafNode(r *reader, ln *LeafNode) error {
+	start := r.offset()
+	var err error
+
+	if ln.EncryptionKey, err = r.vector(); err != nil {
+		return fieldError("encryption_key", err)
+	}
+	if ln.SignatureKey, err = r.vector(); err != nil {
+		return fieldError("signature_key", err)
+	}
+	if err := parseCredential(r, &ln.Credential); err != nil {
+		return fieldError("credential", err)
+	}
+	if err := parseCapabilities(r, &ln.Capabilities); err != nil {
+		return fieldError("capabilities", err)
+	}
+	if ln.Source, err = r.uint8(); err != nil {
+		return fieldError("leaf_node_source", err)
+	}
+	switch ln.Source {
+	case LeafNodeSourceKeyPackage:
+		if ln.NotBefore, err = r.uint64(); err != nil {
+			return fieldError("lifetime", err)
+		}
+		if ln.NotAfter, err = r.uint64(); err != nil {
+			return fieldError("lifetime", err)
+		}
+	case LeafNodeSourceUpdate:
+	case LeafNodeSourceCommit:
+		if ln.ParentHash, err = r.vector(); err != nil {
+			return fieldError("parent_hash", err)
+		}
+	default:
+		return fmt.Errorf("unknown leaf_node_source %d", ln.Source)
+	}
+	if ln.Extensions, err = parseExtensions(r); err != nil {
+		return fieldError("extensions", err)
+	}
+	ln.tbs = r.data[start:r.offset()]
+	if ln.Signature, err = r.vector(); err != nil {
+		return fieldError("signature", err)
+	}
+	return nil
+}
+
+func parseCredential(r *reader, c *Credential) error {
+	var err error
+	if c.Type, err = r.uint16(); err != nil {
+		return err
+	}
+	switch c.Type {
+	case CredentialTypeBasic:
+		c.Identity, err = r.vector()
+		return err
+	case CredentialTypeX509:
+		return r.vectorOf(func(sub *reader) error {
+			cert, err := sub.vector()
+			if err != nil {
+				return err
+			}
+			c.Certificates = append(c.Certificates, cert)
+			return nil
+		})
+	default:
+		// Other credential types have no length prefix, so the rest of
+		// the structure cannot be located.
+		return fmt.Errorf("unsupported credential type %d", c.Type)
+	}
+}
+
+func parseCapabilities(r *reader, c *Capabilities) error {
+	var err error
+	if c.Versions, err = r.uint16s(); err != nil {
+		return fieldError("versions", err)
+	}
+	if c.CipherSuites, err = r.uint16s(); err != nil {
+		return fieldError("cipher_suites", err)
+	}
+	if c.Extensions, err = r.uint16s(); err != nil {
+		return fieldError("extensions", err)
+	}
+	if c.Proposals, err = r.uint16s(); err != nil {
+		return fieldError("proposals", err)
+	}
+	if c.Credentials, err = r.uint16s(); err != nil {
+		return fieldError("credentials", err)
+	}
+	return nil
+}
+
+func parseExtensions(r *reader) ([]Extension, error) {
+	var exts []Extension
+	seen := make(map[uint16]bool)
+	err := r.vectorOf(func(sub *reader) error {
+		typ, err := sub.uint16()
+		if err != nil {
+			return err
+		}
+		if seen[typ] {
+			return fmt.Errorf("duplicate extension %d", typ)
+		}
+		seen[typ] = true
+		data, err := sub.vector()
+		if err != nil {
+			return err
+		}
+		exts = append(exts, Extension{Type: typ, Data: data})
+		return nil
+	})
+	return exts, err
+}
+
+// Verify checks that the KeyPackage is usable at now: it is for MLS 1.0
+// and a supported cipher suite, its leaf node came from a KeyPackage and
+// is within its lifetime, and both the leaf node and KeyPackage
+// signatures are valid under the leaf's signature key.
+func (kp *KeyPackage) Verify(now time.Time) error {
+	if kp.Version != ProtocolVersionMLS10 {
+		return invalidKeyPackage(fmt.Errorf("unsupported protocol version %d", kp.Version))
+	}
+	ln := &kp.LeafNode
+	if ln.Source != LeafNodeSourceKeyPackage {
+		return invalidKeyPackage(fmt.Errorf("leaf_node_source is %d, want key_package", ln.Source))
+	}
+	if bytes.Equal(kp.InitKey, ln.EncryptionKey) {
+		return invalidKeyPackage(errors.New("init_key equals leaf encryption_key"))
+	}
+
+	if ln.NotAfter > math.MaxInt64 || ln.NotBefore > ln.NotAfter {
+		return invalidKeyPackage(errors.New("invalid lifetime"))
+	}
+	if int64(ln.NotAfter) <= now.Unix() {
+		return invalidKeyPackage(errors.New("lifetime has expired"))
+	}
+	if int64(ln.NotBefore) > now.Add(lifetimeSkew).Unix() {
+		return invalidKeyPackage(errors.New("lifetime has not started"))
+	}
+
+	if err := verifyWithLabel(kp.CipherSuite, ln.SignatureKey, "LeafNodeTBS", ln.tbs, ln.Signature); err != nil {
+		return invalidKeyPackage(fieldError("leaf_node signature", err))
+	}
+	if err := verifyWithLabel(kp.CipherSuite, ln.SignatureKey, "KeyPackageTBS", kp.tbs, kp.Signature); err != nil {
+		return invalidKeyPackage(fieldError("signature", err))
+	}
+	return nil
+}
+
+// ExpiresAt returns the end of the KeyPackage's lifetime.
+func (kp *KeyPackage) ExpiresAt() time.Time {
+	return time.Unix(int64(min(kp.LeafNode.NotAfter, math.MaxInt64)), 0)
+}
+
+// verifyWithLabel implements VerifyWithLabel (RFC 9420 Section 5.1.2)
+// for the signature scheme of suite.
+func verifyWithLabel(suite uint16, publicKey []byte, label string, content, signature []byte) error {
+	var msg []byte
+	msg = appendVector(msg, []byte("MLS 1.0 "+label))
+	msg = appendVector(msg, content)
+
+	switch suite {
+	case CipherSuiteX25519AES128Ed25519, CipherSuiteX25519ChaCha20Ed25519:
+		if len(publicKey) != ed25519.PublicKeySize {
+			return errors.New("invalid Ed25519 public key")
+		}
+		if !ed25519.Verify(ed25519.PublicKey(publicKey), msg, signature) {
+			return errors.New("verification failed")
+		}
+		return nil
+	case CipherSuiteP256AES128P256:
+		return verifyECDSA(ecdh.P256(), elliptic.P256(), sha256.New(), publicKey, msg, signature)
+	case CipherSuiteP384AES256P384:
+		return verifyECDSA(ecdh.P384(), elliptic.P384(), sha512.New384(), publicKey, msg, signature)
+	case CipherSuiteP521AES256P521:
+		return verifyECDSA(ecdh.P521(), elliptic.P521(), sha512.New(), publicKey, msg, signature)
+	default:
+		return fmt.Errorf("unsupported cipher suite 0x%04x", suite)
+	}
+}
+
+// verifyECDSA checks an ASN.1 ECDSA signature. publicKey is an
+// uncompressed SEC1 point, which ecdh validates is on the curve.
+func verifyECDSA(c ecdh.Curve, curve elliptic.Curve, h hash.Hash, publicKey, msg, signature []byte) error {
+	if _, err := c.NewPublicKey(publicKey); err != nil || publicKey[0] != 4 {
+		return errors.New("invalid ECDSA public key")
+	}
+	size := (len(publicKey) - 1) / 2
+	pub := &ecdsa.PublicKey{
+		Curve: curve,
+		X:     new(big.Int).SetBytes(publicKey[1 : 1+size]),
+		Y:     new(big.Int).SetBytes(publicKey[1+size:]),
+	}
+	h.Write(msg)
+	if !ecdsa.VerifyASN1(pub, h.Sum(nil), signature) {
+		return errors.New("verification failed")
+	}
+	return nil
+}
+
+// invalidKeyPackage wraps err so that it matches ErrInvalidPayload.
+func invalidKeyPackage(err error) error {
+	return fmt.Errorf("%w: %w", ErrInvalidPayload, err)
+}
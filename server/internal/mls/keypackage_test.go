@@ -0,0 +1,168 @@
+package mls
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sovereign-im/sovereign/server/internal/mls/mlstest"
+)
+
+func TestReaderVarint(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []byte
+		want    int
+		wantErr bool
+	}{
+		{name: "one byte", data: []byte{0x25}, want: 37},
+		{name: "two bytes", data: []byte{0x7b, 0xbd}, want: 15293},
+		{name: "four bytes", data: []byte{0x9d, 0x7f, 0x3e, 0x7d}, want: 494878333},
+		{name: "not minimal", data: []byte{0x40, 0x25}, wantErr: true},
+		{name: "eight byte form", data: []byte{0xc0, 0, 0, 0, 0, 0, 0, 1}, wantErr: true},
+		{name: "truncated", data: []byte{0x7b}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := (&reader{data: tt.data}).varint()
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("varint() = %d, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("varint() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseKeyPackage(t *testing.T) {
+	valid := mlstest.NewKeyPackage(t, mlstest.KeyPackageOptions{Identity: "alice"})
+
+	// The credential type follows the 4-byte header and three 32-byte keys
+	// (init, encryption, signature), each with a 1-byte length prefix.
+	unknownCredential := append([]byte(nil), valid...)
+	unknownCredential[4+33+33+33+1] = 9
+
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr bool
+	}{
+		{name: "valid", data: valid},
+		{name: "empty", data: nil, wantErr: true},
+		{name: "truncated", data: valid[:len(valid)-1], wantErr: true},
+		{name: "trailing data", data: append(append([]byte(nil), valid...), 0), wantErr: true},
+		{name: "unknown credential type", data: unknownCredential, wantErr: true},
+		{name: "garbage", data: []byte("key-package-blob"), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kp, err := ParseKeyPackage(tt.data)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidPayload) {
+					t.Errorf("error = %v, want %v", err, ErrInvalidPayload)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if kp.Version != ProtocolVersionMLS10 || kp.CipherSuite != CipherSuiteX25519AES128Ed25519 {
+				t.Errorf("Version, CipherSuite = %d, %d, want 1, 1", kp.Version, kp.CipherSuite)
+			}
+			ln := kp.LeafNode
+			if ln.Credential.Type != CredentialTypeBasic || string(ln.Credential.Identity) != "alice" {
+				t.Errorf("Credential = %+v, want basic alice", ln.Credential)
+			}
+			if ln.Source != LeafNodeSourceKeyPackage || ln.NotAfter <= ln.NotBefore {
+				t.Errorf("Source, lifetime = %d, %d-%d", ln.Source, ln.NotBefore, ln.NotAfter)
+			}
+		})
+	}
+}
+
+func TestKeyPackageVerify(t *testing.T) {
+	now := time.Now()
+
+	tamper := func(data []byte, i int) []byte {
+		b := append([]byte(nil), data...)
+		b[i] ^= 0xff
+		return b
+	}
+	valid := mlstest.NewKeyPackage(t, mlstest.KeyPackageOptions{})
+
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr bool
+	}{
+		{name: "Ed25519", data: valid},
+		{name: "P-256", data: mlstest.NewKeyPackage(t, mlstest.KeyPackageOptions{CipherSuite: mlstest.CipherSuiteP256})},
+		{
+			name: "expired",
+			data: mlstest.NewKeyPackage(t, mlstest.KeyPackageOptions{
+				NotBefore: now.Add(-48 * time.Hour),
+				NotAfter:  now.Add(-time.Hour),
+			}),
+			wantErr: true,
+		},
+		{
+			name: "not yet valid",
+			data: mlstest.NewKeyPackage(t, mlstest.KeyPackageOptions{
+				NotBefore: now.Add(48 * time.Hour),
+				NotAfter:  now.Add(72 * time.Hour),
+			}),
+			wantErr: true,
+		},
+		{name: "unsupported version", data: tamper(valid, 1), wantErr: true},
+		{name: "bad key package signature", data: tamper(valid, len(valid)-1), wantErr: true},
+		{name: "modified init key", data: tamper(valid, 5), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kp, err := ParseKeyPackage(tt.data)
+			if err != nil {
+				t.Fatalf("ParseKeyPackage: %v", err)
+			}
+			err = kp.Verify(now)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidPayload) {
+					t.Errorf("error = %v, want %v", err, ErrInvalidPayload)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestUploadKeyPackageUsesLifetime(t *testing.T) {
+	svc, s := newTestService(t)
+	ctx := context.Background()
+
+	notAfter := time.Now().Add(3 * time.Hour).Truncate(time.Second)
+	data := mlstest.NewKeyPackage(t, mlstest.KeyPackageOptions{NotAfter: notAfter})
+	if err := svc.UploadKeyPackage(ctx, "alice", data); err != nil {
+		t.Fatalf("UploadKeyPackage: %v", err)
+	}
+
+	kp, err := s.ConsumeKeyPackage(ctx, "alice", 0)
+	if err != nil {
+		t.Fatalf("ConsumeKeyPackage: %v", err)
+	}
+	if kp.ExpiresAt != notAfter.Unix() {
+		t.Errorf("ExpiresAt = %d, want not_after %d", kp.ExpiresAt, notAfter.Unix())
+	}
+}
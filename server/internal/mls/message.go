@@ -0,0 +1,136 @@
+package mls
+
+import (
+	"errors"
+	"fmt"
+)
+
+// MLSMessage wire formats (RFC 9420 Section 6).
+const (
+	WireFormatPublicMessage  = 1
+	WireFormatPrivateMessage = 2
+	WireFormatWelcome        = 3
+	WireFormatGroupInfo      = 4
+	WireFormatKeyPackage     = 5
+)
+
+// Content types of a framed message (RFC 9420 Section 6).
+const (
+	ContentTypeApplication = 1
+	ContentTypeProposal    = 2
+	ContentTypeCommit      = 3
+)
+
+// Proposal types (RFC 9420 Section 12.1).
+const (
+	ProposalTypeAdd    = 1
+	ProposalTypeUpdate = 2
+	ProposalTypeRemove = 3
+)
+
+// Sender types (RFC 9420 Section 6).
+const (
+	senderTypeMember          = 1
+	senderTypeExternal        = 2
+	senderTypeNewMemberCommit = 4
+)
+
+// MessageHeader is the unencrypted start of an MLSMessage carrying a
+// PublicMessage or PrivateMessage, such as a Commit or Proposal.
+type MessageHeader struct {
+	WireFormat uint16
+	GroupID    []byte
+	Epoch      uint64
+}
+
+// ParseMessageHeader reads the group ID and epoch of a TLS-encoded
+// MLSMessage carrying a PublicMessage or PrivateMessage. The rest of the
+// message is not checked. Errors wrap ErrInvalidMessage.
+func ParseMessageHeader(data []byte) (*MessageHeader, error) {
+	return parseMessageHeader(&reader{data: data})
+}
+
+func parseMessageHeader(r *reader) (*MessageHeader, error) {
+	h := &MessageHeader{}
+
+	version, err := r.uint16()
+	if err != nil {
+		return nil, invalidMessage(fieldError("version", err))
+	}
+	if version != ProtocolVersionMLS10 {
+		return nil, invalidMessage(fmt.Errorf("unsupported protocol version %d", version))
+	}
+	if h.WireFormat, err = r.uint16(); err != nil {
+		return nil, invalidMessage(fieldError("wire_format", err))
+	}
+	if h.WireFormat != WireFormatPublicMessage && h.WireFormat != WireFormatPrivateMessage {
+		return nil, invalidMessage(fmt.Errorf("wire_format is %d, want public or private message", h.WireFormat))
+	}
+	// Both a PublicMessage's FramedContent and a PrivateMessage start with
+	// the group ID and epoch.
+	if h.GroupID, err = r.vector(); err != nil {
+		return nil, invalidMessage(fieldError("group_id", err))
+	}
+	if len(h.GroupID) == 0 {
+		return nil, invalidMessage(errors.New("empty group_id"))
+	}
+	if h.Epoch, err = r.uint64(); err != nil {
+		return nil, invalidMessage(fieldError("epoch", err))
+	}
+	return h, nil
+}
+
+// IsLeafUpdate reports whether data is a TLS-encoded MLSMessage in which a
+// member replaces their own leaf key: a PublicMessage carrying an Update
+// proposal, or a Commit with an UpdatePath. Other proposals and Commits
+// without a path leave the sender's leaf as it was. The content of a
+// PrivateMessage is encrypted, so it never counts. Only as much of the
+// message as is needed to tell is read.
+func IsLeafUpdate(data []byte) bool {
+	r := &reader{data: data}
+	h, err := parseMessageHeader(r)
+	if err != nil || h.WireFormat != WireFormatPublicMessage {
+		return false
+	}
+
+	// FramedContent continues with the sender, authenticated data and
+	// content type.
+	senderType, err := r.uint8()
+	if err != nil {
+		return false
+	}
+	if senderType == senderTypeMember || senderType == senderTypeExternal {
+		if _, err := r.uint32(); err != nil { // leaf_index or sender_index
+			return false
+		}
+	}
+	if _, err := r.vector(); err != nil { // authenticated_data
+		return false
+	}
+	contentType, err := r.uint8()
+	if err != nil {
+		return false
+	}
+
+	switch contentType {
+	case ContentTypeProposal:
+		proposalType, err := r.uint16()
+		return err == nil && senderType == senderTypeMember && proposalType == ProposalTypeUpdate
+	case ContentTypeCommit:
+		if senderType != senderTypeMember && senderType != senderTypeNewMemberCommit {
+			return false
+		}
+		if _, err := r.vector(); err != nil { // proposals
+			return false
+		}
+		hasPath, err := r.uint8() // optional<UpdatePath>
+		return err == nil && hasPath == 1
+	default:
+		return false
+	}
+}
+
+// invalidMessage wraps err so that it matches ErrInvalidMessage.
+func invalidMessage(err error) error {
+	return fmt.Errorf("%w: %w", ErrInvalidMessage, err)
+}
@@ -0,0 +1,76 @@
+package mls
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/sovereign-im/sovereign/server/internal/mls/mlstest"
+)
+
+func TestParseMessageHeader(t *testing.T) {
+	groupID := []byte("group-1")
+	valid := mlstest.NewPrivateMessage(t, mlstest.MessageOptions{GroupID: groupID, Epoch: 9})
+
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr bool
+	}{
+		{name: "private message", data: valid},
+		{name: "header only", data: valid[:2+2+1+len(groupID)+8]},
+		{name: "empty", data: nil, wantErr: true},
+		{name: "truncated epoch", data: valid[:2+2+1+len(groupID)+4], wantErr: true},
+		{name: "empty group id", data: mlstest.NewPrivateMessage(t, mlstest.MessageOptions{GroupID: []byte{}}), wantErr: true},
+		{name: "group info", data: mlstest.NewGroupInfo(t, mlstest.GroupInfoOptions{GroupID: groupID}), wantErr: true},
+		{name: "opaque bytes", data: []byte("commit-data"), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, err := ParseMessageHeader(tt.data)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidMessage) {
+					t.Fatalf("ParseMessageHeader: err = %v, want ErrInvalidMessage", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseMessageHeader: %v", err)
+			}
+			if h.WireFormat != WireFormatPrivateMessage || !bytes.Equal(h.GroupID, groupID) || h.Epoch != 9 {
+				t.Errorf("ParseMessageHeader = %+v, want private message for %q at epoch 9", h, groupID)
+			}
+		})
+	}
+}
+
+func TestIsLeafUpdate(t *testing.T) {
+	public := func(opts mlstest.PublicMessageOptions) []byte {
+		return mlstest.NewPublicMessage(t, opts)
+	}
+	commitWithPath := public(mlstest.PublicMessageOptions{UpdatePath: true})
+
+	tests := []struct {
+		name string
+		data []byte
+		want bool
+	}{
+		{name: "update proposal", data: public(mlstest.PublicMessageOptions{ProposalType: ProposalTypeUpdate}), want: true},
+		{name: "commit with update path", data: commitWithPath, want: true},
+		{name: "add proposal", data: public(mlstest.PublicMessageOptions{ProposalType: ProposalTypeAdd})},
+		{name: "remove proposal", data: public(mlstest.PublicMessageOptions{ProposalType: ProposalTypeRemove})},
+		{name: "commit without path", data: public(mlstest.PublicMessageOptions{})},
+		{name: "truncated commit", data: commitWithPath[:40]}, // inside the proposals
+		{name: "private message", data: mlstest.NewPrivateMessage(t, mlstest.MessageOptions{})},
+		{name: "opaque bytes", data: []byte("commit-data")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsLeafUpdate(tt.data); got != tt.want {
+				t.Errorf("IsLeafUpdate = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -4,52 +4,147 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"math"
 	"time"
 
 	"github.com/sovereign-im/sovereign/server/internal/store"
 )
 
-// Default key package expiry (30 days).
-const defaultKeyPackageExpiry = 30 * 24 * time.Hour
+// MaxKeyPackageBatch is the most KeyPackages that can be uploaded, or users
+// fetched for, in one request.
+const MaxKeyPackageBatch = 100
 
 // Errors for MLS operations.
 var (
-	ErrNoKeyPackage    = errors.New("no key package available")
-	ErrInvalidPayload  = errors.New("invalid key package payload")
-	ErrNotMember       = errors.New("not a member of conversation")
+	ErrNoKeyPackage      = errors.New("no key package available")
+	ErrInvalidPayload    = errors.New("invalid key package payload")
+	ErrBatchTooLarge     = errors.New("too many key packages or users in one request")
+	ErrNotMember         = errors.New("not a member of conversation")
 	ErrRecipientNotFound = errors.New("recipient not found")
+	ErrInvalidGroupInfo  = errors.New("invalid group info")
+	ErrStaleGroupInfo    = errors.New("group info is older than the stored epoch")
+	ErrNoGroupInfo       = errors.New("no group info published")
+	ErrInvalidMessage    = errors.New("invalid MLS message")
+
+	ErrSealedSenderDisabled = errors.New("conversation does not use sealed sender")
+	ErrInvalidDeliveryToken = errors.New("invalid or expired delivery token")
 )
 
 // Service manages MLS key packages and message routing.
-// The server is a delivery service — it stores and forwards opaque blobs
-// without performing MLS crypto.
+// The server is a delivery service — it stores and forwards MLS messages
+// without taking part in groups. It only checks the structure and
+// signatures of KeyPackages.
 type Service struct {
-	store *store.Store
+	store          store.Backend
+	minKeyPackages int
+	updateInterval time.Duration
+	deliveryKey    []byte // signs delivery tokens; kept in the store
 }
 
-// NewService creates a new MLS service.
-func NewService(s *store.Store) *Service {
-	return &Service{store: s}
+// NewService creates a new MLS service. Users are told to upload more key
+// packages when they have fewer than minKeyPackages, and to update their
+// leaf key in groups where they have not for updateInterval; zero disables
+// either.
+//
+// The key that signs delivery tokens is kept in the store, sealed when
+// encryption at rest is on, so that tokens stay valid across restarts.
+func NewService(s store.Backend, minKeyPackages int, updateInterval time.Duration) (*Service, error) {
+	deliveryKey, err := s.ServerSecret(context.Background(), "mls.delivery_key", 32)
+	if err != nil {
+		return nil, fmt.Errorf("load delivery key: %w", err)
+	}
+	return &Service{
+		store:          s,
+		minKeyPackages: minKeyPackages,
+		updateInterval: updateInterval,
+		deliveryKey:    deliveryKey,
+	}, nil
 }
 
-// UploadKeyPackage validates basic structure and stores a key package for the user.
+// UploadKeyPackage parses and verifies an RFC 9420 KeyPackage and stores it
+// for the user until the end of its lifetime. Malformed, expired or badly
+// signed KeyPackages are rejected with ErrInvalidPayload.
 func (s *Service) UploadKeyPackage(ctx context.Context, userID string, data []byte) error {
-	if len(data) == 0 {
+	return s.UploadKeyPackages(ctx, userID, [][]byte{data}, nil)
+}
+
+// UploadLastResortKeyPackage verifies a KeyPackage like UploadKeyPackage
+// and stores it as the user's last-resort key package, replacing any
+// previous one. It is handed out when no other key package is left and is
+// never consumed, as recommended by RFC 9420 Section 16.8.
+func (s *Service) UploadLastResortKeyPackage(ctx context.Context, userID string, data []byte) error {
+	return s.UploadKeyPackages(ctx, userID, nil, data)
+}
+
+// UploadKeyPackages verifies and stores several key packages, and
+// optionally a last-resort key package, in one transaction. If any of them
+// is invalid nothing is stored and the error names its index in packages.
+// Returns ErrBatchTooLarge for more than MaxKeyPackageBatch packages.
+func (s *Service) UploadKeyPackages(ctx context.Context, userID string, packages [][]byte, lastResort []byte) error {
+	if len(packages) == 0 && lastResort == nil {
 		return ErrInvalidPayload
 	}
-	// The server treats key packages as opaque blobs. We only validate
-	// that the data is non-empty. Cryptographic validation happens on clients.
-	expiresAt := time.Now().Add(defaultKeyPackageExpiry).Unix()
-	_, err := s.store.StoreKeyPackage(ctx, userID, data, expiresAt)
-	if err != nil {
-		return fmt.Errorf("upload key package: %w", err)
+	if len(packages) > MaxKeyPackageBatch {
+		return ErrBatchTooLarge
+	}
+
+	kps := make([]*store.KeyPackage, 0, len(packages)+1)
+	for i, data := range packages {
+		kp, err := verifyKeyPackage(data)
+		if err != nil {
+			if len(packages) > 1 {
+				return fmt.Errorf("key package %d: %w", i, err)
+			}
+			return err
+		}
+		kps = append(kps, storedKeyPackage(data, kp, false))
+	}
+	if lastResort != nil {
+		kp, err := verifyKeyPackage(lastResort)
+		if err != nil {
+			return fmt.Errorf("last-resort key package: %w", err)
+		}
+		kps = append(kps, storedKeyPackage(lastResort, kp, true))
+	}
+
+	if err := s.store.StoreKeyPackages(ctx, userID, kps); err != nil {
+		return fmt.Errorf("upload key packages: %w", err)
 	}
 	return nil
 }
 
+// storedKeyPackage returns the store row for a verified key package.
+func storedKeyPackage(data []byte, kp *KeyPackage, lastResort bool) *store.KeyPackage {
+	return &store.KeyPackage{
+		KeyPackageData: data,
+		ExpiresAt:      kp.ExpiresAt().Unix(),
+		LastResort:     lastResort,
+		CipherSuite:    kp.CipherSuite,
+		CredentialType: kp.LeafNode.Credential.Type,
+	}
+}
+
+// verifyKeyPackage parses data and verifies it at the current time.
+func verifyKeyPackage(data []byte) (*KeyPackage, error) {
+	if len(data) == 0 {
+		return nil, ErrInvalidPayload
+	}
+	kp, err := ParseKeyPackage(data)
+	if err != nil {
+		return nil, err
+	}
+	if err := kp.Verify(time.Now()); err != nil {
+		return nil, err
+	}
+	return kp, nil
+}
+
 // FetchKeyPackage consumes and returns one key package for the target user.
-func (s *Service) FetchKeyPackage(ctx context.Context, targetUserID string) ([]byte, error) {
-	kp, err := s.store.ConsumeKeyPackage(ctx, targetUserID)
+// If cipherSuite is non-zero, only key packages for that suite are
+// returned. If only the user's last-resort key package is left, it is
+// returned without being consumed.
+func (s *Service) FetchKeyPackage(ctx context.Context, targetUserID string, cipherSuite uint16) ([]byte, error) {
+	kp, err := s.store.ConsumeKeyPackage(ctx, targetUserID, cipherSuite)
 	if err != nil {
 		if errors.Is(err, store.ErrNotFound) {
 			return nil, ErrNoKeyPackage
@@ -59,11 +154,184 @@ func (s *Service) FetchKeyPackage(ctx context.Context, targetUserID string) ([]b
 	return kp.KeyPackageData, nil
 }
 
-// CountKeyPackages returns the number of available key packages for a user.
+// FetchKeyPackages consumes one key package for each user in a single
+// transaction, as FetchKeyPackage does. Users with no key package
+// available are absent from the result. Returns ErrBatchTooLarge for more
+// than MaxKeyPackageBatch users.
+func (s *Service) FetchKeyPackages(ctx context.Context, targetUserIDs []string, cipherSuite uint16) (map[string][]byte, error) {
+	if len(targetUserIDs) > MaxKeyPackageBatch {
+		return nil, ErrBatchTooLarge
+	}
+	kps, err := s.store.ConsumeKeyPackages(ctx, targetUserIDs, cipherSuite)
+	if err != nil {
+		return nil, fmt.Errorf("fetch key packages: %w", err)
+	}
+	data := make(map[string][]byte, len(kps))
+	for userID, kp := range kps {
+		data[userID] = kp.KeyPackageData
+	}
+	return data, nil
+}
+
+// CountKeyPackages returns the number of available key packages for a user,
+// not counting the last-resort one.
 func (s *Service) CountKeyPackages(ctx context.Context, userID string) (int, error) {
 	return s.store.CountKeyPackages(ctx, userID)
 }
 
+// KeyPackageInventory returns a user's available key packages counted per
+// cipher suite.
+func (s *Service) KeyPackageInventory(ctx context.Context, userID string) ([]store.KeyPackageSuiteCount, error) {
+	counts, err := s.store.KeyPackageInventory(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("key package inventory: %w", err)
+	}
+	return counts, nil
+}
+
+// MinKeyPackages returns the number of key packages users should keep on
+// the server, or zero if no minimum is configured.
+func (s *Service) MinKeyPackages() int {
+	return s.minKeyPackages
+}
+
+// KeyPackagesLow returns the number of key packages available for a user,
+// not counting the last-resort one, and whether it is below the configured
+// minimum.
+func (s *Service) KeyPackagesLow(ctx context.Context, userID string) (available int, low bool, err error) {
+	if s.minKeyPackages <= 0 {
+		return 0, false, nil
+	}
+	available, err = s.store.CountKeyPackages(ctx, userID)
+	if err != nil {
+		return 0, false, fmt.Errorf("count key packages: %w", err)
+	}
+	return available, available < s.minKeyPackages, nil
+}
+
+// PublishGroupInfo stores a GroupInfo, wrapped in an MLSMessage, as the
+// latest for a conversation so that members can rejoin the group by
+// external commit (RFC 9420 Section 12.4.3.2). The user must be a member of
+// the conversation. A GroupInfo for an earlier epoch than the stored one is
+// rejected with ErrStaleGroupInfo, and one for a different MLS group with
+// ErrInvalidGroupInfo. Returns the GroupInfo's epoch.
+func (s *Service) PublishGroupInfo(ctx context.Context, conversationID, userID string, data []byte) (uint64, error) {
+	if err := s.checkMember(ctx, conversationID, userID); err != nil {
+		return 0, err
+	}
+	gi, err := ParseGroupInfo(data)
+	if err != nil {
+		return 0, err
+	}
+
+	err = s.store.PutMLSGroupState(ctx, &store.MLSGroupState{
+		ConversationID: conversationID,
+		GroupID:        gi.GroupID,
+		Epoch:          int64(gi.Epoch),
+		GroupInfo:      data,
+		UpdatedBy:      userID,
+	})
+	switch {
+	case errors.Is(err, store.ErrStale):
+		return 0, fmt.Errorf("%w: %w", ErrStaleGroupInfo, err)
+	case errors.Is(err, store.ErrConflict):
+		return 0, invalidGroupInfo(errors.New("group_id does not match the conversation's group"))
+	case err != nil:
+		return 0, fmt.Errorf("publish group info: %w", err)
+	}
+	return gi.Epoch, nil
+}
+
+// GroupInfo returns the latest GroupInfo published for a conversation and
+// its epoch. The user must be a member of the conversation. Returns
+// ErrNoGroupInfo if none has been published.
+func (s *Service) GroupInfo(ctx context.Context, conversationID, userID string) ([]byte, uint64, error) {
+	if err := s.checkMember(ctx, conversationID, userID); err != nil {
+		return nil, 0, err
+	}
+	gs, err := s.store.GetMLSGroupState(ctx, conversationID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, 0, ErrNoGroupInfo
+		}
+		return nil, 0, fmt.Errorf("get group info: %w", err)
+	}
+	return gs.GroupInfo, uint64(gs.Epoch), nil
+}
+
+// RecordLeafUpdate records that a member rotated their leaf key, if the
+// Commit or Proposal they sent does so according to IsLeafUpdate. Other
+// messages are ignored, so that a member cannot put off being asked to
+// update by sending Proposals that change nothing about their own leaf.
+func (s *Service) RecordLeafUpdate(ctx context.Context, conversationID, userID string, message []byte) error {
+	if !IsLeafUpdate(message) {
+		return nil
+	}
+	h, err := ParseMessageHeader(message)
+	if err != nil {
+		return fmt.Errorf("record leaf update: %w", err)
+	}
+	var epoch *int64
+	if h.Epoch <= math.MaxInt64 {
+		e := int64(h.Epoch)
+		epoch = &e
+	}
+	if err := s.store.RecordMemberUpdate(ctx, conversationID, userID, epoch); err != nil {
+		return fmt.Errorf("record leaf update: %w", err)
+	}
+	return nil
+}
+
+// UpdateInterval returns how long members may go without updating their
+// leaf key before they are asked to, or zero if they are never asked.
+func (s *Service) UpdateInterval() time.Duration {
+	return s.updateInterval
+}
+
+// ClaimStaleUpdates returns the conversations in which the user has not
+// updated their leaf key for the update interval and has not been asked to
+// since, and records that they have now been asked.
+func (s *Service) ClaimStaleUpdates(ctx context.Context, userID string) ([]*store.MemberUpdate, error) {
+	if s.updateInterval <= 0 {
+		return nil, nil
+	}
+	staleBefore := time.Now().Add(-s.updateInterval).Unix()
+	updates, err := s.store.ClaimStaleMembers(ctx, userID, staleBefore)
+	if err != nil {
+		return nil, fmt.Errorf("claim stale updates: %w", err)
+	}
+	return updates, nil
+}
+
+// MemberUpdates returns when each member of a conversation last updated
+// their leaf key.
+func (s *Service) MemberUpdates(ctx context.Context, conversationID string) ([]*store.MemberUpdate, error) {
+	updates, err := s.store.GetMemberUpdates(ctx, conversationID)
+	if err != nil {
+		return nil, fmt.Errorf("member updates: %w", err)
+	}
+	return updates, nil
+}
+
+// IsStale reports whether a member has gone longer than the update
+// interval without updating their leaf key at now.
+func (s *Service) IsStale(u *store.MemberUpdate, now time.Time) bool {
+	return s.updateInterval > 0 && u.UpdatedAt() < now.Add(-s.updateInterval).Unix()
+}
+
+// checkMember returns ErrNotMember unless userID is a member of the
+// conversation.
+func (s *Service) checkMember(ctx context.Context, conversationID, userID string) error {
+	isMember, err := s.store.IsUserMember(ctx, conversationID, userID)
+	if err != nil {
+		return fmt.Errorf("check membership: %w", err)
+	}
+	if !isMember {
+		return ErrNotMember
+	}
+	return nil
+}
+
 // CleanupExpiredKeyPackages removes expired key packages.
 func (s *Service) CleanupExpiredKeyPackages(ctx context.Context) (int64, error) {
 	return s.store.DeleteExpiredKeyPackages(ctx)
@@ -1,21 +1,44 @@
 package mls
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"testing"
+	"time"
 
+	"github.com/sovereign-im/sovereign/server/internal/mls/mlstest"
 	"github.com/sovereign-im/sovereign/server/internal/store"
 )
 
 func newTestService(t *testing.T) (*Service, *store.Store) {
+	t.Helper()
+	s := newTestStore(t)
+	svc, err := NewService(s, 0, 0)
+	if err != nil {
+		t.Fatalf("NewService: %v", err)
+	}
+	return svc, s
+}
+
+// newTestStore opens an in-memory store with the users the tests use.
+func newTestStore(t *testing.T) *store.Store {
 	t.Helper()
 	s, err := store.New(":memory:")
 	if err != nil {
 		t.Fatalf("store.New: %v", err)
 	}
 	t.Cleanup(func() { s.Close() })
-	return NewService(s), s
+
+	// Key packages and memberships belong to existing users.
+	now := time.Now().Unix()
+	for _, id := range []string{"alice", "bob", "carol"} {
+		u := &store.User{ID: id, Username: id, DisplayName: id, Role: "member", Enabled: true, CreatedAt: now, UpdatedAt: now}
+		if err := s.CreateUser(context.Background(), u); err != nil {
+			t.Fatalf("CreateUser(%s): %v", id, err)
+		}
+	}
+	return s
 }
 
 func TestUploadKeyPackage(t *testing.T) {
@@ -28,7 +51,22 @@ func TestUploadKeyPackage(t *testing.T) {
 		{
 			name:   "valid key package",
 			userID: "alice",
-			data:   []byte("key-package-blob"),
+			data:   mlstest.NewKeyPackage(t, mlstest.KeyPackageOptions{}),
+		},
+		{
+			name:    "unparseable data returns ErrInvalidPayload",
+			userID:  "alice",
+			data:    []byte("key-package-blob"),
+			wantErr: ErrInvalidPayload,
+		},
+		{
+			name:   "expired key package returns ErrInvalidPayload",
+			userID: "alice",
+			data: mlstest.NewKeyPackage(t, mlstest.KeyPackageOptions{
+				NotBefore: time.Now().Add(-48 * time.Hour),
+				NotAfter:  time.Now().Add(-24 * time.Hour),
+			}),
+			wantErr: ErrInvalidPayload,
 		},
 		{
 			name:    "empty data returns ErrInvalidPayload",
@@ -68,16 +106,17 @@ func TestFetchKeyPackage(t *testing.T) {
 		svc, _ := newTestService(t)
 		ctx := context.Background()
 
-		if err := svc.UploadKeyPackage(ctx, "alice", []byte("kp-data")); err != nil {
+		kp := mlstest.NewKeyPackage(t, mlstest.KeyPackageOptions{})
+		if err := svc.UploadKeyPackage(ctx, "alice", kp); err != nil {
 			t.Fatalf("UploadKeyPackage: %v", err)
 		}
 
-		data, err := svc.FetchKeyPackage(ctx, "alice")
+		data, err := svc.FetchKeyPackage(ctx, "alice", 0)
 		if err != nil {
 			t.Fatalf("FetchKeyPackage: %v", err)
 		}
-		if string(data) != "kp-data" {
-			t.Errorf("data = %q, want kp-data", data)
+		if !bytes.Equal(data, kp) {
+			t.Errorf("data = %x, want %x", data, kp)
 		}
 	})
 
@@ -85,7 +124,7 @@ func TestFetchKeyPackage(t *testing.T) {
 		svc, _ := newTestService(t)
 		ctx := context.Background()
 
-		_, err := svc.FetchKeyPackage(ctx, "nobody")
+		_, err := svc.FetchKeyPackage(ctx, "nobody", 0)
 		if !errors.Is(err, ErrNoKeyPackage) {
 			t.Errorf("error = %v, want ErrNoKeyPackage", err)
 		}
@@ -95,18 +134,18 @@ func TestFetchKeyPackage(t *testing.T) {
 		svc, _ := newTestService(t)
 		ctx := context.Background()
 
-		if err := svc.UploadKeyPackage(ctx, "alice", []byte("single-use")); err != nil {
+		if err := svc.UploadKeyPackage(ctx, "alice", mlstest.NewKeyPackage(t, mlstest.KeyPackageOptions{})); err != nil {
 			t.Fatalf("UploadKeyPackage: %v", err)
 		}
 
 		// First fetch succeeds.
-		_, err := svc.FetchKeyPackage(ctx, "alice")
+		_, err := svc.FetchKeyPackage(ctx, "alice", 0)
 		if err != nil {
 			t.Fatalf("first FetchKeyPackage: %v", err)
 		}
 
 		// Second fetch fails — consumed.
-		_, err = svc.FetchKeyPackage(ctx, "alice")
+		_, err = svc.FetchKeyPackage(ctx, "alice", 0)
 		if !errors.Is(err, ErrNoKeyPackage) {
 			t.Errorf("second fetch error = %v, want ErrNoKeyPackage", err)
 		}
@@ -116,19 +155,21 @@ func TestFetchKeyPackage(t *testing.T) {
 		svc, _ := newTestService(t)
 		ctx := context.Background()
 
-		if err := svc.UploadKeyPackage(ctx, "alice", []byte("alice-kp")); err != nil {
+		aliceKP := mlstest.NewKeyPackage(t, mlstest.KeyPackageOptions{Identity: "alice"})
+		bobKP := mlstest.NewKeyPackage(t, mlstest.KeyPackageOptions{Identity: "bob"})
+		if err := svc.UploadKeyPackage(ctx, "alice", aliceKP); err != nil {
 			t.Fatalf("UploadKeyPackage alice: %v", err)
 		}
-		if err := svc.UploadKeyPackage(ctx, "bob", []byte("bob-kp")); err != nil {
+		if err := svc.UploadKeyPackage(ctx, "bob", bobKP); err != nil {
 			t.Fatalf("UploadKeyPackage bob: %v", err)
 		}
 
-		data, err := svc.FetchKeyPackage(ctx, "bob")
+		data, err := svc.FetchKeyPackage(ctx, "bob", 0)
 		if err != nil {
 			t.Fatalf("FetchKeyPackage bob: %v", err)
 		}
-		if string(data) != "bob-kp" {
-			t.Errorf("data = %q, want bob-kp", data)
+		if !bytes.Equal(data, bobKP) {
+			t.Errorf("data = %x, want bob's key package", data)
 		}
 	})
 }
@@ -149,7 +190,7 @@ func TestCountKeyPackages(t *testing.T) {
 
 	t.Run("counts after uploads", func(t *testing.T) {
 		for i := 0; i < 5; i++ {
-			if err := svc.UploadKeyPackage(ctx, "alice", []byte("kp")); err != nil {
+			if err := svc.UploadKeyPackage(ctx, "alice", mlstest.NewKeyPackage(t, mlstest.KeyPackageOptions{})); err != nil {
 				t.Fatalf("UploadKeyPackage: %v", err)
 			}
 		}
@@ -163,7 +204,7 @@ func TestCountKeyPackages(t *testing.T) {
 	})
 
 	t.Run("decrements after fetch", func(t *testing.T) {
-		_, err := svc.FetchKeyPackage(ctx, "alice")
+		_, err := svc.FetchKeyPackage(ctx, "alice", 0)
 		if err != nil {
 			t.Fatalf("FetchKeyPackage: %v", err)
 		}
@@ -177,18 +218,180 @@ func TestCountKeyPackages(t *testing.T) {
 	})
 }
 
+func TestUploadKeyPackages(t *testing.T) {
+	valid := func() []byte { return mlstest.NewKeyPackage(t, mlstest.KeyPackageOptions{}) }
+
+	tests := []struct {
+		name       string
+		packages   [][]byte
+		lastResort []byte
+		wantCount  int
+		wantErr    error
+	}{
+		{name: "batch", packages: [][]byte{valid(), valid(), valid()}, wantCount: 3},
+		{name: "batch with last resort", packages: [][]byte{valid()}, lastResort: valid(), wantCount: 1},
+		{name: "one invalid stores none", packages: [][]byte{valid(), []byte("garbage")}, wantErr: ErrInvalidPayload},
+		{name: "invalid last resort stores none", packages: [][]byte{valid()}, lastResort: []byte("garbage"), wantErr: ErrInvalidPayload},
+		{name: "empty", wantErr: ErrInvalidPayload},
+		{name: "too many", packages: make([][]byte, MaxKeyPackageBatch+1), wantErr: ErrBatchTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, _ := newTestService(t)
+			ctx := context.Background()
+
+			err := svc.UploadKeyPackages(ctx, "alice", tt.packages, tt.lastResort)
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && err != nil {
+				t.Fatalf("UploadKeyPackages: %v", err)
+			}
+
+			count, err := svc.CountKeyPackages(ctx, "alice")
+			if err != nil {
+				t.Fatalf("CountKeyPackages: %v", err)
+			}
+			if count != tt.wantCount {
+				t.Errorf("count = %d, want %d", count, tt.wantCount)
+			}
+		})
+	}
+}
+
+func TestFetchKeyPackages(t *testing.T) {
+	svc, _ := newTestService(t)
+	ctx := context.Background()
+
+	aliceKP := mlstest.NewKeyPackage(t, mlstest.KeyPackageOptions{Identity: "alice"})
+	if err := svc.UploadKeyPackage(ctx, "alice", aliceKP); err != nil {
+		t.Fatalf("UploadKeyPackage: %v", err)
+	}
+
+	got, err := svc.FetchKeyPackages(ctx, []string{"alice", "bob"}, 0)
+	if err != nil {
+		t.Fatalf("FetchKeyPackages: %v", err)
+	}
+	if len(got) != 1 || !bytes.Equal(got["alice"], aliceKP) {
+		t.Errorf("FetchKeyPackages = %v, want only alice's key package", got)
+	}
+
+	if _, err := svc.FetchKeyPackages(ctx, make([]string, MaxKeyPackageBatch+1), 0); !errors.Is(err, ErrBatchTooLarge) {
+		t.Errorf("too many users: error = %v, want ErrBatchTooLarge", err)
+	}
+}
+
+func TestKeyPackageCipherSuites(t *testing.T) {
+	svc, _ := newTestService(t)
+	ctx := context.Background()
+
+	ed25519KP := mlstest.NewKeyPackage(t, mlstest.KeyPackageOptions{CipherSuite: mlstest.CipherSuiteEd25519})
+	p256KP := mlstest.NewKeyPackage(t, mlstest.KeyPackageOptions{CipherSuite: mlstest.CipherSuiteP256})
+	if err := svc.UploadKeyPackages(ctx, "alice", [][]byte{ed25519KP, p256KP}, nil); err != nil {
+		t.Fatalf("UploadKeyPackages: %v", err)
+	}
+
+	inventory, err := svc.KeyPackageInventory(ctx, "alice")
+	if err != nil {
+		t.Fatalf("KeyPackageInventory: %v", err)
+	}
+	if len(inventory) != 2 || inventory[0].CipherSuite != CipherSuiteX25519AES128Ed25519 || inventory[1].CipherSuite != CipherSuiteP256AES128P256 {
+		t.Errorf("inventory = %+v, want one key package each for suites 1 and 2", inventory)
+	}
+
+	data, err := svc.FetchKeyPackage(ctx, "alice", CipherSuiteP256AES128P256)
+	if err != nil {
+		t.Fatalf("FetchKeyPackage: %v", err)
+	}
+	if !bytes.Equal(data, p256KP) {
+		t.Error("FetchKeyPackage(suite 2) did not return the P-256 key package")
+	}
+	if _, err := svc.FetchKeyPackage(ctx, "alice", CipherSuiteP256AES128P256); !errors.Is(err, ErrNoKeyPackage) {
+		t.Errorf("second suite 2 fetch: error = %v, want ErrNoKeyPackage", err)
+	}
+}
+
+func TestKeyPackagesLow(t *testing.T) {
+	tests := []struct {
+		name          string
+		min           int
+		uploads       int
+		lastResort    bool
+		wantAvailable int
+		wantLow       bool
+	}{
+		{name: "no minimum", min: 0, uploads: 0, wantAvailable: 0, wantLow: false},
+		{name: "at minimum", min: 2, uploads: 2, wantAvailable: 2, wantLow: false},
+		{name: "below minimum", min: 2, uploads: 1, wantAvailable: 1, wantLow: true},
+		{name: "last resort not counted", min: 1, lastResort: true, wantAvailable: 0, wantLow: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, err := NewService(newTestStore(t), tt.min, 0)
+			if err != nil {
+				t.Fatalf("NewService: %v", err)
+			}
+			ctx := context.Background()
+
+			for i := 0; i < tt.uploads; i++ {
+				if err := svc.UploadKeyPackage(ctx, "alice", mlstest.NewKeyPackage(t, mlstest.KeyPackageOptions{})); err != nil {
+					t.Fatalf("UploadKeyPackage: %v", err)
+				}
+			}
+			if tt.lastResort {
+				if err := svc.UploadLastResortKeyPackage(ctx, "alice", mlstest.NewKeyPackage(t, mlstest.KeyPackageOptions{})); err != nil {
+					t.Fatalf("UploadLastResortKeyPackage: %v", err)
+				}
+			}
+
+			available, low, err := svc.KeyPackagesLow(ctx, "alice")
+			if err != nil {
+				t.Fatalf("KeyPackagesLow: %v", err)
+			}
+			if available != tt.wantAvailable || low != tt.wantLow {
+				t.Errorf("KeyPackagesLow = %d, %v, want %d, %v", available, low, tt.wantAvailable, tt.wantLow)
+			}
+		})
+	}
+}
+
+func TestFetchLastResortKeyPackage(t *testing.T) {
+	svc, _ := newTestService(t)
+	ctx := context.Background()
+
+	lastResort := mlstest.NewKeyPackage(t, mlstest.KeyPackageOptions{})
+	if err := svc.UploadLastResortKeyPackage(ctx, "alice", lastResort); err != nil {
+		t.Fatalf("UploadLastResortKeyPackage: %v", err)
+	}
+	if err := svc.UploadLastResortKeyPackage(ctx, "alice", []byte("garbage")); !errors.Is(err, ErrInvalidPayload) {
+		t.Errorf("invalid last resort: error = %v, want ErrInvalidPayload", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		data, err := svc.FetchKeyPackage(ctx, "alice", 0)
+		if err != nil {
+			t.Fatalf("FetchKeyPackage %d: %v", i, err)
+		}
+		if !bytes.Equal(data, lastResort) {
+			t.Errorf("FetchKeyPackage %d returned %x, want the last-resort key package", i, data)
+		}
+	}
+}
+
 func TestCleanupExpiredKeyPackages(t *testing.T) {
 	svc, _ := newTestService(t)
 	ctx := context.Background()
 
 	// Upload some valid key packages.
 	for i := 0; i < 3; i++ {
-		if err := svc.UploadKeyPackage(ctx, "alice", []byte("valid-kp")); err != nil {
+		if err := svc.UploadKeyPackage(ctx, "alice", mlstest.NewKeyPackage(t, mlstest.KeyPackageOptions{})); err != nil {
 			t.Fatalf("UploadKeyPackage: %v", err)
 		}
 	}
 
-	// Cleanup should delete 0 (all are fresh with 30-day expiry).
+	// Cleanup should delete 0 (all are within their lifetime).
 	deleted, err := svc.CleanupExpiredKeyPackages(ctx)
 	if err != nil {
 		t.Fatalf("CleanupExpiredKeyPackages: %v", err)
@@ -0,0 +1,260 @@
+// Package mlstest builds RFC 9420 structures for tests of the MLS delivery
+// service.
+package mlstest
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+// Cipher suites supported by the builders.
+const (
+	CipherSuiteEd25519 = 0x0001
+	CipherSuiteP256    = 0x0002
+)
+
+// KeyPackageOptions configures NewKeyPackage. Zero values give a valid
+// KeyPackage for cipher suite 1 that expires in 30 days.
+type KeyPackageOptions struct {
+	CipherSuite uint16
+	Identity    string
+	NotBefore   time.Time
+	NotAfter    time.Time
+}
+
+// NewKeyPackage returns a TLS-encoded KeyPackage signed with a fresh key.
+func NewKeyPackage(t testing.TB, opts KeyPackageOptions) []byte {
+	t.Helper()
+	if opts.CipherSuite == 0 {
+		opts.CipherSuite = CipherSuiteEd25519
+	}
+	if opts.Identity == "" {
+		opts.Identity = "test"
+	}
+	if opts.NotBefore.IsZero() {
+		opts.NotBefore = time.Now().Add(-time.Hour)
+	}
+	if opts.NotAfter.IsZero() {
+		opts.NotAfter = time.Now().Add(30 * 24 * time.Hour)
+	}
+
+	pub, sign := newSigner(t, opts.CipherSuite)
+
+	// LeafNode, up to its signature.
+	var leaf []byte
+	leaf = appendVector(leaf, randomBytes(t, 32)) // encryption_key
+	leaf = appendVector(leaf, pub)                // signature_key
+	leaf = binary.BigEndian.AppendUint16(leaf, 1) // credential_type basic
+	leaf = appendVector(leaf, []byte(opts.Identity))
+	leaf = appendVector(leaf, uint16s(1))                // versions
+	leaf = appendVector(leaf, uint16s(opts.CipherSuite)) // cipher_suites
+	leaf = appendVector(leaf, nil)                       // extensions
+	leaf = appendVector(leaf, nil)                       // proposals
+	leaf = appendVector(leaf, uint16s(1))                // credentials
+	leaf = append(leaf, 1)                               // leaf_node_source key_package
+	leaf = binary.BigEndian.AppendUint64(leaf, uint64(opts.NotBefore.Unix()))
+	leaf = binary.BigEndian.AppendUint64(leaf, uint64(opts.NotAfter.Unix()))
+	leaf = appendVector(leaf, nil) // extensions
+	leaf = appendVector(leaf, sign(signContent("LeafNodeTBS", leaf)))
+
+	var kp []byte
+	kp = binary.BigEndian.AppendUint16(kp, 1) // version mls10
+	kp = binary.BigEndian.AppendUint16(kp, opts.CipherSuite)
+	kp = appendVector(kp, randomBytes(t, 32)) // init_key
+	kp = append(kp, leaf...)
+	kp = appendVector(kp, nil) // extensions
+	return appendVector(kp, sign(signContent("KeyPackageTBS", kp)))
+}
+
+// GroupInfoOptions configures NewGroupInfo. Zero values give a GroupInfo
+// for cipher suite 1 at epoch 0 of a random group.
+type GroupInfoOptions struct {
+	CipherSuite uint16
+	GroupID     []byte
+	Epoch       uint64
+}
+
+// NewGroupInfo returns a GroupInfo wrapped in a TLS-encoded MLSMessage.
+// Its hashes, confirmation tag and signature are random, since the server
+// does not check them.
+func NewGroupInfo(t testing.TB, opts GroupInfoOptions) []byte {
+	t.Helper()
+	if opts.CipherSuite == 0 {
+		opts.CipherSuite = CipherSuiteEd25519
+	}
+	if opts.GroupID == nil {
+		opts.GroupID = randomBytes(t, 16)
+	}
+
+	var b []byte
+	b = binary.BigEndian.AppendUint16(b, 1) // version mls10
+	b = binary.BigEndian.AppendUint16(b, 4) // wire_format mls_group_info
+
+	// GroupContext
+	b = binary.BigEndian.AppendUint16(b, 1)
+	b = binary.BigEndian.AppendUint16(b, opts.CipherSuite)
+	b = appendVector(b, opts.GroupID)
+	b = binary.BigEndian.AppendUint64(b, opts.Epoch)
+	b = appendVector(b, randomBytes(t, 32)) // tree_hash
+	b = appendVector(b, randomBytes(t, 32)) // confirmed_transcript_hash
+	b = appendVector(b, nil)                // extensions
+
+	b = appendVector(b, nil)                // extensions
+	b = appendVector(b, randomBytes(t, 32)) // confirmation_tag
+	b = binary.BigEndian.AppendUint32(b, 0) // signer
+	return appendVector(b, randomBytes(t, 64))
+}
+
+// MessageOptions configures NewPrivateMessage. Zero values give a
+// message at epoch 0 of a random group.
+type MessageOptions struct {
+	GroupID []byte
+	Epoch   uint64
+}
+
+// NewPrivateMessage returns a PrivateMessage carrying a Commit, wrapped in
+// a TLS-encoded MLSMessage. Its sender data and ciphertext are random.
+func NewPrivateMessage(t testing.TB, opts MessageOptions) []byte {
+	t.Helper()
+	if opts.GroupID == nil {
+		opts.GroupID = randomBytes(t, 16)
+	}
+
+	var b []byte
+	b = binary.BigEndian.AppendUint16(b, 1) // version mls10
+	b = binary.BigEndian.AppendUint16(b, 2) // wire_format mls_private_message
+	b = appendVector(b, opts.GroupID)
+	b = binary.BigEndian.AppendUint64(b, opts.Epoch)
+	b = append(b, 3)                        // content_type commit
+	b = appendVector(b, nil)                // authenticated_data
+	b = appendVector(b, randomBytes(t, 28)) // encrypted_sender_data
+	return appendVector(b, randomBytes(t, 96))
+}
+
+// PublicMessageOptions configures NewPublicMessage. Zero values give a
+// Commit without an UpdatePath, sent by a member at epoch 0 of a random
+// group.
+type PublicMessageOptions struct {
+	GroupID []byte
+	Epoch   uint64
+	// ProposalType makes the message a Proposal of this type instead.
+	ProposalType uint16
+	// UpdatePath gives the Commit an UpdatePath.
+	UpdatePath bool
+}
+
+// NewPublicMessage returns a PublicMessage carrying a Proposal or Commit,
+// wrapped in a TLS-encoded MLSMessage. The proposal body, the Commit's
+// proposal reference and UpdatePath, and the signature and tags are random.
+func NewPublicMessage(t testing.TB, opts PublicMessageOptions) []byte {
+	t.Helper()
+	if opts.GroupID == nil {
+		opts.GroupID = randomBytes(t, 16)
+	}
+
+	var b []byte
+	b = binary.BigEndian.AppendUint16(b, 1) // version mls10
+	b = binary.BigEndian.AppendUint16(b, 1) // wire_format mls_public_message
+	b = appendVector(b, opts.GroupID)
+	b = binary.BigEndian.AppendUint64(b, opts.Epoch)
+	b = append(b, 1)                        // sender_type member
+	b = binary.BigEndian.AppendUint32(b, 0) // leaf_index
+	b = appendVector(b, nil)                // authenticated_data
+	if opts.ProposalType != 0 {
+		b = append(b, 2) // content_type proposal
+		b = binary.BigEndian.AppendUint16(b, opts.ProposalType)
+		b = append(b, randomBytes(t, 32)...)
+		b = appendVector(b, randomBytes(t, 64)) // signature
+	} else {
+		b = append(b, 3) // content_type commit
+		ref := append([]byte{2}, appendVector(nil, randomBytes(t, 32))...)
+		b = appendVector(b, ref) // proposals: one reference
+		if opts.UpdatePath {
+			b = append(b, 1)
+			b = append(b, randomBytes(t, 96)...)
+		} else {
+			b = append(b, 0)
+		}
+		b = appendVector(b, randomBytes(t, 64)) // signature
+		b = appendVector(b, randomBytes(t, 32)) // confirmation_tag
+	}
+	return appendVector(b, randomBytes(t, 32)) // membership_tag
+}
+
+// newSigner generates a signature key pair for suite and returns the
+// encoded public key and a signing function.
+func newSigner(t testing.TB, suite uint16) ([]byte, func([]byte) []byte) {
+	t.Helper()
+	switch suite {
+	case CipherSuiteEd25519:
+		pub, priv, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			t.Fatalf("generate Ed25519 key: %v", err)
+		}
+		return pub, func(msg []byte) []byte { return ed25519.Sign(priv, msg) }
+	case CipherSuiteP256:
+		priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatalf("generate P-256 key: %v", err)
+		}
+		ecdhPub, err := priv.PublicKey.ECDH()
+		if err != nil {
+			t.Fatalf("encode P-256 key: %v", err)
+		}
+		return ecdhPub.Bytes(), func(msg []byte) []byte {
+			digest := sha256.Sum256(msg)
+			sig, err := ecdsa.SignASN1(rand.Reader, priv, digest[:])
+			if err != nil {
+				t.Fatalf("sign: %v", err)
+			}
+			return sig
+		}
+	default:
+		t.Fatalf("mlstest: unsupported cipher suite %d", suite)
+		return nil, nil
+	}
+}
+
+// signContent returns the SignContent structure signed by SignWithLabel.
+func signContent(label string, content []byte) []byte {
+	var b []byte
+	b = appendVector(b, []byte("MLS 1.0 "+label))
+	return appendVector(b, content)
+}
+
+func uint16s(vs ...uint16) []byte {
+	var b []byte
+	for _, v := range vs {
+		b = binary.BigEndian.AppendUint16(b, v)
+	}
+	return b
+}
+
+func randomBytes(t testing.TB, n int) []byte {
+	t.Helper()
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		t.Fatalf("rand: %v", err)
+	}
+	return b
+}
+
+// appendVector appends b to dst as an MLS variable-length vector.
+func appendVector(dst, b []byte) []byte {
+	n := len(b)
+	switch {
+	case n < 1<<6:
+		dst = append(dst, byte(n))
+	case n < 1<<14:
+		dst = append(dst, byte(n>>8)|0x40, byte(n))
+	default:
+		dst = append(dst, byte(n>>24)|0x80, byte(n>>16), byte(n>>8), byte(n))
+	}
+	return append(dst, b...)
+}
@@ -0,0 +1,157 @@
+package mls
+
+import (
+	"errors"
+	"fmt"
+)
+
+// errTruncated is returned when a structure ends before all of its fields
+// have been read.
+var errTruncated = errors.New("truncated")
+
+// reader decodes the TLS presentation language encoding used by RFC 9420.
+// Variable-length vectors are prefixed with a QUIC-style variable-length
+// integer (RFC 9420 Section 2.1.2).
+type reader struct {
+	data []byte
+	off  int
+}
+
+// empty reports whether all input has been consumed.
+func (r *reader) empty() bool {
+	return r.off == len(r.data)
+}
+
+// offset returns the number of bytes consumed so far.
+func (r *reader) offset() int {
+	return r.off
+}
+
+func (r *reader) bytes(n int) ([]byte, error) {
+	if n < 0 || len(r.data)-r.off < n {
+		return nil, errTruncated
+	}
+	b := r.data[r.off : r.off+n]
+	r.off += n
+	return b, nil
+}
+
+func (r *reader) uint8() (uint8, error) {
+	b, err := r.bytes(1)
+	if err != nil {
+		return 0, err
+	}
+	return b[0], nil
+}
+
+func (r *reader) uint16() (uint16, error) {
+	b, err := r.bytes(2)
+	if err != nil {
+		return 0, err
+	}
+	return uint16(b[0])<<8 | uint16(b[1]), nil
+}
+
+func (r *reader) uint32() (uint32, error) {
+	b, err := r.bytes(4)
+	if err != nil {
+		return 0, err
+	}
+	return uint32(b[0])<<24 | uint32(b[1])<<16 | uint32(b[2])<<8 | uint32(b[3]), nil
+}
+
+func (r *reader) uint64() (uint64, error) {
+	b, err := r.bytes(8)
+	if err != nil {
+		return 0, err
+	}
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+	return v, nil
+}
+
+// varint reads a variable-length integer. RFC 9420 allows only the 1, 2
+// and 4 byte forms and requires the shortest encoding.
+func (r *reader) varint() (int, error) {
+	first, err := r.uint8()
+	if err != nil {
+		return 0, err
+	}
+	prefix := first >> 6
+	if prefix == 3 {
+		return 0, errors.New("invalid variable-length integer prefix")
+	}
+	n := 1 << prefix
+	v := int(first & 0x3f)
+	rest, err := r.bytes(n - 1)
+	if err != nil {
+		return 0, err
+	}
+	for _, c := range rest {
+		v = v<<8 | int(c)
+	}
+	if prefix > 0 && v < 1<<(8*(n/2)-2) {
+		return 0, errors.New("variable-length integer not minimally encoded")
+	}
+	return v, nil
+}
+
+// vector reads a variable-length opaque vector.
+func (r *reader) vector() ([]byte, error) {
+	n, err := r.varint()
+	if err != nil {
+		return nil, err
+	}
+	return r.bytes(n)
+}
+
+// vectorOf reads a variable-length vector and calls elem until its
+// contents are consumed.
+func (r *reader) vectorOf(elem func(*reader) error) error {
+	b, err := r.vector()
+	if err != nil {
+		return err
+	}
+	sub := &reader{data: b}
+	for !sub.empty() {
+		if err := elem(sub); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// uint16s reads a variable-length vector of uint16 values.
+func (r *reader) uint16s() ([]uint16, error) {
+	var out []uint16
+	err := r.vectorOf(func(sub *reader) error {
+		v, err := sub.uint16()
+		if err != nil {
+			return err
+		}
+		out = append(out, v)
+		return nil
+	})
+	return out, err
+}
+
+// appendVector appends b to dst as a variable-length vector.
+func appendVector(dst, b []byte) []byte {
+	n := len(b)
+	switch {
+	case n < 1<<6:
+		dst = append(dst, byte(n))
+	case n < 1<<14:
+		dst = append(dst, byte(n>>8)|0x40, byte(n))
+	default:
+		dst = append(dst, byte(n>>24)|0x80, byte(n>>16), byte(n>>8), byte(n))
+	}
+	return append(dst, b...)
+}
+
+// fieldError annotates a decoding error with the field being read.
+func fieldError(field string, err error) error {
+	return fmt.Errorf("%s: %w", field, err)
+}
@@ -0,0 +1,81 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// AuditEvent records a security-relevant event for server administrators.
+type AuditEvent struct {
+	ID        string
+	EventType string
+	UserID    string // may be empty for events not tied to a user
+	Detail    string // free-form, human-readable context
+	CreatedAt int64
+}
+
+// InsertAuditEvent stores an audit event. If e.ID is empty, a ULID is assigned.
+func (s *Store) InsertAuditEvent(ctx context.Context, e *AuditEvent) error {
+	if e.ID == "" {
+		e.ID = NewULID()
+	}
+	var userID interface{}
+	if e.UserID != "" {
+		userID = e.UserID
+	}
+
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO audit_event (id, event_type, user_id, detail, created_at) VALUES (?, ?, ?, ?, ?)`,
+		e.ID, e.EventType, userID, e.Detail, e.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("insert audit event: %w", err)
+	}
+	return nil
+}
+
+// ListAuditEvents returns audit events, newest first. If eventType is
+// non-empty, only events of that type are returned.
+func (s *Store) ListAuditEvents(ctx context.Context, eventType string, offset, limit int) ([]*AuditEvent, error) {
+	rows, err := s.readDB.QueryContext(ctx,
+		`SELECT id, event_type, user_id, detail, created_at
+		 FROM audit_event WHERE ? = '' OR event_type = ?
+		 ORDER BY created_at DESC, id DESC LIMIT ? OFFSET ?`,
+		eventType, eventType, limit, offset,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("list audit events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []*AuditEvent
+	for rows.Next() {
+		e := &AuditEvent{}
+		var userID sql.NullString
+		if err := rows.Scan(&e.ID, &e.EventType, &userID, &e.Detail, &e.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan audit event: %w", err)
+		}
+		if userID.Valid {
+			e.UserID = userID.String
+		}
+		events = append(events, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate audit events: %w", err)
+	}
+	return events, nil
+}
+
+// CountAuditEvents returns the number of audit events. If eventType is
+// non-empty, only events of that type are counted.
+func (s *Store) CountAuditEvents(ctx context.Context, eventType string) (int, error) {
+	var count int
+	err := s.readDB.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM audit_event WHERE ? = '' OR event_type = ?`, eventType, eventType,
+	).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("count audit events: %w", err)
+	}
+	return count, nil
+}
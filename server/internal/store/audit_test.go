@@ -0,0 +1,70 @@
+package store
+
+import (
+	"context"
+	"testing"
+)
+
+func TestAuditEvents(t *testing.T) {
+	s := newTestStore(t)
+	setupUserForCredentialTests(t, s)
+	ctx := context.Background()
+
+	events := []*AuditEvent{
+		{EventType: "a", UserID: "u1", Detail: "first", CreatedAt: 100},
+		{EventType: "b", CreatedAt: 200},
+		{EventType: "c", UserID: "u1", CreatedAt: 300},
+	}
+	for _, e := range events {
+		if err := s.InsertAuditEvent(ctx, e); err != nil {
+			t.Fatalf("InsertAuditEvent: %v", err)
+		}
+		if e.ID == "" {
+			t.Error("InsertAuditEvent did not assign an ID")
+		}
+	}
+
+	tests := []struct {
+		name      string
+		eventType string
+		offset    int
+		limit     int
+		wantTypes []string
+		wantTotal int
+	}{
+		{name: "newest first", offset: 0, limit: 10, wantTypes: []string{"c", "b", "a"}, wantTotal: 3},
+		{name: "limit", offset: 0, limit: 2, wantTypes: []string{"c", "b"}, wantTotal: 3},
+		{name: "offset", offset: 2, limit: 10, wantTypes: []string{"a"}, wantTotal: 3},
+		{name: "filter by type", eventType: "b", offset: 0, limit: 10, wantTypes: []string{"b"}, wantTotal: 1},
+		{name: "filter with no matches", eventType: "z", offset: 0, limit: 10, wantTypes: []string{}, wantTotal: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			total, err := s.CountAuditEvents(ctx, tt.eventType)
+			if err != nil {
+				t.Fatalf("CountAuditEvents: %v", err)
+			}
+			if total != tt.wantTotal {
+				t.Errorf("total = %d, want %d", total, tt.wantTotal)
+			}
+
+			got, err := s.ListAuditEvents(ctx, tt.eventType, tt.offset, tt.limit)
+			if err != nil {
+				t.Fatalf("ListAuditEvents: %v", err)
+			}
+			if len(got) != len(tt.wantTypes) {
+				t.Fatalf("len = %d, want %d", len(got), len(tt.wantTypes))
+			}
+			for i, e := range got {
+				if e.EventType != tt.wantTypes[i] {
+					t.Errorf("event[%d].EventType = %q, want %q", i, e.EventType, tt.wantTypes[i])
+				}
+			}
+		})
+	}
+
+	got, _ := s.ListAuditEvents(ctx, "", 1, 1)
+	if got[0].UserID != "" {
+		t.Errorf("UserID = %q, want empty", got[0].UserID)
+	}
+}
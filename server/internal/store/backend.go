@@ -0,0 +1,135 @@
+package store
+
+import "context"
+
+// UserStore manages user accounts.
+type UserStore interface {
+	CreateUser(ctx context.Context, u *User) error
+	GetUserByID(ctx context.Context, id string) (*User, error)
+	GetUserByUsername(ctx context.Context, username string) (*User, error)
+	GetUserByFoldedUsername(ctx context.Context, folded string) (*User, error)
+	UpdateUser(ctx context.Context, u *User) error
+	ListUsers(ctx context.Context) ([]*User, error)
+}
+
+// CredentialStore manages WebAuthn credentials.
+type CredentialStore interface {
+	CreateCredential(ctx context.Context, c *Credential) error
+	GetCredentialByID(ctx context.Context, id string) (*Credential, error)
+	GetCredentialsByUserID(ctx context.Context, userID string) ([]*Credential, error)
+	UpdateSignCount(ctx context.Context, id string, signCount int64) error
+	DeleteCredential(ctx context.Context, id string) error
+}
+
+// SessionStore manages login sessions.
+type SessionStore interface {
+	CreateSession(ctx context.Context, sess *Session) error
+	GetSessionByTokenHash(ctx context.Context, tokenHash []byte) (*Session, error)
+	GetSessionByID(ctx context.Context, id string) (*Session, error)
+	ExtendSession(ctx context.Context, id string, lastSeenAt, expiresAt int64) error
+	RotateSessionToken(ctx context.Context, id string, oldHash, newHash []byte, lastSeenAt, expiresAt int64) error
+	UpdateSessionLastUsed(ctx context.Context, id string) error
+	DeleteSession(ctx context.Context, id string) error
+	DeleteExpiredSessions(ctx context.Context) (int64, error)
+}
+
+// ChallengeStore manages outstanding WebAuthn challenges.
+type ChallengeStore interface {
+	CreateChallenge(ctx context.Context, c *Challenge) error
+	GetChallenge(ctx context.Context, challengeID string) (*Challenge, error)
+	DeleteChallenge(ctx context.Context, challengeID string) error
+	DeleteExpiredChallenges(ctx context.Context) (int64, error)
+	CountActiveChallengesByClientIP(ctx context.Context, clientIP string) (int, error)
+	CountActiveChallengesByConn(ctx context.Context, connID string) (int, error)
+}
+
+// RecoveryStore manages account recovery codes.
+type RecoveryStore interface {
+	ReplaceRecoveryCodes(ctx context.Context, userID string, codeHashes [][]byte) error
+	RedeemRecoveryCode(ctx context.Context, userID string, codeHash []byte) error
+	CountRecoveryCodes(ctx context.Context, userID string) (int, error)
+	RecoverAccount(ctx context.Context, cred *Credential, sess *Session) (sessionsRevoked, credentialsRemoved int64, err error)
+}
+
+// AuditStore manages the admin audit log.
+type AuditStore interface {
+	InsertAuditEvent(ctx context.Context, e *AuditEvent) error
+	ListAuditEvents(ctx context.Context, eventType string, offset, limit int) ([]*AuditEvent, error)
+	CountAuditEvents(ctx context.Context, eventType string) (int, error)
+}
+
+// ConversationStore manages conversations, their members and the MLS state
+// kept per member and per group.
+type ConversationStore interface {
+	CreateConversation(ctx context.Context, title, createdBy string, memberIDs []string) (*Conversation, error)
+	CreateConversationWithOptions(ctx context.Context, title, createdBy string, memberIDs []string, opts ConversationOptions) (*Conversation, error)
+	GetConversation(ctx context.Context, id string) (*Conversation, error)
+	AddMember(ctx context.Context, groupID, userID, role string) error
+	CommitMembershipChange(ctx context.Context, groupID, senderID string, commit []byte, added, removed []string) (string, int64, error)
+	RemoveMember(ctx context.Context, groupID, userID string) error
+	GetMembers(ctx context.Context, groupID string) ([]*GroupMember, error)
+	GetConversationsForUser(ctx context.Context, userID string) ([]*Conversation, error)
+	GetContactIDs(ctx context.Context, userID string) ([]string, error)
+	IsUserMember(ctx context.Context, groupID, userID string) (bool, error)
+	GetMemberRole(ctx context.Context, groupID, userID string) (string, error)
+	TransferAdmin(ctx context.Context, groupID, leavingUserID string) error
+
+	RecordMemberUpdate(ctx context.Context, groupID, userID string, epoch *int64) error
+	GetMemberUpdates(ctx context.Context, groupID string) ([]*MemberUpdate, error)
+	ClaimStaleMembers(ctx context.Context, userID string, staleBefore int64) ([]*MemberUpdate, error)
+
+	PutMLSGroupState(ctx context.Context, gs *MLSGroupState) error
+	GetMLSGroupState(ctx context.Context, conversationID string) (*MLSGroupState, error)
+}
+
+// MessageStore manages stored messages and their delivery status.
+type MessageStore interface {
+	InsertMessage(ctx context.Context, groupID, senderID string, payload []byte, messageType, epoch int) (string, int64, error)
+	InsertDirectMessage(ctx context.Context, groupID, senderID, recipientID string, payload []byte, messageType int) (string, int64, error)
+	GetMessagesByGroup(ctx context.Context, groupID, cursor string, limit int, forward bool) ([]*Message, error)
+	GetPendingMessages(ctx context.Context, recipientID string) ([]*Message, error)
+	GetPendingMessagesAfter(ctx context.Context, recipientID string, afterTimestamp int64, afterID string, limit int) ([]*Message, error)
+	UpdateDeliveryStatus(ctx context.Context, messageID, recipientID string, status int) error
+	MarkDelivered(ctx context.Context, recipientID string, messageIDs []string) ([]DeliveredMessage, error)
+	MarkMessageDelivered(ctx context.Context, messageID string, recipientIDs []string) error
+	GetDeliveryStatus(ctx context.Context, messageID, recipientID string) (*DeliveryRecord, error)
+	GetMessageSenderID(ctx context.Context, messageID string) (string, error)
+	DeleteExpiredMessages(ctx context.Context, cutoffUnixSeconds int64) (int64, error)
+}
+
+// KeyPackageStore manages uploaded MLS key packages.
+type KeyPackageStore interface {
+	StoreKeyPackage(ctx context.Context, userID string, data []byte, expiresAt int64) (string, error)
+	StoreLastResortKeyPackage(ctx context.Context, userID string, data []byte, expiresAt int64) (string, error)
+	StoreKeyPackages(ctx context.Context, userID string, kps []*KeyPackage) error
+	ConsumeKeyPackage(ctx context.Context, userID string, cipherSuite uint16) (*KeyPackage, error)
+	ConsumeKeyPackages(ctx context.Context, userIDs []string, cipherSuite uint16) (map[string]*KeyPackage, error)
+	CountKeyPackages(ctx context.Context, userID string) (int, error)
+	KeyPackageInventory(ctx context.Context, userID string) ([]KeyPackageSuiteCount, error)
+	DeleteExpiredKeyPackages(ctx context.Context) (int64, error)
+}
+
+// SecretStore keeps the secrets the server generates for itself.
+type SecretStore interface {
+	ServerSecret(ctx context.Context, name string, size int) ([]byte, error)
+}
+
+// Backend is the full set of storage operations the server needs. Store
+// implements it over SQLite (the default) and PostgreSQL; see Open and
+// OpenPostgres.
+type Backend interface {
+	UserStore
+	CredentialStore
+	SessionStore
+	ChallengeStore
+	RecoveryStore
+	AuditStore
+	ConversationStore
+	MessageStore
+	KeyPackageStore
+	SecretStore
+
+	Close() error
+}
+
+var _ Backend = (*Store)(nil)
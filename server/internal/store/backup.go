@@ -0,0 +1,170 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+
+	"modernc.org/sqlite"
+)
+
+// ErrSchemaTooNew is returned for a database migrated further than this
+// build knows how to, by Open and MigrateTo as well as CheckSnapshot.
+var ErrSchemaTooNew = errors.New("schema version newer than supported")
+
+// LatestSchemaVersion returns the schema version this build migrates
+// SQLite databases to.
+func LatestSchemaVersion() int {
+	return len(migrations)
+}
+
+// Snapshot writes a consistent copy of the database to dest, which must
+// not exist, using VACUUM INTO. The copy is taken in a read transaction on
+// a connection of its own, so writes carry on while it runs. Only SQLite
+// databases can be snapshotted; use pg_dump for PostgreSQL.
+func (s *Store) Snapshot(ctx context.Context, dest string) error {
+	if s.dialect != SQLite {
+		return fmt.Errorf("snapshot %s database: %w", s.dialect, errors.ErrUnsupported)
+	}
+	// An in-memory database is only reachable through its one connection.
+	if isMemoryPath(s.path) {
+		return vacuumInto(ctx, s.db, dest)
+	}
+	return SnapshotFile(ctx, s.path, dest)
+}
+
+// SnapshotFile writes a consistent copy of the SQLite database at dbPath
+// to dest, as Snapshot does. It does not run migrations, so it is safe to
+// use on the database of a running server of another version.
+func SnapshotFile(ctx context.Context, dbPath, dest string) error {
+	db, err := sql.Open("sqlite", dsn(fileURI(dbPath), []string{"busy_timeout(5000)"}, "mode=rw"))
+	if err != nil {
+		return fmt.Errorf("open database: %w", err)
+	}
+	defer db.Close()
+	return vacuumInto(ctx, db, dest)
+}
+
+// CopyPages writes a page-for-page copy of the database to dest using
+// SQLite's online backup API, in one read transaction so that the copy is
+// consistent. Unlike Snapshot, which rebuilds the database, pages that have
+// not changed keep their content and position, so successive copies can be
+// compared page by page.
+func (s *Store) CopyPages(ctx context.Context, dest string) error {
+	if s.dialect != SQLite {
+		return fmt.Errorf("copy %s database: %w", s.dialect, errors.ErrUnsupported)
+	}
+	conn, err := s.readDB.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("get connection: %w", err)
+	}
+	defer conn.Close()
+
+	return conn.Raw(func(dc any) error {
+		src, ok := dc.(interface {
+			NewBackup(dstURI string) (*sqlite.Backup, error)
+		})
+		if !ok {
+			return fmt.Errorf("copy pages: driver connection %T has no backup API", dc)
+		}
+		b, err := src.NewBackup(dest)
+		if err != nil {
+			return fmt.Errorf("copy pages to %s: %w", dest, err)
+		}
+		// Copying every page in one step holds a single read transaction, so
+		// writes from other connections do not restart the copy.
+		_, stepErr := b.Step(-1)
+		if err := errors.Join(stepErr, b.Finish()); err != nil {
+			return fmt.Errorf("copy pages to %s: %w", dest, err)
+		}
+		return nil
+	})
+}
+
+// WithWritesPaused calls fn with the paths of the SQLite database file and
+// its write-ahead log while holding the write lock, so that nothing is
+// committed until fn returns. It is for reading the files directly, and
+// fn must be quick, since writers wait for it.
+func (s *Store) WithWritesPaused(ctx context.Context, fn func(dbPath, walPath string) error) error {
+	if s.dialect != SQLite || isMemoryPath(s.path) {
+		return fmt.Errorf("pause writes to %s database: %w", s.dialect, errors.ErrUnsupported)
+	}
+	// The writer connection begins transactions with BEGIN IMMEDIATE, which
+	// takes the write lock.
+	return s.InTx(ctx, func(*sql.Tx) error {
+		return fn(s.path, s.path+"-wal")
+	})
+}
+
+// Checkpoint copies the pages committed to the write-ahead log into the
+// database file, as far as open read transactions allow, without waiting
+// for them (a passive checkpoint). It runs on a read connection, so it can
+// be called inside WithWritesPaused.
+func (s *Store) Checkpoint(ctx context.Context) error {
+	if s.dialect != SQLite {
+		return fmt.Errorf("checkpoint %s database: %w", s.dialect, errors.ErrUnsupported)
+	}
+	if _, err := s.readDB.ExecContext(ctx, `PRAGMA wal_checkpoint(PASSIVE)`); err != nil {
+		return fmt.Errorf("checkpoint: %w", err)
+	}
+	return nil
+}
+
+// fileURI turns a file path into a SQLite URI, so that URI parameters such
+// as mode apply and a missing file is not created.
+func fileURI(path string) string {
+	return "file:" + (&url.URL{Path: path}).EscapedPath()
+}
+
+func vacuumInto(ctx context.Context, db *sql.DB, dest string) error {
+	if _, err := db.ExecContext(ctx, `VACUUM INTO ?`, dest); err != nil {
+		return fmt.Errorf("vacuum into %s: %w", dest, err)
+	}
+	return nil
+}
+
+// SchemaVersion returns the version of the last migration applied to the
+// database.
+func (s *Store) SchemaVersion(ctx context.Context) (int, error) {
+	return schemaVersion(ctx, s.readDB)
+}
+
+func schemaVersion(ctx context.Context, db *sql.DB) (int, error) {
+	var version int
+	err := db.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_version`).Scan(&version)
+	if err != nil {
+		return 0, fmt.Errorf("get schema version: %w", err)
+	}
+	return version, nil
+}
+
+// CheckSnapshot opens the SQLite database file at path read-only, checks
+// its integrity and returns its schema version. A database with a schema
+// newer than LatestSchemaVersion returns ErrSchemaTooNew; an older one is
+// migrated when the server next opens it.
+func CheckSnapshot(ctx context.Context, path string) (int, error) {
+	db, err := sql.Open("sqlite", dsn(fileURI(path), []string{"query_only(1)"}, "mode=ro"))
+	if err != nil {
+		return 0, fmt.Errorf("open snapshot: %w", err)
+	}
+	defer db.Close()
+
+	var result string
+	if err := db.QueryRowContext(ctx, `PRAGMA integrity_check`).Scan(&result); err != nil {
+		return 0, fmt.Errorf("integrity check: %w", err)
+	}
+	if result != "ok" {
+		return 0, fmt.Errorf("integrity check: %s", result)
+	}
+
+	version, err := schemaVersion(ctx, db)
+	if err != nil {
+		return 0, err
+	}
+	if version > LatestSchemaVersion() {
+		return version, fmt.Errorf("version %d, supported %d: %w", version, LatestSchemaVersion(), ErrSchemaTooNew)
+	}
+	return version, nil
+}
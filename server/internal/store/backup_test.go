@@ -0,0 +1,116 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestSnapshot(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	s, err := New(filepath.Join(dir, "live.db"))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer s.Close()
+	if err := s.CreateUser(ctx, makeUser("user-1", "alice")); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+
+	// A write transaction in progress is not blocked by, and not included
+	// in, the snapshot.
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("BeginTx: %v", err)
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE "user" SET display_name = 'uncommitted'`); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	snap := filepath.Join(dir, "snap.db")
+	if err := s.Snapshot(ctx, snap); err != nil {
+		t.Fatalf("Snapshot: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+
+	if err := s.Snapshot(ctx, snap); err == nil {
+		t.Error("Snapshot to an existing file succeeded")
+	}
+
+	version, err := CheckSnapshot(ctx, snap)
+	if err != nil {
+		t.Fatalf("CheckSnapshot: %v", err)
+	}
+	if version != LatestSchemaVersion() {
+		t.Errorf("snapshot version = %d, want %d", version, LatestSchemaVersion())
+	}
+
+	copied, err := New(snap)
+	if err != nil {
+		t.Fatalf("open snapshot: %v", err)
+	}
+	defer copied.Close()
+	u, err := copied.GetUserByID(ctx, "user-1")
+	if err != nil {
+		t.Fatalf("GetUserByID from snapshot: %v", err)
+	}
+	if u.DisplayName == "uncommitted" {
+		t.Error("snapshot includes an uncommitted write")
+	}
+}
+
+func TestSnapshotFile(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	if err := SnapshotFile(ctx, filepath.Join(dir, "missing.db"), filepath.Join(dir, "snap.db")); err == nil {
+		t.Error("SnapshotFile of a missing database succeeded")
+	}
+
+	live := filepath.Join(dir, "live.db")
+	s, err := New(live)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer s.Close()
+
+	snap := filepath.Join(dir, "snap.db")
+	if err := SnapshotFile(ctx, live, snap); err != nil {
+		t.Fatalf("SnapshotFile: %v", err)
+	}
+	if _, err := CheckSnapshot(ctx, snap); err != nil {
+		t.Errorf("CheckSnapshot: %v", err)
+	}
+}
+
+func TestCheckSnapshot(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	if _, err := CheckSnapshot(ctx, filepath.Join(dir, "missing.db")); err == nil {
+		t.Error("CheckSnapshot of a missing file succeeded")
+	}
+
+	path := filepath.Join(dir, "newer.db")
+	s, err := New(path)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if _, err := s.db.ExecContext(ctx, `INSERT INTO schema_version (version, applied_at) VALUES (?, 0)`,
+		LatestSchemaVersion()+1); err != nil {
+		t.Fatalf("insert schema version: %v", err)
+	}
+	s.Close()
+
+	version, err := CheckSnapshot(ctx, path)
+	if !errors.Is(err, ErrSchemaTooNew) {
+		t.Errorf("CheckSnapshot(newer schema) error = %v, want ErrSchemaTooNew", err)
+	}
+	if version != LatestSchemaVersion()+1 {
+		t.Errorf("CheckSnapshot(newer schema) version = %d, want %d", version, LatestSchemaVersion()+1)
+	}
+}
@@ -12,7 +12,9 @@ type Challenge struct {
 	ChallengeID   string
 	ChallengeData []byte
 	Username      string // may be empty for login challenges
-	ChallengeType string // "registration" or "login"
+	ChallengeType string // "registration", "login" or "recovery"
+	ClientIP      string // may be empty if the origin is unknown
+	ConnID        string // may be empty if not issued on a connection
 	CreatedAt     int64
 	ExpiresAt     int64
 }
@@ -25,9 +27,9 @@ func (s *Store) CreateChallenge(ctx context.Context, c *Challenge) error {
 	}
 
 	_, err := s.db.ExecContext(ctx,
-		`INSERT INTO challenge (challenge_id, challenge_data, username, challenge_type, created_at, expires_at)
-		 VALUES (?, ?, ?, ?, ?, ?)`,
-		c.ChallengeID, c.ChallengeData, username, c.ChallengeType, c.CreatedAt, c.ExpiresAt,
+		`INSERT INTO challenge (challenge_id, challenge_data, username, challenge_type, client_ip, conn_id, created_at, expires_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		c.ChallengeID, c.ChallengeData, username, c.ChallengeType, c.ClientIP, c.ConnID, c.CreatedAt, c.ExpiresAt,
 	)
 	if err != nil {
 		return fmt.Errorf("insert challenge: %w", err)
@@ -39,10 +41,10 @@ func (s *Store) CreateChallenge(ctx context.Context, c *Challenge) error {
 func (s *Store) GetChallenge(ctx context.Context, challengeID string) (*Challenge, error) {
 	c := &Challenge{}
 	var username sql.NullString
-	err := s.db.QueryRowContext(ctx,
-		`SELECT challenge_id, challenge_data, username, challenge_type, created_at, expires_at
+	err := s.readDB.QueryRowContext(ctx,
+		`SELECT challenge_id, challenge_data, username, challenge_type, client_ip, conn_id, created_at, expires_at
 		 FROM challenge WHERE challenge_id = ?`, challengeID,
-	).Scan(&c.ChallengeID, &c.ChallengeData, &username, &c.ChallengeType, &c.CreatedAt, &c.ExpiresAt)
+	).Scan(&c.ChallengeID, &c.ChallengeData, &username, &c.ChallengeType, &c.ClientIP, &c.ConnID, &c.CreatedAt, &c.ExpiresAt)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, ErrNotFound
@@ -85,3 +87,31 @@ func (s *Store) DeleteExpiredChallenges(ctx context.Context) (int64, error) {
 	}
 	return n, nil
 }
+
+// CountActiveChallengesByClientIP returns the number of unexpired challenges
+// issued to the given client IP.
+func (s *Store) CountActiveChallengesByClientIP(ctx context.Context, clientIP string) (int, error) {
+	var count int
+	err := s.readDB.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM challenge WHERE client_ip = ? AND expires_at > ?`,
+		clientIP, time.Now().Unix(),
+	).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("count challenges by client ip: %w", err)
+	}
+	return count, nil
+}
+
+// CountActiveChallengesByConn returns the number of unexpired challenges
+// issued on the given connection.
+func (s *Store) CountActiveChallengesByConn(ctx context.Context, connID string) (int, error) {
+	var count int
+	err := s.readDB.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM challenge WHERE conn_id = ? AND expires_at > ?`,
+		connID, time.Now().Unix(),
+	).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("count challenges by conn: %w", err)
+	}
+	return count, nil
+}
@@ -219,6 +219,8 @@ func TestChallengeRoundTrip(t *testing.T) {
 		ChallengeData: []byte(`{"session_data":{"challenge":"abc"}}`),
 		Username:      "alice",
 		ChallengeType: "registration",
+		ClientIP:      "192.0.2.1",
+		ConnID:        "conn-1",
 		CreatedAt:     time.Now().Unix(),
 		ExpiresAt:     time.Now().Add(60 * time.Second).Unix(),
 	}
@@ -244,6 +246,12 @@ func TestChallengeRoundTrip(t *testing.T) {
 	if got.ChallengeType != want.ChallengeType {
 		t.Errorf("ChallengeType = %q, want %q", got.ChallengeType, want.ChallengeType)
 	}
+	if got.ClientIP != want.ClientIP {
+		t.Errorf("ClientIP = %q, want %q", got.ClientIP, want.ClientIP)
+	}
+	if got.ConnID != want.ConnID {
+		t.Errorf("ConnID = %q, want %q", got.ConnID, want.ConnID)
+	}
 	if got.CreatedAt != want.CreatedAt {
 		t.Errorf("CreatedAt = %d, want %d", got.CreatedAt, want.CreatedAt)
 	}
@@ -251,3 +259,50 @@ func TestChallengeRoundTrip(t *testing.T) {
 		t.Errorf("ExpiresAt = %d, want %d", got.ExpiresAt, want.ExpiresAt)
 	}
 }
+
+func TestCountActiveChallenges(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	now := time.Now().Unix()
+
+	challenges := []struct {
+		id, ip, conn string
+		expiresAt    int64
+	}{
+		{"ch1", "192.0.2.1", "conn-1", now + 60},
+		{"ch2", "192.0.2.1", "conn-2", now + 60},
+		{"ch3", "192.0.2.1", "conn-1", now - 1}, // expired
+		{"ch4", "192.0.2.2", "conn-3", now + 60},
+	}
+	for _, c := range challenges {
+		ch := makeChallenge(c.id, "alice", "login", c.expiresAt)
+		ch.ClientIP = c.ip
+		ch.ConnID = c.conn
+		if err := s.CreateChallenge(ctx, ch); err != nil {
+			t.Fatalf("CreateChallenge: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		count func() (int, error)
+		want  int
+	}{
+		{"by ip", func() (int, error) { return s.CountActiveChallengesByClientIP(ctx, "192.0.2.1") }, 2},
+		{"by other ip", func() (int, error) { return s.CountActiveChallengesByClientIP(ctx, "192.0.2.2") }, 1},
+		{"by unknown ip", func() (int, error) { return s.CountActiveChallengesByClientIP(ctx, "192.0.2.9") }, 0},
+		{"by conn", func() (int, error) { return s.CountActiveChallengesByConn(ctx, "conn-1") }, 1},
+		{"by other conn", func() (int, error) { return s.CountActiveChallengesByConn(ctx, "conn-2") }, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.count()
+			if err != nil {
+				t.Fatalf("count: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("count = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
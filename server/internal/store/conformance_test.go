@@ -0,0 +1,108 @@
+package store_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+
+	"github.com/sovereign-im/sovereign/server/internal/store"
+	"github.com/sovereign-im/sovereign/server/internal/store/storetest"
+)
+
+// postgresDSNEnv names the environment variable holding the connection
+// string of a PostgreSQL database to run the conformance suite against.
+// Each test gets a schema of its own in it, dropped when the test ends.
+// CI provides one (.github/workflows/server.yml), so there the suite fails
+// instead of skipping without it.
+const postgresDSNEnv = "SOVEREIGN_TEST_POSTGRES_DSN"
+
+func TestConformanceSQLite(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.Backend {
+		// A file database, so that the read pool is exercised.
+		s, err := store.New(filepath.Join(t.TempDir(), "test.db"))
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+		t.Cleanup(func() { s.Close() })
+		return s
+	})
+}
+
+func TestConformanceSQLiteEncrypted(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "master.key")
+	if err := store.GenerateKeyFile(keyFile); err != nil {
+		t.Fatalf("GenerateKeyFile: %v", err)
+	}
+	mk, err := store.MasterKeyFromFile(keyFile)
+	if err != nil {
+		t.Fatalf("MasterKeyFromFile: %v", err)
+	}
+	storetest.Run(t, func(t *testing.T) store.Backend {
+		s, err := store.New(filepath.Join(t.TempDir(), "test.db"))
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+		t.Cleanup(func() { s.Close() })
+		if _, err := s.Unlock(context.Background(), mk); err != nil {
+			t.Fatalf("Unlock: %v", err)
+		}
+		return s
+	})
+}
+
+func TestConformancePostgres(t *testing.T) {
+	dsn := os.Getenv(postgresDSNEnv)
+	if dsn == "" {
+		if os.Getenv("CI") != "" {
+			t.Fatalf("%s not set; CI must run the PostgreSQL suite", postgresDSNEnv)
+		}
+		t.Skipf("%s not set", postgresDSNEnv)
+	}
+
+	admin, err := sql.Open("pgx", dsn)
+	if err != nil {
+		t.Fatalf("open postgres: %v", err)
+	}
+	t.Cleanup(func() { admin.Close() })
+
+	n := 0
+	storetest.Run(t, func(t *testing.T) store.Backend {
+		n++
+		schema := fmt.Sprintf("storetest_%d_%d", os.Getpid(), n)
+		ctx := context.Background()
+		if _, err := admin.ExecContext(ctx, `CREATE SCHEMA `+schema); err != nil {
+			t.Fatalf("create schema: %v", err)
+		}
+		t.Cleanup(func() {
+			if _, err := admin.ExecContext(ctx, `DROP SCHEMA `+schema+` CASCADE`); err != nil {
+				t.Errorf("drop schema: %v", err)
+			}
+		})
+
+		s, err := store.OpenPostgres(withSearchPath(dsn, schema), store.Options{})
+		if err != nil {
+			t.Fatalf("OpenPostgres: %v", err)
+		}
+		t.Cleanup(func() { s.Close() })
+		return s
+	})
+}
+
+// withSearchPath adds a search_path run-time parameter to a URL or
+// key/value connection string.
+func withSearchPath(dsn, schema string) string {
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		sep := "?"
+		if strings.Contains(dsn, "?") {
+			sep = "&"
+		}
+		return dsn + sep + "search_path=" + schema
+	}
+	return dsn + " search_path=" + schema
+}
@@ -3,16 +3,27 @@ package store
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
+	"slices"
 	"time"
 )
 
 // Conversation represents a conversation (1:1 or group).
 type Conversation struct {
-	ID        string
-	Title     string
-	CreatedBy string
-	CreatedAt int64
+	ID           string
+	Title        string
+	CreatedBy    string
+	CreatedAt    int64
+	SealedSender bool // messages are stored and delivered without sender_id
+
+	// MemberGeneration increases whenever a member leaves or is removed.
+	MemberGeneration int64
+}
+
+// ConversationOptions configures CreateConversationWithOptions.
+type ConversationOptions struct {
+	SealedSender bool
 }
 
 // GroupMember represents a user's membership in a group.
@@ -26,17 +37,25 @@ type GroupMember struct {
 // CreateConversation creates a new conversation and adds the creator as an admin member.
 // Additional member IDs are added with the "member" role.
 func (s *Store) CreateConversation(ctx context.Context, title, createdBy string, memberIDs []string) (*Conversation, error) {
+	return s.CreateConversationWithOptions(ctx, title, createdBy, memberIDs, ConversationOptions{})
+}
+
+// CreateConversationWithOptions is CreateConversation with non-default
+// settings.
+func (s *Store) CreateConversationWithOptions(ctx context.Context, title, createdBy string, memberIDs []string, opts ConversationOptions) (*Conversation, error) {
 	conv := &Conversation{
-		ID:        NewULID(),
-		Title:     title,
-		CreatedBy: createdBy,
-		CreatedAt: time.Now().Unix(),
+		ID:           NewULID(),
+		Title:        title,
+		CreatedBy:    createdBy,
+		CreatedAt:    time.Now().Unix(),
+		SealedSender: opts.SealedSender,
 	}
 
 	err := s.InTx(ctx, func(tx *sql.Tx) error {
 		_, err := tx.ExecContext(ctx,
-			`INSERT INTO conversations (id, title, created_by, created_at) VALUES (?, ?, ?, ?)`,
-			conv.ID, conv.Title, conv.CreatedBy, conv.CreatedAt,
+			`INSERT INTO conversations (id, title, created_by, created_at, sealed_sender) VALUES (?, ?, ?, ?, ?)`,
+			conv.ID, s.sealString("conversations.title", conv.Title), conv.CreatedBy,
+			conv.CreatedAt, conv.SealedSender,
 		)
 		if err != nil {
 			return fmt.Errorf("insert conversation: %w", err)
@@ -46,8 +65,8 @@ func (s *Store) CreateConversation(ctx context.Context, title, createdBy string,
 
 		// Add creator as admin.
 		_, err = tx.ExecContext(ctx,
-			`INSERT INTO group_members (group_id, user_id, role, joined_at) VALUES (?, ?, 'admin', ?)`,
-			conv.ID, createdBy, now,
+			`INSERT INTO group_members (group_id, user_id, user_index, role, joined_at) VALUES (?, ?, ?, 'admin', ?)`,
+			conv.ID, s.sealString("group_members.user_id", createdBy), s.userIndex(createdBy), now,
 		)
 		if err != nil {
 			return fmt.Errorf("add creator to group: %w", err)
@@ -59,8 +78,8 @@ func (s *Store) CreateConversation(ctx context.Context, title, createdBy string,
 				continue
 			}
 			_, err = tx.ExecContext(ctx,
-				`INSERT INTO group_members (group_id, user_id, role, joined_at) VALUES (?, ?, 'member', ?)`,
-				conv.ID, memberID, now,
+				`INSERT INTO group_members (group_id, user_id, user_index, role, joined_at) VALUES (?, ?, ?, 'member', ?)`,
+				conv.ID, s.sealString("group_members.user_id", memberID), s.userIndex(memberID), now,
 			)
 			if err != nil {
 				return fmt.Errorf("add member %s: %w", memberID, err)
@@ -79,23 +98,34 @@ func (s *Store) CreateConversation(ctx context.Context, title, createdBy string,
 // GetConversation returns a conversation by ID. Returns ErrNotFound if not found.
 func (s *Store) GetConversation(ctx context.Context, id string) (*Conversation, error) {
 	conv := &Conversation{}
-	err := s.db.QueryRowContext(ctx,
-		`SELECT id, title, created_by, created_at FROM conversations WHERE id = ?`, id,
-	).Scan(&conv.ID, &conv.Title, &conv.CreatedBy, &conv.CreatedAt)
+	err := s.readDB.QueryRowContext(ctx,
+		`SELECT id, title, created_by, created_at, sealed_sender, member_generation FROM conversations WHERE id = ?`, id,
+	).Scan(&conv.ID, &conv.Title, &conv.CreatedBy, &conv.CreatedAt, &conv.SealedSender, &conv.MemberGeneration)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, ErrNotFound
 		}
 		return nil, fmt.Errorf("get conversation: %w", err)
 	}
+	if err := s.openConversation(conv); err != nil {
+		return nil, err
+	}
 	return conv, nil
 }
 
+// openConversation decrypts the sealed fields of a conversation read from
+// the database.
+func (s *Store) openConversation(c *Conversation) error {
+	var err error
+	c.Title, err = s.openString("conversations.title", c.Title)
+	return err
+}
+
 // AddMember adds a user to a conversation.
 func (s *Store) AddMember(ctx context.Context, groupID, userID, role string) error {
 	_, err := s.db.ExecContext(ctx,
-		`INSERT INTO group_members (group_id, user_id, role, joined_at) VALUES (?, ?, ?, ?)`,
-		groupID, userID, role, time.Now().Unix(),
+		`INSERT INTO group_members (group_id, user_id, user_index, role, joined_at) VALUES (?, ?, ?, ?, ?)`,
+		groupID, s.sealString("group_members.user_id", userID), s.userIndex(userID), role, time.Now().Unix(),
 	)
 	if err != nil {
 		if isUniqueConstraintError(err) {
@@ -106,31 +136,122 @@ func (s *Store) AddMember(ctx context.Context, groupID, userID, role string) err
 	return nil
 }
 
+// CommitMembershipChange stores an MLS Commit and applies the membership
+// change it makes in one transaction. The Commit is queued for delivery to
+// the members before the change, except the sender, so removed members
+// receive it and added members, who join from a Welcome, do not. Added
+// users get the "member" role. Returns ErrConflict if an added user is
+// already a member and ErrNotFound if a removed user is not a member.
+// The change is stored with the Commit, for Message.AddedUserIDs and
+// RemovedUserIDs.
+func (s *Store) CommitMembershipChange(ctx context.Context, groupID, senderID string, commit []byte, added, removed []string) (string, int64, error) {
+	var msgID string
+	var serverTS int64
+	err := s.InTx(ctx, func(tx *sql.Tx) error {
+		var err error
+		msgID, serverTS, err = s.insertMessage(ctx, tx, groupID, senderID, commit, MsgTypeCommit, 0)
+		if err != nil {
+			return err
+		}
+		change, err := json.Marshal(membershipChange{Added: added, Removed: removed})
+		if err != nil {
+			return fmt.Errorf("encode membership change: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE messages SET membership_change = ? WHERE id = ?`,
+			s.sealBytes("messages.membership_change", change), msgID,
+		); err != nil {
+			return fmt.Errorf("store membership change: %w", err)
+		}
+
+		now := time.Now().Unix()
+		for _, userID := range added {
+			_, err := tx.ExecContext(ctx,
+				`INSERT INTO group_members (group_id, user_id, user_index, role, joined_at) VALUES (?, ?, ?, 'member', ?)`,
+				groupID, s.sealString("group_members.user_id", userID), s.userIndex(userID), now,
+			)
+			if err != nil {
+				if isUniqueConstraintError(err) {
+					return fmt.Errorf("member %s in group %s: %w", userID, groupID, ErrConflict)
+				}
+				return fmt.Errorf("add member %s: %w", userID, err)
+			}
+		}
+		for _, userID := range removed {
+			result, err := tx.ExecContext(ctx,
+				`DELETE FROM group_members WHERE group_id = ? AND user_index = ?`,
+				groupID, s.userIndex(userID),
+			)
+			if err != nil {
+				return fmt.Errorf("remove member %s: %w", userID, err)
+			}
+			n, err := result.RowsAffected()
+			if err != nil {
+				return fmt.Errorf("rows affected: %w", err)
+			}
+			if n == 0 {
+				return fmt.Errorf("member %s in group %s: %w", userID, groupID, ErrNotFound)
+			}
+		}
+		if len(removed) > 0 {
+			return bumpMemberGeneration(ctx, tx, groupID)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", 0, err
+	}
+	return msgID, serverTS, nil
+}
+
 // RemoveMember removes a user from a conversation.
 func (s *Store) RemoveMember(ctx context.Context, groupID, userID string) error {
-	result, err := s.db.ExecContext(ctx,
-		`DELETE FROM group_members WHERE group_id = ? AND user_id = ?`,
-		groupID, userID,
+	return s.InTx(ctx, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx,
+			`DELETE FROM group_members WHERE group_id = ? AND user_index = ?`,
+			groupID, s.userIndex(userID),
+		)
+		if err != nil {
+			return fmt.Errorf("remove member: %w", err)
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("rows affected: %w", err)
+		}
+		if n == 0 {
+			return ErrNotFound
+		}
+		return bumpMemberGeneration(ctx, tx, groupID)
+	})
+}
+
+// membershipChange is how a Commit's membership change is stored in
+// messages.membership_change.
+type membershipChange struct {
+	Added   []string `json:"added,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+}
+
+// bumpMemberGeneration records in tx that a member left the group.
+func bumpMemberGeneration(ctx context.Context, tx *sql.Tx, groupID string) error {
+	_, err := tx.ExecContext(ctx,
+		`UPDATE conversations SET member_generation = member_generation + 1 WHERE id = ?`,
+		groupID,
 	)
 	if err != nil {
-		return fmt.Errorf("remove member: %w", err)
-	}
-	n, err := result.RowsAffected()
-	if err != nil {
-		return fmt.Errorf("rows affected: %w", err)
-	}
-	if n == 0 {
-		return ErrNotFound
+		return fmt.Errorf("bump member generation: %w", err)
 	}
 	return nil
 }
 
 // GetMembers returns all members of a conversation.
 func (s *Store) GetMembers(ctx context.Context, groupID string) ([]*GroupMember, error) {
-	rows, err := s.db.QueryContext(ctx,
-		`SELECT group_id, user_id, role, joined_at FROM group_members WHERE group_id = ? ORDER BY joined_at`,
-		groupID,
-	)
+	stmt, err := s.readStmt(ctx,
+		`SELECT group_id, user_id, role, joined_at FROM group_members WHERE group_id = ? ORDER BY joined_at`)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := stmt.QueryContext(ctx, groupID)
 	if err != nil {
 		return nil, fmt.Errorf("get members: %w", err)
 	}
@@ -142,6 +263,10 @@ func (s *Store) GetMembers(ctx context.Context, groupID string) ([]*GroupMember,
 		if err := rows.Scan(&m.GroupID, &m.UserID, &m.Role, &m.JoinedAt); err != nil {
 			return nil, fmt.Errorf("scan member: %w", err)
 		}
+		var err error
+		if m.UserID, err = s.openString("group_members.user_id", m.UserID); err != nil {
+			return nil, err
+		}
 		members = append(members, m)
 	}
 	if err := rows.Err(); err != nil {
@@ -152,13 +277,13 @@ func (s *Store) GetMembers(ctx context.Context, groupID string) ([]*GroupMember,
 
 // GetConversationsForUser returns all conversations a user is a member of.
 func (s *Store) GetConversationsForUser(ctx context.Context, userID string) ([]*Conversation, error) {
-	rows, err := s.db.QueryContext(ctx,
-		`SELECT c.id, c.title, c.created_by, c.created_at
+	rows, err := s.readDB.QueryContext(ctx,
+		`SELECT c.id, c.title, c.created_by, c.created_at, c.sealed_sender, c.member_generation
 		 FROM conversations c
 		 JOIN group_members gm ON gm.group_id = c.id
-		 WHERE gm.user_id = ?
+		 WHERE gm.user_index = ?
 		 ORDER BY c.created_at DESC`,
-		userID,
+		s.userIndex(userID),
 	)
 	if err != nil {
 		return nil, fmt.Errorf("get conversations for user: %w", err)
@@ -168,9 +293,12 @@ func (s *Store) GetConversationsForUser(ctx context.Context, userID string) ([]*
 	var convs []*Conversation
 	for rows.Next() {
 		c := &Conversation{}
-		if err := rows.Scan(&c.ID, &c.Title, &c.CreatedBy, &c.CreatedAt); err != nil {
+		if err := rows.Scan(&c.ID, &c.Title, &c.CreatedBy, &c.CreatedAt, &c.SealedSender, &c.MemberGeneration); err != nil {
 			return nil, fmt.Errorf("scan conversation: %w", err)
 		}
+		if err := s.openConversation(c); err != nil {
+			return nil, err
+		}
 		convs = append(convs, c)
 	}
 	if err := rows.Err(); err != nil {
@@ -179,14 +307,51 @@ func (s *Store) GetConversationsForUser(ctx context.Context, userID string) ([]*
 	return convs, nil
 }
 
+// GetContactIDs returns the IDs of all other users who share at least one
+// conversation with userID.
+func (s *Store) GetContactIDs(ctx context.Context, userID string) ([]string, error) {
+	rows, err := s.readDB.QueryContext(ctx,
+		`SELECT MIN(other.user_id)
+		 FROM group_members me
+		 JOIN group_members other ON other.group_id = me.group_id
+		 WHERE me.user_index = ? AND other.user_index != ?
+		 GROUP BY other.user_index`,
+		s.userIndex(userID), s.userIndex(userID),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("get contact ids: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("scan contact id: %w", err)
+		}
+		// Each user's memberships hold their ID sealed separately, so any
+		// one of them will do.
+		id, err := s.openString("group_members.user_id", id)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate contact ids: %w", err)
+	}
+	slices.Sort(ids)
+	return ids, nil
+}
+
 // IsUserMember checks if a user is a member of a conversation.
 func (s *Store) IsUserMember(ctx context.Context, groupID, userID string) (bool, error) {
-	var count int
-	err := s.db.QueryRowContext(ctx,
-		`SELECT COUNT(*) FROM group_members WHERE group_id = ? AND user_id = ?`,
-		groupID, userID,
-	).Scan(&count)
+	stmt, err := s.readStmt(ctx, `SELECT COUNT(*) FROM group_members WHERE group_id = ? AND user_index = ?`)
 	if err != nil {
+		return false, err
+	}
+	var count int
+	if err := stmt.QueryRowContext(ctx, groupID, s.userIndex(userID)).Scan(&count); err != nil {
 		return false, fmt.Errorf("check membership: %w", err)
 	}
 	return count > 0, nil
@@ -196,9 +361,9 @@ func (s *Store) IsUserMember(ctx context.Context, groupID, userID string) (bool,
 // if the user is not a member.
 func (s *Store) GetMemberRole(ctx context.Context, groupID, userID string) (string, error) {
 	var role string
-	err := s.db.QueryRowContext(ctx,
-		`SELECT role FROM group_members WHERE group_id = ? AND user_id = ?`,
-		groupID, userID,
+	err := s.readDB.QueryRowContext(ctx,
+		`SELECT role FROM group_members WHERE group_id = ? AND user_index = ?`,
+		groupID, s.userIndex(userID),
 	).Scan(&role)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -214,12 +379,12 @@ func (s *Store) GetMemberRole(ctx context.Context, groupID, userID string) (stri
 func (s *Store) TransferAdmin(ctx context.Context, groupID, leavingUserID string) error {
 	_, err := s.db.ExecContext(ctx,
 		`UPDATE group_members SET role = 'admin'
-		 WHERE group_id = ? AND user_id = (
-			SELECT user_id FROM group_members
-			WHERE group_id = ? AND user_id != ?
+		 WHERE group_id = ? AND user_index = (
+			SELECT user_index FROM group_members
+			WHERE group_id = ? AND user_index != ?
 			ORDER BY joined_at ASC LIMIT 1
 		 )`,
-		groupID, groupID, leavingUserID,
+		groupID, groupID, s.userIndex(leavingUserID),
 	)
 	if err != nil {
 		return fmt.Errorf("transfer admin: %w", err)
@@ -3,6 +3,8 @@ package store
 import (
 	"context"
 	"errors"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 )
@@ -117,6 +119,23 @@ func TestGetConversation(t *testing.T) {
 		}
 	})
 
+	t.Run("sealed sender", func(t *testing.T) {
+		sealed, err := s.CreateConversationWithOptions(ctx, "Sealed", "alice", nil, ConversationOptions{SealedSender: true})
+		if err != nil {
+			t.Fatalf("CreateConversationWithOptions: %v", err)
+		}
+		got, err := s.GetConversation(ctx, sealed.ID)
+		if err != nil {
+			t.Fatalf("GetConversation: %v", err)
+		}
+		if !got.SealedSender {
+			t.Error("SealedSender = false, want true")
+		}
+		if got, _ := s.GetConversation(ctx, conv.ID); got.SealedSender {
+			t.Error("SealedSender = true for a default conversation, want false")
+		}
+	})
+
 	t.Run("not found", func(t *testing.T) {
 		_, err := s.GetConversation(ctx, "nonexistent")
 		if !errors.Is(err, ErrNotFound) {
@@ -175,14 +194,114 @@ func TestAddRemoveMember(t *testing.T) {
 		}
 	})
 
+	t.Run("remove member bumps generation", func(t *testing.T) {
+		got, err := s.GetConversation(ctx, conv.ID)
+		if err != nil {
+			t.Fatalf("GetConversation: %v", err)
+		}
+		if got.MemberGeneration != 1 {
+			t.Errorf("MemberGeneration = %d, want 1", got.MemberGeneration)
+		}
+	})
+
 	t.Run("remove nonexistent member returns ErrNotFound", func(t *testing.T) {
 		err := s.RemoveMember(ctx, conv.ID, "nonexistent")
 		if !errors.Is(err, ErrNotFound) {
 			t.Errorf("error = %v, want ErrNotFound", err)
 		}
+		if got, _ := s.GetConversation(ctx, conv.ID); got.MemberGeneration != 1 {
+			t.Errorf("MemberGeneration = %d after failed removal, want 1", got.MemberGeneration)
+		}
 	})
 }
 
+func TestCommitMembershipChange(t *testing.T) {
+	tests := []struct {
+		name        string
+		added       []string
+		removed     []string
+		wantErr     error
+		wantMembers []string
+		wantPending map[string]int // pending commits per user
+	}{
+		{
+			name:        "add and remove",
+			added:       []string{"dave"},
+			removed:     []string{"bob"},
+			wantMembers: []string{"alice", "charlie", "dave"},
+			wantPending: map[string]int{"bob": 1, "charlie": 1, "dave": 0, "alice": 0},
+		},
+		{
+			name:        "add existing member",
+			added:       []string{"dave", "charlie"},
+			wantErr:     ErrConflict,
+			wantMembers: []string{"alice", "bob", "charlie"},
+			wantPending: map[string]int{"bob": 0, "charlie": 0, "dave": 0},
+		},
+		{
+			name:        "remove non-member",
+			removed:     []string{"bob", "dave"},
+			wantErr:     ErrNotFound,
+			wantMembers: []string{"alice", "bob", "charlie"},
+			wantPending: map[string]int{"bob": 0, "charlie": 0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestStore(t)
+			ctx := context.Background()
+			seedUsers(t, s, "alice", "bob", "charlie", "dave")
+
+			conv, err := s.CreateConversation(ctx, "Group", "alice", []string{"bob", "charlie"})
+			if err != nil {
+				t.Fatalf("CreateConversation: %v", err)
+			}
+
+			msgID, _, err := s.CommitMembershipChange(ctx, conv.ID, "alice", []byte("commit"), tt.added, tt.removed)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("CommitMembershipChange: err = %v, want %v", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatalf("CommitMembershipChange: %v", err)
+			} else if msgID == "" {
+				t.Error("message ID is empty")
+			}
+
+			members, err := s.GetMembers(ctx, conv.ID)
+			if err != nil {
+				t.Fatalf("GetMembers: %v", err)
+			}
+			var got []string
+			for _, m := range members {
+				got = append(got, m.UserID)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.wantMembers, ",") {
+				t.Errorf("members = %v, want %v", got, tt.wantMembers)
+			}
+
+			for userID, want := range tt.wantPending {
+				msgs, err := s.GetPendingMessages(ctx, userID)
+				if err != nil {
+					t.Fatalf("GetPendingMessages(%s): %v", userID, err)
+				}
+				if len(msgs) != want {
+					t.Errorf("pending messages for %s = %d, want %d", userID, len(msgs), want)
+				}
+				for _, m := range msgs {
+					if m.MessageType != MsgTypeCommit {
+						t.Errorf("pending message type = %d, want MsgTypeCommit", m.MessageType)
+					}
+					if !slices.Equal(m.AddedUserIDs, tt.added) || !slices.Equal(m.RemovedUserIDs, tt.removed) {
+						t.Errorf("pending commit change = +%v -%v, want +%v -%v", m.AddedUserIDs, m.RemovedUserIDs, tt.added, tt.removed)
+					}
+				}
+			}
+		})
+	}
+}
+
 func TestGetMembers(t *testing.T) {
 	s := newTestStore(t)
 	ctx := context.Background()
@@ -220,6 +339,47 @@ func TestGetMembers(t *testing.T) {
 	}
 }
 
+func TestGetContactIDs(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	now := time.Now().Unix()
+
+	for _, uid := range []string{"alice", "bob", "charlie", "dave"} {
+		_ = s.CreateUser(ctx, &User{
+			ID: uid, Username: uid, DisplayName: uid,
+			Role: "member", Enabled: true, CreatedAt: now, UpdatedAt: now,
+		})
+	}
+
+	if _, err := s.CreateConversation(ctx, "Conv 1", "alice", []string{"bob"}); err != nil {
+		t.Fatalf("CreateConversation 1: %v", err)
+	}
+	if _, err := s.CreateConversation(ctx, "Conv 2", "alice", []string{"bob", "charlie"}); err != nil {
+		t.Fatalf("CreateConversation 2: %v", err)
+	}
+
+	tests := []struct {
+		userID string
+		want   []string
+	}{
+		{userID: "alice", want: []string{"bob", "charlie"}},
+		{userID: "charlie", want: []string{"alice", "bob"}},
+		{userID: "dave", want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.userID, func(t *testing.T) {
+			got, err := s.GetContactIDs(ctx, tt.userID)
+			if err != nil {
+				t.Fatalf("GetContactIDs: %v", err)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("GetContactIDs(%q) = %v, want %v", tt.userID, got, tt.want)
+			}
+		})
+	}
+}
+
 func TestGetConversationsForUser(t *testing.T) {
 	s := newTestStore(t)
 	ctx := context.Background()
@@ -16,14 +16,20 @@ type Credential struct {
 	SignCount    int64
 	CreatedAt    int64
 	LastUsedAt   *int64 // nil if never used after creation
+
+	// AAGUID identifies the authenticator model. Nil for credentials created
+	// before it was recorded; all zeros if the authenticator did not disclose it.
+	AAGUID []byte
+	// AttestationFormat is the attestation statement format, e.g. "none" or "packed".
+	AttestationFormat string
 }
 
 // CreateCredential inserts a new credential.
 func (s *Store) CreateCredential(ctx context.Context, c *Credential) error {
 	_, err := s.db.ExecContext(ctx,
-		`INSERT INTO credential (id, user_id, credential_id, public_key, sign_count, created_at, last_used_at)
-		 VALUES (?, ?, ?, ?, ?, ?, ?)`,
-		c.ID, c.UserID, c.CredentialID, c.PublicKey, c.SignCount, c.CreatedAt, c.LastUsedAt,
+		`INSERT INTO credential (id, user_id, credential_id, public_key, sign_count, created_at, last_used_at, aaguid, attestation_format)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		c.ID, c.UserID, c.CredentialID, c.PublicKey, c.SignCount, c.CreatedAt, c.LastUsedAt, c.AAGUID, c.AttestationFormat,
 	)
 	if err != nil {
 		if isUniqueConstraintError(err) {
@@ -38,10 +44,10 @@ func (s *Store) CreateCredential(ctx context.Context, c *Credential) error {
 func (s *Store) GetCredentialByID(ctx context.Context, id string) (*Credential, error) {
 	c := &Credential{}
 	var lastUsedAt sql.NullInt64
-	err := s.db.QueryRowContext(ctx,
-		`SELECT id, user_id, credential_id, public_key, sign_count, created_at, last_used_at
+	err := s.readDB.QueryRowContext(ctx,
+		`SELECT id, user_id, credential_id, public_key, sign_count, created_at, last_used_at, aaguid, attestation_format
 		 FROM credential WHERE id = ?`, id,
-	).Scan(&c.ID, &c.UserID, &c.CredentialID, &c.PublicKey, &c.SignCount, &c.CreatedAt, &lastUsedAt)
+	).Scan(&c.ID, &c.UserID, &c.CredentialID, &c.PublicKey, &c.SignCount, &c.CreatedAt, &lastUsedAt, &c.AAGUID, &c.AttestationFormat)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, ErrNotFound
@@ -56,8 +62,8 @@ func (s *Store) GetCredentialByID(ctx context.Context, id string) (*Credential,
 
 // GetCredentialsByUserID returns all credentials for a user.
 func (s *Store) GetCredentialsByUserID(ctx context.Context, userID string) ([]*Credential, error) {
-	rows, err := s.db.QueryContext(ctx,
-		`SELECT id, user_id, credential_id, public_key, sign_count, created_at, last_used_at
+	rows, err := s.readDB.QueryContext(ctx,
+		`SELECT id, user_id, credential_id, public_key, sign_count, created_at, last_used_at, aaguid, attestation_format
 		 FROM credential WHERE user_id = ? ORDER BY created_at`, userID,
 	)
 	if err != nil {
@@ -69,7 +75,7 @@ func (s *Store) GetCredentialsByUserID(ctx context.Context, userID string) ([]*C
 	for rows.Next() {
 		c := &Credential{}
 		var lastUsedAt sql.NullInt64
-		if err := rows.Scan(&c.ID, &c.UserID, &c.CredentialID, &c.PublicKey, &c.SignCount, &c.CreatedAt, &lastUsedAt); err != nil {
+		if err := rows.Scan(&c.ID, &c.UserID, &c.CredentialID, &c.PublicKey, &c.SignCount, &c.CreatedAt, &lastUsedAt, &c.AAGUID, &c.AttestationFormat); err != nil {
 			return nil, fmt.Errorf("scan credential: %w", err)
 		}
 		if lastUsedAt.Valid {
@@ -300,6 +300,9 @@ func TestCredentialRoundTrip(t *testing.T) {
 		PublicKey:    []byte("public-key-bytes"),
 		SignCount:    42,
 		CreatedAt:    time.Now().Unix(),
+
+		AAGUID:            []byte{0xee, 0x88, 0x28, 0x79, 0x72, 0x1c, 0x49, 0x13, 0x97, 0x75, 0x3d, 0xfc, 0xce, 0x97, 0x07, 0x2a},
+		AttestationFormat: "packed",
 	}
 
 	if err := s.CreateCredential(ctx, want); err != nil {
@@ -329,4 +332,25 @@ func TestCredentialRoundTrip(t *testing.T) {
 	if got.LastUsedAt != nil {
 		t.Errorf("LastUsedAt = %v, want nil", *got.LastUsedAt)
 	}
+	if string(got.AAGUID) != string(want.AAGUID) {
+		t.Errorf("AAGUID = %x, want %x", got.AAGUID, want.AAGUID)
+	}
+	if got.AttestationFormat != want.AttestationFormat {
+		t.Errorf("AttestationFormat = %q, want %q", got.AttestationFormat, want.AttestationFormat)
+	}
+
+	// Credentials created without policy data read back as empty.
+	legacy := makeCredential("c2", "u1", []byte("legacy-cred-id"))
+	if err := s.CreateCredential(ctx, legacy); err != nil {
+		t.Fatalf("CreateCredential: %v", err)
+	}
+	creds, err := s.GetCredentialsByUserID(ctx, "u1")
+	if err != nil {
+		t.Fatalf("GetCredentialsByUserID: %v", err)
+	}
+	for _, c := range creds {
+		if c.ID == "c2" && (c.AAGUID != nil || c.AttestationFormat != "") {
+			t.Errorf("legacy credential AAGUID = %x, format = %q, want empty", c.AAGUID, c.AttestationFormat)
+		}
+	}
 }
@@ -0,0 +1,758 @@
+package store
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// Encryption at rest is optional. When it is enabled, the columns listed
+// in encryptedColumns are sealed with AES-256-GCM under a random data key,
+// and session token hashes are replaced by their HMAC-SHA256 under a
+// random index key, so that sessions can still be looked up by hash.
+// Memberships and deliveries are looked up by user, so their user IDs are
+// replaced by keyed hashes under the same index key (see userIndex); a
+// membership also keeps its user ID, sealed. Both keys are stored in the
+// encryption_key table, wrapped with the master key, which the server is
+// given at startup as a key file or a passphrase.
+//
+// Rotating the master key rewraps the two keys. Rotating the data key also
+// re-encrypts every sealed value. The index key is never rotated, since
+// the token hashes it covers cannot be recomputed without the tokens.
+//
+// Conversation and message IDs, the creators of conversations, the senders
+// of messages that are not sealed-sender, and all timestamps stay in the
+// clear: the server needs them to route messages.
+
+// Errors returned by encryption at rest.
+var (
+	ErrWrongKey        = errors.New("wrong master key or corrupted key")
+	ErrEncrypted       = errors.New("database is encrypted; a master key is required")
+	ErrNotEncrypted    = errors.New("database is not encrypted")
+	ErrInvalidKeyFile  = errors.New("key file must hold 32 bytes, hex-encoded")
+	ErrEmptyPassphrase = errors.New("empty passphrase")
+)
+
+// Argon2id parameters for deriving a master key from a passphrase.
+const (
+	kdfTime    = 3
+	kdfMemory  = 64 * 1024
+	kdfThreads = 4
+)
+
+// sealedTextPrefix marks a sealed value in a text column, which holds the
+// sealed bytes base64-encoded.
+const sealedTextPrefix = "enc1:"
+
+// sealedVersion is the first byte of every sealed value.
+const sealedVersion = 1
+
+// userIndexPrefix marks the keyed hash of a user ID in a lookup column.
+const userIndexPrefix = "idx1:"
+
+// encryptedColumn is a column whose values are sealed.
+type encryptedColumn struct {
+	table  string
+	key    string // the table's primary key, used to page through it; comma-separated if composite
+	column string
+	text   bool // a TEXT column; otherwise a BLOB
+}
+
+// encryptedColumns lists every sealed column. The column name, with its
+// table, is the additional data of each sealed value, so that a value
+// cannot be moved to another column unnoticed.
+var encryptedColumns = []encryptedColumn{
+	{table: "messages", key: "id", column: "payload"},
+	{table: "messages", key: "id", column: "membership_change"},
+	{table: "conversations", key: "id", column: "title", text: true},
+	{table: "group_members", key: "group_id, user_index", column: "user_id", text: true},
+	{table: "key_packages", key: "id", column: "key_package_data"},
+	{table: "mls_group_state", key: "conversation_id", column: "group_info"},
+	{table: "mls_group_state", key: "conversation_id", column: "updated_by", text: true},
+	{table: "server_secret", key: "name", column: "value"},
+}
+
+// MasterKey is the key that wraps the data and index keys: either the
+// contents of a key file or a passphrase.
+type MasterKey struct {
+	key        []byte // nil for a passphrase
+	passphrase string
+}
+
+// MasterKeyFromFile reads a master key from a key file, as written by
+// GenerateKeyFile.
+func MasterKeyFromFile(path string) (*MasterKey, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read key file: %w", err)
+	}
+	key, err := hex.DecodeString(strings.TrimSpace(string(b)))
+	if err != nil || len(key) != 32 {
+		return nil, ErrInvalidKeyFile
+	}
+	return &MasterKey{key: key}, nil
+}
+
+// MasterKeyFromPassphrase returns a master key derived from passphrase
+// with Argon2id.
+func MasterKeyFromPassphrase(passphrase string) (*MasterKey, error) {
+	if passphrase == "" {
+		return nil, ErrEmptyPassphrase
+	}
+	return &MasterKey{passphrase: passphrase}, nil
+}
+
+// GenerateKeyFile writes a new random master key to path, which must not
+// exist, readable only by its owner.
+func GenerateKeyFile(path string) error {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return fmt.Errorf("generate key: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(f, hex.EncodeToString(key))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(path)
+		return fmt.Errorf("write key file: %w", err)
+	}
+	return nil
+}
+
+// isPassphrase reports whether the master key is a passphrase.
+func (mk *MasterKey) isPassphrase() bool {
+	return mk.key == nil
+}
+
+// kek returns the key-encryption key. salt is used for a passphrase and
+// ignored for a key file.
+func (mk *MasterKey) kek(salt []byte) []byte {
+	if !mk.isPassphrase() {
+		return mk.key
+	}
+	return argon2.IDKey([]byte(mk.passphrase), salt, kdfTime, kdfMemory, kdfThreads, 32)
+}
+
+// wrapKey seals key under kek. purpose is the additional data, so a
+// wrapped data key cannot be passed off as an index key.
+func wrapKey(kek, key []byte, purpose string) ([]byte, error) {
+	aead, err := newAEAD(kek)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("generate nonce: %w", err)
+	}
+	return aead.Seal(nonce, nonce, key, []byte(purpose)), nil
+}
+
+// unwrapKey opens a key sealed by wrapKey.
+func unwrapKey(kek, wrapped []byte, purpose string) ([]byte, error) {
+	aead, err := newAEAD(kek)
+	if err != nil {
+		return nil, err
+	}
+	if len(wrapped) < aead.NonceSize() {
+		return nil, ErrWrongKey
+	}
+	key, err := aead.Open(nil, wrapped[:aead.NonceSize()], wrapped[aead.NonceSize():], []byte(purpose))
+	if err != nil {
+		return nil, ErrWrongKey
+	}
+	return key, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("create cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// fieldCipher seals column values and computes token hash indexes.
+type fieldCipher struct {
+	dataKey  []byte
+	indexKey []byte
+	aead     cipher.AEAD
+}
+
+func newFieldCipher(dataKey, indexKey []byte) (*fieldCipher, error) {
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	return &fieldCipher{dataKey: dataKey, indexKey: indexKey, aead: aead}, nil
+}
+
+// newRandomKey returns 32 random bytes.
+func newRandomKey() ([]byte, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("generate key: %w", err)
+	}
+	return key, nil
+}
+
+// seal encrypts a value of column ("table.column") as
+// version || nonce || ciphertext.
+func (c *fieldCipher) seal(column string, plaintext []byte) []byte {
+	out := make([]byte, 1+c.aead.NonceSize(), 1+c.aead.NonceSize()+len(plaintext)+c.aead.Overhead())
+	out[0] = sealedVersion
+	nonce := out[1:]
+	if _, err := rand.Read(nonce); err != nil {
+		// crypto/rand does not fail on supported platforms.
+		panic(fmt.Sprintf("generate nonce: %v", err))
+	}
+	return c.aead.Seal(out, nonce, plaintext, []byte(column))
+}
+
+// open decrypts a value sealed by seal.
+func (c *fieldCipher) open(column string, sealed []byte) ([]byte, error) {
+	n := c.aead.NonceSize()
+	if len(sealed) < 1+n || sealed[0] != sealedVersion {
+		return nil, fmt.Errorf("decrypt %s: not a sealed value", column)
+	}
+	plaintext, err := c.aead.Open(nil, sealed[1:1+n], sealed[1+n:], []byte(column))
+	if err != nil {
+		return nil, fmt.Errorf("decrypt %s: %w", column, err)
+	}
+	return plaintext, nil
+}
+
+// sealText encrypts a value of a text column.
+func (c *fieldCipher) sealText(column, plaintext string) string {
+	return sealedTextPrefix + base64.RawStdEncoding.EncodeToString(c.seal(column, []byte(plaintext)))
+}
+
+// openText decrypts a value sealed by sealText.
+func (c *fieldCipher) openText(column, sealed string) (string, error) {
+	b64, ok := strings.CutPrefix(sealed, sealedTextPrefix)
+	if !ok {
+		return "", fmt.Errorf("decrypt %s: not a sealed value", column)
+	}
+	b, err := base64.RawStdEncoding.DecodeString(b64)
+	if err != nil {
+		return "", fmt.Errorf("decrypt %s: %w", column, err)
+	}
+	plaintext, err := c.open(column, b)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// index returns the keyed hash stored in place of a session token hash.
+func (c *fieldCipher) index(tokenHash []byte) []byte {
+	mac := hmac.New(sha256.New, c.indexKey)
+	mac.Write(tokenHash)
+	return mac.Sum(nil)
+}
+
+// userIndex returns the keyed hash stored in place of a user ID in the
+// lookup columns of memberships and deliveries.
+func (c *fieldCipher) userIndex(userID string) string {
+	mac := hmac.New(sha256.New, c.indexKey)
+	mac.Write([]byte("user\x00" + userID))
+	return userIndexPrefix + base64.RawStdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// sealBytes encrypts a value of a BLOB column if encryption is enabled.
+func (s *Store) sealBytes(column string, b []byte) []byte {
+	if s.cipher == nil {
+		return b
+	}
+	return s.cipher.seal(column, b)
+}
+
+// openBytes decrypts a value of a BLOB column if encryption is enabled.
+func (s *Store) openBytes(column string, b []byte) ([]byte, error) {
+	if s.cipher == nil {
+		return b, nil
+	}
+	return s.cipher.open(column, b)
+}
+
+// sealString encrypts a value of a TEXT column if encryption is enabled.
+func (s *Store) sealString(column, v string) string {
+	if s.cipher == nil {
+		return v
+	}
+	return s.cipher.sealText(column, v)
+}
+
+// openString decrypts a value of a TEXT column if encryption is enabled.
+func (s *Store) openString(column, v string) (string, error) {
+	if s.cipher == nil {
+		return v, nil
+	}
+	return s.cipher.openText(column, v)
+}
+
+// tokenIndex returns the value stored for a session token hash.
+func (s *Store) tokenIndex(tokenHash []byte) []byte {
+	if s.cipher == nil {
+		return tokenHash
+	}
+	return s.cipher.index(tokenHash)
+}
+
+// userIndex returns the value stored for a user ID in
+// group_members.user_index and delivery_status.recipient_index.
+func (s *Store) userIndex(userID string) string {
+	if s.cipher == nil {
+		return userID
+	}
+	return s.cipher.userIndex(userID)
+}
+
+// userIndexes returns the userIndex of each of userIDs.
+func (s *Store) userIndexes(userIDs []string) []string {
+	indexes := make([]string, len(userIDs))
+	for i, id := range userIDs {
+		indexes[i] = s.userIndex(id)
+	}
+	return indexes
+}
+
+// EncryptionInfo describes the encryption at rest of a database.
+type EncryptionInfo struct {
+	Enabled    bool
+	Passphrase bool  // the master key is a passphrase rather than a key file
+	CreatedAt  int64 // Unix seconds
+	RotatedAt  int64 // Unix seconds; when the master or data key last changed
+}
+
+// encryptionKeyRow is the single row of the encryption_key table.
+type encryptionKeyRow struct {
+	dataKey   []byte
+	indexKey  []byte
+	kdfSalt   []byte
+	createdAt int64
+	rotatedAt int64
+	// identitiesIndexed is false for a database encrypted before
+	// migrateV16, until indexIdentities has run.
+	identitiesIndexed bool
+}
+
+func getEncryptionKey(ctx context.Context, q interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}) (*encryptionKeyRow, error) {
+	var row encryptionKeyRow
+	err := q.QueryRowContext(ctx,
+		`SELECT data_key, index_key, kdf_salt, created_at, rotated_at, identities_indexed FROM encryption_key WHERE id = 1`,
+	).Scan(&row.dataKey, &row.indexKey, &row.kdfSalt, &row.createdAt, &row.rotatedAt, &row.identitiesIndexed)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("get encryption key: %w", err)
+	}
+	return &row, nil
+}
+
+// Encryption reports whether the database is encrypted at rest.
+func (s *Store) Encryption(ctx context.Context) (*EncryptionInfo, error) {
+	row, err := getEncryptionKey(ctx, s.readDB)
+	if errors.Is(err, ErrNotFound) {
+		return &EncryptionInfo{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &EncryptionInfo{
+		Enabled:    true,
+		Passphrase: row.kdfSalt != nil,
+		CreatedAt:  row.createdAt,
+		RotatedAt:  row.rotatedAt,
+	}, nil
+}
+
+// Unlock enables encryption at rest with the master key mk. If the
+// database is encrypted, its keys are unwrapped with mk; ErrWrongKey means
+// mk is not the key they were wrapped with, and a database encrypted
+// before migrateV16 is brought up to date. Otherwise new keys are
+// generated and every existing value is encrypted, in one transaction,
+// and Unlock reports that it did so. The schema must be up to date.
+//
+// Unlock must be called before the store is used. A store opened on an
+// encrypted database without Unlock cannot read the sealed columns.
+func (s *Store) Unlock(ctx context.Context, mk *MasterKey) (initialized bool, err error) {
+	row, err := getEncryptionKey(ctx, s.db)
+	if err == nil {
+		if mk.isPassphrase() != (row.kdfSalt != nil) {
+			return false, ErrWrongKey
+		}
+		kek := mk.kek(row.kdfSalt)
+		dataKey, err := unwrapKey(kek, row.dataKey, "data key")
+		if err != nil {
+			return false, err
+		}
+		indexKey, err := unwrapKey(kek, row.indexKey, "index key")
+		if err != nil {
+			return false, err
+		}
+		c, err := newFieldCipher(dataKey, indexKey)
+		if err != nil {
+			return false, err
+		}
+		if !row.identitiesIndexed {
+			if err := s.InTx(ctx, func(tx *sql.Tx) error {
+				return indexIdentities(ctx, tx, c)
+			}); err != nil {
+				return false, err
+			}
+		}
+		s.cipher = c
+		return false, nil
+	}
+	if !errors.Is(err, ErrNotFound) {
+		return false, err
+	}
+
+	dataKey, err := newRandomKey()
+	if err != nil {
+		return false, err
+	}
+	indexKey, err := newRandomKey()
+	if err != nil {
+		return false, err
+	}
+	c, err := newFieldCipher(dataKey, indexKey)
+	if err != nil {
+		return false, err
+	}
+	err = s.InTx(ctx, func(tx *sql.Tx) error {
+		if err := reencrypt(ctx, tx, nil, c); err != nil {
+			return err
+		}
+		if err := indexTokenHashes(ctx, tx, c); err != nil {
+			return err
+		}
+		if err := indexUserIDs(ctx, tx, c); err != nil {
+			return err
+		}
+		now := time.Now().Unix()
+		return putEncryptionKey(ctx, tx, mk, c, now, now, false)
+	})
+	if err != nil {
+		return false, err
+	}
+	s.cipher = c
+	return true, nil
+}
+
+// RotateKey rewraps the keys of an unlocked store with a new master key.
+// With newDataKey, a new data key is also generated and every sealed value
+// is re-encrypted with it, in the same transaction.
+//
+// A running server would go on sealing values with the keys it unlocked,
+// so the store must hold the database lock with LockExclusive.
+func (s *Store) RotateKey(ctx context.Context, newMK *MasterKey, newDataKey bool) error {
+	if s.cipher == nil {
+		return ErrNotEncrypted
+	}
+	if !s.exclusive.Load() {
+		return errors.New("rotate key: the database must be locked with LockExclusive")
+	}
+	c := s.cipher
+	err := s.InTx(ctx, func(tx *sql.Tx) error {
+		row, err := getEncryptionKey(ctx, tx)
+		if err != nil {
+			return err
+		}
+		if newDataKey {
+			dataKey, err := newRandomKey()
+			if err != nil {
+				return err
+			}
+			if c, err = newFieldCipher(dataKey, s.cipher.indexKey); err != nil {
+				return err
+			}
+			if err := reencrypt(ctx, tx, s.cipher, c); err != nil {
+				return err
+			}
+		}
+		return putEncryptionKey(ctx, tx, newMK, c, row.createdAt, time.Now().Unix(), true)
+	})
+	if err != nil {
+		return err
+	}
+	s.cipher = c
+	return nil
+}
+
+// putEncryptionKey wraps the keys of c with mk and stores them.
+func putEncryptionKey(ctx context.Context, tx *sql.Tx, mk *MasterKey, c *fieldCipher, createdAt, rotatedAt int64, replace bool) error {
+	var salt []byte
+	if mk.isPassphrase() {
+		salt = make([]byte, 16)
+		if _, err := rand.Read(salt); err != nil {
+			return fmt.Errorf("generate salt: %w", err)
+		}
+	}
+	kek := mk.kek(salt)
+	dataKey, err := wrapKey(kek, c.dataKey, "data key")
+	if err != nil {
+		return err
+	}
+	indexKey, err := wrapKey(kek, c.indexKey, "index key")
+	if err != nil {
+		return err
+	}
+
+	stmt := `INSERT INTO encryption_key (id, data_key, index_key, kdf_salt, created_at, rotated_at, identities_indexed) VALUES (1, ?, ?, ?, ?, ?, ?)`
+	args := []any{dataKey, indexKey, salt, createdAt, rotatedAt, true}
+	if replace {
+		stmt = `UPDATE encryption_key SET data_key = ?, index_key = ?, kdf_salt = ?, created_at = ?, rotated_at = ? WHERE id = 1`
+		args = args[:5]
+	}
+	if _, err := tx.ExecContext(ctx, stmt, args...); err != nil {
+		return fmt.Errorf("store encryption key: %w", err)
+	}
+	return nil
+}
+
+// reencryptBatch is the number of rows read at a time by reencrypt.
+const reencryptBatch = 500
+
+// reencrypt re-encrypts every value of the encrypted columns from one
+// cipher to another. A nil from means the values are in the clear.
+func reencrypt(ctx context.Context, tx *sql.Tx, from, to *fieldCipher) error {
+	for _, col := range encryptedColumns {
+		if err := reencryptColumn(ctx, tx, col, from, to); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reencryptColumn re-encrypts every value of one encrypted column, as
+// reencrypt does.
+func reencryptColumn(ctx context.Context, tx *sql.Tx, col encryptedColumn, from, to *fieldCipher) error {
+	name := col.table + "." + col.column
+	keys := strings.Split(col.key, ", ")
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(keys)), ", ")
+	match := strings.Join(keys, " = ? AND ") + " = ?"
+	after := make([]any, len(keys))
+	for i := range after {
+		after[i] = ""
+	}
+	for {
+		type value struct {
+			key  []any
+			data []byte
+			null bool
+		}
+		rows, err := tx.QueryContext(ctx,
+			`SELECT `+col.key+`, `+col.column+`, `+col.column+` IS NULL FROM `+col.table+
+				` WHERE (`+col.key+`) > (`+placeholders+`) ORDER BY `+col.key+` LIMIT ?`,
+			append(after, reencryptBatch)...)
+		if err != nil {
+			return fmt.Errorf("read %s: %w", name, err)
+		}
+		var batch []value
+		for rows.Next() {
+			v := value{key: make([]any, len(keys))}
+			dest := make([]any, len(keys), len(keys)+2)
+			for i := range keys {
+				dest[i] = new(string)
+			}
+			if err := rows.Scan(append(dest, &v.data, &v.null)...); err != nil {
+				rows.Close()
+				return fmt.Errorf("scan %s: %w", name, err)
+			}
+			for i := range keys {
+				v.key[i] = *dest[i].(*string)
+			}
+			batch = append(batch, v)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("read %s: %w", name, err)
+		}
+
+		for _, v := range batch {
+			if v.null {
+				continue // NULL means no value, encrypted or not
+			}
+			var updated any
+			if col.text {
+				plaintext := string(v.data)
+				if from != nil {
+					if plaintext, err = from.openText(name, plaintext); err != nil {
+						return err
+					}
+				}
+				updated = to.sealText(name, plaintext)
+			} else {
+				plaintext := v.data
+				if from != nil {
+					if plaintext, err = from.open(name, plaintext); err != nil {
+						return err
+					}
+				}
+				updated = to.seal(name, plaintext)
+			}
+			if _, err := tx.ExecContext(ctx,
+				`UPDATE `+col.table+` SET `+col.column+` = ? WHERE `+match, append([]any{updated}, v.key...)...,
+			); err != nil {
+				return fmt.Errorf("update %s: %w", name, err)
+			}
+		}
+		if len(batch) < reencryptBatch {
+			return nil
+		}
+		after = batch[len(batch)-1].key
+	}
+}
+
+// indexTokenHashes replaces the stored session token hashes with their
+// keyed hashes.
+func indexTokenHashes(ctx context.Context, tx *sql.Tx, c *fieldCipher) error {
+	rows, err := tx.QueryContext(ctx, `SELECT id, token_hash FROM session`)
+	if err != nil {
+		return fmt.Errorf("read session token hashes: %w", err)
+	}
+	hashes := make(map[string][]byte)
+	for rows.Next() {
+		var id string
+		var hash []byte
+		if err := rows.Scan(&id, &hash); err != nil {
+			rows.Close()
+			return fmt.Errorf("scan session token hash: %w", err)
+		}
+		hashes[id] = hash
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("read session token hashes: %w", err)
+	}
+	for id, hash := range hashes {
+		if _, err := tx.ExecContext(ctx, `UPDATE session SET token_hash = ? WHERE id = ?`, c.index(hash), id); err != nil {
+			return fmt.Errorf("update session token hash: %w", err)
+		}
+	}
+	return nil
+}
+
+// indexUserIDs replaces the user IDs in the lookup columns of memberships
+// and deliveries with their keyed hashes.
+func indexUserIDs(ctx context.Context, tx *sql.Tx, c *fieldCipher) error {
+	for _, col := range []struct{ table, column string }{
+		{"group_members", "user_index"},
+		{"delivery_status", "recipient_index"},
+	} {
+		name := col.table + "." + col.column
+		rows, err := tx.QueryContext(ctx,
+			`SELECT DISTINCT `+col.column+` FROM `+col.table+` WHERE `+col.column+` NOT LIKE '`+userIndexPrefix+`%'`)
+		if err != nil {
+			return fmt.Errorf("read %s: %w", name, err)
+		}
+		var ids []string
+		for rows.Next() {
+			var id string
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return fmt.Errorf("scan %s: %w", name, err)
+			}
+			ids = append(ids, id)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("read %s: %w", name, err)
+		}
+		for _, id := range ids {
+			if _, err := tx.ExecContext(ctx,
+				`UPDATE `+col.table+` SET `+col.column+` = ? WHERE `+col.column+` = ?`, c.userIndex(id), id,
+			); err != nil {
+				return fmt.Errorf("update %s: %w", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// unsealedColumns are the columns that were sealed before the user IDs of
+// memberships and deliveries were indexed, and are now kept in the clear.
+var unsealedColumns = []encryptedColumn{
+	{table: "messages", key: "id", column: "sender_id", text: true},
+	{table: "conversations", key: "id", column: "created_by", text: true},
+}
+
+// indexIdentities brings a database encrypted before migrateV16 up to
+// date: it opens the values of unsealedColumns, seals the user IDs of
+// memberships and indexes the user IDs of memberships and deliveries.
+func indexIdentities(ctx context.Context, tx *sql.Tx, c *fieldCipher) error {
+	for _, col := range unsealedColumns {
+		name := col.table + "." + col.column
+		for {
+			rows, err := tx.QueryContext(ctx,
+				`SELECT `+col.key+`, `+col.column+` FROM `+col.table+` WHERE `+col.column+` LIKE '`+sealedTextPrefix+`%' LIMIT ?`,
+				reencryptBatch)
+			if err != nil {
+				return fmt.Errorf("read %s: %w", name, err)
+			}
+			sealed := make(map[string]string)
+			for rows.Next() {
+				var key, v string
+				if err := rows.Scan(&key, &v); err != nil {
+					rows.Close()
+					return fmt.Errorf("scan %s: %w", name, err)
+				}
+				sealed[key] = v
+			}
+			rows.Close()
+			if err := rows.Err(); err != nil {
+				return fmt.Errorf("read %s: %w", name, err)
+			}
+			for key, v := range sealed {
+				plaintext, err := c.openText(name, v)
+				if err != nil {
+					return err
+				}
+				if _, err := tx.ExecContext(ctx,
+					`UPDATE `+col.table+` SET `+col.column+` = ? WHERE `+col.key+` = ?`, plaintext, key,
+				); err != nil {
+					return fmt.Errorf("update %s: %w", name, err)
+				}
+			}
+			if len(sealed) < reencryptBatch {
+				break
+			}
+		}
+	}
+	for _, col := range encryptedColumns {
+		if col.table == "group_members" {
+			if err := reencryptColumn(ctx, tx, col, nil, c); err != nil {
+				return err
+			}
+		}
+	}
+	if err := indexUserIDs(ctx, tx, c); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE encryption_key SET identities_indexed = ? WHERE id = 1`, true); err != nil {
+		return fmt.Errorf("update encryption key: %w", err)
+	}
+	return nil
+}
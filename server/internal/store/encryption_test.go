@@ -0,0 +1,373 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newKeyFile(t *testing.T) *MasterKey {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "master.key")
+	if err := GenerateKeyFile(path); err != nil {
+		t.Fatalf("GenerateKeyFile: %v", err)
+	}
+	mk, err := MasterKeyFromFile(path)
+	if err != nil {
+		t.Fatalf("MasterKeyFromFile: %v", err)
+	}
+	return mk
+}
+
+func TestMasterKeyFromFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "master.key")
+	if err := GenerateKeyFile(path); err != nil {
+		t.Fatalf("GenerateKeyFile: %v", err)
+	}
+	if fi, err := os.Stat(path); err != nil || fi.Mode().Perm() != 0o600 {
+		t.Errorf("key file mode = %v, %v; want 0600", fi.Mode().Perm(), err)
+	}
+	if err := GenerateKeyFile(path); err == nil {
+		t.Error("GenerateKeyFile overwrote an existing file")
+	}
+
+	short := filepath.Join(dir, "short.key")
+	os.WriteFile(short, []byte("00ff\n"), 0o600)
+	if _, err := MasterKeyFromFile(short); !errors.Is(err, ErrInvalidKeyFile) {
+		t.Errorf("MasterKeyFromFile(short) error = %v, want ErrInvalidKeyFile", err)
+	}
+	if _, err := MasterKeyFromPassphrase(""); !errors.Is(err, ErrEmptyPassphrase) {
+		t.Errorf("MasterKeyFromPassphrase(\"\") error = %v, want ErrEmptyPassphrase", err)
+	}
+}
+
+// seedEncryptionData stores a message, a Commit adding carol, a key
+// package, group state, a session and a server secret, and returns the
+// conversation ID.
+func seedEncryptionData(t *testing.T, s *Store) string {
+	t.Helper()
+	ctx := context.Background()
+	seedUsers(t, s, "alice", "bob", "carol")
+	conv, err := s.CreateConversation(ctx, "Secret plans", "alice", []string{"bob"})
+	if err != nil {
+		t.Fatalf("CreateConversation: %v", err)
+	}
+	if _, _, err := s.InsertMessage(ctx, conv.ID, "alice", []byte("hello bob"), MsgTypeApplication, 0); err != nil {
+		t.Fatalf("InsertMessage: %v", err)
+	}
+	if _, _, err := s.CommitMembershipChange(ctx, conv.ID, "alice", []byte("commit"), []string{"carol"}, nil); err != nil {
+		t.Fatalf("CommitMembershipChange: %v", err)
+	}
+	if _, err := s.StoreKeyPackage(ctx, "bob", []byte("bob-kp"), time.Now().Add(time.Hour).Unix()); err != nil {
+		t.Fatalf("StoreKeyPackage: %v", err)
+	}
+	gs := &MLSGroupState{ConversationID: conv.ID, GroupID: []byte("g1"), Epoch: 1, GroupInfo: []byte("info"), UpdatedBy: "alice"}
+	if err := s.PutMLSGroupState(ctx, gs); err != nil {
+		t.Fatalf("PutMLSGroupState: %v", err)
+	}
+	now := time.Now().Unix()
+	if err := s.CreateSession(ctx, makeSession("sess-1", "alice", hashToken("token-1"), now+3600)); err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	if _, err := s.ServerSecret(ctx, "test", 32); err != nil {
+		t.Fatalf("ServerSecret: %v", err)
+	}
+	return conv.ID
+}
+
+// checkEncryptionData checks that the data stored by seedEncryptionData
+// reads back unchanged.
+func checkEncryptionData(t *testing.T, s *Store, convID string) {
+	t.Helper()
+	ctx := context.Background()
+	conv, err := s.GetConversation(ctx, convID)
+	if err != nil || conv.Title != "Secret plans" || conv.CreatedBy != "alice" {
+		t.Errorf("GetConversation = %+v, %v", conv, err)
+	}
+	msgs, err := s.GetPendingMessages(ctx, "bob")
+	if err != nil || len(msgs) != 2 || string(msgs[0].Payload) != "hello bob" || msgs[0].SenderID != "alice" {
+		t.Fatalf("GetPendingMessages = %+v, %v", msgs, err)
+	}
+	if added := msgs[1].AddedUserIDs; len(added) != 1 || added[0] != "carol" {
+		t.Errorf("pending commit added %v, want [carol]", added)
+	}
+	if got, err := s.GetMessageSenderID(ctx, msgs[0].ID); err != nil || got != "alice" {
+		t.Errorf("GetMessageSenderID = %q, %v; want alice", got, err)
+	}
+	gs, err := s.GetMLSGroupState(ctx, convID)
+	if err != nil || string(gs.GroupInfo) != "info" || gs.UpdatedBy != "alice" {
+		t.Errorf("GetMLSGroupState = %+v, %v", gs, err)
+	}
+	sess, err := s.GetSessionByTokenHash(ctx, hashToken("token-1"))
+	if err != nil || sess.ID != "sess-1" {
+		t.Errorf("GetSessionByTokenHash = %+v, %v", sess, err)
+	}
+	if secret, err := s.ServerSecret(ctx, "test", 32); err != nil || len(secret) != 32 {
+		t.Errorf("ServerSecret = %x, %v", secret, err)
+	}
+	members, err := s.GetMembers(ctx, convID)
+	if err != nil {
+		t.Fatalf("GetMembers: %v", err)
+	}
+	var memberIDs []string
+	for _, m := range members {
+		memberIDs = append(memberIDs, m.UserID)
+	}
+	slices.Sort(memberIDs)
+	if !slices.Equal(memberIDs, []string{"alice", "bob", "carol"}) {
+		t.Errorf("GetMembers = %v, want [alice bob carol]", memberIDs)
+	}
+	if ids, err := s.GetContactIDs(ctx, "alice"); err != nil || !slices.Equal(ids, []string{"bob", "carol"}) {
+		t.Errorf("GetContactIDs = %v, %v; want [bob carol]", ids, err)
+	}
+	if role, err := s.GetMemberRole(ctx, convID, "alice"); err != nil || role != "admin" {
+		t.Errorf("GetMemberRole = %q, %v; want admin", role, err)
+	}
+}
+
+// rawColumn returns a column of the first row of table as stored.
+func rawColumn(t *testing.T, s *Store, table, column string) []byte {
+	t.Helper()
+	var v []byte
+	if err := s.db.QueryRow(`SELECT ` + column + ` FROM ` + table + ` WHERE ` + column + ` IS NOT NULL LIMIT 1`).Scan(&v); err != nil {
+		t.Fatalf("read %s.%s: %v", table, column, err)
+	}
+	return v
+}
+
+// checkUserIDsIndexed checks that memberships and deliveries hold no user
+// IDs in the clear, and that creators and senders do.
+func checkUserIDsIndexed(t *testing.T, s *Store) {
+	t.Helper()
+	users := `('alice', 'bob', 'carol')`
+	if n := count(t, s, "group_members", "user_id IN "+users+" OR user_index IN "+users); n != 0 {
+		t.Errorf("memberships with clear user IDs = %d, want 0", n)
+	}
+	if n := count(t, s, "delivery_status", "recipient_index IN "+users); n != 0 {
+		t.Errorf("deliveries with clear user IDs = %d, want 0", n)
+	}
+	if n := count(t, s, "conversations", "created_by = 'alice'"); n != 1 {
+		t.Errorf("conversations created by alice = %d, want 1", n)
+	}
+	if n := count(t, s, "messages", "sender_id = 'alice'"); n == 0 {
+		t.Error("message senders are not stored in the clear")
+	}
+}
+
+func TestUnlockEncryptsExistingData(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "test.db")
+	s, err := New(path)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer s.Close()
+	convID := seedEncryptionData(t, s)
+	secret, _ := s.ServerSecret(ctx, "test", 32)
+
+	if info, err := s.Encryption(ctx); err != nil || info.Enabled {
+		t.Fatalf("Encryption before Unlock = %+v, %v; want disabled", info, err)
+	}
+	mk := newKeyFile(t)
+	initialized, err := s.Unlock(ctx, mk)
+	if err != nil {
+		t.Fatalf("Unlock: %v", err)
+	}
+	if !initialized {
+		t.Error("Unlock of an unencrypted database did not initialize encryption")
+	}
+	if info, err := s.Encryption(ctx); err != nil || !info.Enabled || info.Passphrase {
+		t.Errorf("Encryption after Unlock = %+v, %v; want enabled with a key file", info, err)
+	}
+
+	// Nothing sensitive is stored in the clear.
+	for _, col := range encryptedColumns {
+		raw := rawColumn(t, s, col.table, col.column)
+		if col.text && !strings.HasPrefix(string(raw), sealedTextPrefix) {
+			t.Errorf("%s.%s = %q, want sealed", col.table, col.column, raw)
+		}
+		if !col.text && raw[0] != sealedVersion {
+			t.Errorf("%s.%s = %q, want sealed", col.table, col.column, raw)
+		}
+	}
+	if bytes.Equal(rawColumn(t, s, "session", "token_hash"), hashToken("token-1")) {
+		t.Error("session token hash stored unkeyed")
+	}
+	checkUserIDsIndexed(t, s)
+	checkEncryptionData(t, s, convID)
+	if got, _ := s.ServerSecret(ctx, "test", 32); !bytes.Equal(got, secret) {
+		t.Error("server secret changed when it was encrypted")
+	}
+
+	// New data is encrypted too.
+	if _, _, err := s.InsertMessage(ctx, convID, "bob", []byte("hi alice"), MsgTypeApplication, 0); err != nil {
+		t.Fatalf("InsertMessage: %v", err)
+	}
+	var n int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM messages WHERE payload = ?`, []byte("hi alice")).Scan(&n); err != nil || n != 0 {
+		t.Errorf("plaintext payloads = %d, %v; want 0", n, err)
+	}
+	s.Close()
+
+	// The database can only be read with the same master key.
+	s, err = New(path)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer s.Close()
+	if _, err := s.Unlock(ctx, newKeyFile(t)); !errors.Is(err, ErrWrongKey) {
+		t.Errorf("Unlock with another key error = %v, want ErrWrongKey", err)
+	}
+	pass, _ := MasterKeyFromPassphrase("hunter2")
+	if _, err := s.Unlock(ctx, pass); !errors.Is(err, ErrWrongKey) {
+		t.Errorf("Unlock with a passphrase error = %v, want ErrWrongKey", err)
+	}
+	initialized, err = s.Unlock(ctx, mk)
+	if err != nil || initialized {
+		t.Fatalf("Unlock = %v, %v; want existing keys unlocked", initialized, err)
+	}
+	checkEncryptionData(t, s, convID)
+}
+
+func TestUnlockIndexesUserIDs(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "test.db")
+	s, err := New(path)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer s.Close()
+	convID := seedEncryptionData(t, s)
+	mk := newKeyFile(t)
+	if _, err := s.Unlock(ctx, mk); err != nil {
+		t.Fatalf("Unlock: %v", err)
+	}
+
+	// Put the database back as migrateV16 leaves one encrypted before it:
+	// creators and senders sealed, and memberships and deliveries holding
+	// user IDs in the clear.
+	c := s.cipher
+	byIndex := map[string]string{}
+	for _, id := range []string{"alice", "bob", "carol"} {
+		byIndex[c.userIndex(id)] = id
+	}
+	stmts := []struct {
+		query string
+		args  []any
+	}{
+		{`UPDATE conversations SET created_by = ?`, []any{c.sealText("conversations.created_by", "alice")}},
+		{`UPDATE messages SET sender_id = ?`, []any{c.sealText("messages.sender_id", "alice")}},
+		{`UPDATE encryption_key SET identities_indexed = ?`, []any{false}},
+	}
+	for index, id := range byIndex {
+		stmts = append(stmts,
+			struct {
+				query string
+				args  []any
+			}{`UPDATE group_members SET user_id = ?, user_index = ? WHERE user_index = ?`, []any{id, id, index}},
+			struct {
+				query string
+				args  []any
+			}{`UPDATE delivery_status SET recipient_index = ? WHERE recipient_index = ?`, []any{id, index}},
+		)
+	}
+	for _, stmt := range stmts {
+		if _, err := s.db.Exec(stmt.query, stmt.args...); err != nil {
+			t.Fatalf("exec %q: %v", stmt.query, err)
+		}
+	}
+	s.Close()
+
+	s, err = New(path)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer s.Close()
+	if _, err := s.Unlock(ctx, mk); err != nil {
+		t.Fatalf("Unlock: %v", err)
+	}
+	checkUserIDsIndexed(t, s)
+	checkEncryptionData(t, s, convID)
+	if raw := rawColumn(t, s, "group_members", "user_id"); !strings.HasPrefix(string(raw), sealedTextPrefix) {
+		t.Errorf("group_members.user_id = %q, want sealed", raw)
+	}
+}
+
+func TestRotateKey(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "test.db")
+	s, err := New(path)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer s.Close()
+	if err := s.RotateKey(ctx, newKeyFile(t), false); !errors.Is(err, ErrNotEncrypted) {
+		t.Errorf("RotateKey before Unlock error = %v, want ErrNotEncrypted", err)
+	}
+	oldKey := newKeyFile(t)
+	if _, err := s.Unlock(ctx, oldKey); err != nil {
+		t.Fatalf("Unlock: %v", err)
+	}
+	convID := seedEncryptionData(t, s)
+	payload := rawColumn(t, s, "messages", "payload")
+
+	// Keys are only rotated under the exclusive lock.
+	if err := s.RotateKey(ctx, newKeyFile(t), false); err == nil {
+		t.Fatal("RotateKey without the exclusive lock succeeded")
+	}
+	release, err := s.LockExclusive(ctx)
+	if err != nil {
+		t.Fatalf("LockExclusive: %v", err)
+	}
+	defer release()
+
+	// Rotating the master key alone leaves the sealed values alone.
+	midKey := newKeyFile(t)
+	if err := s.RotateKey(ctx, midKey, false); err != nil {
+		t.Fatalf("RotateKey: %v", err)
+	}
+	if !bytes.Equal(rawColumn(t, s, "messages", "payload"), payload) {
+		t.Error("payload re-encrypted by a master key rotation")
+	}
+
+	// Rotating the data key re-encrypts them, and sessions survive.
+	newKey, _ := MasterKeyFromPassphrase("correct horse battery staple")
+	if err := s.RotateKey(ctx, newKey, true); err != nil {
+		t.Fatalf("RotateKey with data key: %v", err)
+	}
+	if bytes.Equal(rawColumn(t, s, "messages", "payload"), payload) {
+		t.Error("payload not re-encrypted by a data key rotation")
+	}
+	checkEncryptionData(t, s, convID)
+	s.Close()
+
+	s, err = New(path)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer s.Close()
+	for _, mk := range []*MasterKey{oldKey, midKey} {
+		if _, err := s.Unlock(ctx, mk); !errors.Is(err, ErrWrongKey) {
+			t.Errorf("Unlock with a rotated-out key error = %v, want ErrWrongKey", err)
+		}
+	}
+	if _, err := s.Unlock(ctx, newKey); err != nil {
+		t.Fatalf("Unlock with the new key: %v", err)
+	}
+	if info, _ := s.Encryption(ctx); !info.Passphrase || info.RotatedAt < info.CreatedAt {
+		t.Errorf("Encryption = %+v, want a passphrase rotated after creation", info)
+	}
+	checkEncryptionData(t, s, convID)
+
+	// An encrypted database cannot be rolled back past encryption.
+	if _, err := s.MigrateTo(ctx, 12, false); !errors.Is(err, ErrEncrypted) {
+		t.Errorf("MigrateTo(12) error = %v, want ErrEncrypted", err)
+	}
+}
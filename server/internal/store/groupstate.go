@@ -0,0 +1,87 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// MLSGroupState is the latest MLS GroupInfo published for a conversation.
+// The GroupInfo is public group metadata; it holds no private keys.
+type MLSGroupState struct {
+	ConversationID string
+	GroupID        []byte
+	Epoch          int64
+	GroupInfo      []byte
+	UpdatedBy      string
+	UpdatedAt      int64
+}
+
+// PutMLSGroupState stores the GroupInfo for a conversation, replacing the
+// previous one. UpdatedAt is filled in. Returns ErrStale if the stored
+// GroupInfo is for a later epoch, and ErrConflict if the group ID differs
+// from the one stored for the conversation or is used by another
+// conversation.
+func (s *Store) PutMLSGroupState(ctx context.Context, gs *MLSGroupState) error {
+	gs.UpdatedAt = time.Now().Unix()
+	return s.InTx(ctx, func(tx *sql.Tx) error {
+		var groupID []byte
+		var epoch int64
+		err := tx.QueryRowContext(ctx,
+			`SELECT group_id, epoch FROM mls_group_state WHERE conversation_id = ?`,
+			gs.ConversationID,
+		).Scan(&groupID, &epoch)
+		switch {
+		case err == sql.ErrNoRows:
+		case err != nil:
+			return fmt.Errorf("get mls group state: %w", err)
+		case !bytes.Equal(groupID, gs.GroupID):
+			return fmt.Errorf("group id for conversation %s: %w", gs.ConversationID, ErrConflict)
+		case epoch > gs.Epoch:
+			return fmt.Errorf("epoch %d, stored %d: %w", gs.Epoch, epoch, ErrStale)
+		}
+
+		_, err = tx.ExecContext(ctx,
+			`INSERT INTO mls_group_state (conversation_id, group_id, epoch, group_info, updated_by, updated_at)
+			 VALUES (?, ?, ?, ?, ?, ?)
+			 ON CONFLICT (conversation_id) DO UPDATE SET
+				epoch = excluded.epoch, group_info = excluded.group_info,
+				updated_by = excluded.updated_by, updated_at = excluded.updated_at`,
+			gs.ConversationID, gs.GroupID, gs.Epoch, s.sealBytes("mls_group_state.group_info", gs.GroupInfo),
+			s.sealString("mls_group_state.updated_by", gs.UpdatedBy), gs.UpdatedAt,
+		)
+		if err != nil {
+			if isUniqueConstraintError(err) {
+				return fmt.Errorf("group id used by another conversation: %w", ErrConflict)
+			}
+			return fmt.Errorf("put mls group state: %w", err)
+		}
+		return nil
+	})
+}
+
+// GetMLSGroupState returns the GroupInfo stored for a conversation.
+// Returns ErrNotFound if none has been published.
+func (s *Store) GetMLSGroupState(ctx context.Context, conversationID string) (*MLSGroupState, error) {
+	gs := &MLSGroupState{}
+	err := s.readDB.QueryRowContext(ctx,
+		`SELECT conversation_id, group_id, epoch, group_info, updated_by, updated_at
+		 FROM mls_group_state WHERE conversation_id = ?`,
+		conversationID,
+	).Scan(&gs.ConversationID, &gs.GroupID, &gs.Epoch, &gs.GroupInfo, &gs.UpdatedBy, &gs.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("get mls group state: %w", err)
+	}
+	if gs.GroupInfo, err = s.openBytes("mls_group_state.group_info", gs.GroupInfo); err != nil {
+		return nil, err
+	}
+	if gs.UpdatedBy, err = s.openString("mls_group_state.updated_by", gs.UpdatedBy); err != nil {
+		return nil, err
+	}
+	return gs, nil
+}
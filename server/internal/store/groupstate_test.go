@@ -0,0 +1,95 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestPutMLSGroupState(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	seedUsers(t, s, "alice", "bob")
+
+	conv, err := s.CreateConversation(ctx, "Group", "alice", []string{"bob"})
+	if err != nil {
+		t.Fatalf("CreateConversation: %v", err)
+	}
+	other, err := s.CreateConversation(ctx, "Other", "alice", nil)
+	if err != nil {
+		t.Fatalf("CreateConversation: %v", err)
+	}
+
+	if _, err := s.GetMLSGroupState(ctx, conv.ID); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("GetMLSGroupState before put: err = %v, want ErrNotFound", err)
+	}
+
+	groupID := []byte("group-1")
+	if err := s.PutMLSGroupState(ctx, &MLSGroupState{
+		ConversationID: conv.ID, GroupID: groupID, Epoch: 3, GroupInfo: []byte("gi-3"), UpdatedBy: "alice",
+	}); err != nil {
+		t.Fatalf("PutMLSGroupState: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		state   MLSGroupState
+		wantErr error
+	}{
+		{
+			name:  "later epoch",
+			state: MLSGroupState{ConversationID: conv.ID, GroupID: groupID, Epoch: 5, GroupInfo: []byte("gi-5"), UpdatedBy: "bob"},
+		},
+		{
+			name:  "same epoch replaces",
+			state: MLSGroupState{ConversationID: conv.ID, GroupID: groupID, Epoch: 5, GroupInfo: []byte("gi-5b"), UpdatedBy: "alice"},
+		},
+		{
+			name:    "earlier epoch",
+			state:   MLSGroupState{ConversationID: conv.ID, GroupID: groupID, Epoch: 4, GroupInfo: []byte("gi-4"), UpdatedBy: "bob"},
+			wantErr: ErrStale,
+		},
+		{
+			name:    "different group id",
+			state:   MLSGroupState{ConversationID: conv.ID, GroupID: []byte("group-2"), Epoch: 6, GroupInfo: []byte("gi-6"), UpdatedBy: "bob"},
+			wantErr: ErrConflict,
+		},
+		{
+			name:    "group id of another conversation",
+			state:   MLSGroupState{ConversationID: other.ID, GroupID: groupID, Epoch: 0, GroupInfo: []byte("gi-0"), UpdatedBy: "alice"},
+			wantErr: ErrConflict,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := s.PutMLSGroupState(ctx, &tt.state)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("PutMLSGroupState: err = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("PutMLSGroupState: %v", err)
+			}
+			got, err := s.GetMLSGroupState(ctx, tt.state.ConversationID)
+			if err != nil {
+				t.Fatalf("GetMLSGroupState: %v", err)
+			}
+			if got.Epoch != tt.state.Epoch || !bytes.Equal(got.GroupInfo, tt.state.GroupInfo) || got.UpdatedBy != tt.state.UpdatedBy {
+				t.Errorf("GetMLSGroupState = epoch %d, info %q, by %q; want %d, %q, %q",
+					got.Epoch, got.GroupInfo, got.UpdatedBy, tt.state.Epoch, tt.state.GroupInfo, tt.state.UpdatedBy)
+			}
+		})
+	}
+
+	got, err := s.GetMLSGroupState(ctx, conv.ID)
+	if err != nil {
+		t.Fatalf("GetMLSGroupState: %v", err)
+	}
+	if string(got.GroupInfo) != "gi-5b" {
+		t.Errorf("rejected puts changed the stored GroupInfo to %q", got.GroupInfo)
+	}
+}
@@ -0,0 +1,209 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// IntegrityReport is what CheckIntegrity found wrong with a database.
+type IntegrityReport struct {
+	// Corruption lists the problems SQLite's integrity_check found in the
+	// database file. They cannot be repaired; restore from a backup.
+	Corruption []string
+
+	// ForeignKeys lists the rows that violate a foreign key, as found by
+	// SQLite's foreign_key_check, counted per table and parent table.
+	ForeignKeys []ForeignKeyViolation
+
+	// Orphans counts the rows that refer to a user, conversation, message
+	// or credential that does not exist, for each check that found any.
+	Orphans []OrphanCount
+}
+
+// ForeignKeyViolation counts the rows of Table whose reference to Parent
+// points at a missing row.
+type ForeignKeyViolation struct {
+	Table  string
+	Parent string
+	Count  int64
+}
+
+// OrphanCount is the number of rows an orphan check found or repaired.
+type OrphanCount struct {
+	Check       string // table.column
+	Description string
+	Count       int64
+}
+
+// OK reports whether the report found nothing wrong.
+func (r *IntegrityReport) OK() bool {
+	return len(r.Corruption) == 0 && len(r.ForeignKeys) == 0 && len(r.Orphans) == 0
+}
+
+// orphanCheck finds rows whose column refers to a row that does not exist.
+// Foreign keys prevent these, but a database may predate them, or have
+// been written with foreign keys off.
+type orphanCheck struct {
+	table       string
+	column      string
+	parent      string // SELECT of the referenced ids
+	description string
+	repair      string // statement that fixes the rows; empty to delete them
+	// indexed means column holds user IDs only on a database that is not
+	// encrypted, and keyed hashes of them on one that is; see userIndex.
+	// The check is skipped on an encrypted database.
+	indexed bool
+}
+
+// orphanChecks are run in order by CheckIntegrity and RepairIntegrity.
+// Rows are repaired parents first, so that deleting a message also deletes
+// its deliveries where the foreign key exists.
+var orphanChecks = []orphanCheck{
+	{table: "credential", column: "user_id", parent: `SELECT id FROM "user"`,
+		description: "credentials of deleted users"},
+	{table: "session", column: "user_id", parent: `SELECT id FROM "user"`,
+		description: "sessions of deleted users"},
+	{table: "session", column: "credential_id", parent: `SELECT id FROM credential`,
+		description: "sessions signed in with deleted credentials",
+		repair:      `UPDATE session SET credential_id = NULL`},
+	{table: "recovery_code", column: "user_id", parent: `SELECT id FROM "user"`,
+		description: "recovery codes of deleted users"},
+	{table: "group_members", column: "user_index", parent: `SELECT id FROM "user"`,
+		description: "memberships of deleted users", indexed: true},
+	{table: "group_members", column: "group_id", parent: `SELECT id FROM conversations`,
+		description: "memberships of deleted conversations"},
+	{table: "key_packages", column: "user_id", parent: `SELECT id FROM "user"`,
+		description: "key packages of deleted users"},
+	{table: "mls_group_state", column: "conversation_id", parent: `SELECT id FROM conversations`,
+		description: "group state of deleted conversations"},
+	{table: "messages", column: "group_id", parent: `SELECT id FROM conversations`,
+		description: "messages in deleted conversations"},
+	{table: "delivery_status", column: "message_id", parent: `SELECT id FROM messages`,
+		description: "deliveries of deleted messages"},
+	{table: "delivery_status", column: "recipient_index", parent: `SELECT id FROM "user"`,
+		description: "deliveries to deleted users", indexed: true},
+}
+
+// where returns the condition that selects the check's orphaned rows. A
+// NULL reference is not orphaned.
+func (c orphanCheck) where() string {
+	return c.column + ` IS NOT NULL AND ` + c.column + ` NOT IN (` + c.parent + `)`
+}
+
+// isEncrypted reports whether the database is encrypted at rest, whether
+// or not the store has been unlocked.
+func isEncrypted(ctx context.Context, q interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}) (bool, error) {
+	var n int
+	if err := q.QueryRowContext(ctx, `SELECT COUNT(*) FROM encryption_key`).Scan(&n); err != nil {
+		return false, fmt.Errorf("count encryption keys: %w", err)
+	}
+	return n > 0, nil
+}
+
+// CheckIntegrity checks the database for corruption, foreign key
+// violations and orphaned rows, without changing it. Corruption and
+// foreign key violations are only checked with SQLite; PostgreSQL enforces
+// foreign keys on every write. On an encrypted database, memberships and
+// deliveries of deleted users are not found, since they refer to users by
+// keyed hash. The schema must be at the latest version.
+func (s *Store) CheckIntegrity(ctx context.Context) (*IntegrityReport, error) {
+	report := &IntegrityReport{}
+	if s.dialect == SQLite {
+		rows, err := s.readDB.QueryContext(ctx, `PRAGMA integrity_check`)
+		if err != nil {
+			return nil, fmt.Errorf("integrity check: %w", err)
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var line string
+			if err := rows.Scan(&line); err != nil {
+				return nil, fmt.Errorf("scan integrity check: %w", err)
+			}
+			if line != "ok" {
+				report.Corruption = append(report.Corruption, line)
+			}
+		}
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("integrity check: %w", err)
+		}
+
+		rows, err = s.readDB.QueryContext(ctx,
+			`SELECT "table", parent, COUNT(*) FROM pragma_foreign_key_check GROUP BY "table", parent ORDER BY "table", parent`)
+		if err != nil {
+			return nil, fmt.Errorf("foreign key check: %w", err)
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var v ForeignKeyViolation
+			if err := rows.Scan(&v.Table, &v.Parent, &v.Count); err != nil {
+				return nil, fmt.Errorf("scan foreign key check: %w", err)
+			}
+			report.ForeignKeys = append(report.ForeignKeys, v)
+		}
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("foreign key check: %w", err)
+		}
+	}
+
+	encrypted, err := isEncrypted(ctx, s.readDB)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range orphanChecks {
+		if c.indexed && encrypted {
+			continue
+		}
+		var n int64
+		err := s.readDB.QueryRowContext(ctx, `SELECT COUNT(*) FROM `+c.table+` WHERE `+c.where()).Scan(&n)
+		if err != nil {
+			return nil, fmt.Errorf("check %s.%s: %w", c.table, c.column, err)
+		}
+		if n > 0 {
+			report.Orphans = append(report.Orphans, OrphanCount{Check: c.table + "." + c.column, Description: c.description, Count: n})
+		}
+	}
+	return report, nil
+}
+
+// RepairIntegrity deletes orphaned rows, or clears references that may be
+// NULL, in a single transaction. It returns how many rows each check
+// repaired, for the checks that repaired any. Corruption cannot be
+// repaired this way.
+func (s *Store) RepairIntegrity(ctx context.Context) ([]OrphanCount, error) {
+	var repaired []OrphanCount
+	err := s.InTx(ctx, func(tx *sql.Tx) error {
+		repaired = nil
+		encrypted, err := isEncrypted(ctx, tx)
+		if err != nil {
+			return err
+		}
+		for _, c := range orphanChecks {
+			if c.indexed && encrypted {
+				continue
+			}
+			stmt := c.repair
+			if stmt == "" {
+				stmt = `DELETE FROM ` + c.table
+			}
+			result, err := tx.ExecContext(ctx, stmt+` WHERE `+c.where())
+			if err != nil {
+				return fmt.Errorf("repair %s.%s: %w", c.table, c.column, err)
+			}
+			n, err := result.RowsAffected()
+			if err != nil {
+				return fmt.Errorf("rows affected: %w", err)
+			}
+			if n > 0 {
+				repaired = append(repaired, OrphanCount{Check: c.table + "." + c.column, Description: c.description, Count: n})
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return repaired, nil
+}
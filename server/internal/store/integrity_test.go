@@ -0,0 +1,170 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// count returns the number of rows in table that match where.
+func count(t *testing.T, s *Store, table, where string, args ...any) int {
+	t.Helper()
+	var n int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM `+table+` WHERE `+where, args...).Scan(&n); err != nil {
+		t.Fatalf("count %s: %v", table, err)
+	}
+	return n
+}
+
+func TestDeleteCascades(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	seedUsers(t, s, "alice", "bob")
+
+	conv, err := s.CreateConversation(ctx, "Group", "alice", []string{"bob"})
+	if err != nil {
+		t.Fatalf("CreateConversation: %v", err)
+	}
+	if _, _, err := s.InsertMessage(ctx, conv.ID, "alice", []byte("hello"), MsgTypeApplication, 0); err != nil {
+		t.Fatalf("InsertMessage: %v", err)
+	}
+	if _, err := s.StoreKeyPackage(ctx, "bob", []byte("kp"), time.Now().Add(time.Hour).Unix()); err != nil {
+		t.Fatalf("StoreKeyPackage: %v", err)
+	}
+
+	// Deleting a user deletes their key packages, but not the messages
+	// they were sent.
+	if _, err := s.db.Exec(`DELETE FROM user WHERE id = 'bob'`); err != nil {
+		t.Fatalf("delete user: %v", err)
+	}
+	if n := count(t, s, "key_packages", "user_id = 'bob'"); n != 0 {
+		t.Errorf("key packages of deleted user = %d, want 0", n)
+	}
+	if n := count(t, s, "messages", "group_id = ?", conv.ID); n != 1 {
+		t.Errorf("messages after deleting recipient = %d, want 1", n)
+	}
+
+	// Memberships and deliveries refer to users by userIndex, which has no
+	// foreign key, so their rows are left for RepairIntegrity.
+	if n := count(t, s, "group_members", "user_index = 'bob'"); n != 1 {
+		t.Errorf("memberships of deleted user = %d, want 1", n)
+	}
+	if _, err := s.RepairIntegrity(ctx); err != nil {
+		t.Fatalf("RepairIntegrity: %v", err)
+	}
+	if n := count(t, s, "group_members", "user_index = 'bob'"); n != 0 {
+		t.Errorf("memberships of deleted user after repair = %d, want 0", n)
+	}
+	if n := count(t, s, "delivery_status", "recipient_index = 'bob'"); n != 0 {
+		t.Errorf("deliveries to deleted user after repair = %d, want 0", n)
+	}
+
+	// Deleting a conversation deletes its members and messages.
+	if _, err := s.db.Exec(`DELETE FROM conversations WHERE id = ?`, conv.ID); err != nil {
+		t.Fatalf("delete conversation: %v", err)
+	}
+	if n := count(t, s, "group_members", "group_id = ?", conv.ID); n != 0 {
+		t.Errorf("members of deleted conversation = %d, want 0", n)
+	}
+	if n := count(t, s, "messages", "group_id = ?", conv.ID); n != 0 {
+		t.Errorf("messages of deleted conversation = %d, want 0", n)
+	}
+}
+
+func TestCheckIntegrity(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	seedUsers(t, s, "alice", "bob")
+
+	conv, err := s.CreateConversation(ctx, "Group", "alice", []string{"bob"})
+	if err != nil {
+		t.Fatalf("CreateConversation: %v", err)
+	}
+	msgID, _, err := s.InsertMessage(ctx, conv.ID, "alice", []byte("hello"), MsgTypeApplication, 0)
+	if err != nil {
+		t.Fatalf("InsertMessage: %v", err)
+	}
+
+	report, err := s.CheckIntegrity(ctx)
+	if err != nil {
+		t.Fatalf("CheckIntegrity: %v", err)
+	}
+	if !report.OK() {
+		t.Fatalf("CheckIntegrity on a consistent database = %+v", report)
+	}
+
+	// Orphans are written with foreign keys off, as by an older server or
+	// a manual edit.
+	stmts := []string{
+		`PRAGMA foreign_keys = OFF`,
+		`INSERT INTO group_members (group_id, user_id, user_index, role, joined_at) VALUES ('` + conv.ID + `', 'ghost', 'ghost', 'member', 0)`,
+		`INSERT INTO delivery_status (message_id, recipient_index, status) VALUES ('` + msgID + `', 'ghost', 0)`,
+		`INSERT INTO messages (id, group_id, sender_id, server_timestamp, payload, payload_size, created_at)
+		 VALUES ('orphan-msg', 'no-such-conv', 'alice', 0, x'00', 1, 0)`,
+		`INSERT INTO delivery_status (message_id, recipient_index, status) VALUES ('orphan-msg', 'bob', 0)`,
+		`INSERT INTO session (id, user_id, credential_id, token_hash, created_at, expires_at, last_seen_at)
+		 VALUES ('sess-1', 'alice', 'no-such-cred', x'01', 0, 0, 0)`,
+		`PRAGMA foreign_keys = ON`,
+	}
+	for _, stmt := range stmts {
+		if _, err := s.db.Exec(stmt); err != nil {
+			t.Fatalf("exec %q: %v", stmt, err)
+		}
+	}
+
+	report, err = s.CheckIntegrity(ctx)
+	if err != nil {
+		t.Fatalf("CheckIntegrity: %v", err)
+	}
+	if len(report.Corruption) != 0 {
+		t.Errorf("Corruption = %v, want none", report.Corruption)
+	}
+	if len(report.ForeignKeys) == 0 {
+		t.Error("ForeignKeys is empty, want violations")
+	}
+	want := map[string]int64{
+		"session.credential_id":           1,
+		"group_members.user_index":        1,
+		"messages.group_id":               1,
+		"delivery_status.recipient_index": 1,
+	}
+	got := map[string]int64{}
+	for _, o := range report.Orphans {
+		got[o.Check] = o.Count
+	}
+	if len(got) != len(want) {
+		t.Errorf("Orphans = %+v, want %v", report.Orphans, want)
+	}
+	for check, n := range want {
+		if got[check] != n {
+			t.Errorf("orphans of %s = %d, want %d", check, got[check], n)
+		}
+	}
+
+	repaired, err := s.RepairIntegrity(ctx)
+	if err != nil {
+		t.Fatalf("RepairIntegrity: %v", err)
+	}
+	if len(repaired) != len(want) {
+		t.Errorf("RepairIntegrity = %+v, want %d checks repaired", repaired, len(want))
+	}
+	report, err = s.CheckIntegrity(ctx)
+	if err != nil {
+		t.Fatalf("CheckIntegrity after repair: %v", err)
+	}
+	if !report.OK() {
+		t.Errorf("CheckIntegrity after repair = %+v", report)
+	}
+
+	// The session survives without its credential, and the orphaned
+	// message took its deliveries with it.
+	if n := count(t, s, "session", "id = 'sess-1' AND credential_id IS NULL"); n != 1 {
+		t.Errorf("repaired session count = %d, want 1", n)
+	}
+	if n := count(t, s, "delivery_status", "message_id = 'orphan-msg'"); n != 0 {
+		t.Errorf("deliveries of orphaned message = %d, want 0", n)
+	}
+	if n := count(t, s, "delivery_status", "message_id = ?", msgID); n != 1 {
+		t.Errorf("deliveries of valid message = %d, want 1", n)
+	}
+}
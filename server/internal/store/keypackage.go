@@ -3,6 +3,7 @@ package store
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"time"
 )
@@ -14,6 +15,16 @@ type KeyPackage struct {
 	KeyPackageData []byte
 	CreatedAt      int64
 	ExpiresAt      int64
+	LastResort     bool   // returned when no other key package is left, never consumed
+	CipherSuite    uint16 // MLS cipher suite; 0 if unknown
+	CredentialType uint16 // MLS credential type; 0 if unknown
+}
+
+// KeyPackageSuiteCount is a user's key package inventory for one cipher suite.
+type KeyPackageSuiteCount struct {
+	CipherSuite   uint16
+	Available     int  // unexpired key packages, not counting the last-resort one
+	HasLastResort bool // whether an unexpired last-resort key package is stored
 }
 
 // StoreKeyPackage saves a key package for a user.
@@ -23,7 +34,7 @@ func (s *Store) StoreKeyPackage(ctx context.Context, userID string, data []byte,
 	_, err := s.db.ExecContext(ctx,
 		`INSERT INTO key_packages (id, user_id, key_package_data, created_at, expires_at)
 		 VALUES (?, ?, ?, ?, ?)`,
-		id, userID, data, now, expiresAt,
+		id, userID, s.sealBytes("key_packages.key_package_data", data), now, expiresAt,
 	)
 	if err != nil {
 		return "", fmt.Errorf("store key package: %w", err)
@@ -31,49 +42,149 @@ func (s *Store) StoreKeyPackage(ctx context.Context, userID string, data []byte,
 	return id, nil
 }
 
-// ConsumeKeyPackage fetches one key package for a user and deletes it (single-use).
-// Returns ErrNotFound if no key packages are available.
-func (s *Store) ConsumeKeyPackage(ctx context.Context, userID string) (*KeyPackage, error) {
-	var kp KeyPackage
+// StoreLastResortKeyPackage saves the user's last-resort key package,
+// replacing any previous one with the same (unknown) cipher suite.
+func (s *Store) StoreLastResortKeyPackage(ctx context.Context, userID string, data []byte, expiresAt int64) (string, error) {
+	kps := []*KeyPackage{{KeyPackageData: data, ExpiresAt: expiresAt, LastResort: true}}
+	if err := s.StoreKeyPackages(ctx, userID, kps); err != nil {
+		return "", err
+	}
+	return kps[0].ID, nil
+}
+
+// StoreKeyPackages saves several key packages for a user in one
+// transaction. ID, UserID and CreatedAt are filled in. A last-resort key
+// package replaces the user's previous one for the same cipher suite.
+func (s *Store) StoreKeyPackages(ctx context.Context, userID string, kps []*KeyPackage) error {
 	now := time.Now().Unix()
+	err := s.InTx(ctx, func(tx *sql.Tx) error {
+		for _, kp := range kps {
+			if kp.LastResort {
+				if _, err := tx.ExecContext(ctx,
+					`DELETE FROM key_packages WHERE user_id = ? AND cipher_suite = ? AND last_resort`,
+					userID, kp.CipherSuite,
+				); err != nil {
+					return fmt.Errorf("delete previous last-resort key package: %w", err)
+				}
+			}
+			kp.ID = NewULID()
+			kp.UserID = userID
+			kp.CreatedAt = now
+			if _, err := tx.ExecContext(ctx,
+				`INSERT INTO key_packages (id, user_id, key_package_data, created_at, expires_at, last_resort,
+				                           cipher_suite, credential_type)
+				 VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+				kp.ID, userID, s.sealBytes("key_packages.key_package_data", kp.KeyPackageData), now, kp.ExpiresAt, kp.LastResort,
+				kp.CipherSuite, kp.CredentialType,
+			); err != nil {
+				return fmt.Errorf("insert key package: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("store key packages: %w", err)
+	}
+	return nil
+}
 
+// ConsumeKeyPackage fetches one key package for a user and deletes it (single-use).
+// If cipherSuite is non-zero, only key packages for that suite are considered.
+// When only the user's last-resort key package is left, it is returned and
+// kept. Returns ErrNotFound if no key packages are available.
+func (s *Store) ConsumeKeyPackage(ctx context.Context, userID string, cipherSuite uint16) (*KeyPackage, error) {
+	var kp *KeyPackage
 	err := s.InTx(ctx, func(tx *sql.Tx) error {
+		var err error
+		kp, err = s.consumeKeyPackage(ctx, tx, userID, cipherSuite, time.Now().Unix())
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return kp, nil
+}
+
+// ConsumeKeyPackages consumes one key package for each user, as
+// ConsumeKeyPackage does, in a single transaction. Users with no key
+// package available are absent from the result.
+func (s *Store) ConsumeKeyPackages(ctx context.Context, userIDs []string, cipherSuite uint16) (map[string]*KeyPackage, error) {
+	kps := make(map[string]*KeyPackage, len(userIDs))
+	now := time.Now().Unix()
+	err := s.InTx(ctx, func(tx *sql.Tx) error {
+		for _, userID := range userIDs {
+			if _, ok := kps[userID]; ok {
+				continue
+			}
+			kp, err := s.consumeKeyPackage(ctx, tx, userID, cipherSuite, now)
+			if errors.Is(err, ErrNotFound) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			kps[userID] = kp
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return kps, nil
+}
+
+// consumeKeyPackage selects the user's oldest unexpired key package for
+// cipherSuite (any suite if zero), preferring regular ones over a
+// last-resort one, and deletes it unless it is a last-resort one. If a
+// concurrent transaction deletes the selected key package first, the next
+// one is selected.
+func (s *Store) consumeKeyPackage(ctx context.Context, tx *sql.Tx, userID string, cipherSuite uint16, now int64) (*KeyPackage, error) {
+	for {
+		var kp KeyPackage
 		err := tx.QueryRowContext(ctx,
-			`SELECT id, user_id, key_package_data, created_at, expires_at
+			`SELECT id, user_id, key_package_data, created_at, expires_at, last_resort, cipher_suite, credential_type
 			 FROM key_packages
-			 WHERE user_id = ? AND expires_at > ?
-			 ORDER BY created_at ASC LIMIT 1`,
-			userID, now,
-		).Scan(&kp.ID, &kp.UserID, &kp.KeyPackageData, &kp.CreatedAt, &kp.ExpiresAt)
+			 WHERE user_id = ? AND expires_at > ? AND (? = 0 OR cipher_suite = ?)
+			 ORDER BY last_resort ASC, created_at ASC LIMIT 1`,
+			userID, now, cipherSuite, cipherSuite,
+		).Scan(&kp.ID, &kp.UserID, &kp.KeyPackageData, &kp.CreatedAt, &kp.ExpiresAt, &kp.LastResort,
+			&kp.CipherSuite, &kp.CredentialType)
 		if err != nil {
 			if err == sql.ErrNoRows {
-				return ErrNotFound
+				return nil, ErrNotFound
 			}
-			return fmt.Errorf("select key package: %w", err)
+			return nil, fmt.Errorf("select key package: %w", err)
+		}
+		if kp.KeyPackageData, err = s.openBytes("key_packages.key_package_data", kp.KeyPackageData); err != nil {
+			return nil, err
+		}
+		if kp.LastResort {
+			return &kp, nil
 		}
 
-		_, err = tx.ExecContext(ctx,
+		result, err := tx.ExecContext(ctx,
 			`DELETE FROM key_packages WHERE id = ?`, kp.ID,
 		)
 		if err != nil {
-			return fmt.Errorf("delete consumed key package: %w", err)
+			return nil, fmt.Errorf("delete consumed key package: %w", err)
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return nil, fmt.Errorf("rows affected: %w", err)
+		}
+		if n == 1 {
+			return &kp, nil
 		}
-
-		return nil
-	})
-	if err != nil {
-		return nil, err
 	}
-
-	return &kp, nil
 }
 
 // CountKeyPackages returns the number of available (non-expired) key packages for a user.
+// The last-resort key package is not counted.
 func (s *Store) CountKeyPackages(ctx context.Context, userID string) (int, error) {
 	var count int
 	now := time.Now().Unix()
-	err := s.db.QueryRowContext(ctx,
-		`SELECT COUNT(*) FROM key_packages WHERE user_id = ? AND expires_at > ?`,
+	err := s.readDB.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM key_packages WHERE user_id = ? AND expires_at > ? AND NOT last_resort`,
 		userID, now,
 	).Scan(&count)
 	if err != nil {
@@ -82,6 +193,35 @@ func (s *Store) CountKeyPackages(ctx context.Context, userID string) (int, error
 	return count, nil
 }
 
+// KeyPackageInventory returns the user's unexpired key packages counted per
+// cipher suite, ordered by suite. Key packages stored before cipher suites
+// were recorded are counted under suite 0.
+func (s *Store) KeyPackageInventory(ctx context.Context, userID string) ([]KeyPackageSuiteCount, error) {
+	rows, err := s.readDB.QueryContext(ctx,
+		`SELECT cipher_suite, SUM(CASE WHEN last_resort THEN 0 ELSE 1 END),
+		        MAX(CASE WHEN last_resort THEN 1 ELSE 0 END)
+		 FROM key_packages
+		 WHERE user_id = ? AND expires_at > ?
+		 GROUP BY cipher_suite
+		 ORDER BY cipher_suite`,
+		userID, time.Now().Unix(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("query key package inventory: %w", err)
+	}
+	defer rows.Close()
+
+	var counts []KeyPackageSuiteCount
+	for rows.Next() {
+		var c KeyPackageSuiteCount
+		if err := rows.Scan(&c.CipherSuite, &c.Available, &c.HasLastResort); err != nil {
+			return nil, fmt.Errorf("scan key package inventory: %w", err)
+		}
+		counts = append(counts, c)
+	}
+	return counts, rows.Err()
+}
+
 // DeleteExpiredKeyPackages removes key packages that have passed their expiry.
 // Returns the number of deleted key packages.
 func (s *Store) DeleteExpiredKeyPackages(ctx context.Context) (int64, error) {
@@ -9,6 +9,7 @@ import (
 
 func TestStoreKeyPackage(t *testing.T) {
 	s := newTestStore(t)
+	seedUsers(t, s, "alice", "bob", "charlie")
 	ctx := context.Background()
 
 	tests := []struct {
@@ -46,6 +47,7 @@ func TestStoreKeyPackage(t *testing.T) {
 
 func TestConsumeKeyPackage(t *testing.T) {
 	s := newTestStore(t)
+	seedUsers(t, s, "alice", "bob", "charlie")
 	ctx := context.Background()
 
 	t.Run("consumes and deletes a key package", func(t *testing.T) {
@@ -55,7 +57,7 @@ func TestConsumeKeyPackage(t *testing.T) {
 			t.Fatalf("StoreKeyPackage: %v", err)
 		}
 
-		kp, err := s.ConsumeKeyPackage(ctx, "alice")
+		kp, err := s.ConsumeKeyPackage(ctx, "alice", 0)
 		if err != nil {
 			t.Fatalf("ConsumeKeyPackage: %v", err)
 		}
@@ -67,14 +69,14 @@ func TestConsumeKeyPackage(t *testing.T) {
 		}
 
 		// Second consume should fail — single-use.
-		_, err = s.ConsumeKeyPackage(ctx, "alice")
+		_, err = s.ConsumeKeyPackage(ctx, "alice", 0)
 		if !errors.Is(err, ErrNotFound) {
 			t.Errorf("second consume: error = %v, want ErrNotFound", err)
 		}
 	})
 
 	t.Run("no key package available returns ErrNotFound", func(t *testing.T) {
-		_, err := s.ConsumeKeyPackage(ctx, "nonexistent")
+		_, err := s.ConsumeKeyPackage(ctx, "nonexistent", 0)
 		if !errors.Is(err, ErrNotFound) {
 			t.Errorf("error = %v, want ErrNotFound", err)
 		}
@@ -88,7 +90,7 @@ func TestConsumeKeyPackage(t *testing.T) {
 			t.Fatalf("StoreKeyPackage: %v", err)
 		}
 
-		_, err = s.ConsumeKeyPackage(ctx, "bob")
+		_, err = s.ConsumeKeyPackage(ctx, "bob", 0)
 		if !errors.Is(err, ErrNotFound) {
 			t.Errorf("expired consume: error = %v, want ErrNotFound", err)
 		}
@@ -105,7 +107,7 @@ func TestConsumeKeyPackage(t *testing.T) {
 			t.Fatalf("StoreKeyPackage 2: %v", err)
 		}
 
-		kp, err := s.ConsumeKeyPackage(ctx, "charlie")
+		kp, err := s.ConsumeKeyPackage(ctx, "charlie", 0)
 		if err != nil {
 			t.Fatalf("ConsumeKeyPackage: %v", err)
 		}
@@ -115,8 +117,183 @@ func TestConsumeKeyPackage(t *testing.T) {
 	})
 }
 
+func TestLastResortKeyPackage(t *testing.T) {
+	s := newTestStore(t)
+	seedUsers(t, s, "alice", "bob", "charlie")
+	ctx := context.Background()
+	expiresAt := time.Now().Add(24 * time.Hour).Unix()
+
+	if _, err := s.StoreLastResortKeyPackage(ctx, "alice", []byte("kp-old-last-resort"), expiresAt); err != nil {
+		t.Fatalf("StoreLastResortKeyPackage: %v", err)
+	}
+	if _, err := s.StoreLastResortKeyPackage(ctx, "alice", []byte("kp-last-resort"), expiresAt); err != nil {
+		t.Fatalf("StoreLastResortKeyPackage (replace): %v", err)
+	}
+	if _, err := s.StoreKeyPackage(ctx, "alice", []byte("kp-regular"), expiresAt); err != nil {
+		t.Fatalf("StoreKeyPackage: %v", err)
+	}
+
+	count, err := s.CountKeyPackages(ctx, "alice")
+	if err != nil {
+		t.Fatalf("CountKeyPackages: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("count = %d, want 1 (last resort not counted)", count)
+	}
+
+	// Regular key packages are consumed first; the last-resort one is then
+	// returned on every fetch without being consumed.
+	want := []struct {
+		data       string
+		lastResort bool
+	}{
+		{"kp-regular", false},
+		{"kp-last-resort", true},
+		{"kp-last-resort", true},
+	}
+	for i, w := range want {
+		kp, err := s.ConsumeKeyPackage(ctx, "alice", 0)
+		if err != nil {
+			t.Fatalf("consume %d: %v", i, err)
+		}
+		if string(kp.KeyPackageData) != w.data || kp.LastResort != w.lastResort {
+			t.Errorf("consume %d = %q (last resort %v), want %q (last resort %v)",
+				i, kp.KeyPackageData, kp.LastResort, w.data, w.lastResort)
+		}
+	}
+}
+
+func TestStoreKeyPackages(t *testing.T) {
+	s := newTestStore(t)
+	seedUsers(t, s, "alice", "bob", "charlie")
+	ctx := context.Background()
+	expiresAt := time.Now().Add(24 * time.Hour).Unix()
+
+	kps := []*KeyPackage{
+		{KeyPackageData: []byte("kp-1"), ExpiresAt: expiresAt},
+		{KeyPackageData: []byte("kp-2"), ExpiresAt: expiresAt},
+		{KeyPackageData: []byte("kp-last-resort"), ExpiresAt: expiresAt, LastResort: true},
+	}
+	if err := s.StoreKeyPackages(ctx, "alice", kps); err != nil {
+		t.Fatalf("StoreKeyPackages: %v", err)
+	}
+	for i, kp := range kps {
+		if kp.ID == "" || kp.UserID != "alice" || kp.CreatedAt == 0 {
+			t.Errorf("kps[%d] = %+v, want ID, UserID and CreatedAt set", i, kp)
+		}
+	}
+
+	count, err := s.CountKeyPackages(ctx, "alice")
+	if err != nil {
+		t.Fatalf("CountKeyPackages: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("count = %d, want 2", count)
+	}
+}
+
+func TestConsumeKeyPackages(t *testing.T) {
+	s := newTestStore(t)
+	seedUsers(t, s, "alice", "bob", "charlie")
+	ctx := context.Background()
+	expiresAt := time.Now().Add(24 * time.Hour).Unix()
+
+	for _, kp := range []struct{ userID, data string }{
+		{"alice", "alice-kp-1"},
+		{"alice", "alice-kp-2"},
+		{"bob", "bob-kp"},
+	} {
+		if _, err := s.StoreKeyPackage(ctx, kp.userID, []byte(kp.data), expiresAt); err != nil {
+			t.Fatalf("StoreKeyPackage: %v", err)
+		}
+	}
+
+	// alice is requested twice but only consumed once; carol has none.
+	got, err := s.ConsumeKeyPackages(ctx, []string{"alice", "bob", "carol", "alice"}, 0)
+	if err != nil {
+		t.Fatalf("ConsumeKeyPackages: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("got %d results, want 2", len(got))
+	}
+	if string(got["alice"].KeyPackageData) != "alice-kp-1" {
+		t.Errorf("alice = %q, want alice-kp-1", got["alice"].KeyPackageData)
+	}
+	if string(got["bob"].KeyPackageData) != "bob-kp" {
+		t.Errorf("bob = %q, want bob-kp", got["bob"].KeyPackageData)
+	}
+	if _, ok := got["carol"]; ok {
+		t.Error("carol has a result, want none")
+	}
+
+	for user, want := range map[string]int{"alice": 1, "bob": 0} {
+		count, err := s.CountKeyPackages(ctx, user)
+		if err != nil {
+			t.Fatalf("CountKeyPackages: %v", err)
+		}
+		if count != want {
+			t.Errorf("%s count = %d, want %d", user, count, want)
+		}
+	}
+}
+
+func TestKeyPackageCipherSuites(t *testing.T) {
+	s := newTestStore(t)
+	seedUsers(t, s, "alice", "bob", "charlie")
+	ctx := context.Background()
+	expiresAt := time.Now().Add(24 * time.Hour).Unix()
+
+	kps := []*KeyPackage{
+		{KeyPackageData: []byte("kp-suite-1"), ExpiresAt: expiresAt, CipherSuite: 1, CredentialType: 1},
+		{KeyPackageData: []byte("kp-suite-2"), ExpiresAt: expiresAt, CipherSuite: 2, CredentialType: 1},
+		{KeyPackageData: []byte("kp-suite-2-last-resort"), ExpiresAt: expiresAt, CipherSuite: 2, CredentialType: 1, LastResort: true},
+		{KeyPackageData: []byte("kp-suite-1-last-resort"), ExpiresAt: expiresAt, CipherSuite: 1, CredentialType: 1, LastResort: true},
+	}
+	if err := s.StoreKeyPackages(ctx, "alice", kps); err != nil {
+		t.Fatalf("StoreKeyPackages: %v", err)
+	}
+	if _, err := s.StoreKeyPackage(ctx, "alice", []byte("kp-legacy"), expiresAt); err != nil {
+		t.Fatalf("StoreKeyPackage: %v", err)
+	}
+
+	inventory, err := s.KeyPackageInventory(ctx, "alice")
+	if err != nil {
+		t.Fatalf("KeyPackageInventory: %v", err)
+	}
+	want := []KeyPackageSuiteCount{
+		{CipherSuite: 0, Available: 1},
+		{CipherSuite: 1, Available: 1, HasLastResort: true},
+		{CipherSuite: 2, Available: 1, HasLastResort: true},
+	}
+	if len(inventory) != len(want) {
+		t.Fatalf("inventory = %+v, want %+v", inventory, want)
+	}
+	for i := range want {
+		if inventory[i] != want[i] {
+			t.Errorf("inventory[%d] = %+v, want %+v", i, inventory[i], want[i])
+		}
+	}
+
+	// Suite 2 fetches take the regular package, then the suite's last-resort
+	// package; they never see suite 1 or legacy packages.
+	for _, wantData := range []string{"kp-suite-2", "kp-suite-2-last-resort"} {
+		kp, err := s.ConsumeKeyPackage(ctx, "alice", 2)
+		if err != nil {
+			t.Fatalf("ConsumeKeyPackage: %v", err)
+		}
+		if string(kp.KeyPackageData) != wantData || kp.CipherSuite != 2 || kp.CredentialType != 1 {
+			t.Errorf("consumed %q (suite %d, credential %d), want %q (suite 2, credential 1)",
+				kp.KeyPackageData, kp.CipherSuite, kp.CredentialType, wantData)
+		}
+	}
+	if _, err := s.ConsumeKeyPackage(ctx, "alice", 3); !errors.Is(err, ErrNotFound) {
+		t.Errorf("suite 3: error = %v, want ErrNotFound", err)
+	}
+}
+
 func TestCountKeyPackages(t *testing.T) {
 	s := newTestStore(t)
+	seedUsers(t, s, "alice", "bob", "charlie")
 	ctx := context.Background()
 	expiresAt := time.Now().Add(24 * time.Hour).Unix()
 
@@ -153,6 +330,7 @@ func TestCountKeyPackages(t *testing.T) {
 
 func TestDeleteExpiredKeyPackages(t *testing.T) {
 	s := newTestStore(t)
+	seedUsers(t, s, "alice", "bob", "charlie")
 	ctx := context.Background()
 
 	// Store 2 expired and 1 valid key packages.
@@ -0,0 +1,121 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// ErrInUse is returned when the database lock cannot be taken because
+// another process holds it in a conflicting mode.
+var ErrInUse = errors.New("database in use by another process")
+
+// advisoryLockClass is the first key of the PostgreSQL advisory lock; the
+// second is a hash of the schema, so that deployments sharing a database
+// in separate schemas do not contend.
+const advisoryLockClass = 0x536f76 // "Sov"
+
+// LockShared takes the database lock in shared mode, for as long as a
+// server has the database open. Any number of servers can hold it at
+// once. It returns ErrInUse while a maintenance command holds it with
+// LockExclusive. The returned function releases it; so does exiting.
+func (s *Store) LockShared(ctx context.Context) (release func() error, err error) {
+	return s.lock(ctx, false)
+}
+
+// LockExclusive takes the database lock in exclusive mode, for
+// maintenance that must not run alongside a server, such as RotateKey. It
+// returns ErrInUse while a server or another command holds the lock.
+func (s *Store) LockExclusive(ctx context.Context) (release func() error, err error) {
+	unlock, err := s.lock(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+	s.exclusive.Store(true)
+	return func() error {
+		s.exclusive.Store(false)
+		return unlock()
+	}, nil
+}
+
+// LockFileExclusive takes the lock of the SQLite database at dbPath in
+// exclusive mode without opening the database, for commands that replace
+// the file, such as restoring a backup. Like LockExclusive, it returns
+// ErrInUse while a server or another command holds the lock.
+func LockFileExclusive(dbPath string) (release func() error, err error) {
+	return lockFile(dbPath, true)
+}
+
+// lock takes an advisory lock on PostgreSQL, and a flock(2) on a file next
+// to the database on SQLite. Neither blocks.
+func (s *Store) lock(ctx context.Context, exclusive bool) (func() error, error) {
+	if s.dialect == Postgres {
+		return s.lockPostgres(ctx, exclusive)
+	}
+	if isMemoryPath(s.path) {
+		// An in-memory database is private to this process.
+		return func() error { return nil }, nil
+	}
+	return lockFile(s.path, exclusive)
+}
+
+// lockFile takes a flock(2) on <dbPath>.lock.
+func lockFile(dbPath string, exclusive bool) (func() error, error) {
+	f, err := os.OpenFile(dbPath+".lock", os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("open lock file: %w", err)
+	}
+	how := syscall.LOCK_SH
+	if exclusive {
+		how = syscall.LOCK_EX
+	}
+	if err := syscall.Flock(int(f.Fd()), how|syscall.LOCK_NB); err != nil {
+		f.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil, ErrInUse
+		}
+		return nil, fmt.Errorf("lock database: %w", err)
+	}
+	return f.Close, nil
+}
+
+// lockPostgres takes a session-level advisory lock, which is held by one
+// connection taken out of the pool until the lock is released. The pool
+// grows by one meanwhile, so the lock does not cost a query connection.
+func (s *Store) lockPostgres(ctx context.Context, exclusive bool) (func() error, error) {
+	lockFn, unlockFn := "pg_try_advisory_lock_shared", "pg_advisory_unlock_shared"
+	if exclusive {
+		lockFn, unlockFn = "pg_try_advisory_lock", "pg_advisory_unlock"
+	}
+	maxConns := s.db.Stats().MaxOpenConnections
+	s.db.SetMaxOpenConns(maxConns + 1)
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		s.db.SetMaxOpenConns(maxConns)
+		return nil, fmt.Errorf("lock database: %w", err)
+	}
+	fail := func(err error) (func() error, error) {
+		conn.Close()
+		s.db.SetMaxOpenConns(maxConns)
+		return nil, err
+	}
+
+	var locked bool
+	if err := conn.QueryRowContext(ctx, `SELECT `+lockFn+`(?, hashtext(current_schema()))`, advisoryLockClass).Scan(&locked); err != nil {
+		return fail(fmt.Errorf("lock database: %w", err))
+	}
+	if !locked {
+		return fail(ErrInUse)
+	}
+	return func() error {
+		_, err := conn.ExecContext(context.Background(), `SELECT `+unlockFn+`(?, hashtext(current_schema()))`, advisoryLockClass)
+		closeErr := conn.Close()
+		s.db.SetMaxOpenConns(maxConns)
+		if err != nil {
+			return fmt.Errorf("unlock database: %w", err)
+		}
+		return closeErr
+	}, nil
+}
@@ -0,0 +1,73 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestLock(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "test.db")
+	open := func() *Store {
+		s, err := New(path)
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+		t.Cleanup(func() { s.Close() })
+		return s
+	}
+	server1, server2, cli := open(), open(), open()
+
+	// Servers share the lock, and keep maintenance out.
+	release1, err := server1.LockShared(ctx)
+	if err != nil {
+		t.Fatalf("LockShared: %v", err)
+	}
+	release2, err := server2.LockShared(ctx)
+	if err != nil {
+		t.Fatalf("second LockShared: %v", err)
+	}
+	if _, err := cli.LockExclusive(ctx); !errors.Is(err, ErrInUse) {
+		t.Fatalf("LockExclusive while shared error = %v, want ErrInUse", err)
+	}
+	release1()
+	if _, err := cli.LockExclusive(ctx); !errors.Is(err, ErrInUse) {
+		t.Fatalf("LockExclusive while one server holds it error = %v, want ErrInUse", err)
+	}
+	release2()
+
+	// Maintenance keeps servers out until it is done.
+	release, err := cli.LockExclusive(ctx)
+	if err != nil {
+		t.Fatalf("LockExclusive: %v", err)
+	}
+	if _, err := server1.LockShared(ctx); !errors.Is(err, ErrInUse) {
+		t.Fatalf("LockShared while exclusive error = %v, want ErrInUse", err)
+	}
+	if err := release(); err != nil {
+		t.Fatalf("release: %v", err)
+	}
+	if cli.exclusive.Load() {
+		t.Error("store still marked exclusive after release")
+	}
+	release, err = server1.LockShared(ctx)
+	if err != nil {
+		t.Fatalf("LockShared after release: %v", err)
+	}
+
+	// A restore locks the file without opening it.
+	if _, err := LockFileExclusive(path); !errors.Is(err, ErrInUse) {
+		t.Fatalf("LockFileExclusive while shared error = %v, want ErrInUse", err)
+	}
+	release()
+	releaseFile, err := LockFileExclusive(path)
+	if err != nil {
+		t.Fatalf("LockFileExclusive: %v", err)
+	}
+	if _, err := server1.LockShared(ctx); !errors.Is(err, ErrInUse) {
+		t.Fatalf("LockShared during restore error = %v, want ErrInUse", err)
+	}
+	releaseFile()
+}
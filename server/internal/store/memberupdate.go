@@ -0,0 +1,141 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// MemberUpdate records when a group member last rotated their MLS leaf key
+// by sending a Commit or Proposal.
+type MemberUpdate struct {
+	GroupID           string
+	UserID            string
+	JoinedAt          int64
+	LastUpdateAt      *int64 // nil if the member has not updated since joining
+	LastUpdateEpoch   *int64 // nil if the epoch is unknown
+	UpdateRequestedAt *int64 // nil unless asked to update since the last update
+}
+
+// UpdatedAt returns when the member last updated, or when they joined if
+// they have not.
+func (m *MemberUpdate) UpdatedAt() int64 {
+	if m.LastUpdateAt != nil {
+		return *m.LastUpdateAt
+	}
+	return m.JoinedAt
+}
+
+// RecordMemberUpdate records that a member updated their leaf key now, at
+// epoch if it is known, and clears any outstanding update request.
+func (s *Store) RecordMemberUpdate(ctx context.Context, groupID, userID string, epoch *int64) error {
+	_, err := s.db.ExecContext(ctx,
+		`UPDATE group_members
+		 SET last_update_at = ?, last_update_epoch = COALESCE(?, last_update_epoch), update_requested_at = NULL
+		 WHERE group_id = ? AND user_index = ?`,
+		time.Now().Unix(), epoch, groupID, s.userIndex(userID),
+	)
+	if err != nil {
+		return fmt.Errorf("record member update: %w", err)
+	}
+	return nil
+}
+
+// GetMemberUpdates returns the update state of every member of a group.
+func (s *Store) GetMemberUpdates(ctx context.Context, groupID string) ([]*MemberUpdate, error) {
+	rows, err := s.readDB.QueryContext(ctx,
+		`SELECT group_id, user_id, joined_at, last_update_at, last_update_epoch, update_requested_at
+		 FROM group_members WHERE group_id = ? ORDER BY joined_at`,
+		groupID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("get member updates: %w", err)
+	}
+	defer rows.Close()
+	return s.scanMemberUpdates(rows)
+}
+
+// ClaimStaleMembers returns the groups in which userID has not updated
+// since staleBefore and has not been asked to since then either, and marks
+// them as asked now. Each stale membership is thus returned at most once
+// per staleness interval.
+func (s *Store) ClaimStaleMembers(ctx context.Context, userID string, staleBefore int64) ([]*MemberUpdate, error) {
+	var updates []*MemberUpdate
+	index := s.userIndex(userID)
+	err := s.InTx(ctx, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx,
+			`SELECT group_id, user_id, joined_at, last_update_at, last_update_epoch, update_requested_at
+			 FROM group_members
+			 WHERE user_index = ? AND COALESCE(last_update_at, joined_at) < ?
+			   AND (update_requested_at IS NULL OR update_requested_at < ?)
+			 ORDER BY joined_at`,
+			index, staleBefore, staleBefore,
+		)
+		if err != nil {
+			return fmt.Errorf("query stale members: %w", err)
+		}
+		updates, err = s.scanMemberUpdates(rows)
+		rows.Close()
+		if err != nil {
+			return err
+		}
+
+		// The update repeats the staleness check so that a member claimed
+		// by a concurrent transaction in the meantime is left out.
+		now := time.Now().Unix()
+		claimed := updates[:0]
+		for _, u := range updates {
+			result, err := tx.ExecContext(ctx,
+				`UPDATE group_members SET update_requested_at = ?
+				 WHERE group_id = ? AND user_index = ? AND (update_requested_at IS NULL OR update_requested_at < ?)`,
+				now, u.GroupID, index, staleBefore,
+			)
+			if err != nil {
+				return fmt.Errorf("mark update requested: %w", err)
+			}
+			n, err := result.RowsAffected()
+			if err != nil {
+				return fmt.Errorf("rows affected: %w", err)
+			}
+			if n == 1 {
+				claimed = append(claimed, u)
+			}
+		}
+		updates = claimed
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return updates, nil
+}
+
+func (s *Store) scanMemberUpdates(rows *sql.Rows) ([]*MemberUpdate, error) {
+	var updates []*MemberUpdate
+	for rows.Next() {
+		u := &MemberUpdate{}
+		var lastUpdateAt, lastUpdateEpoch, requestedAt sql.NullInt64
+		if err := rows.Scan(&u.GroupID, &u.UserID, &u.JoinedAt, &lastUpdateAt, &lastUpdateEpoch, &requestedAt); err != nil {
+			return nil, fmt.Errorf("scan member update: %w", err)
+		}
+		var err error
+		if u.UserID, err = s.openString("group_members.user_id", u.UserID); err != nil {
+			return nil, err
+		}
+		if lastUpdateAt.Valid {
+			u.LastUpdateAt = &lastUpdateAt.Int64
+		}
+		if lastUpdateEpoch.Valid {
+			u.LastUpdateEpoch = &lastUpdateEpoch.Int64
+		}
+		if requestedAt.Valid {
+			u.UpdateRequestedAt = &requestedAt.Int64
+		}
+		updates = append(updates, u)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate member updates: %w", err)
+	}
+	return updates, nil
+}
@@ -0,0 +1,72 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemberUpdates(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	seedUsers(t, s, "alice", "bob")
+
+	conv, err := s.CreateConversation(ctx, "Group", "alice", []string{"bob"})
+	if err != nil {
+		t.Fatalf("CreateConversation: %v", err)
+	}
+	now := time.Now().Unix()
+	if _, err := s.db.ExecContext(ctx, `UPDATE group_members SET joined_at = ?`, now-1000); err != nil {
+		t.Fatalf("backdate joined_at: %v", err)
+	}
+	staleBefore := now - 500
+
+	claim := func(userID string) int {
+		t.Helper()
+		updates, err := s.ClaimStaleMembers(ctx, userID, staleBefore)
+		if err != nil {
+			t.Fatalf("ClaimStaleMembers(%s): %v", userID, err)
+		}
+		for _, u := range updates {
+			if u.GroupID != conv.ID || u.UserID != userID {
+				t.Errorf("claimed %s/%s, want %s/%s", u.GroupID, u.UserID, conv.ID, userID)
+			}
+		}
+		return len(updates)
+	}
+
+	if n := claim("alice"); n != 1 {
+		t.Errorf("first claim for alice = %d, want 1", n)
+	}
+	if n := claim("alice"); n != 0 {
+		t.Errorf("second claim for alice = %d, want 0", n)
+	}
+
+	epoch := int64(7)
+	if err := s.RecordMemberUpdate(ctx, conv.ID, "bob", &epoch); err != nil {
+		t.Fatalf("RecordMemberUpdate: %v", err)
+	}
+	if n := claim("bob"); n != 0 {
+		t.Errorf("claim for bob after update = %d, want 0", n)
+	}
+	// An update with an unknown epoch keeps the last known one.
+	if err := s.RecordMemberUpdate(ctx, conv.ID, "bob", nil); err != nil {
+		t.Fatalf("RecordMemberUpdate: %v", err)
+	}
+
+	updates, err := s.GetMemberUpdates(ctx, conv.ID)
+	if err != nil {
+		t.Fatalf("GetMemberUpdates: %v", err)
+	}
+	got := make(map[string]*MemberUpdate)
+	for _, u := range updates {
+		got[u.UserID] = u
+	}
+	if u := got["alice"]; u == nil || u.LastUpdateAt != nil || u.UpdateRequestedAt == nil || u.UpdatedAt() != now-1000 {
+		t.Errorf("alice = %+v, want no update, requested, updated at join", u)
+	}
+	if u := got["bob"]; u == nil || u.LastUpdateAt == nil || *u.LastUpdateAt < now ||
+		u.LastUpdateEpoch == nil || *u.LastUpdateEpoch != 7 || u.UpdateRequestedAt != nil {
+		t.Errorf("bob = %+v, want updated now at epoch 7 and not requested", u)
+	}
+}
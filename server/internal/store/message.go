@@ -4,6 +4,7 @@ import (
 	"context"
 	"crypto/rand"
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"time"
 
@@ -36,6 +37,11 @@ type Message struct {
 	MessageType     int
 	Epoch           int
 	CreatedAt       int64
+
+	// AddedUserIDs and RemovedUserIDs are the members a Commit stored by
+	// CommitMembershipChange added and removed.
+	AddedUserIDs   []string
+	RemovedUserIDs []string
 }
 
 // DeliveryRecord tracks per-recipient delivery state.
@@ -55,41 +61,102 @@ func NewULID() string {
 // InsertMessage stores a message and creates delivery_status rows for all
 // group members except the sender. It returns the generated message ID.
 func (s *Store) InsertMessage(ctx context.Context, groupID, senderID string, payload []byte, messageType, epoch int) (string, int64, error) {
+	if err := s.prepareWrites(ctx, insertMessageQuery, insertDeliveriesQuery); err != nil {
+		return "", 0, err
+	}
+	var msgID string
+	var serverTS int64
+	err := s.InTx(ctx, func(tx *sql.Tx) error {
+		var err error
+		msgID, serverTS, err = s.insertMessage(ctx, tx, groupID, senderID, payload, messageType, epoch)
+		return err
+	})
+	if err != nil {
+		return "", 0, err
+	}
+
+	return msgID, serverTS, nil
+}
+
+const insertMessageQuery = `INSERT INTO messages (id, group_id, sender_id, server_timestamp, payload, payload_size, message_type, epoch, created_at)
+	 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+const insertDeliveriesQuery = `INSERT INTO delivery_status (message_id, recipient_index, status)
+	 SELECT CAST(? AS TEXT), user_index, 0 FROM group_members WHERE group_id = ? AND user_index != ?`
+
+// insertMessage stores a message in tx and creates delivery_status rows for
+// the group's current members except the sender.
+func (s *Store) insertMessage(ctx context.Context, tx *sql.Tx, groupID, senderID string, payload []byte, messageType, epoch int) (string, int64, error) {
 	msgID := NewULID()
 	now := time.Now()
 	serverTS := now.UnixMicro()
 	createdAt := now.Unix()
 	payloadSize := len(payload)
 
+	insert, err := s.writeStmt(ctx, tx, insertMessageQuery)
+	if err != nil {
+		return "", 0, err
+	}
+	_, err = insert.ExecContext(ctx,
+		msgID, groupID, senderID, serverTS, s.sealBytes("messages.payload", payload),
+		payloadSize, messageType, epoch, createdAt,
+	)
+	if err != nil {
+		return "", 0, fmt.Errorf("insert message: %w", err)
+	}
+
+	// Create delivery_status rows for all group members except sender.
+	fanOut, err := s.writeStmt(ctx, tx, insertDeliveriesQuery)
+	if err != nil {
+		return "", 0, err
+	}
+	if _, err := fanOut.ExecContext(ctx, msgID, groupID, s.userIndex(senderID)); err != nil {
+		return "", 0, fmt.Errorf("insert delivery status: %w", err)
+	}
+
+	return msgID, serverTS, nil
+}
+
+// InsertDirectMessage stores a message in a group for one recipient only,
+// such as an MLS Welcome, and creates its delivery_status row. The
+// recipient must be a member of the group.
+func (s *Store) InsertDirectMessage(ctx context.Context, groupID, senderID, recipientID string, payload []byte, messageType int) (string, int64, error) {
+	if err := s.prepareWrites(ctx, insertMessageQuery, insertDeliveryQuery); err != nil {
+		return "", 0, err
+	}
+	var msgID string
+	var serverTS int64
 	err := s.InTx(ctx, func(tx *sql.Tx) error {
-		_, err := tx.ExecContext(ctx,
-			`INSERT INTO messages (id, group_id, sender_id, server_timestamp, payload, payload_size, message_type, epoch, created_at)
-			 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
-			msgID, groupID, senderID, serverTS, payload, payloadSize, messageType, epoch, createdAt,
-		)
+		msgID = NewULID()
+		now := time.Now()
+		serverTS = now.UnixMicro()
+		insert, err := s.writeStmt(ctx, tx, insertMessageQuery)
 		if err != nil {
+			return err
+		}
+		if _, err := insert.ExecContext(ctx,
+			msgID, groupID, senderID, serverTS, s.sealBytes("messages.payload", payload),
+			len(payload), messageType, 0, now.Unix(),
+		); err != nil {
 			return fmt.Errorf("insert message: %w", err)
 		}
-
-		// Create delivery_status rows for all group members except sender.
-		_, err = tx.ExecContext(ctx,
-			`INSERT INTO delivery_status (message_id, recipient_id, status)
-			 SELECT ?, user_id, 0 FROM group_members WHERE group_id = ? AND user_id != ?`,
-			msgID, groupID, senderID,
-		)
+		deliver, err := s.writeStmt(ctx, tx, insertDeliveryQuery)
 		if err != nil {
+			return err
+		}
+		if _, err := deliver.ExecContext(ctx, msgID, s.userIndex(recipientID)); err != nil {
 			return fmt.Errorf("insert delivery status: %w", err)
 		}
-
 		return nil
 	})
 	if err != nil {
 		return "", 0, err
 	}
-
 	return msgID, serverTS, nil
 }
 
+const insertDeliveryQuery = `INSERT INTO delivery_status (message_id, recipient_index, status) VALUES (?, ?, 0)`
+
 // GetMessagesByGroup returns messages for a group using cursor-based pagination.
 // If cursor is empty, returns the most recent messages.
 // direction: true = forward (newer), false = backward (older).
@@ -102,20 +169,20 @@ func (s *Store) GetMessagesByGroup(ctx context.Context, groupID, cursor string,
 	var err error
 
 	if cursor == "" {
-		rows, err = s.db.QueryContext(ctx,
-			`SELECT id, group_id, sender_id, server_timestamp, payload, payload_size, message_type, epoch, created_at
+		rows, err = s.readDB.QueryContext(ctx,
+			`SELECT id, group_id, sender_id, server_timestamp, payload, payload_size, message_type, epoch, created_at, membership_change
 			 FROM messages WHERE group_id = ? ORDER BY id DESC LIMIT ?`,
 			groupID, limit,
 		)
 	} else if forward {
-		rows, err = s.db.QueryContext(ctx,
-			`SELECT id, group_id, sender_id, server_timestamp, payload, payload_size, message_type, epoch, created_at
+		rows, err = s.readDB.QueryContext(ctx,
+			`SELECT id, group_id, sender_id, server_timestamp, payload, payload_size, message_type, epoch, created_at, membership_change
 			 FROM messages WHERE group_id = ? AND id > ? ORDER BY id ASC LIMIT ?`,
 			groupID, cursor, limit,
 		)
 	} else {
-		rows, err = s.db.QueryContext(ctx,
-			`SELECT id, group_id, sender_id, server_timestamp, payload, payload_size, message_type, epoch, created_at
+		rows, err = s.readDB.QueryContext(ctx,
+			`SELECT id, group_id, sender_id, server_timestamp, payload, payload_size, message_type, epoch, created_at, membership_change
 			 FROM messages WHERE group_id = ? AND id < ? ORDER BY id DESC LIMIT ?`,
 			groupID, cursor, limit,
 		)
@@ -125,26 +192,55 @@ func (s *Store) GetMessagesByGroup(ctx context.Context, groupID, cursor string,
 	}
 	defer rows.Close()
 
-	return scanMessages(rows)
+	return s.scanMessages(rows)
 }
 
 // GetPendingMessages returns all messages with PENDING delivery status for a user,
 // ordered by server_timestamp ascending (oldest first for delivery).
 func (s *Store) GetPendingMessages(ctx context.Context, recipientID string) ([]*Message, error) {
-	rows, err := s.db.QueryContext(ctx,
-		`SELECT m.id, m.group_id, m.sender_id, m.server_timestamp, m.payload, m.payload_size, m.message_type, m.epoch, m.created_at
+	stmt, err := s.readStmt(ctx,
+		`SELECT m.id, m.group_id, m.sender_id, m.server_timestamp, m.payload, m.payload_size, m.message_type, m.epoch, m.created_at, m.membership_change
 		 FROM delivery_status ds
 		 JOIN messages m ON m.id = ds.message_id
-		 WHERE ds.recipient_id = ? AND ds.status = 0
-		 ORDER BY m.server_timestamp ASC`,
-		recipientID,
-	)
+		 WHERE ds.recipient_index = ? AND ds.status = 0
+		 ORDER BY m.server_timestamp ASC, m.id ASC`)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := stmt.QueryContext(ctx, s.userIndex(recipientID))
 	if err != nil {
 		return nil, fmt.Errorf("query pending messages: %w", err)
 	}
 	defer rows.Close()
 
-	return scanMessages(rows)
+	return s.scanMessages(rows)
+}
+
+// GetPendingMessagesAfter returns up to limit messages with PENDING
+// delivery status for a user, in the order GetPendingMessages returns them,
+// starting after the message with the given server timestamp and ID. Pass
+// 0 and "" to start from the oldest. Messages stay pending until they are
+// marked delivered, so callers page through them with the last message
+// returned rather than an offset.
+func (s *Store) GetPendingMessagesAfter(ctx context.Context, recipientID string, afterTimestamp int64, afterID string, limit int) ([]*Message, error) {
+	stmt, err := s.readStmt(ctx,
+		`SELECT m.id, m.group_id, m.sender_id, m.server_timestamp, m.payload, m.payload_size, m.message_type, m.epoch, m.created_at, m.membership_change
+		 FROM delivery_status ds
+		 JOIN messages m ON m.id = ds.message_id
+		 WHERE ds.recipient_index = ? AND ds.status = 0
+		   AND (m.server_timestamp > ? OR (m.server_timestamp = ? AND m.id > ?))
+		 ORDER BY m.server_timestamp ASC, m.id ASC
+		 LIMIT ?`)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := stmt.QueryContext(ctx, s.userIndex(recipientID), afterTimestamp, afterTimestamp, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("query pending messages: %w", err)
+	}
+	defer rows.Close()
+
+	return s.scanMessages(rows)
 }
 
 // UpdateDeliveryStatus updates the delivery status for a message-recipient pair.
@@ -160,13 +256,15 @@ func (s *Store) UpdateDeliveryStatus(ctx context.Context, messageID, recipientID
 		readAt = now
 	}
 
-	result, err := s.db.ExecContext(ctx,
+	stmt, err := s.writeStmt(ctx, nil,
 		`UPDATE delivery_status SET status = ?,
 		 delivered_at = COALESCE(delivered_at, ?),
 		 read_at = COALESCE(read_at, ?)
-		 WHERE message_id = ? AND recipient_id = ?`,
-		status, deliveredAt, readAt, messageID, recipientID,
-	)
+		 WHERE message_id = ? AND recipient_index = ?`)
+	if err != nil {
+		return err
+	}
+	result, err := stmt.ExecContext(ctx, status, deliveredAt, readAt, messageID, s.userIndex(recipientID))
 	if err != nil {
 		return fmt.Errorf("update delivery status: %w", err)
 	}
@@ -180,15 +278,120 @@ func (s *Store) UpdateDeliveryStatus(ctx context.Context, messageID, recipientID
 	return nil
 }
 
+// DeliveredMessage is a message whose delivery MarkDelivered changed.
+type DeliveredMessage struct {
+	MessageID string
+	SenderID  string // empty for sealed-sender messages
+}
+
+// MarkDelivered marks the pending deliveries of messageIDs to recipientID
+// as delivered, in one transaction, and returns the messages it changed.
+// Deliveries that are already delivered or read are left alone, and IDs
+// with no delivery to the recipient are ignored.
+func (s *Store) MarkDelivered(ctx context.Context, recipientID string, messageIDs []string) ([]DeliveredMessage, error) {
+	var delivered []DeliveredMessage
+	err := s.markDelivered(ctx, `message_id`, `recipient_index = ?`, s.userIndex(recipientID), messageIDs,
+		`message_id, (SELECT sender_id FROM messages WHERE messages.id = delivery_status.message_id)`,
+		func(rows *sql.Rows) error {
+			var d DeliveredMessage
+			var senderID sql.NullString
+			if err := rows.Scan(&d.MessageID, &senderID); err != nil {
+				return err
+			}
+			// The message may have expired meanwhile.
+			d.SenderID = senderID.String
+			delivered = append(delivered, d)
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+	return delivered, nil
+}
+
+// MarkMessageDelivered marks the pending deliveries of messageID to each of
+// recipientIDs as delivered, in one transaction, as MarkDelivered does for
+// one recipient.
+func (s *Store) MarkMessageDelivered(ctx context.Context, messageID string, recipientIDs []string) error {
+	return s.markDelivered(ctx, `recipient_index`, `message_id = ?`, messageID, s.userIndexes(recipientIDs), "", nil)
+}
+
+// markDelivered marks as delivered the pending deliveries matching where,
+// which has one placeholder for key, whose column is one of values. The
+// values are sent in chunks, each through a prepared statement. With
+// returning, scan is called with each changed row's returning columns.
+func (s *Store) markDelivered(ctx context.Context, column, where, key string, values []string, returning string, scan func(*sql.Rows) error) error {
+	if len(values) == 0 {
+		return nil
+	}
+	query := func(size int) string {
+		q := `UPDATE delivery_status SET status = ` + fmt.Sprint(DeliveryDelivered) + `, delivered_at = ?
+		 WHERE status = ` + fmt.Sprint(DeliveryPending) + ` AND ` + where + ` AND ` + column + ` IN (` + inList(size) + `)`
+		if returning != "" {
+			q += ` RETURNING ` + returning
+		}
+		return q
+	}
+	// Every chunk is full but the last.
+	var sizes []int
+	if len(values) >= maxInList {
+		sizes = append(sizes, maxInList)
+	}
+	if rest := len(values) % maxInList; rest > 0 {
+		sizes = append(sizes, inListSize(rest))
+	}
+	for _, size := range sizes {
+		if err := s.prepareWrites(ctx, query(size)); err != nil {
+			return err
+		}
+	}
+
+	now := time.Now().UnixMicro()
+	return s.InTx(ctx, func(tx *sql.Tx) error {
+		for len(values) > 0 {
+			chunk := values[:min(len(values), maxInList)]
+			values = values[len(chunk):]
+
+			size := inListSize(len(chunk))
+			stmt, err := s.writeStmt(ctx, tx, query(size))
+			if err != nil {
+				return err
+			}
+			args := padArgs([]any{now, key}, chunk, size)
+			if returning == "" {
+				if _, err := stmt.ExecContext(ctx, args...); err != nil {
+					return fmt.Errorf("mark delivered: %w", err)
+				}
+				continue
+			}
+			rows, err := stmt.QueryContext(ctx, args...)
+			if err != nil {
+				return fmt.Errorf("mark delivered: %w", err)
+			}
+			for rows.Next() {
+				if err := scan(rows); err != nil {
+					rows.Close()
+					return fmt.Errorf("scan delivered: %w", err)
+				}
+			}
+			rows.Close()
+			if err := rows.Err(); err != nil {
+				return fmt.Errorf("mark delivered: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
 // GetDeliveryStatus returns the delivery record for a message-recipient pair.
 func (s *Store) GetDeliveryStatus(ctx context.Context, messageID, recipientID string) (*DeliveryRecord, error) {
-	d := &DeliveryRecord{}
+	d := &DeliveryRecord{RecipientID: recipientID}
 	var deliveredAt, readAt sql.NullInt64
-	err := s.db.QueryRowContext(ctx,
-		`SELECT message_id, recipient_id, status, delivered_at, read_at
-		 FROM delivery_status WHERE message_id = ? AND recipient_id = ?`,
-		messageID, recipientID,
-	).Scan(&d.MessageID, &d.RecipientID, &d.Status, &deliveredAt, &readAt)
+	err := s.readDB.QueryRowContext(ctx,
+		`SELECT message_id, status, delivered_at, read_at
+		 FROM delivery_status WHERE message_id = ? AND recipient_index = ?`,
+		messageID, s.userIndex(recipientID),
+	).Scan(&d.MessageID, &d.Status, &deliveredAt, &readAt)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, ErrNotFound
@@ -208,7 +411,7 @@ func (s *Store) GetDeliveryStatus(ctx context.Context, messageID, recipientID st
 // the message does not exist.
 func (s *Store) GetMessageSenderID(ctx context.Context, messageID string) (string, error) {
 	var senderID string
-	err := s.db.QueryRowContext(ctx,
+	err := s.readDB.QueryRowContext(ctx,
 		`SELECT sender_id FROM messages WHERE id = ?`, messageID,
 	).Scan(&senderID)
 	if err != nil {
@@ -236,14 +439,29 @@ func (s *Store) DeleteExpiredMessages(ctx context.Context, cutoffUnixSeconds int
 	return n, nil
 }
 
-func scanMessages(rows *sql.Rows) ([]*Message, error) {
+func (s *Store) scanMessages(rows *sql.Rows) ([]*Message, error) {
 	var msgs []*Message
 	for rows.Next() {
 		m := &Message{}
+		var change []byte
 		if err := rows.Scan(&m.ID, &m.GroupID, &m.SenderID, &m.ServerTimestamp, &m.Payload,
-			&m.PayloadSize, &m.MessageType, &m.Epoch, &m.CreatedAt); err != nil {
+			&m.PayloadSize, &m.MessageType, &m.Epoch, &m.CreatedAt, &change); err != nil {
 			return nil, fmt.Errorf("scan message: %w", err)
 		}
+		var err error
+		if m.Payload, err = s.openBytes("messages.payload", m.Payload); err != nil {
+			return nil, err
+		}
+		if change != nil {
+			if change, err = s.openBytes("messages.membership_change", change); err != nil {
+				return nil, err
+			}
+			var mc membershipChange
+			if err := json.Unmarshal(change, &mc); err != nil {
+				return nil, fmt.Errorf("decode membership change of message %s: %w", m.ID, err)
+			}
+			m.AddedUserIDs, m.RemovedUserIDs = mc.Added, mc.Removed
+		}
 		msgs = append(msgs, m)
 	}
 	if err := rows.Err(); err != nil {
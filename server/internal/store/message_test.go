@@ -2,7 +2,10 @@ package store
 
 import (
 	"context"
+	"fmt"
+	"path/filepath"
 	"sort"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -37,8 +40,8 @@ func seedConversationWithMembers(t *testing.T, s *Store, convID string, creator
 
 	// Add creator as admin.
 	_, err = s.db.ExecContext(ctx,
-		`INSERT INTO group_members (group_id, user_id, role, joined_at) VALUES (?, ?, 'admin', ?)`,
-		convID, creator, now,
+		`INSERT INTO group_members (group_id, user_id, user_index, role, joined_at) VALUES (?, ?, ?, 'admin', ?)`,
+		convID, creator, creator, now,
 	)
 	if err != nil {
 		t.Fatalf("add creator: %v", err)
@@ -50,8 +53,8 @@ func seedConversationWithMembers(t *testing.T, s *Store, convID string, creator
 			continue
 		}
 		_, err = s.db.ExecContext(ctx,
-			`INSERT INTO group_members (group_id, user_id, role, joined_at) VALUES (?, ?, 'member', ?)`,
-			convID, uid, now,
+			`INSERT INTO group_members (group_id, user_id, user_index, role, joined_at) VALUES (?, ?, ?, 'member', ?)`,
+			convID, uid, uid, now,
 		)
 		if err != nil {
 			t.Fatalf("add member %s: %v", uid, err)
@@ -353,6 +356,56 @@ func TestUpdateDeliveryStatus(t *testing.T) {
 	})
 }
 
+func TestMarkDelivered(t *testing.T) {
+	// Counts on each side of the chunk size, so that full chunks, padded
+	// chunks and both together are exercised.
+	for _, n := range []int{1, 3, maxInList, maxInList + 1, 2*maxInList + 37} {
+		t.Run(fmt.Sprint(n), func(t *testing.T) {
+			s := newTestStore(t)
+			ctx := context.Background()
+			seedConversationWithMembers(t, s, "group-1", "alice", []string{"bob"})
+
+			var ids []string
+			for range n + 1 {
+				id, _, err := s.InsertMessage(ctx, "group-1", "alice", []byte("hello"), MsgTypeApplication, 0)
+				if err != nil {
+					t.Fatalf("InsertMessage: %v", err)
+				}
+				ids = append(ids, id)
+			}
+
+			// All but the last message are marked.
+			if _, err := s.MarkDelivered(ctx, "bob", ids[:n]); err != nil {
+				t.Fatalf("MarkDelivered: %v", err)
+			}
+			pending, err := s.GetPendingMessages(ctx, "bob")
+			if err != nil {
+				t.Fatalf("GetPendingMessages: %v", err)
+			}
+			if len(pending) != 1 || pending[0].ID != ids[n] {
+				t.Errorf("pending after MarkDelivered = %d messages, want only %s", len(pending), ids[n])
+			}
+			dr, err := s.GetDeliveryStatus(ctx, ids[n-1], "bob")
+			if err != nil {
+				t.Fatalf("GetDeliveryStatus: %v", err)
+			}
+			if dr.Status != DeliveryDelivered || dr.DeliveredAt == nil {
+				t.Errorf("GetDeliveryStatus = %+v, want delivered", dr)
+			}
+		})
+	}
+}
+
+func TestInListSize(t *testing.T) {
+	for _, tt := range []struct{ n, want int }{
+		{1, 1}, {2, 2}, {3, 4}, {64, 64}, {65, 128}, {maxInList, maxInList}, {maxInList + 1, maxInList},
+	} {
+		if got := inListSize(tt.n); got != tt.want {
+			t.Errorf("inListSize(%d) = %d, want %d", tt.n, got, tt.want)
+		}
+	}
+}
+
 func TestGetMessageSenderID(t *testing.T) {
 	s := newTestStore(t)
 	ctx := context.Background()
@@ -421,3 +474,189 @@ func TestDeleteExpiredMessages(t *testing.T) {
 		t.Errorf("expected 1 remaining message, got %d", len(msgs))
 	}
 }
+
+// BenchmarkConcurrentSenders measures message throughput with many
+// concurrent senders, each doing the queries of a message send: a
+// membership check, the insert, the member fan-out with a delivery update
+// and a history read. "shared" routes reads through the writer connection,
+// as a single-connection store does.
+func BenchmarkConcurrentSenders(b *testing.B) {
+	const members = 8
+
+	for _, bc := range []struct {
+		name      string
+		readConns int
+	}{
+		{name: "shared"},
+		{name: "readers=1", readConns: 1},
+		{name: "readers=4", readConns: 4},
+		{name: "readers=8", readConns: 8},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			ctx := context.Background()
+			s, err := Open(filepath.Join(b.TempDir(), "bench.db"), Options{ReadConns: bc.readConns})
+			if err != nil {
+				b.Fatalf("Open: %v", err)
+			}
+			defer s.Close()
+			if bc.readConns == 0 {
+				s.readDB.Close()
+				s.readDB = s.db
+				s.readStmts = s.writeStmts
+			}
+
+			userIDs := make([]string, members)
+			for i := range userIDs {
+				userIDs[i] = fmt.Sprintf("user-%d", i)
+			}
+			seedUsers(b, s, userIDs...)
+			conv, err := s.CreateConversation(ctx, "Bench", userIDs[0], userIDs[1:])
+			if err != nil {
+				b.Fatalf("CreateConversation: %v", err)
+			}
+			payload := make([]byte, 512)
+
+			var next atomic.Int64
+			b.SetParallelism(8) // 8 senders per GOMAXPROCS
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				sender := userIDs[next.Add(1)%members]
+				for pb.Next() {
+					if ok, err := s.IsUserMember(ctx, conv.ID, sender); err != nil || !ok {
+						b.Errorf("IsUserMember = %v, %v", ok, err)
+						return
+					}
+					msgID, _, err := s.InsertMessage(ctx, conv.ID, sender, payload, MsgTypeApplication, 0)
+					if err != nil {
+						b.Errorf("InsertMessage: %v", err)
+						return
+					}
+					ms, err := s.GetMembers(ctx, conv.ID)
+					if err != nil {
+						b.Errorf("GetMembers: %v", err)
+						return
+					}
+					var recipients []string
+					for _, m := range ms {
+						if m.UserID != sender {
+							recipients = append(recipients, m.UserID)
+						}
+					}
+					if err := s.MarkMessageDelivered(ctx, msgID, recipients); err != nil {
+						b.Errorf("MarkMessageDelivered: %v", err)
+						return
+					}
+					if _, err := s.GetMessagesByGroup(ctx, conv.ID, "", 50, false); err != nil {
+						b.Errorf("GetMessagesByGroup: %v", err)
+						return
+					}
+				}
+			})
+		})
+	}
+}
+
+// BenchmarkMarkPendingDelivered measures marking a returning user's
+// pending messages delivered: one UPDATE per message, or MarkDelivered.
+func BenchmarkMarkPendingDelivered(b *testing.B) {
+	const pending = 1000
+
+	for _, batched := range []bool{false, true} {
+		name := "per-message"
+		if batched {
+			name = "batched"
+		}
+		b.Run(name, func(b *testing.B) {
+			ctx := context.Background()
+			s, err := New(filepath.Join(b.TempDir(), "bench.db"))
+			if err != nil {
+				b.Fatalf("New: %v", err)
+			}
+			defer s.Close()
+			seedUsers(b, s, "alice", "bob")
+			conv, err := s.CreateConversation(ctx, "Bench", "alice", []string{"bob"})
+			if err != nil {
+				b.Fatalf("CreateConversation: %v", err)
+			}
+			ids := make([]string, pending)
+			for i := range ids {
+				if ids[i], _, err = s.InsertMessage(ctx, conv.ID, "alice", make([]byte, 512), MsgTypeApplication, 0); err != nil {
+					b.Fatalf("InsertMessage: %v", err)
+				}
+			}
+
+			b.ResetTimer()
+			for range b.N {
+				b.StopTimer()
+				if _, err := s.db.Exec(`UPDATE delivery_status SET status = 0, delivered_at = NULL`); err != nil {
+					b.Fatalf("reset delivery status: %v", err)
+				}
+				b.StartTimer()
+
+				if batched {
+					if _, err := s.MarkDelivered(ctx, "bob", ids); err != nil {
+						b.Fatalf("MarkDelivered: %v", err)
+					}
+					continue
+				}
+				for _, id := range ids {
+					if err := s.UpdateDeliveryStatus(ctx, id, "bob", DeliveryDelivered); err != nil {
+						b.Fatalf("UpdateDeliveryStatus: %v", err)
+					}
+				}
+			}
+			b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N*pending), "ns/message")
+		})
+	}
+}
+
+// BenchmarkFanOut measures storing a message to a large group and marking
+// it delivered to every online member: one UPDATE per recipient, or
+// MarkMessageDelivered.
+func BenchmarkFanOut(b *testing.B) {
+	const members = 64
+
+	for _, batched := range []bool{false, true} {
+		name := "per-recipient"
+		if batched {
+			name = "batched"
+		}
+		b.Run(name, func(b *testing.B) {
+			ctx := context.Background()
+			s, err := New(filepath.Join(b.TempDir(), "bench.db"))
+			if err != nil {
+				b.Fatalf("New: %v", err)
+			}
+			defer s.Close()
+			userIDs := make([]string, members)
+			for i := range userIDs {
+				userIDs[i] = fmt.Sprintf("user-%d", i)
+			}
+			seedUsers(b, s, userIDs...)
+			conv, err := s.CreateConversation(ctx, "Bench", userIDs[0], userIDs[1:])
+			if err != nil {
+				b.Fatalf("CreateConversation: %v", err)
+			}
+			payload := make([]byte, 512)
+
+			b.ResetTimer()
+			for range b.N {
+				msgID, _, err := s.InsertMessage(ctx, conv.ID, userIDs[0], payload, MsgTypeApplication, 0)
+				if err != nil {
+					b.Fatalf("InsertMessage: %v", err)
+				}
+				if batched {
+					if err := s.MarkMessageDelivered(ctx, msgID, userIDs[1:]); err != nil {
+						b.Fatalf("MarkMessageDelivered: %v", err)
+					}
+					continue
+				}
+				for _, id := range userIDs[1:] {
+					if err := s.UpdateDeliveryStatus(ctx, msgID, id, DeliveryDelivered); err != nil {
+						b.Fatalf("UpdateDeliveryStatus: %v", err)
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,200 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// migration is a schema change and the change that reverts it.
+type migration struct {
+	name string
+	up   func(*sql.Tx) error
+	down func(*sql.Tx) error
+}
+
+// MigrationInfo describes a schema version.
+type MigrationInfo struct {
+	Version   int
+	Name      string // empty for a version applied by a newer build
+	AppliedAt int64  // Unix seconds; 0 if not applied
+}
+
+// migrationList returns the migrations for the store's dialect.
+func (s *Store) migrationList() []migration {
+	if s.dialect == Postgres {
+		return postgresMigrations
+	}
+	return migrations
+}
+
+func (s *Store) createSchemaVersionTable(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_version (
+		version    INTEGER PRIMARY KEY,
+		applied_at BIGINT NOT NULL
+	)`)
+	if err != nil {
+		return fmt.Errorf("create schema_version table: %w", err)
+	}
+	return nil
+}
+
+// migrate runs all pending migrations. It is how Open brings a database up
+// to date unless Options.SkipMigrations is set.
+func (s *Store) migrate() error {
+	_, err := s.Migrate(context.Background(), false)
+	return err
+}
+
+// Migrations returns every schema version this build knows and any newer
+// ones applied to the database, in order, with when each was applied.
+func (s *Store) Migrations(ctx context.Context) ([]MigrationInfo, error) {
+	if err := s.createSchemaVersionTable(ctx); err != nil {
+		return nil, err
+	}
+	list := s.migrationList()
+	infos := make([]MigrationInfo, len(list))
+	for i, m := range list {
+		infos[i] = MigrationInfo{Version: i + 1, Name: m.name}
+	}
+
+	rows, err := s.db.QueryContext(ctx, `SELECT version, applied_at FROM schema_version ORDER BY version`)
+	if err != nil {
+		return nil, fmt.Errorf("list schema versions: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var version int
+		var appliedAt int64
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, fmt.Errorf("scan schema version: %w", err)
+		}
+		if version >= 1 && version <= len(infos) {
+			infos[version-1].AppliedAt = appliedAt
+		} else if version > len(list) {
+			infos = append(infos, MigrationInfo{Version: version, AppliedAt: appliedAt})
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list schema versions: %w", err)
+	}
+	return infos, nil
+}
+
+// Migrate applies all pending migrations. See MigrateTo.
+func (s *Store) Migrate(ctx context.Context, dryRun bool) (backupPath string, err error) {
+	return s.MigrateTo(ctx, len(s.migrationList()), dryRun)
+}
+
+// MigrateTo applies or rolls back migrations until the schema is at the
+// given version. A database with a schema newer than this build knows
+// returns ErrSchemaTooNew and is left alone.
+//
+// Each step runs in a transaction of its own. With dryRun every step runs
+// in one transaction that is then rolled back, which checks that they
+// would succeed without changing anything.
+//
+// Before changing an existing SQLite database file, MigrateTo snapshots it
+// next to the database as <path>.v<version>-<time>.bak and returns that
+// path. Rolling back discards what the reverted migrations stored.
+func (s *Store) MigrateTo(ctx context.Context, version int, dryRun bool) (backupPath string, err error) {
+	list := s.migrationList()
+	if version < 0 || version > len(list) {
+		return "", fmt.Errorf("schema version %d out of range 0 to %d", version, len(list))
+	}
+	if err := s.createSchemaVersionTable(ctx); err != nil {
+		return "", err
+	}
+
+	var current int
+	err = s.db.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_version`).Scan(&current)
+	if err != nil {
+		return "", fmt.Errorf("get current version: %w", err)
+	}
+	if current > len(list) {
+		return "", fmt.Errorf("database at version %d, supported %d: %w", current, len(list), ErrSchemaTooNew)
+	}
+	if current == version {
+		return "", nil
+	}
+
+	if !dryRun && current > 0 && s.dialect == SQLite && !isMemoryPath(s.path) {
+		backupPath = migrationBackupPath(s.path, current, time.Now())
+		if err := s.Snapshot(ctx, backupPath); err != nil {
+			return "", fmt.Errorf("back up before migrating: %w", err)
+		}
+	}
+
+	var tx *sql.Tx
+	if dryRun {
+		if tx, err = s.db.BeginTx(ctx, nil); err != nil {
+			return "", fmt.Errorf("begin dry run: %w", err)
+		}
+		defer tx.Rollback()
+	}
+
+	up := version > current
+	for current != version {
+		// step is the migration applied or rolled back in this pass.
+		step := current
+		if up {
+			step = current + 1
+		}
+		if !dryRun {
+			if tx, err = s.db.BeginTx(ctx, nil); err != nil {
+				return backupPath, fmt.Errorf("begin migration %d: %w", step, err)
+			}
+		}
+
+		m := list[step-1]
+		if up {
+			err = m.up(tx)
+			if err == nil {
+				_, err = tx.ExecContext(ctx, `INSERT INTO schema_version (version, applied_at) VALUES (?, ?)`,
+					step, time.Now().Unix())
+			}
+		} else {
+			err = m.down(tx)
+			if err == nil {
+				_, err = tx.ExecContext(ctx, `DELETE FROM schema_version WHERE version = ?`, step)
+			}
+		}
+		if err != nil {
+			if !dryRun {
+				tx.Rollback()
+			}
+			if up {
+				return backupPath, fmt.Errorf("migration %d: %w", step, err)
+			}
+			return backupPath, fmt.Errorf("roll back migration %d: %w", step, err)
+		}
+
+		if !dryRun {
+			if err := tx.Commit(); err != nil {
+				return backupPath, fmt.Errorf("commit migration %d: %w", step, err)
+			}
+		}
+		if up {
+			current = step
+		} else {
+			current = step - 1
+		}
+	}
+	return backupPath, nil
+}
+
+// migrationBackupPath returns an unused path for a backup of the database
+// at dbPath, taken at version before migrating.
+func migrationBackupPath(dbPath string, version int, now time.Time) string {
+	base := fmt.Sprintf("%s.v%d-%s", dbPath, version, now.UTC().Format("20060102T150405Z"))
+	path := base + ".bak"
+	for i := 2; ; i++ {
+		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+			return path
+		}
+		path = fmt.Sprintf("%s-%d.bak", base, i)
+	}
+}
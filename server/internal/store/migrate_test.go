@@ -0,0 +1,248 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// schemaDump describes the tables, columns, foreign keys and indexes of a
+// SQLite database, in a form that does not depend on how they were
+// created.
+func schemaDump(t *testing.T, s *Store) string {
+	t.Helper()
+	queries := []string{
+		`SELECT m.name, p.name, p.type, p."notnull", COALESCE(p.dflt_value, ''), p.pk
+		 FROM sqlite_master m, pragma_table_info(m.name) p
+		 WHERE m.type = 'table' ORDER BY m.name, p.cid`,
+		`SELECT m.name, f."table", f."from", f."to", f.on_delete
+		 FROM sqlite_master m, pragma_foreign_key_list(m.name) f
+		 WHERE m.type = 'table' ORDER BY m.name, f.id, f.seq`,
+		`SELECT name, tbl_name, COALESCE(sql, '') FROM sqlite_master WHERE type = 'index' ORDER BY name`,
+	}
+	var b strings.Builder
+	for _, q := range queries {
+		rows, err := s.db.Query(q)
+		if err != nil {
+			t.Fatalf("dump schema: %v", err)
+		}
+		cols, _ := rows.Columns()
+		for rows.Next() {
+			vals := make([]any, len(cols))
+			ptrs := make([]any, len(cols))
+			for i := range vals {
+				ptrs[i] = &vals[i]
+			}
+			if err := rows.Scan(ptrs...); err != nil {
+				t.Fatalf("dump schema: %v", err)
+			}
+			fmt.Fprintln(&b, vals...)
+		}
+		rows.Close()
+	}
+	return b.String()
+}
+
+func openUnmigrated(t *testing.T, path string) *Store {
+	t.Helper()
+	s, err := Open(path, Options{SkipMigrations: true})
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestMigrationsRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	s := openUnmigrated(t, filepath.Join(t.TempDir(), "test.db"))
+
+	dumps := make([]string, len(migrations)+1)
+	for v := 0; v <= len(migrations); v++ {
+		if _, err := s.MigrateTo(ctx, v, false); err != nil {
+			t.Fatalf("MigrateTo(%d): %v", v, err)
+		}
+		dumps[v] = schemaDump(t, s)
+	}
+
+	// Each rollback must restore exactly the schema before its migration.
+	for v := len(migrations) - 1; v >= 0; v-- {
+		if _, err := s.MigrateTo(ctx, v, false); err != nil {
+			t.Fatalf("MigrateTo(%d) from %d: %v", v, v+1, err)
+		}
+		if got := schemaDump(t, s); got != dumps[v] {
+			t.Errorf("schema after rolling back to %d differs:\ngot:\n%s\nwant:\n%s", v, got, dumps[v])
+		}
+		if got, _ := s.SchemaVersion(ctx); got != v {
+			t.Errorf("SchemaVersion after rolling back to %d = %d", v, got)
+		}
+	}
+
+	if _, err := s.Migrate(ctx, false); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	if got := schemaDump(t, s); got != dumps[len(migrations)] {
+		t.Error("schema after migrating up again differs")
+	}
+}
+
+func TestMigrateToKeepsData(t *testing.T) {
+	ctx := context.Background()
+	s := openUnmigrated(t, filepath.Join(t.TempDir(), "test.db"))
+	if _, err := s.Migrate(ctx, false); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+
+	if err := s.CreateUser(ctx, makeUser("user-1", "alice")); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	for i, suite := range []int{1, 2} {
+		if _, err := s.db.Exec(`INSERT INTO key_packages (id, user_id, key_package_data, created_at, expires_at, last_resort, cipher_suite)
+			VALUES (?, 'user-1', x'00', ?, 0, 1, ?)`, fmt.Sprintf("kp-%d", i), i, suite); err != nil {
+			t.Fatalf("insert key package: %v", err)
+		}
+	}
+
+	// A dry run changes nothing.
+	if _, err := s.MigrateTo(ctx, 0, true); err != nil {
+		t.Fatalf("dry run: %v", err)
+	}
+	if v, _ := s.SchemaVersion(ctx); v != len(migrations) {
+		t.Errorf("SchemaVersion after dry run = %d, want %d", v, len(migrations))
+	}
+
+	// Rolling back to before cipher suites keeps one last-resort key
+	// package per user.
+	backupPath, err := s.MigrateTo(ctx, 7, false)
+	if err != nil {
+		t.Fatalf("MigrateTo(7): %v", err)
+	}
+	var n int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM key_packages WHERE last_resort = 1`).Scan(&n); err != nil {
+		t.Fatalf("count key packages: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("last-resort key packages after rollback = %d, want 1", n)
+	}
+
+	// The database was backed up before it was changed.
+	if backupPath == "" {
+		t.Fatal("no backup before rollback")
+	}
+	if v, err := CheckSnapshot(ctx, backupPath); err != nil || v != len(migrations) {
+		t.Errorf("CheckSnapshot(backup) = %d, %v; want %d", v, err, len(migrations))
+	}
+
+	if _, err := s.Migrate(ctx, false); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	u, err := s.GetUserByUsername(ctx, "alice")
+	if err != nil {
+		t.Fatalf("GetUserByUsername after round trip: %v", err)
+	}
+	if u.ID != "user-1" {
+		t.Errorf("user = %+v", u)
+	}
+}
+
+func TestMigrateSchemaTooNew(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "test.db")
+	s, err := New(path)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if _, err := s.db.Exec(`INSERT INTO schema_version (version, applied_at) VALUES (?, 1)`, len(migrations)+1); err != nil {
+		t.Fatalf("insert schema version: %v", err)
+	}
+	s.Close()
+
+	if _, err := New(path); !errors.Is(err, ErrSchemaTooNew) {
+		t.Fatalf("New on a newer schema error = %v, want ErrSchemaTooNew", err)
+	}
+
+	s = openUnmigrated(t, path)
+	infos, err := s.Migrations(ctx)
+	if err != nil {
+		t.Fatalf("Migrations: %v", err)
+	}
+	if len(infos) != len(migrations)+1 {
+		t.Fatalf("Migrations returned %d versions, want %d", len(infos), len(migrations)+1)
+	}
+	if last := infos[len(infos)-1]; last.Version != len(migrations)+1 || last.Name != "" || last.AppliedAt != 1 {
+		t.Errorf("unknown version = %+v", last)
+	}
+	if _, err := s.MigrateTo(ctx, 0, false); !errors.Is(err, ErrSchemaTooNew) {
+		t.Errorf("MigrateTo on a newer schema error = %v, want ErrSchemaTooNew", err)
+	}
+
+	// Nothing was backed up, since nothing was changed.
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	for _, e := range entries {
+		if strings.HasSuffix(e.Name(), ".bak") {
+			t.Errorf("unexpected backup %s", e.Name())
+		}
+	}
+}
+
+func TestMigrateV12DeletesOrphans(t *testing.T) {
+	ctx := context.Background()
+	s := openUnmigrated(t, filepath.Join(t.TempDir(), "test.db"))
+	if _, err := s.MigrateTo(ctx, 11, false); err != nil {
+		t.Fatalf("MigrateTo(11): %v", err)
+	}
+
+	// Before version 12 nothing stopped rows referring to missing users
+	// and conversations.
+	if err := s.CreateUser(ctx, makeUser("alice", "alice")); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	stmts := []string{
+		`INSERT INTO conversations (id, title, created_by, created_at) VALUES ('conv-1', '', 'alice', 0)`,
+		`INSERT INTO group_members (group_id, user_id, role, joined_at) VALUES ('conv-1', 'alice', 'admin', 0)`,
+		`INSERT INTO group_members (group_id, user_id, role, joined_at) VALUES ('conv-1', 'ghost', 'member', 0)`,
+		`INSERT INTO group_members (group_id, user_id, role, joined_at) VALUES ('gone', 'alice', 'member', 0)`,
+		`INSERT INTO messages (id, group_id, sender_id, server_timestamp, payload, payload_size, created_at)
+		 VALUES ('msg-1', 'conv-1', 'ghost', 0, x'00', 1, 0), ('msg-2', 'gone', 'alice', 0, x'00', 1, 0)`,
+		`INSERT INTO delivery_status (message_id, recipient_id, status)
+		 VALUES ('msg-1', 'alice', 0), ('msg-1', 'ghost', 0), ('msg-2', 'alice', 0)`,
+		`INSERT INTO key_packages (id, user_id, key_package_data, created_at, expires_at)
+		 VALUES ('kp-1', 'alice', x'00', 0, 0), ('kp-2', 'ghost', x'00', 0, 0)`,
+	}
+	for _, stmt := range stmts {
+		if _, err := s.db.Exec(stmt); err != nil {
+			t.Fatalf("exec %q: %v", stmt, err)
+		}
+	}
+
+	if _, err := s.MigrateTo(ctx, 12, false); err != nil {
+		t.Fatalf("MigrateTo(12): %v", err)
+	}
+	for _, tc := range []struct {
+		table string
+		want  int
+	}{
+		{"group_members", 1},
+		{"messages", 1},
+		{"delivery_status", 1},
+		{"key_packages", 1},
+	} {
+		if n := count(t, s, tc.table, "1 = 1"); n != tc.want {
+			t.Errorf("%s rows after migration = %d, want %d", tc.table, n, tc.want)
+		}
+	}
+	if _, err := s.Migrate(ctx, false); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	report, err := s.CheckIntegrity(ctx)
+	if err != nil {
+		t.Fatalf("CheckIntegrity: %v", err)
+	}
+	if !report.OK() {
+		t.Errorf("CheckIntegrity after migration = %+v", report)
+	}
+}
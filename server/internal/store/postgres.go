@@ -0,0 +1,445 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+// OpenPostgres connects to a PostgreSQL database and runs migrations. dsn
+// is a libpq connection string or URL. Reads and writes share one pool of
+// opts.ReadConns+1 connections, since PostgreSQL has no single-writer limit.
+func OpenPostgres(dsn string, opts Options) (*Store, error) {
+	readConns := opts.ReadConns
+	if readConns <= 0 {
+		readConns = DefaultReadConns
+	}
+
+	cfg, err := pgx.ParseConfig(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("parse postgres dsn: %w", err)
+	}
+	db := sql.OpenDB(pgConnector{stdlib.GetConnector(*cfg)})
+	db.SetMaxOpenConns(readConns + 1)
+	db.SetMaxIdleConns(readConns + 1)
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("connect to database: %w", err)
+	}
+
+	s := &Store{dialect: Postgres, db: db, readDB: db, writeStmts: newStmtCache(db)}
+	s.readStmts = s.writeStmts
+	if !opts.SkipMigrations {
+		if err := s.migrate(); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("run migrations: %w", err)
+		}
+	}
+	return s, nil
+}
+
+// pgConnector wraps the pgx connector so that queries written with ?
+// placeholders are sent with PostgreSQL's $1, $2, ... instead.
+type pgConnector struct {
+	driver.Connector
+}
+
+func (c pgConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pc, ok := conn.(*stdlib.Conn)
+	if !ok {
+		conn.Close()
+		return nil, fmt.Errorf("unexpected postgres connection type %T", conn)
+	}
+	return pgConn{pc}, nil
+}
+
+// pgConn rebinds placeholders in every query it is given. The remaining
+// driver interfaces are those of the embedded connection.
+type pgConn struct {
+	*stdlib.Conn
+}
+
+func (c pgConn) Prepare(query string) (driver.Stmt, error) {
+	return c.Conn.Prepare(rebind(query))
+}
+
+func (c pgConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	return c.Conn.PrepareContext(ctx, rebind(query))
+}
+
+func (c pgConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return c.Conn.ExecContext(ctx, rebind(query), args)
+}
+
+func (c pgConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return c.Conn.QueryContext(ctx, rebind(query), args)
+}
+
+// rebind replaces each ? placeholder in query with $1, $2, ... in order.
+// A ? inside a quoted string, a quoted identifier or a -- comment is left
+// alone.
+func rebind(query string) string {
+	if !strings.Contains(query, "?") {
+		return query
+	}
+	var b strings.Builder
+	b.Grow(len(query) + 8)
+	n := 0
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case c == '\'' || c == '"':
+			end := strings.IndexByte(query[i+1:], c)
+			if end < 0 {
+				b.WriteString(query[i:])
+				return b.String()
+			}
+			b.WriteString(query[i : i+end+2])
+			i += end + 1
+		case c == '-' && strings.HasPrefix(query[i:], "--"):
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				b.WriteString(query[i:])
+				return b.String()
+			}
+			b.WriteString(query[i : i+end])
+			i += end - 1
+		case c == '?':
+			n++
+			b.WriteByte('$')
+			b.WriteString(strconv.Itoa(n))
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// postgresMigrations is the ordered list of PostgreSQL schema changes. The
+// first creates the schema the SQLite migrations had reached when
+// PostgreSQL support was added; later changes are added to both lists.
+var postgresMigrations = []migration{
+	{name: "initial schema", up: migratePostgresV1, down: rollbackPostgresV1},
+	{name: "messaging foreign keys", up: migratePostgresV2, down: rollbackPostgresV2},
+	{name: "encryption at rest", up: migratePostgresV3, down: rollbackPostgresV3},
+	{name: "server secrets", up: migratePostgresV4, down: rollbackPostgresV4},
+	{name: "commit membership changes", up: migratePostgresV5, down: rollbackPostgresV5},
+	{name: "indexed member and recipient IDs", up: migratePostgresV6, down: rollbackPostgresV6},
+}
+
+// migratePostgresV1 creates the schema of SQLite migrations 1 to 11.
+// Booleans are BOOLEAN rather than 0/1 integers, and the text columns that
+// results are ordered by use the "C" collation so that they sort bytewise,
+// as in SQLite.
+func migratePostgresV1(tx *sql.Tx) error {
+	stmts := []string{
+		`CREATE TABLE "user" (
+			id              TEXT PRIMARY KEY,
+			username        TEXT COLLATE "C" NOT NULL UNIQUE,
+			username_folded TEXT NOT NULL DEFAULT '',
+			display_name    TEXT NOT NULL,
+			role            TEXT NOT NULL DEFAULT 'member',
+			enabled         BOOLEAN NOT NULL DEFAULT TRUE,
+			created_at      BIGINT NOT NULL,
+			updated_at      BIGINT NOT NULL
+		)`,
+		`CREATE UNIQUE INDEX idx_user_username_folded ON "user" (username_folded) WHERE username_folded != ''`,
+
+		`CREATE TABLE credential (
+			id                 TEXT PRIMARY KEY,
+			user_id            TEXT NOT NULL,
+			credential_id      BYTEA NOT NULL,
+			public_key         BYTEA NOT NULL,
+			sign_count         BIGINT NOT NULL DEFAULT 0,
+			created_at         BIGINT NOT NULL,
+			last_used_at       BIGINT,
+			aaguid             BYTEA,
+			attestation_format TEXT NOT NULL DEFAULT '',
+			FOREIGN KEY (user_id) REFERENCES "user" (id) ON DELETE CASCADE
+		)`,
+		`CREATE INDEX idx_credential_user_id ON credential (user_id)`,
+		`CREATE UNIQUE INDEX idx_credential_credential_id ON credential (credential_id)`,
+
+		`CREATE TABLE session (
+			id            TEXT PRIMARY KEY,
+			user_id       TEXT NOT NULL,
+			credential_id TEXT,
+			token_hash    BYTEA NOT NULL,
+			created_at    BIGINT NOT NULL,
+			expires_at    BIGINT NOT NULL,
+			last_seen_at  BIGINT NOT NULL,
+			FOREIGN KEY (user_id) REFERENCES "user" (id) ON DELETE CASCADE,
+			FOREIGN KEY (credential_id) REFERENCES credential (id) ON DELETE SET NULL
+		)`,
+		`CREATE INDEX idx_session_user_id ON session (user_id)`,
+		`CREATE UNIQUE INDEX idx_session_token_hash ON session (token_hash)`,
+		`CREATE INDEX idx_session_expires_at ON session (expires_at)`,
+
+		`CREATE TABLE challenge (
+			challenge_id   TEXT PRIMARY KEY,
+			challenge_data BYTEA NOT NULL,
+			username       TEXT,
+			challenge_type TEXT NOT NULL,
+			client_ip      TEXT NOT NULL DEFAULT '',
+			conn_id        TEXT NOT NULL DEFAULT '',
+			created_at     BIGINT NOT NULL,
+			expires_at     BIGINT NOT NULL
+		)`,
+		`CREATE INDEX idx_challenge_expires_at ON challenge (expires_at)`,
+		`CREATE INDEX idx_challenge_client_ip ON challenge (client_ip, expires_at)`,
+		`CREATE INDEX idx_challenge_conn_id ON challenge (conn_id, expires_at)`,
+
+		`CREATE TABLE recovery_code (
+			id         TEXT PRIMARY KEY,
+			user_id    TEXT NOT NULL,
+			code_hash  BYTEA NOT NULL,
+			created_at BIGINT NOT NULL,
+			used_at    BIGINT,
+			FOREIGN KEY (user_id) REFERENCES "user" (id) ON DELETE CASCADE
+		)`,
+		`CREATE INDEX idx_recovery_code_user_id ON recovery_code (user_id)`,
+		`CREATE UNIQUE INDEX idx_recovery_code_hash ON recovery_code (code_hash)`,
+
+		`CREATE TABLE audit_event (
+			id         TEXT COLLATE "C" PRIMARY KEY,
+			event_type TEXT NOT NULL,
+			user_id    TEXT,
+			detail     TEXT NOT NULL DEFAULT '',
+			created_at BIGINT NOT NULL
+		)`,
+		`CREATE INDEX idx_audit_event_created_at ON audit_event (created_at)`,
+		`CREATE INDEX idx_audit_event_user_id ON audit_event (user_id)`,
+
+		`CREATE TABLE messages (
+			id               TEXT COLLATE "C" PRIMARY KEY,
+			group_id         TEXT NOT NULL,
+			sender_id        TEXT NOT NULL,
+			server_timestamp BIGINT NOT NULL,
+			payload          BYTEA NOT NULL,
+			payload_size     INTEGER NOT NULL,
+			message_type     INTEGER NOT NULL DEFAULT 0,
+			epoch            BIGINT NOT NULL DEFAULT 0,
+			created_at       BIGINT NOT NULL
+		)`,
+		`CREATE INDEX idx_messages_group_timestamp ON messages (group_id, server_timestamp)`,
+		`CREATE INDEX idx_messages_sender ON messages (sender_id, server_timestamp)`,
+		`CREATE INDEX idx_messages_created_at ON messages (created_at)`,
+		`CREATE INDEX idx_messages_group_size ON messages (group_id, payload_size)`,
+
+		`CREATE TABLE delivery_status (
+			message_id   TEXT COLLATE "C" NOT NULL,
+			recipient_id TEXT NOT NULL,
+			status       INTEGER NOT NULL DEFAULT 0,
+			delivered_at BIGINT,
+			read_at      BIGINT,
+			PRIMARY KEY (message_id, recipient_id),
+			FOREIGN KEY (message_id) REFERENCES messages (id) ON DELETE CASCADE
+		)`,
+		`CREATE INDEX idx_delivery_pending ON delivery_status (recipient_id, status)`,
+
+		`CREATE TABLE group_members (
+			group_id            TEXT NOT NULL,
+			user_id             TEXT COLLATE "C" NOT NULL,
+			role                TEXT NOT NULL DEFAULT 'member',
+			joined_at           BIGINT NOT NULL,
+			last_update_at      BIGINT,
+			last_update_epoch   BIGINT,
+			update_requested_at BIGINT,
+			PRIMARY KEY (group_id, user_id)
+		)`,
+		`CREATE INDEX idx_group_members_user ON group_members (user_id)`,
+
+		`CREATE TABLE conversations (
+			id                TEXT PRIMARY KEY,
+			title             TEXT NOT NULL DEFAULT '',
+			created_by        TEXT NOT NULL,
+			created_at        BIGINT NOT NULL,
+			sealed_sender     BOOLEAN NOT NULL DEFAULT FALSE,
+			member_generation BIGINT NOT NULL DEFAULT 0
+		)`,
+
+		`CREATE TABLE key_packages (
+			id               TEXT PRIMARY KEY,
+			user_id          TEXT NOT NULL,
+			key_package_data BYTEA NOT NULL,
+			created_at       BIGINT NOT NULL,
+			expires_at       BIGINT NOT NULL,
+			last_resort      BOOLEAN NOT NULL DEFAULT FALSE,
+			cipher_suite     INTEGER NOT NULL DEFAULT 0,
+			credential_type  INTEGER NOT NULL DEFAULT 0
+		)`,
+		`CREATE INDEX idx_key_packages_user ON key_packages (user_id)`,
+		`CREATE INDEX idx_key_packages_expires ON key_packages (expires_at)`,
+		`CREATE UNIQUE INDEX idx_key_packages_last_resort ON key_packages (user_id, cipher_suite) WHERE last_resort`,
+		`CREATE INDEX idx_key_packages_user_suite ON key_packages (user_id, cipher_suite)`,
+
+		`CREATE TABLE mls_group_state (
+			conversation_id TEXT PRIMARY KEY,
+			group_id        BYTEA NOT NULL,
+			epoch           BIGINT NOT NULL,
+			group_info      BYTEA NOT NULL,
+			updated_by      TEXT NOT NULL,
+			updated_at      BIGINT NOT NULL,
+			FOREIGN KEY (conversation_id) REFERENCES conversations (id) ON DELETE CASCADE
+		)`,
+		`CREATE UNIQUE INDEX idx_mls_group_state_group_id ON mls_group_state (group_id)`,
+	}
+
+	for _, stmt := range stmts {
+		if _, err := tx.Exec(stmt); err != nil {
+			return fmt.Errorf("exec %q: %w", stmt[:min(len(stmt), 60)], err)
+		}
+	}
+	return nil
+}
+
+// rollbackPostgresV1 drops the whole schema.
+func rollbackPostgresV1(tx *sql.Tx) error {
+	return execStmts(tx, []string{
+		`DROP TABLE mls_group_state`,
+		`DROP TABLE key_packages`,
+		`DROP TABLE conversations`,
+		`DROP TABLE group_members`,
+		`DROP TABLE delivery_status`,
+		`DROP TABLE messages`,
+		`DROP TABLE audit_event`,
+		`DROP TABLE recovery_code`,
+		`DROP TABLE challenge`,
+		`DROP TABLE session`,
+		`DROP TABLE credential`,
+		`DROP TABLE "user"`,
+	})
+}
+
+// migratePostgresV2 adds the foreign keys of SQLite migration 12, after
+// deleting the rows that would violate them.
+func migratePostgresV2(tx *sql.Tx) error {
+	return execStmts(tx, []string{
+		`DELETE FROM group_members WHERE user_id NOT IN (SELECT id FROM "user")
+		 OR group_id NOT IN (SELECT id FROM conversations)`,
+		`DELETE FROM key_packages WHERE user_id NOT IN (SELECT id FROM "user")`,
+		`DELETE FROM messages WHERE group_id NOT IN (SELECT id FROM conversations)`,
+		`DELETE FROM delivery_status WHERE recipient_id NOT IN (SELECT id FROM "user")
+		 OR message_id NOT IN (SELECT id FROM messages)`,
+		`ALTER TABLE group_members ADD CONSTRAINT group_members_group_id_fkey
+		 FOREIGN KEY (group_id) REFERENCES conversations (id) ON DELETE CASCADE`,
+		`ALTER TABLE group_members ADD CONSTRAINT group_members_user_id_fkey
+		 FOREIGN KEY (user_id) REFERENCES "user" (id) ON DELETE CASCADE`,
+		`ALTER TABLE key_packages ADD CONSTRAINT key_packages_user_id_fkey
+		 FOREIGN KEY (user_id) REFERENCES "user" (id) ON DELETE CASCADE`,
+		`ALTER TABLE messages ADD CONSTRAINT messages_group_id_fkey
+		 FOREIGN KEY (group_id) REFERENCES conversations (id) ON DELETE CASCADE`,
+		`ALTER TABLE delivery_status ADD CONSTRAINT delivery_status_recipient_id_fkey
+		 FOREIGN KEY (recipient_id) REFERENCES "user" (id) ON DELETE CASCADE`,
+	})
+}
+
+// rollbackPostgresV2 drops the foreign keys added by migratePostgresV2.
+func rollbackPostgresV2(tx *sql.Tx) error {
+	return execStmts(tx, []string{
+		`ALTER TABLE delivery_status DROP CONSTRAINT delivery_status_recipient_id_fkey`,
+		`ALTER TABLE messages DROP CONSTRAINT messages_group_id_fkey`,
+		`ALTER TABLE key_packages DROP CONSTRAINT key_packages_user_id_fkey`,
+		`ALTER TABLE group_members DROP CONSTRAINT group_members_user_id_fkey`,
+		`ALTER TABLE group_members DROP CONSTRAINT group_members_group_id_fkey`,
+	})
+}
+
+// migratePostgresV3 adds the encryption_key table of SQLite migration 13.
+func migratePostgresV3(tx *sql.Tx) error {
+	return execStmts(tx, []string{
+		`CREATE TABLE encryption_key (
+			id         INTEGER PRIMARY KEY CHECK (id = 1),
+			data_key   BYTEA NOT NULL,
+			index_key  BYTEA NOT NULL,
+			kdf_salt   BYTEA,
+			created_at BIGINT NOT NULL,
+			rotated_at BIGINT NOT NULL
+		)`,
+	})
+}
+
+// rollbackPostgresV3 drops the encryption_key table, as rollbackV13 does.
+func rollbackPostgresV3(tx *sql.Tx) error {
+	return rollbackV13(tx)
+}
+
+// migratePostgresV4 adds server_secret, as migrateV14 does.
+func migratePostgresV4(tx *sql.Tx) error {
+	return execStmts(tx, []string{
+		`CREATE TABLE server_secret (
+			name       TEXT PRIMARY KEY,
+			value      BYTEA NOT NULL,
+			created_at BIGINT NOT NULL
+		)`,
+	})
+}
+
+// rollbackPostgresV4 drops server_secret, as rollbackV14 does.
+func rollbackPostgresV4(tx *sql.Tx) error {
+	return rollbackV14(tx)
+}
+
+// migratePostgresV5 adds messages.membership_change, as migrateV15 does.
+func migratePostgresV5(tx *sql.Tx) error {
+	return execStmts(tx, []string{
+		`ALTER TABLE messages ADD COLUMN membership_change BYTEA`,
+	})
+}
+
+// rollbackPostgresV5 drops messages.membership_change, as rollbackV15 does.
+func rollbackPostgresV5(tx *sql.Tx) error {
+	return rollbackV15(tx)
+}
+
+// migratePostgresV6 adds group_members.user_index and renames
+// delivery_status.recipient_id to recipient_index, as migrateV16 does.
+func migratePostgresV6(tx *sql.Tx) error {
+	return execStmts(tx, []string{
+		`ALTER TABLE group_members DROP CONSTRAINT group_members_user_id_fkey`,
+		`ALTER TABLE group_members ADD COLUMN user_index TEXT COLLATE "C"`,
+		`UPDATE group_members SET user_index = user_id`,
+		`ALTER TABLE group_members ALTER COLUMN user_index SET NOT NULL`,
+		`ALTER TABLE group_members DROP CONSTRAINT group_members_pkey`,
+		`ALTER TABLE group_members ADD PRIMARY KEY (group_id, user_index)`,
+		`DROP INDEX idx_group_members_user`,
+		`CREATE INDEX idx_group_members_user ON group_members (user_index)`,
+		`ALTER TABLE delivery_status DROP CONSTRAINT delivery_status_recipient_id_fkey`,
+		`ALTER TABLE delivery_status RENAME COLUMN recipient_id TO recipient_index`,
+		`ALTER TABLE encryption_key ADD COLUMN identities_indexed BOOLEAN NOT NULL DEFAULT FALSE`,
+	})
+}
+
+// rollbackPostgresV6 restores the user IDs of memberships and deliveries
+// and their foreign keys, as rollbackV16 does.
+func rollbackPostgresV6(tx *sql.Tx) error {
+	if err := checkNotEncrypted(tx, "roll back indexed member and recipient IDs"); err != nil {
+		return err
+	}
+	return execStmts(tx, []string{
+		`DELETE FROM group_members WHERE user_id NOT IN (SELECT id FROM "user")`,
+		`DELETE FROM delivery_status WHERE recipient_index NOT IN (SELECT id FROM "user")`,
+		`ALTER TABLE encryption_key DROP COLUMN identities_indexed`,
+		`ALTER TABLE delivery_status RENAME COLUMN recipient_index TO recipient_id`,
+		`ALTER TABLE delivery_status ADD CONSTRAINT delivery_status_recipient_id_fkey
+		 FOREIGN KEY (recipient_id) REFERENCES "user" (id) ON DELETE CASCADE`,
+		`DROP INDEX idx_group_members_user`,
+		`CREATE INDEX idx_group_members_user ON group_members (user_id)`,
+		`ALTER TABLE group_members DROP CONSTRAINT group_members_pkey`,
+		`ALTER TABLE group_members ADD PRIMARY KEY (group_id, user_id)`,
+		`ALTER TABLE group_members DROP COLUMN user_index`,
+		`ALTER TABLE group_members ADD CONSTRAINT group_members_user_id_fkey
+		 FOREIGN KEY (user_id) REFERENCES "user" (id) ON DELETE CASCADE`,
+	})
+}
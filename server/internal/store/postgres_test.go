@@ -0,0 +1,50 @@
+package store
+
+import "testing"
+
+func TestRebind(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{
+			name:  "no placeholders",
+			query: `SELECT COUNT(*) FROM "user"`,
+			want:  `SELECT COUNT(*) FROM "user"`,
+		},
+		{
+			name:  "placeholders in order",
+			query: `UPDATE session SET last_seen_at = ? WHERE id = ? AND token_hash = ?`,
+			want:  `UPDATE session SET last_seen_at = $1 WHERE id = $2 AND token_hash = $3`,
+		},
+		{
+			name:  "string literals are left alone",
+			query: `SELECT '?', 'it''s ?' FROM t WHERE a = ? AND b != ''`,
+			want:  `SELECT '?', 'it''s ?' FROM t WHERE a = $1 AND b != ''`,
+		},
+		{
+			name:  "quoted identifiers are left alone",
+			query: `SELECT "a?b" FROM t WHERE c = ?`,
+			want:  `SELECT "a?b" FROM t WHERE c = $1`,
+		},
+		{
+			name:  "comments are left alone",
+			query: "SELECT a -- why?\nFROM t WHERE b = ?",
+			want:  "SELECT a -- why?\nFROM t WHERE b = $1",
+		},
+		{
+			name:  "unterminated string",
+			query: `SELECT ? WHERE a = 'x?`,
+			want:  `SELECT $1 WHERE a = 'x?`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rebind(tt.query); got != tt.want {
+				t.Errorf("rebind(%q) = %q, want %q", tt.query, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// RecoveryCode is a single-use account recovery code.
+// The raw code is never stored; only its SHA-256 hash.
+type RecoveryCode struct {
+	ID        string
+	UserID    string
+	CodeHash  []byte
+	CreatedAt int64
+	UsedAt    *int64 // nil until redeemed
+}
+
+// ReplaceRecoveryCodes deletes all existing recovery codes for a user and
+// stores the given code hashes in their place.
+func (s *Store) ReplaceRecoveryCodes(ctx context.Context, userID string, codeHashes [][]byte) error {
+	now := time.Now().Unix()
+	return s.InTx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM recovery_code WHERE user_id = ?`, userID); err != nil {
+			return fmt.Errorf("delete recovery codes: %w", err)
+		}
+		for _, h := range codeHashes {
+			_, err := tx.ExecContext(ctx,
+				`INSERT INTO recovery_code (id, user_id, code_hash, created_at) VALUES (?, ?, ?, ?)`,
+				NewULID(), userID, h, now,
+			)
+			if err != nil {
+				if isUniqueConstraintError(err) {
+					return fmt.Errorf("recovery code: %w", ErrConflict)
+				}
+				return fmt.Errorf("insert recovery code: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// RedeemRecoveryCode marks an unused recovery code belonging to the user as
+// used. Returns ErrNotFound if no unused code matches.
+func (s *Store) RedeemRecoveryCode(ctx context.Context, userID string, codeHash []byte) error {
+	now := time.Now().Unix()
+	result, err := s.db.ExecContext(ctx,
+		`UPDATE recovery_code SET used_at = ?
+		 WHERE user_id = ? AND code_hash = ? AND used_at IS NULL`,
+		now, userID, codeHash,
+	)
+	if err != nil {
+		return fmt.Errorf("redeem recovery code: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("rows affected: %w", err)
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// CountRecoveryCodes returns the number of unused recovery codes for a user.
+func (s *Store) CountRecoveryCodes(ctx context.Context, userID string) (int, error) {
+	var count int
+	err := s.readDB.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM recovery_code WHERE user_id = ? AND used_at IS NULL`, userID,
+	).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("count recovery codes: %w", err)
+	}
+	return count, nil
+}
+
+// RecoverAccount atomically replaces all of a user's credentials and sessions
+// with the given credential and session. Returns the number of sessions
+// revoked and credentials removed.
+func (s *Store) RecoverAccount(ctx context.Context, cred *Credential, sess *Session) (sessionsRevoked, credentialsRemoved int64, err error) {
+	err = s.InTx(ctx, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, `DELETE FROM session WHERE user_id = ?`, cred.UserID)
+		if err != nil {
+			return fmt.Errorf("delete sessions: %w", err)
+		}
+		if sessionsRevoked, err = result.RowsAffected(); err != nil {
+			return fmt.Errorf("rows affected: %w", err)
+		}
+
+		result, err = tx.ExecContext(ctx, `DELETE FROM credential WHERE user_id = ?`, cred.UserID)
+		if err != nil {
+			return fmt.Errorf("delete credentials: %w", err)
+		}
+		if credentialsRemoved, err = result.RowsAffected(); err != nil {
+			return fmt.Errorf("rows affected: %w", err)
+		}
+
+		_, err = tx.ExecContext(ctx,
+			`INSERT INTO credential (id, user_id, credential_id, public_key, sign_count, created_at, last_used_at, aaguid, attestation_format)
+			 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			cred.ID, cred.UserID, cred.CredentialID, cred.PublicKey, cred.SignCount, cred.CreatedAt, cred.LastUsedAt, cred.AAGUID, cred.AttestationFormat,
+		)
+		if err != nil {
+			if isUniqueConstraintError(err) {
+				return fmt.Errorf("credential: %w", ErrConflict)
+			}
+			return fmt.Errorf("insert credential: %w", err)
+		}
+
+		_, err = tx.ExecContext(ctx,
+			`INSERT INTO session (id, user_id, credential_id, token_hash, created_at, expires_at, last_seen_at)
+			 VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			sess.ID, sess.UserID, cred.ID, s.tokenIndex(sess.TokenHash), sess.CreatedAt, sess.ExpiresAt, sess.LastSeenAt,
+		)
+		if err != nil {
+			return fmt.Errorf("insert session: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	return sessionsRevoked, credentialsRemoved, nil
+}
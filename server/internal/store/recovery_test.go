@@ -0,0 +1,124 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestReplaceRecoveryCodes(t *testing.T) {
+	s := newTestStore(t)
+	setupUserForCredentialTests(t, s)
+	ctx := context.Background()
+
+	if err := s.ReplaceRecoveryCodes(ctx, "u1", [][]byte{[]byte("h1"), []byte("h2")}); err != nil {
+		t.Fatalf("ReplaceRecoveryCodes: %v", err)
+	}
+	count, err := s.CountRecoveryCodes(ctx, "u1")
+	if err != nil {
+		t.Fatalf("CountRecoveryCodes: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("count = %d, want 2", count)
+	}
+
+	// Replacing invalidates the previous set.
+	if err := s.ReplaceRecoveryCodes(ctx, "u1", [][]byte{[]byte("h3")}); err != nil {
+		t.Fatalf("ReplaceRecoveryCodes: %v", err)
+	}
+	count, _ = s.CountRecoveryCodes(ctx, "u1")
+	if count != 1 {
+		t.Errorf("count after replace = %d, want 1", count)
+	}
+	if err := s.RedeemRecoveryCode(ctx, "u1", []byte("h1")); !errors.Is(err, ErrNotFound) {
+		t.Errorf("redeem replaced code: error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestRedeemRecoveryCode(t *testing.T) {
+	tests := []struct {
+		name    string
+		userID  string
+		hash    []byte
+		redeems int
+		wantErr error
+	}{
+		{name: "valid code", userID: "u1", hash: []byte("h1"), redeems: 1},
+		{name: "unknown code", userID: "u1", hash: []byte("nope"), redeems: 1, wantErr: ErrNotFound},
+		{name: "wrong user", userID: "u2", hash: []byte("h1"), redeems: 1, wantErr: ErrNotFound},
+		{name: "already used", userID: "u1", hash: []byte("h1"), redeems: 2, wantErr: ErrNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestStore(t)
+			setupUserForCredentialTests(t, s)
+			ctx := context.Background()
+			if err := s.CreateUser(ctx, makeUser("u2", "bob")); err != nil {
+				t.Fatalf("CreateUser: %v", err)
+			}
+			if err := s.ReplaceRecoveryCodes(ctx, "u1", [][]byte{[]byte("h1")}); err != nil {
+				t.Fatalf("ReplaceRecoveryCodes: %v", err)
+			}
+
+			var err error
+			for i := 0; i < tt.redeems; i++ {
+				err = s.RedeemRecoveryCode(ctx, tt.userID, tt.hash)
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRecoverAccount(t *testing.T) {
+	s := newTestStore(t)
+	setupUserForCredentialTests(t, s)
+	ctx := context.Background()
+	now := time.Now().Unix()
+
+	for _, c := range []*Credential{
+		makeCredential("c1", "u1", []byte("cred-id-1")),
+		makeCredential("c2", "u1", []byte("cred-id-2")),
+	} {
+		if err := s.CreateCredential(ctx, c); err != nil {
+			t.Fatalf("CreateCredential: %v", err)
+		}
+	}
+	if err := s.CreateSession(ctx, makeSession("s1", "u1", hashToken("old"), now+3600)); err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+
+	newCred := makeCredential("c3", "u1", []byte("cred-id-3"))
+	newSess := makeSession("s2", "u1", hashToken("new"), now+3600)
+	sessions, creds, err := s.RecoverAccount(ctx, newCred, newSess)
+	if err != nil {
+		t.Fatalf("RecoverAccount: %v", err)
+	}
+	if sessions != 1 {
+		t.Errorf("sessions revoked = %d, want 1", sessions)
+	}
+	if creds != 2 {
+		t.Errorf("credentials removed = %d, want 2", creds)
+	}
+
+	if _, err := s.GetSessionByTokenHash(ctx, hashToken("old")); !errors.Is(err, ErrNotFound) {
+		t.Errorf("old session: error = %v, want ErrNotFound", err)
+	}
+	got, err := s.GetSessionByTokenHash(ctx, hashToken("new"))
+	if err != nil {
+		t.Fatalf("new session: %v", err)
+	}
+	if got.CredentialID != "c3" {
+		t.Errorf("session credential = %q, want %q", got.CredentialID, "c3")
+	}
+	remaining, err := s.GetCredentialsByUserID(ctx, "u1")
+	if err != nil {
+		t.Fatalf("GetCredentialsByUserID: %v", err)
+	}
+	if len(remaining) != 1 || remaining[0].ID != "c3" {
+		t.Errorf("credentials = %v, want only c3", remaining)
+	}
+}
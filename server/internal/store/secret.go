@@ -0,0 +1,33 @@
+package store
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"time"
+)
+
+// ServerSecret returns the random secret stored under name, generating one
+// of size bytes the first time it is asked for. Secrets outlive restarts
+// and are shared by servers on the same database, so that what is derived
+// from them stays valid. They are sealed when encryption at rest is on.
+func (s *Store) ServerSecret(ctx context.Context, name string, size int) ([]byte, error) {
+	secret := make([]byte, size)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("generate secret %s: %w", name, err)
+	}
+	// Whichever server inserts first wins; the others read its secret.
+	if _, err := s.db.ExecContext(ctx,
+		`INSERT INTO server_secret (name, value, created_at) VALUES (?, ?, ?)
+		 ON CONFLICT (name) DO NOTHING`,
+		name, s.sealBytes("server_secret.value", secret), time.Now().Unix(),
+	); err != nil {
+		return nil, fmt.Errorf("store secret %s: %w", name, err)
+	}
+
+	var sealed []byte
+	if err := s.db.QueryRowContext(ctx, `SELECT value FROM server_secret WHERE name = ?`, name).Scan(&sealed); err != nil {
+		return nil, fmt.Errorf("get secret %s: %w", name, err)
+	}
+	return s.openBytes("server_secret.value", sealed)
+}
@@ -8,7 +8,9 @@ import (
 )
 
 // Session represents an active user session.
-// The raw session token is never stored; only its SHA-256 hash.
+// The raw session token is never stored; only its SHA-256 hash. With
+// encryption at rest, the stored value is a keyed hash of that hash, and
+// GetSessionByID returns it in TokenHash.
 type Session struct {
 	ID           string
 	UserID       string
@@ -29,7 +31,7 @@ func (s *Store) CreateSession(ctx context.Context, sess *Session) error {
 	_, err := s.db.ExecContext(ctx,
 		`INSERT INTO session (id, user_id, credential_id, token_hash, created_at, expires_at, last_seen_at)
 		 VALUES (?, ?, ?, ?, ?, ?, ?)`,
-		sess.ID, sess.UserID, credID, sess.TokenHash, sess.CreatedAt, sess.ExpiresAt, sess.LastSeenAt,
+		sess.ID, sess.UserID, credID, s.tokenIndex(sess.TokenHash), sess.CreatedAt, sess.ExpiresAt, sess.LastSeenAt,
 	)
 	if err != nil {
 		return fmt.Errorf("insert session: %w", err)
@@ -41,22 +43,88 @@ func (s *Store) CreateSession(ctx context.Context, sess *Session) error {
 func (s *Store) GetSessionByTokenHash(ctx context.Context, tokenHash []byte) (*Session, error) {
 	sess := &Session{}
 	var credID sql.NullString
-	err := s.db.QueryRowContext(ctx,
+	stmt, err := s.readStmt(ctx,
 		`SELECT id, user_id, credential_id, token_hash, created_at, expires_at, last_seen_at
-		 FROM session WHERE token_hash = ?`, tokenHash,
-	).Scan(&sess.ID, &sess.UserID, &credID, &sess.TokenHash, &sess.CreatedAt, &sess.ExpiresAt, &sess.LastSeenAt)
+		 FROM session WHERE token_hash = ?`)
+	if err != nil {
+		return nil, err
+	}
+	err = stmt.QueryRowContext(ctx, s.tokenIndex(tokenHash)).Scan(&sess.ID, &sess.UserID, &credID, &sess.TokenHash, &sess.CreatedAt, &sess.ExpiresAt, &sess.LastSeenAt)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, ErrNotFound
 		}
 		return nil, fmt.Errorf("get session by token hash: %w", err)
 	}
+	sess.TokenHash = tokenHash
+	if credID.Valid {
+		sess.CredentialID = credID.String
+	}
+	return sess, nil
+}
+
+// GetSessionByID returns a session by its ID. Returns ErrNotFound if not found.
+func (s *Store) GetSessionByID(ctx context.Context, id string) (*Session, error) {
+	sess := &Session{}
+	var credID sql.NullString
+	err := s.readDB.QueryRowContext(ctx,
+		`SELECT id, user_id, credential_id, token_hash, created_at, expires_at, last_seen_at
+		 FROM session WHERE id = ?`, id,
+	).Scan(&sess.ID, &sess.UserID, &credID, &sess.TokenHash, &sess.CreatedAt, &sess.ExpiresAt, &sess.LastSeenAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("get session by id: %w", err)
+	}
 	if credID.Valid {
 		sess.CredentialID = credID.String
 	}
 	return sess, nil
 }
 
+// ExtendSession sets a session's last_seen_at and expires_at timestamps.
+// Returns ErrNotFound if the session does not exist.
+func (s *Store) ExtendSession(ctx context.Context, id string, lastSeenAt, expiresAt int64) error {
+	result, err := s.db.ExecContext(ctx,
+		`UPDATE session SET last_seen_at = ?, expires_at = ? WHERE id = ?`, lastSeenAt, expiresAt, id,
+	)
+	if err != nil {
+		return fmt.Errorf("extend session: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("rows affected: %w", err)
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// RotateSessionToken replaces a session's token hash and extends it. The
+// update only applies if the stored hash still equals oldHash, so a token can
+// be rotated at most once. Returns ErrNotFound if the session does not exist
+// or was already rotated.
+func (s *Store) RotateSessionToken(ctx context.Context, id string, oldHash, newHash []byte, lastSeenAt, expiresAt int64) error {
+	result, err := s.db.ExecContext(ctx,
+		`UPDATE session SET token_hash = ?, last_seen_at = ?, expires_at = ?
+		 WHERE id = ? AND token_hash = ?`,
+		s.tokenIndex(newHash), lastSeenAt, expiresAt, id, s.tokenIndex(oldHash),
+	)
+	if err != nil {
+		return fmt.Errorf("rotate session token: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("rows affected: %w", err)
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
 // UpdateSessionLastUsed updates the last_seen_at timestamp for a session.
 // Returns ErrNotFound if the session does not exist.
 func (s *Store) UpdateSessionLastUsed(ctx context.Context, id string) error {
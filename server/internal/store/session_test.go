@@ -152,6 +152,91 @@ func TestUpdateSessionLastUsed(t *testing.T) {
 	}
 }
 
+func TestExtendSession(t *testing.T) {
+	s := newTestStore(t)
+	setupUserForSessionTests(t, s)
+	ctx := context.Background()
+
+	sess := makeSession("s1", "u1", hashToken("token-1"), time.Now().Add(time.Hour).Unix())
+	if err := s.CreateSession(ctx, sess); err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+
+	if err := s.ExtendSession(ctx, "s1", 1000, 2000); err != nil {
+		t.Fatalf("ExtendSession: %v", err)
+	}
+	got, err := s.GetSessionByID(ctx, "s1")
+	if err != nil {
+		t.Fatalf("GetSessionByID: %v", err)
+	}
+	if got.LastSeenAt != 1000 || got.ExpiresAt != 2000 {
+		t.Errorf("LastSeenAt, ExpiresAt = %d, %d, want 1000, 2000", got.LastSeenAt, got.ExpiresAt)
+	}
+
+	if err := s.ExtendSession(ctx, "nonexistent", 1000, 2000); !errors.Is(err, ErrNotFound) {
+		t.Errorf("extend missing: error = %v, want ErrNotFound", err)
+	}
+	if _, err := s.GetSessionByID(ctx, "nonexistent"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("get missing: error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestRotateSessionToken(t *testing.T) {
+	tests := []struct {
+		name    string
+		oldHash []byte
+		wantErr error
+	}{
+		{
+			name:    "success",
+			oldHash: hashToken("token-1"),
+		},
+		{
+			name:    "stale hash",
+			oldHash: hashToken("token-0"),
+			wantErr: ErrNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestStore(t)
+			setupUserForSessionTests(t, s)
+			ctx := context.Background()
+
+			sess := makeSession("s1", "u1", hashToken("token-1"), time.Now().Add(time.Hour).Unix())
+			if err := s.CreateSession(ctx, sess); err != nil {
+				t.Fatalf("CreateSession: %v", err)
+			}
+
+			err := s.RotateSessionToken(ctx, "s1", tt.oldHash, hashToken("token-2"), 1000, 2000)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("error = %v, want %v", err, tt.wantErr)
+				}
+				if _, err := s.GetSessionByTokenHash(ctx, hashToken("token-1")); err != nil {
+					t.Errorf("original token lookup: %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if _, err := s.GetSessionByTokenHash(ctx, hashToken("token-1")); !errors.Is(err, ErrNotFound) {
+				t.Errorf("old token lookup: error = %v, want ErrNotFound", err)
+			}
+			got, err := s.GetSessionByTokenHash(ctx, hashToken("token-2"))
+			if err != nil {
+				t.Fatalf("new token lookup: %v", err)
+			}
+			if got.ID != "s1" || got.ExpiresAt != 2000 {
+				t.Errorf("got ID=%q ExpiresAt=%d, want s1, 2000", got.ID, got.ExpiresAt)
+			}
+		})
+	}
+}
+
 func TestDeleteSession(t *testing.T) {
 	tests := []struct {
 		name    string
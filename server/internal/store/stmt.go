@@ -0,0 +1,122 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// stmtCache holds the prepared statements of one connection pool, keyed by
+// query. Statements are prepared on first use rather than at open, since
+// the tables they refer to may not exist until migrations have run. Only
+// hot paths use it; everything else runs its query directly.
+type stmtCache struct {
+	db    *sql.DB
+	mu    sync.Mutex
+	stmts map[string]*sql.Stmt
+}
+
+func newStmtCache(db *sql.DB) *stmtCache {
+	return &stmtCache{db: db, stmts: make(map[string]*sql.Stmt)}
+}
+
+// get returns the prepared statement for query, preparing it if needed.
+func (c *stmtCache) get(ctx context.Context, query string) (*sql.Stmt, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if stmt, ok := c.stmts[query]; ok {
+		return stmt, nil
+	}
+	stmt, err := c.db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("prepare statement: %w", err)
+	}
+	c.stmts[query] = stmt
+	return stmt, nil
+}
+
+// lookup returns the prepared statement for query if there is one.
+func (c *stmtCache) lookup(query string) (*sql.Stmt, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stmt, ok := c.stmts[query]
+	return stmt, ok
+}
+
+// close closes every prepared statement.
+func (c *stmtCache) close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var errs []error
+	for query, stmt := range c.stmts {
+		errs = append(errs, stmt.Close())
+		delete(c.stmts, query)
+	}
+	return errors.Join(errs...)
+}
+
+// prepareWrites prepares queries on the writer ahead of a transaction
+// that uses them. Inside the transaction the single SQLite writer
+// connection is taken, so a statement could not be prepared for the cache.
+func (s *Store) prepareWrites(ctx context.Context, queries ...string) error {
+	for _, query := range queries {
+		if _, err := s.writeStmts.get(ctx, query); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeStmt returns query prepared on the writer, bound to tx if it is not
+// nil. A query used in a transaction that was not prepared beforehand with
+// prepareWrites is prepared for that transaction only.
+func (s *Store) writeStmt(ctx context.Context, tx *sql.Tx, query string) (*sql.Stmt, error) {
+	if tx == nil {
+		return s.writeStmts.get(ctx, query)
+	}
+	if stmt, ok := s.writeStmts.lookup(query); ok {
+		return tx.StmtContext(ctx, stmt), nil
+	}
+	stmt, err := tx.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("prepare statement: %w", err)
+	}
+	return stmt, nil
+}
+
+// readStmt returns query prepared on the read pool.
+func (s *Store) readStmt(ctx context.Context, query string) (*sql.Stmt, error) {
+	return s.readStmts.get(ctx, query)
+}
+
+// maxInList is the most values a prepared IN list holds. Longer lists are
+// split into chunks of this size.
+const maxInList = 128
+
+// inListSize returns the size of the prepared IN list used for n values:
+// the next power of two, so that a handful of statements cover every
+// length. The unused slots are filled by repeating a value.
+func inListSize(n int) int {
+	size := 1
+	for size < n && size < maxInList {
+		size *= 2
+	}
+	return size
+}
+
+// inList returns a placeholder list of the given size, such as "?, ?, ?".
+func inList(size int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", size), ", ")
+}
+
+// padArgs appends values to args, repeating the last value to fill size
+// slots.
+func padArgs(args []any, values []string, size int) []any {
+	for i := 0; i < size; i++ {
+		args = append(args, values[min(i, len(values)-1)])
+	}
+	return args
+}
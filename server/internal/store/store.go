@@ -5,9 +5,11 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"slices"
 	"strings"
-	"time"
+	"sync/atomic"
 
+	"github.com/jackc/pgx/v5/pgconn"
 	_ "modernc.org/sqlite"
 )
 
@@ -15,50 +17,148 @@ import (
 var (
 	ErrNotFound = errors.New("not found")
 	ErrConflict = errors.New("already exists")
+	ErrStale    = errors.New("older than stored version")
 )
 
-// Store provides the data access layer over SQLite.
+// DefaultReadConns is the number of read-only connections opened when
+// Options.ReadConns is zero.
+const DefaultReadConns = 4
+
+// Options configures Open.
+type Options struct {
+	// ReadConns bounds the pool of read-only connections used for queries.
+	// Zero means DefaultReadConns.
+	ReadConns int
+
+	// SkipMigrations opens the database without applying pending
+	// migrations, so that the caller can inspect the schema or migrate it
+	// with MigrateTo.
+	SkipMigrations bool
+
+	// ManualCheckpoints turns off SQLite's automatic checkpoints, so that
+	// committed pages stay in the write-ahead log until Checkpoint copies
+	// them into the database file. A Replicator needs this to read every
+	// change from the log.
+	ManualCheckpoints bool
+}
+
+// Dialect names the SQL database behind a Store.
+type Dialect string
+
+// Supported dialects.
+const (
+	SQLite   Dialect = "sqlite"
+	Postgres Dialect = "postgres"
+)
+
+// Store provides the data access layer over SQLite or PostgreSQL. Queries
+// are written once, with ? placeholders, in the SQL both understand.
+//
+// With SQLite, writes go through a single connection, since SQLite allows
+// one writer at a time, and reads through a separate pool of read-only
+// connections so that they do not queue behind writes. In WAL mode readers
+// see every committed write. With PostgreSQL both go through one pool.
 type Store struct {
-	db *sql.DB
+	dialect Dialect
+	path    string       // the SQLite database path
+	db      *sql.DB      // the single writer connection, or the pool for PostgreSQL
+	readDB  *sql.DB      // read-only connections; the writer for in-memory databases
+	cipher  *fieldCipher // nil unless encryption at rest is unlocked
+
+	// exclusive is set while this store holds the database lock with
+	// LockExclusive.
+	exclusive atomic.Bool
+
+	// Prepared statements for hot paths, on db and readDB. They are the
+	// same cache when db and readDB are the same pool.
+	writeStmts *stmtCache
+	readStmts  *stmtCache
 }
 
-// New opens a SQLite database at the given path and runs migrations.
+// New opens a SQLite database at the given path with default options and
+// runs migrations.
 func New(dbPath string) (*Store, error) {
-	db, err := sql.Open("sqlite", dbPath)
+	return Open(dbPath, Options{})
+}
+
+// Open opens a SQLite database at the given path and runs migrations.
+func Open(dbPath string, opts Options) (*Store, error) {
+	readConns := opts.ReadConns
+	if readConns <= 0 {
+		readConns = DefaultReadConns
+	}
+
+	// PRAGMAs are set in the DSN so that every connection gets them, not
+	// just the first one.
+	pragmas := writerPragmas
+	if opts.ManualCheckpoints {
+		pragmas = append(slices.Clip(pragmas), "wal_autocheckpoint(0)")
+	}
+	db, err := sql.Open("sqlite", dsn(dbPath, pragmas, "_txlock=immediate"))
 	if err != nil {
 		return nil, fmt.Errorf("open database: %w", err)
 	}
-
-	// Single connection ensures PRAGMAs persist and avoids
-	// SQLite write contention issues.
 	db.SetMaxOpenConns(1)
 
-	if err := configurePragmas(db); err != nil {
+	// Check that the database can be opened and configured.
+	if err := db.Ping(); err != nil {
 		db.Close()
 		return nil, fmt.Errorf("configure database: %w", err)
 	}
 
-	s := &Store{db: db}
-	if err := s.migrate(); err != nil {
-		db.Close()
-		return nil, fmt.Errorf("run migrations: %w", err)
+	s := &Store{dialect: SQLite, path: dbPath, db: db, readDB: db, writeStmts: newStmtCache(db)}
+	s.readStmts = s.writeStmts
+	if !opts.SkipMigrations {
+		if err := s.migrate(); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("run migrations: %w", err)
+		}
+	}
+
+	// Each connection to an in-memory database is a separate database, so
+	// reads have to share the writer connection.
+	if !isMemoryPath(dbPath) {
+		readDB, err := sql.Open("sqlite", dsn(dbPath, readerPragmas))
+		if err != nil {
+			db.Close()
+			return nil, fmt.Errorf("open read pool: %w", err)
+		}
+		readDB.SetMaxOpenConns(readConns)
+		readDB.SetMaxIdleConns(readConns)
+		if err := readDB.Ping(); err != nil {
+			readDB.Close()
+			db.Close()
+			return nil, fmt.Errorf("configure read pool: %w", err)
+		}
+		s.readDB = readDB
+		s.readStmts = newStmtCache(readDB)
 	}
 
 	return s, nil
 }
 
-// Close closes the database connection.
+// Close closes the database connections.
 func (s *Store) Close() error {
-	return s.db.Close()
+	var readErr error
+	if s.readDB != s.db {
+		readErr = errors.Join(s.readStmts.close(), s.readDB.Close())
+	}
+	return errors.Join(s.writeStmts.close(), s.db.Close(), readErr)
 }
 
-// DB returns the underlying *sql.DB.
+// Dialect reports which database the store is backed by.
+func (s *Store) Dialect() Dialect {
+	return s.dialect
+}
+
+// DB returns the underlying *sql.DB of the writer connection.
 func (s *Store) DB() *sql.DB {
 	return s.db
 }
 
 // InTx executes fn within a database transaction. If fn returns an error,
-// the transaction is rolled back; otherwise it is committed.
+// the transaction is rolled back; otherwise it is committed. Transactions
+// run on the writer connection, so reads inside them see their own writes.
 func (s *Store) InTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
 	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
@@ -73,74 +173,62 @@ func (s *Store) InTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
 	return tx.Commit()
 }
 
-func configurePragmas(db *sql.DB) error {
-	pragmas := []string{
-		"PRAGMA journal_mode = WAL",
-		"PRAGMA busy_timeout = 5000",
-		"PRAGMA synchronous = NORMAL",
-		"PRAGMA foreign_keys = ON",
-		"PRAGMA cache_size = -64000",
-		"PRAGMA temp_store = MEMORY",
-	}
-	for _, p := range pragmas {
-		if _, err := db.Exec(p); err != nil {
-			return fmt.Errorf("exec %q: %w", p, err)
-		}
-	}
-	return nil
+// writerPragmas configure the writer connection.
+var writerPragmas = []string{
+	"journal_mode(WAL)",
+	"busy_timeout(5000)",
+	"synchronous(NORMAL)",
+	"foreign_keys(1)",
+	"cache_size(-64000)",
+	"temp_store(MEMORY)",
 }
 
-// migrate runs all pending database migrations in order.
-func (s *Store) migrate() error {
-	_, err := s.db.Exec(`CREATE TABLE IF NOT EXISTS schema_version (
-		version    INTEGER PRIMARY KEY,
-		applied_at INTEGER NOT NULL
-	)`)
-	if err != nil {
-		return fmt.Errorf("create schema_version table: %w", err)
-	}
+// readerPragmas configure each read-only connection. query_only makes a
+// write routed to the read pool fail instead of contending for the lock.
+var readerPragmas = []string{
+	"busy_timeout(5000)",
+	"foreign_keys(1)",
+	"cache_size(-64000)",
+	"temp_store(MEMORY)",
+	"query_only(1)",
+}
 
-	var currentVersion int
-	err = s.db.QueryRow("SELECT COALESCE(MAX(version), 0) FROM schema_version").Scan(&currentVersion)
-	if err != nil {
-		return fmt.Errorf("get current version: %w", err)
+// dsn appends PRAGMAs and other connection parameters to a database path.
+func dsn(dbPath string, pragmas []string, params ...string) string {
+	for _, p := range pragmas {
+		params = append(params, "_pragma="+p)
 	}
-
-	for i, m := range migrations {
-		version := i + 1
-		if version <= currentVersion {
-			continue
-		}
-
-		tx, err := s.db.Begin()
-		if err != nil {
-			return fmt.Errorf("begin migration %d: %w", version, err)
-		}
-
-		if err := m(tx); err != nil {
-			tx.Rollback()
-			return fmt.Errorf("migration %d: %w", version, err)
-		}
-
-		_, err = tx.Exec("INSERT INTO schema_version (version, applied_at) VALUES (?, ?)",
-			version, time.Now().Unix())
-		if err != nil {
-			tx.Rollback()
-			return fmt.Errorf("record migration %d: %w", version, err)
-		}
-
-		if err := tx.Commit(); err != nil {
-			return fmt.Errorf("commit migration %d: %w", version, err)
-		}
+	sep := "?"
+	if strings.Contains(dbPath, "?") {
+		sep = "&"
 	}
+	return dbPath + sep + strings.Join(params, "&")
+}
 
-	return nil
+// isMemoryPath reports whether dbPath names an in-memory database.
+func isMemoryPath(dbPath string) bool {
+	return dbPath == ":memory:" || strings.HasPrefix(dbPath, "file::memory:") || strings.Contains(dbPath, "mode=memory")
 }
 
-// migrations is an ordered list of migration functions.
-var migrations = []func(*sql.Tx) error{
-	migrateV1,
-	migrateV2,
+// migrations is the ordered list of SQLite schema changes. Migration N
+// takes the schema from version N-1 to N, and its down step takes it back.
+var migrations = []migration{
+	{name: "auth schema", up: migrateV1, down: rollbackV1},
+	{name: "messaging schema", up: migrateV2, down: rollbackV2},
+	{name: "recovery codes and audit log", up: migrateV3, down: rollbackV3},
+	{name: "challenge origin", up: migrateV4, down: rollbackV4},
+	{name: "credential attestation", up: migrateV5, down: rollbackV5},
+	{name: "folded usernames", up: migrateV6, down: rollbackV6},
+	{name: "last-resort key packages", up: migrateV7, down: rollbackV7},
+	{name: "key package cipher suites", up: migrateV8, down: rollbackV8},
+	{name: "MLS group state", up: migrateV9, down: rollbackV9},
+	{name: "member leaf updates", up: migrateV10, down: rollbackV10},
+	{name: "sealed sender", up: migrateV11, down: rollbackV11},
+	{name: "messaging foreign keys", up: migrateV12, down: rollbackV12},
+	{name: "encryption at rest", up: migrateV13, down: rollbackV13},
+	{name: "server secrets", up: migrateV14, down: rollbackV14},
+	{name: "commit membership changes", up: migrateV15, down: rollbackV15},
+	{name: "indexed member and recipient IDs", up: migrateV16, down: rollbackV16},
 }
 
 // migrateV1 creates the initial schema for auth (Phase B).
@@ -204,6 +292,16 @@ func migrateV1(tx *sql.Tx) error {
 	return nil
 }
 
+// rollbackV1 drops the auth schema.
+func rollbackV1(tx *sql.Tx) error {
+	return execStmts(tx, []string{
+		`DROP TABLE challenge`,
+		`DROP TABLE session`,
+		`DROP TABLE credential`,
+		`DROP TABLE "user"`,
+	})
+}
+
 // migrateV2 creates the schema for messaging (Phase C).
 func migrateV2(tx *sql.Tx) error {
 	stmts := []string{
@@ -274,10 +372,688 @@ func migrateV2(tx *sql.Tx) error {
 	return nil
 }
 
-// isUniqueConstraintError returns true if the error is a SQLite UNIQUE constraint violation.
+// rollbackV2 drops the messaging schema.
+func rollbackV2(tx *sql.Tx) error {
+	return execStmts(tx, []string{
+		`DROP TABLE key_packages`,
+		`DROP TABLE conversations`,
+		`DROP TABLE group_members`,
+		`DROP TABLE delivery_status`,
+		`DROP TABLE messages`,
+	})
+}
+
+// migrateV3 adds account recovery codes and the admin audit log.
+func migrateV3(tx *sql.Tx) error {
+	stmts := []string{
+		// Recovery codes: single-use passkey fallback, stored as SHA-256 hashes
+		`CREATE TABLE recovery_code (
+			id         TEXT PRIMARY KEY,
+			user_id    TEXT NOT NULL,
+			code_hash  BLOB NOT NULL,
+			created_at INTEGER NOT NULL,
+			used_at    INTEGER,
+			FOREIGN KEY (user_id) REFERENCES user (id) ON DELETE CASCADE
+		)`,
+		`CREATE INDEX idx_recovery_code_user_id ON recovery_code (user_id)`,
+		`CREATE UNIQUE INDEX idx_recovery_code_hash ON recovery_code (code_hash)`,
+
+		// Audit log: security-relevant events surfaced to server admins
+		`CREATE TABLE audit_event (
+			id         TEXT PRIMARY KEY,
+			event_type TEXT NOT NULL,
+			user_id    TEXT,
+			detail     TEXT NOT NULL DEFAULT '',
+			created_at INTEGER NOT NULL
+		)`,
+		`CREATE INDEX idx_audit_event_created_at ON audit_event (created_at)`,
+		`CREATE INDEX idx_audit_event_user_id ON audit_event (user_id)`,
+	}
+
+	for _, stmt := range stmts {
+		if _, err := tx.Exec(stmt); err != nil {
+			return fmt.Errorf("exec %q: %w", stmt[:min(len(stmt), 60)], err)
+		}
+	}
+	return nil
+}
+
+// rollbackV3 drops recovery codes and the audit log.
+func rollbackV3(tx *sql.Tx) error {
+	return execStmts(tx, []string{
+		`DROP TABLE audit_event`,
+		`DROP TABLE recovery_code`,
+	})
+}
+
+// migrateV4 records the origin of each challenge so outstanding challenges can
+// be capped per client IP and per connection.
+func migrateV4(tx *sql.Tx) error {
+	stmts := []string{
+		`ALTER TABLE challenge ADD COLUMN client_ip TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE challenge ADD COLUMN conn_id TEXT NOT NULL DEFAULT ''`,
+		`CREATE INDEX idx_challenge_client_ip ON challenge (client_ip, expires_at)`,
+		`CREATE INDEX idx_challenge_conn_id ON challenge (conn_id, expires_at)`,
+	}
+
+	for _, stmt := range stmts {
+		if _, err := tx.Exec(stmt); err != nil {
+			return fmt.Errorf("exec %q: %w", stmt[:min(len(stmt), 60)], err)
+		}
+	}
+	return nil
+}
+
+// rollbackV4 drops the origin of challenges.
+func rollbackV4(tx *sql.Tx) error {
+	return execStmts(tx, []string{
+		`DROP INDEX idx_challenge_conn_id`,
+		`DROP INDEX idx_challenge_client_ip`,
+		`ALTER TABLE challenge DROP COLUMN conn_id`,
+		`ALTER TABLE challenge DROP COLUMN client_ip`,
+	})
+}
+
+// migrateV5 records the authenticator model and attestation format of each
+// credential so the WebAuthn policy can be audited.
+func migrateV5(tx *sql.Tx) error {
+	stmts := []string{
+		`ALTER TABLE credential ADD COLUMN aaguid BLOB`,
+		`ALTER TABLE credential ADD COLUMN attestation_format TEXT NOT NULL DEFAULT ''`,
+	}
+
+	for _, stmt := range stmts {
+		if _, err := tx.Exec(stmt); err != nil {
+			return fmt.Errorf("exec %q: %w", stmt[:min(len(stmt), 60)], err)
+		}
+	}
+	return nil
+}
+
+// rollbackV5 drops the authenticator model and attestation format of
+// credentials.
+func rollbackV5(tx *sql.Tx) error {
+	return execStmts(tx, []string{
+		`ALTER TABLE credential DROP COLUMN attestation_format`,
+		`ALTER TABLE credential DROP COLUMN aaguid`,
+	})
+}
+
+// migrateV6 adds the folded username used for case- and
+// compatibility-insensitive uniqueness. Existing users are backfilled with
+// the lower-cased username; where several existing usernames fold to the
+// same value, only the oldest is backfilled and the others keep an empty
+// folded name, so they remain reachable by exact username only.
+func migrateV6(tx *sql.Tx) error {
+	stmts := []string{
+		`ALTER TABLE user ADD COLUMN username_folded TEXT NOT NULL DEFAULT ''`,
+		`UPDATE user SET username_folded = lower(username)
+		 WHERE id = (SELECT u2.id FROM user u2 WHERE lower(u2.username) = lower(user.username)
+		             ORDER BY u2.created_at, u2.id LIMIT 1)`,
+		`CREATE UNIQUE INDEX idx_user_username_folded ON user (username_folded) WHERE username_folded != ''`,
+	}
+
+	for _, stmt := range stmts {
+		if _, err := tx.Exec(stmt); err != nil {
+			return fmt.Errorf("exec %q: %w", stmt[:min(len(stmt), 60)], err)
+		}
+	}
+	return nil
+}
+
+// rollbackV6 drops folded usernames. Usernames that differ only in case
+// are no longer rejected afterwards.
+func rollbackV6(tx *sql.Tx) error {
+	return execStmts(tx, []string{
+		`DROP INDEX idx_user_username_folded`,
+		`ALTER TABLE "user" DROP COLUMN username_folded`,
+	})
+}
+
+// migrateV7 marks last-resort key packages, which are returned by fetches
+// but never consumed. A user has at most one.
+func migrateV7(tx *sql.Tx) error {
+	stmts := []string{
+		`ALTER TABLE key_packages ADD COLUMN last_resort INTEGER NOT NULL DEFAULT 0`,
+		`CREATE UNIQUE INDEX idx_key_packages_last_resort ON key_packages(user_id) WHERE last_resort = 1`,
+	}
+
+	for _, stmt := range stmts {
+		if _, err := tx.Exec(stmt); err != nil {
+			return fmt.Errorf("exec %q: %w", stmt[:min(len(stmt), 60)], err)
+		}
+	}
+	return nil
+}
+
+// rollbackV7 drops the last-resort flag, so last-resort key packages
+// become ordinary ones.
+func rollbackV7(tx *sql.Tx) error {
+	return execStmts(tx, []string{
+		`DROP INDEX idx_key_packages_last_resort`,
+		`ALTER TABLE key_packages DROP COLUMN last_resort`,
+	})
+}
+
+// migrateV8 records the MLS cipher suite and credential type of each key
+// package so fetches can ask for a suite. Existing rows get 0 (unknown) and
+// are only returned to fetches that do not name a suite. A user may have a
+// last-resort key package per cipher suite.
+func migrateV8(tx *sql.Tx) error {
+	stmts := []string{
+		`ALTER TABLE key_packages ADD COLUMN cipher_suite INTEGER NOT NULL DEFAULT 0`,
+		`ALTER TABLE key_packages ADD COLUMN credential_type INTEGER NOT NULL DEFAULT 0`,
+		`DROP INDEX idx_key_packages_last_resort`,
+		`CREATE UNIQUE INDEX idx_key_packages_last_resort ON key_packages(user_id, cipher_suite) WHERE last_resort = 1`,
+		`CREATE INDEX idx_key_packages_user_suite ON key_packages(user_id, cipher_suite)`,
+	}
+
+	for _, stmt := range stmts {
+		if _, err := tx.Exec(stmt); err != nil {
+			return fmt.Errorf("exec %q: %w", stmt[:min(len(stmt), 60)], err)
+		}
+	}
+	return nil
+}
+
+// rollbackV8 drops the cipher suite and credential type of key packages.
+// A user may only have one last-resort key package again, so all but the
+// oldest of each user's are deleted.
+func rollbackV8(tx *sql.Tx) error {
+	return execStmts(tx, []string{
+		`DROP INDEX idx_key_packages_user_suite`,
+		`DROP INDEX idx_key_packages_last_resort`,
+		`DELETE FROM key_packages WHERE last_resort = 1 AND rowid NOT IN (
+			SELECT MIN(rowid) FROM key_packages WHERE last_resort = 1 GROUP BY user_id)`,
+		`CREATE UNIQUE INDEX idx_key_packages_last_resort ON key_packages(user_id) WHERE last_resort = 1`,
+		`ALTER TABLE key_packages DROP COLUMN credential_type`,
+		`ALTER TABLE key_packages DROP COLUMN cipher_suite`,
+	})
+}
+
+// migrateV9 stores the latest MLS GroupInfo published for each
+// conversation, which members fetch to rejoin a group by external commit.
+func migrateV9(tx *sql.Tx) error {
+	stmts := []string{
+		`CREATE TABLE mls_group_state (
+			conversation_id TEXT PRIMARY KEY,
+			group_id        BLOB NOT NULL,
+			epoch           INTEGER NOT NULL,
+			group_info      BLOB NOT NULL,
+			updated_by      TEXT NOT NULL,
+			updated_at      INTEGER NOT NULL,
+			FOREIGN KEY (conversation_id) REFERENCES conversations(id) ON DELETE CASCADE
+		)`,
+		`CREATE UNIQUE INDEX idx_mls_group_state_group_id ON mls_group_state(group_id)`,
+	}
+
+	for _, stmt := range stmts {
+		if _, err := tx.Exec(stmt); err != nil {
+			return fmt.Errorf("exec %q: %w", stmt[:min(len(stmt), 60)], err)
+		}
+	}
+	return nil
+}
+
+// rollbackV9 drops the stored MLS GroupInfo of conversations.
+func rollbackV9(tx *sql.Tx) error {
+	return execStmts(tx, []string{
+		`DROP TABLE mls_group_state`,
+	})
+}
+
+// migrateV10 records when each group member last committed or proposed,
+// which rotates their MLS leaf key, and when they were last asked to.
+func migrateV10(tx *sql.Tx) error {
+	stmts := []string{
+		`ALTER TABLE group_members ADD COLUMN last_update_at INTEGER`,
+		`ALTER TABLE group_members ADD COLUMN last_update_epoch INTEGER`,
+		`ALTER TABLE group_members ADD COLUMN update_requested_at INTEGER`,
+	}
+
+	for _, stmt := range stmts {
+		if _, err := tx.Exec(stmt); err != nil {
+			return fmt.Errorf("exec %q: %w", stmt[:min(len(stmt), 60)], err)
+		}
+	}
+	return nil
+}
+
+// rollbackV10 drops the leaf update tracking of group members.
+func rollbackV10(tx *sql.Tx) error {
+	return execStmts(tx, []string{
+		`ALTER TABLE group_members DROP COLUMN update_requested_at`,
+		`ALTER TABLE group_members DROP COLUMN last_update_epoch`,
+		`ALTER TABLE group_members DROP COLUMN last_update_at`,
+	})
+}
+
+// migrateV11 adds opt-in sealed sender to conversations, and a generation
+// counter that changes whenever a member leaves so that delivery tokens
+// issued before can be revoked.
+func migrateV11(tx *sql.Tx) error {
+	stmts := []string{
+		`ALTER TABLE conversations ADD COLUMN sealed_sender INTEGER NOT NULL DEFAULT 0`,
+		`ALTER TABLE conversations ADD COLUMN member_generation INTEGER NOT NULL DEFAULT 0`,
+	}
+
+	for _, stmt := range stmts {
+		if _, err := tx.Exec(stmt); err != nil {
+			return fmt.Errorf("exec %q: %w", stmt[:min(len(stmt), 60)], err)
+		}
+	}
+	return nil
+}
+
+// rollbackV11 drops sealed sender. Conversations that had it enabled
+// reveal senders again.
+func rollbackV11(tx *sql.Tx) error {
+	return execStmts(tx, []string{
+		`ALTER TABLE conversations DROP COLUMN member_generation`,
+		`ALTER TABLE conversations DROP COLUMN sealed_sender`,
+	})
+}
+
+// migrateV12 adds the foreign keys migrateV2 left out, so that deleting a
+// user or conversation also deletes their memberships, key packages,
+// messages and deliveries. SQLite cannot add a foreign key to a table, so
+// the tables are rebuilt. Rows that are already orphaned would fail the new
+// constraints and are deleted first.
+func migrateV12(tx *sql.Tx) error {
+	err := execStmts(tx, []string{
+		`DELETE FROM group_members WHERE user_id NOT IN (SELECT id FROM user)
+		 OR group_id NOT IN (SELECT id FROM conversations)`,
+		`DELETE FROM key_packages WHERE user_id NOT IN (SELECT id FROM user)`,
+		`DELETE FROM messages WHERE group_id NOT IN (SELECT id FROM conversations)`,
+		`DELETE FROM delivery_status WHERE recipient_id NOT IN (SELECT id FROM user)
+		 OR message_id NOT IN (SELECT id FROM messages)`,
+	})
+	if err != nil {
+		return err
+	}
+	return rebuildTables(tx, []rebuiltTable{
+		{
+			name: "group_members",
+			create: `CREATE TABLE group_members_new (
+				group_id            TEXT NOT NULL,
+				user_id             TEXT NOT NULL,
+				role                TEXT NOT NULL DEFAULT 'member',
+				joined_at           INTEGER NOT NULL,
+				last_update_at      INTEGER,
+				last_update_epoch   INTEGER,
+				update_requested_at INTEGER,
+				PRIMARY KEY (group_id, user_id),
+				FOREIGN KEY (group_id) REFERENCES conversations(id) ON DELETE CASCADE,
+				FOREIGN KEY (user_id) REFERENCES user(id) ON DELETE CASCADE
+			)`,
+			indexes: groupMembersIndexes,
+		},
+		{
+			name: "key_packages",
+			create: `CREATE TABLE key_packages_new (
+				id               TEXT PRIMARY KEY,
+				user_id          TEXT NOT NULL,
+				key_package_data BLOB NOT NULL,
+				created_at       INTEGER NOT NULL,
+				expires_at       INTEGER NOT NULL,
+				last_resort      INTEGER NOT NULL DEFAULT 0,
+				cipher_suite     INTEGER NOT NULL DEFAULT 0,
+				credential_type  INTEGER NOT NULL DEFAULT 0,
+				FOREIGN KEY (user_id) REFERENCES user(id) ON DELETE CASCADE
+			)`,
+			indexes: keyPackagesIndexes,
+		},
+		{
+			name: "messages",
+			create: `CREATE TABLE messages_new (
+				id               TEXT PRIMARY KEY,
+				group_id         TEXT NOT NULL,
+				sender_id        TEXT NOT NULL,
+				server_timestamp INTEGER NOT NULL,
+				payload          BLOB NOT NULL,
+				payload_size     INTEGER NOT NULL,
+				message_type     INTEGER NOT NULL DEFAULT 0,
+				epoch            INTEGER NOT NULL DEFAULT 0,
+				created_at       INTEGER NOT NULL,
+				FOREIGN KEY (group_id) REFERENCES conversations(id) ON DELETE CASCADE
+			)`,
+			indexes: messagesIndexes,
+		},
+		{
+			name: "delivery_status",
+			create: `CREATE TABLE delivery_status_new (
+				message_id   TEXT NOT NULL,
+				recipient_id TEXT NOT NULL,
+				status       INTEGER NOT NULL DEFAULT 0,
+				delivered_at INTEGER,
+				read_at      INTEGER,
+				PRIMARY KEY (message_id, recipient_id),
+				FOREIGN KEY (message_id) REFERENCES messages_new(id) ON DELETE CASCADE,
+				FOREIGN KEY (recipient_id) REFERENCES user(id) ON DELETE CASCADE
+			)`,
+			indexes: deliveryStatusIndexes,
+		},
+	})
+}
+
+// rollbackV12 rebuilds the messaging tables without the foreign keys added
+// by migrateV12. The orphaned rows it deleted are not restored.
+func rollbackV12(tx *sql.Tx) error {
+	return rebuildTables(tx, []rebuiltTable{
+		{
+			name: "group_members",
+			create: `CREATE TABLE group_members_new (
+				group_id            TEXT NOT NULL,
+				user_id             TEXT NOT NULL,
+				role                TEXT NOT NULL DEFAULT 'member',
+				joined_at           INTEGER NOT NULL,
+				last_update_at      INTEGER,
+				last_update_epoch   INTEGER,
+				update_requested_at INTEGER,
+				PRIMARY KEY (group_id, user_id)
+			)`,
+			indexes: groupMembersIndexes,
+		},
+		{
+			name: "key_packages",
+			create: `CREATE TABLE key_packages_new (
+				id               TEXT PRIMARY KEY,
+				user_id          TEXT NOT NULL,
+				key_package_data BLOB NOT NULL,
+				created_at       INTEGER NOT NULL,
+				expires_at       INTEGER NOT NULL,
+				last_resort      INTEGER NOT NULL DEFAULT 0,
+				cipher_suite     INTEGER NOT NULL DEFAULT 0,
+				credential_type  INTEGER NOT NULL DEFAULT 0
+			)`,
+			indexes: keyPackagesIndexes,
+		},
+		{
+			name: "messages",
+			create: `CREATE TABLE messages_new (
+				id               TEXT PRIMARY KEY,
+				group_id         TEXT NOT NULL,
+				sender_id        TEXT NOT NULL,
+				server_timestamp INTEGER NOT NULL,
+				payload          BLOB NOT NULL,
+				payload_size     INTEGER NOT NULL,
+				message_type     INTEGER NOT NULL DEFAULT 0,
+				epoch            INTEGER NOT NULL DEFAULT 0,
+				created_at       INTEGER NOT NULL
+			)`,
+			indexes: messagesIndexes,
+		},
+		{
+			name: "delivery_status",
+			create: `CREATE TABLE delivery_status_new (
+				message_id   TEXT NOT NULL,
+				recipient_id TEXT NOT NULL,
+				status       INTEGER NOT NULL DEFAULT 0,
+				delivered_at INTEGER,
+				read_at      INTEGER,
+				PRIMARY KEY (message_id, recipient_id),
+				FOREIGN KEY (message_id) REFERENCES messages_new(id) ON DELETE CASCADE
+			)`,
+			indexes: deliveryStatusIndexes,
+		},
+	})
+}
+
+// migrateV13 adds the table holding the wrapped keys of encryption at
+// rest. It has at most one row; an empty table means encryption is off.
+func migrateV13(tx *sql.Tx) error {
+	return execStmts(tx, []string{
+		`CREATE TABLE encryption_key (
+			id         INTEGER PRIMARY KEY CHECK (id = 1),
+			data_key   BLOB NOT NULL,
+			index_key  BLOB NOT NULL,
+			kdf_salt   BLOB,
+			created_at INTEGER NOT NULL,
+			rotated_at INTEGER NOT NULL
+		)`,
+	})
+}
+
+// rollbackV13 drops the keys of encryption at rest. It refuses to run on
+// an encrypted database, whose sealed values could not be read afterwards.
+func rollbackV13(tx *sql.Tx) error {
+	if err := checkNotEncrypted(tx, "roll back encryption at rest"); err != nil {
+		return err
+	}
+	return execStmts(tx, []string{
+		`DROP TABLE encryption_key`,
+	})
+}
+
+// checkNotEncrypted returns ErrEncrypted, wrapped with what, if the
+// database is encrypted at rest.
+func checkNotEncrypted(tx *sql.Tx, what string) error {
+	var n int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM encryption_key`).Scan(&n); err != nil {
+		return fmt.Errorf("count encryption keys: %w", err)
+	}
+	if n > 0 {
+		return fmt.Errorf("%s: %w", what, ErrEncrypted)
+	}
+	return nil
+}
+
+// migrateV14 adds server_secret, for keys the server generates once and
+// must keep across restarts.
+func migrateV14(tx *sql.Tx) error {
+	return execStmts(tx, []string{
+		`CREATE TABLE server_secret (
+			name       TEXT PRIMARY KEY,
+			value      BLOB NOT NULL,
+			created_at INTEGER NOT NULL
+		)`,
+	})
+}
+
+// rollbackV14 drops server_secret. The server generates new secrets when
+// migrated again.
+func rollbackV14(tx *sql.Tx) error {
+	return execStmts(tx, []string{
+		`DROP TABLE server_secret`,
+	})
+}
+
+// migrateV15 records with a Commit stored by CommitMembershipChange the
+// members it added and removed, so that members who catch up on it later
+// are told the same as those who received it live.
+func migrateV15(tx *sql.Tx) error {
+	return execStmts(tx, []string{
+		`ALTER TABLE messages ADD COLUMN membership_change BLOB`,
+	})
+}
+
+// rollbackV15 drops the membership changes of stored Commits.
+func rollbackV15(tx *sql.Tx) error {
+	return execStmts(tx, []string{
+		`ALTER TABLE messages DROP COLUMN membership_change`,
+	})
+}
+
+// migrateV16 adds group_members.user_index and renames
+// delivery_status.recipient_id to recipient_index. On an encrypted
+// database they hold keyed hashes of the user IDs, by which memberships and
+// deliveries are looked up, and group_members.user_id is sealed; Unlock
+// computes them for a database encrypted before this migration, which
+// encryption_key.identities_indexed records. Neither table can then refer
+// to user, so the tables are rebuilt without those foreign keys.
+func migrateV16(tx *sql.Tx) error {
+	err := rebuildTables(tx, []rebuiltTable{
+		{
+			name: "group_members",
+			create: `CREATE TABLE group_members_new (
+				group_id            TEXT NOT NULL,
+				user_id             TEXT NOT NULL,
+				role                TEXT NOT NULL DEFAULT 'member',
+				joined_at           INTEGER NOT NULL,
+				last_update_at      INTEGER,
+				last_update_epoch   INTEGER,
+				update_requested_at INTEGER,
+				user_index          TEXT NOT NULL,
+				PRIMARY KEY (group_id, user_index),
+				FOREIGN KEY (group_id) REFERENCES conversations(id) ON DELETE CASCADE
+			)`,
+			columns: `*, user_id`,
+			indexes: []string{
+				`CREATE INDEX idx_group_members_user ON group_members(user_index)`,
+			},
+		},
+		{
+			name: "delivery_status",
+			create: `CREATE TABLE delivery_status_new (
+				message_id      TEXT NOT NULL,
+				recipient_index TEXT NOT NULL,
+				status          INTEGER NOT NULL DEFAULT 0,
+				delivered_at    INTEGER,
+				read_at         INTEGER,
+				PRIMARY KEY (message_id, recipient_index),
+				FOREIGN KEY (message_id) REFERENCES messages(id) ON DELETE CASCADE
+			)`,
+			indexes: []string{
+				`CREATE INDEX idx_delivery_pending ON delivery_status(recipient_index, status)`,
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	return execStmts(tx, []string{
+		`ALTER TABLE encryption_key ADD COLUMN identities_indexed INTEGER NOT NULL DEFAULT 0`,
+	})
+}
+
+// rollbackV16 restores the user IDs of memberships and deliveries and
+// their foreign keys. It refuses to run on an encrypted database, whose
+// keyed hashes cannot be turned back into user IDs. Memberships and
+// deliveries of users who have since been deleted are dropped.
+func rollbackV16(tx *sql.Tx) error {
+	if err := checkNotEncrypted(tx, "roll back indexed member and recipient IDs"); err != nil {
+		return err
+	}
+	err := execStmts(tx, []string{
+		`DELETE FROM group_members WHERE user_id NOT IN (SELECT id FROM user)`,
+		`DELETE FROM delivery_status WHERE recipient_index NOT IN (SELECT id FROM user)`,
+		`ALTER TABLE encryption_key DROP COLUMN identities_indexed`,
+	})
+	if err != nil {
+		return err
+	}
+	return rebuildTables(tx, []rebuiltTable{
+		{
+			name: "group_members",
+			create: `CREATE TABLE group_members_new (
+				group_id            TEXT NOT NULL,
+				user_id             TEXT NOT NULL,
+				role                TEXT NOT NULL DEFAULT 'member',
+				joined_at           INTEGER NOT NULL,
+				last_update_at      INTEGER,
+				last_update_epoch   INTEGER,
+				update_requested_at INTEGER,
+				PRIMARY KEY (group_id, user_id),
+				FOREIGN KEY (group_id) REFERENCES conversations(id) ON DELETE CASCADE,
+				FOREIGN KEY (user_id) REFERENCES user(id) ON DELETE CASCADE
+			)`,
+			columns: `group_id, user_id, role, joined_at, last_update_at, last_update_epoch, update_requested_at`,
+			indexes: groupMembersIndexes,
+		},
+		{
+			name: "delivery_status",
+			create: `CREATE TABLE delivery_status_new (
+				message_id   TEXT NOT NULL,
+				recipient_id TEXT NOT NULL,
+				status       INTEGER NOT NULL DEFAULT 0,
+				delivered_at INTEGER,
+				read_at      INTEGER,
+				PRIMARY KEY (message_id, recipient_id),
+				FOREIGN KEY (message_id) REFERENCES messages(id) ON DELETE CASCADE,
+				FOREIGN KEY (recipient_id) REFERENCES user(id) ON DELETE CASCADE
+			)`,
+			indexes: deliveryStatusIndexes,
+		},
+	})
+}
+
+// Indexes of the tables rebuilt by migrateV12 and rollbackV12, as the
+// earlier migrations created them.
+var (
+	groupMembersIndexes = []string{
+		`CREATE INDEX idx_group_members_user ON group_members(user_id)`,
+	}
+	keyPackagesIndexes = []string{
+		`CREATE INDEX idx_key_packages_user ON key_packages(user_id)`,
+		`CREATE INDEX idx_key_packages_expires ON key_packages(expires_at)`,
+		`CREATE UNIQUE INDEX idx_key_packages_last_resort ON key_packages(user_id, cipher_suite) WHERE last_resort = 1`,
+		`CREATE INDEX idx_key_packages_user_suite ON key_packages(user_id, cipher_suite)`,
+	}
+	messagesIndexes = []string{
+		`CREATE INDEX idx_messages_group_timestamp ON messages(group_id, server_timestamp)`,
+		`CREATE INDEX idx_messages_sender ON messages(sender_id, server_timestamp)`,
+		`CREATE INDEX idx_messages_created_at ON messages(created_at)`,
+		`CREATE INDEX idx_messages_group_size ON messages(group_id, payload_size)`,
+	}
+	deliveryStatusIndexes = []string{
+		`CREATE INDEX idx_delivery_pending ON delivery_status(recipient_id, status)`,
+	}
+)
+
+// rebuiltTable is a table that rebuildTables replaces.
+type rebuiltTable struct {
+	name    string
+	create  string   // creates name_new with the columns copied, in the same order
+	columns string   // the columns copied from name, if not all of them
+	indexes []string // created once name_new has been renamed to name
+}
+
+// rebuildTables replaces each table with a new definition and copies its
+// rows across, which is how SQLite changes constraints. Tables are listed
+// parents first. A new table that refers to another one being rebuilt names
+// it with its _new suffix; renaming the table updates the reference.
+//
+// Foreign keys stay enabled, so dropping a table deletes the rows of any
+// table that still refers to it. Old tables are therefore dropped children
+// first, once no new table refers to them.
+func rebuildTables(tx *sql.Tx, tables []rebuiltTable) error {
+	var stmts []string
+	for _, t := range tables {
+		columns := t.columns
+		if columns == "" {
+			columns = "*"
+		}
+		stmts = append(stmts, t.create, `INSERT INTO `+t.name+`_new SELECT `+columns+` FROM `+t.name)
+	}
+	for i := len(tables) - 1; i >= 0; i-- {
+		stmts = append(stmts, `DROP TABLE `+tables[i].name)
+	}
+	for _, t := range tables {
+		stmts = append(stmts, `ALTER TABLE `+t.name+`_new RENAME TO `+t.name)
+		stmts = append(stmts, t.indexes...)
+	}
+	return execStmts(tx, stmts)
+}
+
+// execStmts executes stmts in order.
+func execStmts(tx *sql.Tx, stmts []string) error {
+	for _, stmt := range stmts {
+		if _, err := tx.Exec(stmt); err != nil {
+			return fmt.Errorf("exec %q: %w", stmt[:min(len(stmt), 60)], err)
+		}
+	}
+	return nil
+}
+
+// isUniqueConstraintError returns true if the error is a SQLite UNIQUE
+// constraint violation or a PostgreSQL unique_violation.
 func isUniqueConstraintError(err error) bool {
 	if err == nil {
 		return false
 	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code == "23505"
+	}
 	return strings.Contains(err.Error(), "UNIQUE constraint failed")
 }
@@ -4,7 +4,9 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"path/filepath"
 	"testing"
+	"time"
 )
 
 // newTestStore creates an in-memory Store for testing.
@@ -18,6 +20,16 @@ func newTestStore(t *testing.T) *Store {
 	return s
 }
 
+// seedUsers creates a user for each ID, with the ID as username.
+func seedUsers(t testing.TB, s *Store, ids ...string) {
+	t.Helper()
+	for _, id := range ids {
+		if err := s.CreateUser(context.Background(), makeUser(id, id)); err != nil {
+			t.Fatalf("CreateUser(%s): %v", id, err)
+		}
+	}
+}
+
 func TestNewStore(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -72,7 +84,7 @@ func TestTablesExist(t *testing.T) {
 	s := newTestStore(t)
 	ctx := context.Background()
 
-	tables := []string{"user", "credential", "session", "challenge", "schema_version"}
+	tables := []string{"user", "credential", "session", "challenge", "schema_version", "recovery_code", "audit_event"}
 	for _, table := range tables {
 		t.Run(table, func(t *testing.T) {
 			var name string
@@ -148,3 +160,87 @@ func TestInTx(t *testing.T) {
 		}
 	})
 }
+
+func TestReadPool(t *testing.T) {
+	ctx := context.Background()
+	s, err := Open(filepath.Join(t.TempDir(), "test.db"), Options{ReadConns: 3})
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer s.Close()
+
+	if s.readDB == s.db {
+		t.Fatal("file database shares the writer connection for reads")
+	}
+
+	// Hold every read connection at once so that each one is checked.
+	var conns []*sql.Conn
+	for i := 0; i < 3; i++ {
+		conn, err := s.readDB.Conn(ctx)
+		if err != nil {
+			t.Fatalf("Conn: %v", err)
+		}
+		defer conn.Close()
+		conns = append(conns, conn)
+	}
+	for i, conn := range conns {
+		for _, pragma := range []string{"foreign_keys", "query_only"} {
+			var v int
+			if err := conn.QueryRowContext(ctx, "PRAGMA "+pragma).Scan(&v); err != nil {
+				t.Fatalf("conn %d: PRAGMA %s: %v", i, pragma, err)
+			}
+			if v != 1 {
+				t.Errorf("conn %d: %s = %d, want 1", i, pragma, v)
+			}
+		}
+		if _, err := conn.ExecContext(ctx, `DELETE FROM user`); err == nil {
+			t.Errorf("conn %d: write on read connection succeeded", i)
+		}
+	}
+	for _, conn := range conns {
+		conn.Close()
+	}
+
+	// Reads do not wait for a write transaction to finish.
+	seedUsers(t, s, "alice")
+	conv, err := s.CreateConversation(ctx, "Group", "alice", nil)
+	if err != nil {
+		t.Fatalf("CreateConversation: %v", err)
+	}
+	inTx := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- s.InTx(ctx, func(tx *sql.Tx) error {
+			if _, err := tx.ExecContext(ctx, `UPDATE conversations SET title = 'Renamed' WHERE id = ?`, conv.ID); err != nil {
+				return err
+			}
+			close(inTx)
+			<-release
+			return nil
+		})
+	}()
+	<-inTx
+
+	readCtx, cancel := context.WithTimeout(ctx, time.Second)
+	got, err := s.GetConversation(readCtx, conv.ID)
+	cancel()
+	close(release)
+	if err != nil {
+		t.Fatalf("GetConversation during write: %v", err)
+	}
+	if got.Title != "Group" {
+		t.Errorf("Title during write = %q, want the committed Group", got.Title)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("InTx: %v", err)
+	}
+
+	got, err = s.GetConversation(ctx, conv.ID)
+	if err != nil {
+		t.Fatalf("GetConversation: %v", err)
+	}
+	if got.Title != "Renamed" {
+		t.Errorf("Title after commit = %q, want Renamed", got.Title)
+	}
+}
@@ -0,0 +1,901 @@
+// Package storetest is a conformance suite for store.Backend
+// implementations. Every backend the server supports must pass it.
+package storetest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sovereign-im/sovereign/server/internal/store"
+)
+
+// Opener returns an empty, migrated backend for one test. It should close
+// the backend when the test ends.
+type Opener func(t *testing.T) store.Backend
+
+// Run runs the conformance suite against the backends returned by open.
+// Each subtest gets a backend of its own.
+func Run(t *testing.T, open Opener) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, b store.Backend)
+	}{
+		{"Users", testUsers},
+		{"Credentials", testCredentials},
+		{"Sessions", testSessions},
+		{"Challenges", testChallenges},
+		{"Recovery", testRecovery},
+		{"Audit", testAudit},
+		{"Conversations", testConversations},
+		{"MembershipCommits", testMembershipCommits},
+		{"MemberUpdates", testMemberUpdates},
+		{"GroupState", testGroupState},
+		{"Messages", testMessages},
+		{"KeyPackages", testKeyPackages},
+		{"ConcurrentKeyPackageConsumers", testConcurrentKeyPackageConsumers},
+		{"ServerSecrets", testServerSecrets},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, open(t))
+		})
+	}
+}
+
+func newUser(t *testing.T, b store.Backend, id, username string) *store.User {
+	t.Helper()
+	now := time.Now().Unix()
+	u := &store.User{
+		ID:          id,
+		Username:    username,
+		DisplayName: "Display " + username,
+		Role:        "member",
+		Enabled:     true,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := b.CreateUser(context.Background(), u); err != nil {
+		t.Fatalf("CreateUser(%s): %v", username, err)
+	}
+	return u
+}
+
+// newUsers creates a user for each ID, with the ID as username.
+func newUsers(t *testing.T, b store.Backend, ids ...string) {
+	t.Helper()
+	for _, id := range ids {
+		newUser(t, b, id, id)
+	}
+}
+
+func newCredential(t *testing.T, b store.Backend, id, userID string) *store.Credential {
+	t.Helper()
+	c := &store.Credential{
+		ID:                id,
+		UserID:            userID,
+		CredentialID:      []byte("cred-" + id),
+		PublicKey:         []byte("pk-" + id),
+		CreatedAt:         time.Now().Unix(),
+		AAGUID:            make([]byte, 16),
+		AttestationFormat: "none",
+	}
+	if err := b.CreateCredential(context.Background(), c); err != nil {
+		t.Fatalf("CreateCredential(%s): %v", id, err)
+	}
+	return c
+}
+
+func testUsers(t *testing.T, b store.Backend) {
+	ctx := context.Background()
+	alice := newUser(t, b, "u1", "alice")
+	newUser(t, b, "u2", "Bob")
+	newUser(t, b, "u3", "carol")
+
+	if err := b.CreateUser(ctx, &store.User{ID: "u4", Username: "alice", DisplayName: "x"}); !errors.Is(err, store.ErrConflict) {
+		t.Errorf("CreateUser(duplicate username) error = %v, want ErrConflict", err)
+	}
+	if err := b.CreateUser(ctx, &store.User{ID: "u4", Username: "ALICE", DisplayName: "x"}); !errors.Is(err, store.ErrConflict) {
+		t.Errorf("CreateUser(duplicate folded username) error = %v, want ErrConflict", err)
+	}
+
+	got, err := b.GetUserByID(ctx, "u1")
+	if err != nil {
+		t.Fatalf("GetUserByID: %v", err)
+	}
+	if *got != *alice {
+		t.Errorf("GetUserByID = %+v, want %+v", got, alice)
+	}
+	if got, err := b.GetUserByUsername(ctx, "Bob"); err != nil || got.ID != "u2" {
+		t.Errorf("GetUserByUsername(Bob) = %v, %v; want u2", got, err)
+	}
+	if got, err := b.GetUserByFoldedUsername(ctx, "bob"); err != nil || got.ID != "u2" {
+		t.Errorf("GetUserByFoldedUsername(bob) = %v, %v; want u2", got, err)
+	}
+	if _, err := b.GetUserByID(ctx, "missing"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("GetUserByID(missing) error = %v, want ErrNotFound", err)
+	}
+
+	alice.DisplayName = "Alice A."
+	alice.Role = "admin"
+	alice.Enabled = false
+	if err := b.UpdateUser(ctx, alice); err != nil {
+		t.Fatalf("UpdateUser: %v", err)
+	}
+	if got, _ := b.GetUserByID(ctx, "u1"); got == nil || got.DisplayName != "Alice A." || got.Role != "admin" || got.Enabled {
+		t.Errorf("after UpdateUser got %+v", got)
+	}
+	if err := b.UpdateUser(ctx, &store.User{ID: "missing"}); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("UpdateUser(missing) error = %v, want ErrNotFound", err)
+	}
+
+	// Usernames sort bytewise, so upper case comes first.
+	users, err := b.ListUsers(ctx)
+	if err != nil {
+		t.Fatalf("ListUsers: %v", err)
+	}
+	var names []string
+	for _, u := range users {
+		names = append(names, u.Username)
+	}
+	if want := []string{"Bob", "alice", "carol"}; !slices.Equal(names, want) {
+		t.Errorf("ListUsers = %v, want %v", names, want)
+	}
+}
+
+func testCredentials(t *testing.T, b store.Backend) {
+	ctx := context.Background()
+	newUser(t, b, "u1", "alice")
+	c1 := newCredential(t, b, "c1", "u1")
+	newCredential(t, b, "c2", "u1")
+
+	dup := *c1
+	dup.ID = "c3"
+	if err := b.CreateCredential(ctx, &dup); !errors.Is(err, store.ErrConflict) {
+		t.Errorf("CreateCredential(duplicate credential ID) error = %v, want ErrConflict", err)
+	}
+
+	got, err := b.GetCredentialByID(ctx, "c1")
+	if err != nil {
+		t.Fatalf("GetCredentialByID: %v", err)
+	}
+	if !bytes.Equal(got.CredentialID, c1.CredentialID) || !bytes.Equal(got.PublicKey, c1.PublicKey) ||
+		!bytes.Equal(got.AAGUID, c1.AAGUID) || got.AttestationFormat != "none" || got.LastUsedAt != nil {
+		t.Errorf("GetCredentialByID = %+v, want %+v", got, c1)
+	}
+
+	if err := b.UpdateSignCount(ctx, "c1", 42); err != nil {
+		t.Fatalf("UpdateSignCount: %v", err)
+	}
+	if got, _ := b.GetCredentialByID(ctx, "c1"); got == nil || got.SignCount != 42 || got.LastUsedAt == nil {
+		t.Errorf("after UpdateSignCount got %+v", got)
+	}
+
+	creds, err := b.GetCredentialsByUserID(ctx, "u1")
+	if err != nil || len(creds) != 2 {
+		t.Fatalf("GetCredentialsByUserID = %d credentials, %v; want 2", len(creds), err)
+	}
+
+	if err := b.DeleteCredential(ctx, "c2"); err != nil {
+		t.Fatalf("DeleteCredential: %v", err)
+	}
+	if err := b.DeleteCredential(ctx, "c2"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("DeleteCredential(deleted) error = %v, want ErrNotFound", err)
+	}
+	if _, err := b.GetCredentialByID(ctx, "c2"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("GetCredentialByID(deleted) error = %v, want ErrNotFound", err)
+	}
+}
+
+func testSessions(t *testing.T, b store.Backend) {
+	ctx := context.Background()
+	newUser(t, b, "u1", "alice")
+	newCredential(t, b, "c1", "u1")
+	now := time.Now().Unix()
+
+	sess := &store.Session{
+		ID: "s1", UserID: "u1", CredentialID: "c1", TokenHash: []byte("hash-1"),
+		CreatedAt: now, ExpiresAt: now + 3600, LastSeenAt: now,
+	}
+	if err := b.CreateSession(ctx, sess); err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	expired := &store.Session{
+		ID: "s2", UserID: "u1", TokenHash: []byte("hash-2"),
+		CreatedAt: now - 7200, ExpiresAt: now - 3600, LastSeenAt: now - 7200,
+	}
+	if err := b.CreateSession(ctx, expired); err != nil {
+		t.Fatalf("CreateSession(expired): %v", err)
+	}
+
+	got, err := b.GetSessionByTokenHash(ctx, []byte("hash-1"))
+	if err != nil {
+		t.Fatalf("GetSessionByTokenHash: %v", err)
+	}
+	if got.ID != "s1" || got.CredentialID != "c1" || got.ExpiresAt != sess.ExpiresAt {
+		t.Errorf("GetSessionByTokenHash = %+v, want %+v", got, sess)
+	}
+	if got, err := b.GetSessionByID(ctx, "s2"); err != nil || got.CredentialID != "" {
+		t.Errorf("GetSessionByID(s2) = %+v, %v; want no credential", got, err)
+	}
+
+	if err := b.RotateSessionToken(ctx, "s1", []byte("hash-1"), []byte("hash-3"), now+1, now+7200); err != nil {
+		t.Fatalf("RotateSessionToken: %v", err)
+	}
+	if err := b.RotateSessionToken(ctx, "s1", []byte("hash-1"), []byte("hash-4"), now+1, now+7200); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("second RotateSessionToken error = %v, want ErrNotFound", err)
+	}
+	if _, err := b.GetSessionByTokenHash(ctx, []byte("hash-1")); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("GetSessionByTokenHash(old hash) error = %v, want ErrNotFound", err)
+	}
+
+	if err := b.ExtendSession(ctx, "s1", now+2, now+9000); err != nil {
+		t.Fatalf("ExtendSession: %v", err)
+	}
+	if got, _ := b.GetSessionByID(ctx, "s1"); got == nil || got.LastSeenAt != now+2 || got.ExpiresAt != now+9000 {
+		t.Errorf("after ExtendSession got %+v", got)
+	}
+	if err := b.UpdateSessionLastUsed(ctx, "missing"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("UpdateSessionLastUsed(missing) error = %v, want ErrNotFound", err)
+	}
+
+	// Deleting a credential keeps its sessions but forgets the credential.
+	if err := b.DeleteCredential(ctx, "c1"); err != nil {
+		t.Fatalf("DeleteCredential: %v", err)
+	}
+	if got, err := b.GetSessionByID(ctx, "s1"); err != nil || got.CredentialID != "" {
+		t.Errorf("session after DeleteCredential = %+v, %v; want no credential", got, err)
+	}
+
+	if n, err := b.DeleteExpiredSessions(ctx); err != nil || n != 1 {
+		t.Errorf("DeleteExpiredSessions = %d, %v; want 1", n, err)
+	}
+	if err := b.DeleteSession(ctx, "s1"); err != nil {
+		t.Fatalf("DeleteSession: %v", err)
+	}
+	if err := b.DeleteSession(ctx, "s1"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("DeleteSession(deleted) error = %v, want ErrNotFound", err)
+	}
+}
+
+func testChallenges(t *testing.T, b store.Backend) {
+	ctx := context.Background()
+	now := time.Now().Unix()
+	challenges := []*store.Challenge{
+		{ChallengeID: "ch1", ChallengeData: []byte("d1"), Username: "alice", ChallengeType: "registration",
+			ClientIP: "10.0.0.1", ConnID: "conn-1", CreatedAt: now, ExpiresAt: now + 300},
+		{ChallengeID: "ch2", ChallengeData: []byte("d2"), ChallengeType: "login",
+			ClientIP: "10.0.0.1", CreatedAt: now, ExpiresAt: now + 300},
+		{ChallengeID: "ch3", ChallengeData: []byte("d3"), ChallengeType: "login",
+			ClientIP: "10.0.0.1", ConnID: "conn-1", CreatedAt: now - 600, ExpiresAt: now - 300},
+	}
+	for _, c := range challenges {
+		if err := b.CreateChallenge(ctx, c); err != nil {
+			t.Fatalf("CreateChallenge(%s): %v", c.ChallengeID, err)
+		}
+	}
+
+	got, err := b.GetChallenge(ctx, "ch1")
+	if err != nil {
+		t.Fatalf("GetChallenge: %v", err)
+	}
+	if got.Username != "alice" || !bytes.Equal(got.ChallengeData, []byte("d1")) || got.ConnID != "conn-1" {
+		t.Errorf("GetChallenge = %+v, want %+v", got, challenges[0])
+	}
+	if got, err := b.GetChallenge(ctx, "ch2"); err != nil || got.Username != "" {
+		t.Errorf("GetChallenge(ch2) = %+v, %v; want no username", got, err)
+	}
+
+	if n, err := b.CountActiveChallengesByClientIP(ctx, "10.0.0.1"); err != nil || n != 2 {
+		t.Errorf("CountActiveChallengesByClientIP = %d, %v; want 2", n, err)
+	}
+	if n, err := b.CountActiveChallengesByConn(ctx, "conn-1"); err != nil || n != 1 {
+		t.Errorf("CountActiveChallengesByConn = %d, %v; want 1", n, err)
+	}
+
+	if n, err := b.DeleteExpiredChallenges(ctx); err != nil || n != 1 {
+		t.Errorf("DeleteExpiredChallenges = %d, %v; want 1", n, err)
+	}
+	if err := b.DeleteChallenge(ctx, "ch1"); err != nil {
+		t.Fatalf("DeleteChallenge: %v", err)
+	}
+	if err := b.DeleteChallenge(ctx, "ch1"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("DeleteChallenge(deleted) error = %v, want ErrNotFound", err)
+	}
+}
+
+func testRecovery(t *testing.T, b store.Backend) {
+	ctx := context.Background()
+	newUser(t, b, "u1", "alice")
+	newCredential(t, b, "c1", "u1")
+
+	hashes := [][]byte{[]byte("code-1"), []byte("code-2"), []byte("code-3")}
+	if err := b.ReplaceRecoveryCodes(ctx, "u1", hashes); err != nil {
+		t.Fatalf("ReplaceRecoveryCodes: %v", err)
+	}
+	if err := b.ReplaceRecoveryCodes(ctx, "u1", [][]byte{[]byte("x"), []byte("x")}); !errors.Is(err, store.ErrConflict) {
+		t.Errorf("ReplaceRecoveryCodes(duplicates) error = %v, want ErrConflict", err)
+	}
+	if n, err := b.CountRecoveryCodes(ctx, "u1"); err != nil || n != 3 {
+		t.Errorf("CountRecoveryCodes after failed replace = %d, %v; want 3", n, err)
+	}
+
+	if err := b.RedeemRecoveryCode(ctx, "u1", []byte("code-2")); err != nil {
+		t.Fatalf("RedeemRecoveryCode: %v", err)
+	}
+	if err := b.RedeemRecoveryCode(ctx, "u1", []byte("code-2")); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("RedeemRecoveryCode(used) error = %v, want ErrNotFound", err)
+	}
+	if n, _ := b.CountRecoveryCodes(ctx, "u1"); n != 2 {
+		t.Errorf("CountRecoveryCodes = %d, want 2", n)
+	}
+
+	now := time.Now().Unix()
+	if err := b.CreateSession(ctx, &store.Session{
+		ID: "s1", UserID: "u1", CredentialID: "c1", TokenHash: []byte("old"),
+		CreatedAt: now, ExpiresAt: now + 3600, LastSeenAt: now,
+	}); err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	cred := &store.Credential{ID: "c2", UserID: "u1", CredentialID: []byte("new-cred"), PublicKey: []byte("pk"), CreatedAt: now}
+	sess := &store.Session{ID: "s2", UserID: "u1", TokenHash: []byte("new"), CreatedAt: now, ExpiresAt: now + 3600, LastSeenAt: now}
+	revoked, removed, err := b.RecoverAccount(ctx, cred, sess)
+	if err != nil {
+		t.Fatalf("RecoverAccount: %v", err)
+	}
+	if revoked != 1 || removed != 1 {
+		t.Errorf("RecoverAccount = %d sessions, %d credentials; want 1, 1", revoked, removed)
+	}
+	if got, err := b.GetSessionByTokenHash(ctx, []byte("new")); err != nil || got.CredentialID != "c2" {
+		t.Errorf("recovered session = %+v, %v; want credential c2", got, err)
+	}
+}
+
+func testAudit(t *testing.T, b store.Backend) {
+	ctx := context.Background()
+	now := time.Now().Unix()
+	for i, typ := range []string{"login", "recovery", "login"} {
+		if err := b.InsertAuditEvent(ctx, &store.AuditEvent{EventType: typ, UserID: "u1", Detail: "d", CreatedAt: now + int64(i)}); err != nil {
+			t.Fatalf("InsertAuditEvent: %v", err)
+		}
+	}
+	if err := b.InsertAuditEvent(ctx, &store.AuditEvent{EventType: "startup", CreatedAt: now + 3}); err != nil {
+		t.Fatalf("InsertAuditEvent: %v", err)
+	}
+
+	if n, err := b.CountAuditEvents(ctx, ""); err != nil || n != 4 {
+		t.Errorf("CountAuditEvents(all) = %d, %v; want 4", n, err)
+	}
+	if n, err := b.CountAuditEvents(ctx, "login"); err != nil || n != 2 {
+		t.Errorf("CountAuditEvents(login) = %d, %v; want 2", n, err)
+	}
+
+	events, err := b.ListAuditEvents(ctx, "", 1, 2)
+	if err != nil {
+		t.Fatalf("ListAuditEvents: %v", err)
+	}
+	if len(events) != 2 || events[0].CreatedAt != now+2 || events[1].CreatedAt != now+1 {
+		t.Errorf("ListAuditEvents(offset 1, limit 2) = %+v, want the second and third newest", events)
+	}
+	events, err = b.ListAuditEvents(ctx, "startup", 0, 10)
+	if err != nil || len(events) != 1 || events[0].UserID != "" {
+		t.Errorf("ListAuditEvents(startup) = %+v, %v; want one event without a user", events, err)
+	}
+}
+
+func testConversations(t *testing.T, b store.Backend) {
+	ctx := context.Background()
+	newUsers(t, b, "alice", "bob", "carol", "dave")
+	conv, err := b.CreateConversation(ctx, "Group", "alice", []string{"alice", "bob", "carol"})
+	if err != nil {
+		t.Fatalf("CreateConversation: %v", err)
+	}
+	sealed, err := b.CreateConversationWithOptions(ctx, "Sealed", "bob", []string{"dave"}, store.ConversationOptions{SealedSender: true})
+	if err != nil {
+		t.Fatalf("CreateConversationWithOptions: %v", err)
+	}
+
+	got, err := b.GetConversation(ctx, conv.ID)
+	if err != nil {
+		t.Fatalf("GetConversation: %v", err)
+	}
+	if *got != *conv {
+		t.Errorf("GetConversation = %+v, want %+v", got, conv)
+	}
+	if got, err := b.GetConversation(ctx, sealed.ID); err != nil || !got.SealedSender {
+		t.Errorf("GetConversation(sealed) = %+v, %v; want sealed sender", got, err)
+	}
+	if _, err := b.GetConversation(ctx, "missing"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("GetConversation(missing) error = %v, want ErrNotFound", err)
+	}
+
+	members, err := b.GetMembers(ctx, conv.ID)
+	if err != nil || len(members) != 3 {
+		t.Fatalf("GetMembers = %d members, %v; want 3", len(members), err)
+	}
+	if role, err := b.GetMemberRole(ctx, conv.ID, "alice"); err != nil || role != "admin" {
+		t.Errorf("GetMemberRole(alice) = %q, %v; want admin", role, err)
+	}
+	if _, err := b.GetMemberRole(ctx, conv.ID, "dave"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("GetMemberRole(non-member) error = %v, want ErrNotFound", err)
+	}
+	if ok, err := b.IsUserMember(ctx, conv.ID, "carol"); err != nil || !ok {
+		t.Errorf("IsUserMember(carol) = %v, %v; want true", ok, err)
+	}
+
+	if err := b.AddMember(ctx, conv.ID, "dave", "member"); err != nil {
+		t.Fatalf("AddMember: %v", err)
+	}
+	if err := b.AddMember(ctx, conv.ID, "dave", "member"); !errors.Is(err, store.ErrConflict) {
+		t.Errorf("AddMember(existing) error = %v, want ErrConflict", err)
+	}
+
+	contacts, err := b.GetContactIDs(ctx, "bob")
+	if err != nil {
+		t.Fatalf("GetContactIDs: %v", err)
+	}
+	if want := []string{"alice", "carol", "dave"}; !slices.Equal(contacts, want) {
+		t.Errorf("GetContactIDs(bob) = %v, want %v", contacts, want)
+	}
+	convs, err := b.GetConversationsForUser(ctx, "dave")
+	if err != nil || len(convs) != 2 {
+		t.Errorf("GetConversationsForUser(dave) = %d, %v; want 2", len(convs), err)
+	}
+
+	if err := b.RemoveMember(ctx, conv.ID, "carol"); err != nil {
+		t.Fatalf("RemoveMember: %v", err)
+	}
+	if err := b.RemoveMember(ctx, conv.ID, "carol"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("RemoveMember(removed) error = %v, want ErrNotFound", err)
+	}
+	if got, _ := b.GetConversation(ctx, conv.ID); got == nil || got.MemberGeneration != conv.MemberGeneration+1 {
+		t.Errorf("member generation after RemoveMember = %+v, want %d", got, conv.MemberGeneration+1)
+	}
+
+	if err := b.TransferAdmin(ctx, sealed.ID, "bob"); err != nil {
+		t.Fatalf("TransferAdmin: %v", err)
+	}
+	if role, _ := b.GetMemberRole(ctx, sealed.ID, "dave"); role != "admin" {
+		t.Errorf("role of dave after TransferAdmin = %q, want admin", role)
+	}
+}
+
+func testMembershipCommits(t *testing.T, b store.Backend) {
+	ctx := context.Background()
+	newUsers(t, b, "alice", "bob", "carol", "dave", "erin")
+	conv, err := b.CreateConversation(ctx, "Group", "alice", []string{"bob", "carol"})
+	if err != nil {
+		t.Fatalf("CreateConversation: %v", err)
+	}
+
+	msgID, _, err := b.CommitMembershipChange(ctx, conv.ID, "alice", []byte("commit"), []string{"dave"}, []string{"carol"})
+	if err != nil {
+		t.Fatalf("CommitMembershipChange: %v", err)
+	}
+	// The commit goes to the members before the change, except the sender.
+	for user, want := range map[string]bool{"bob": true, "carol": true, "dave": false, "alice": false} {
+		_, err := b.GetDeliveryStatus(ctx, msgID, user)
+		if got := err == nil; got != want {
+			t.Errorf("commit queued for %s = %v, want %v", user, got, want)
+		}
+	}
+	if ok, _ := b.IsUserMember(ctx, conv.ID, "dave"); !ok {
+		t.Error("dave is not a member after being added")
+	}
+	if ok, _ := b.IsUserMember(ctx, conv.ID, "carol"); ok {
+		t.Error("carol is still a member after being removed")
+	}
+
+	// A failed change is not applied at all.
+	_, _, err = b.CommitMembershipChange(ctx, conv.ID, "alice", []byte("commit"), []string{"erin"}, []string{"carol"})
+	if !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("CommitMembershipChange(removing non-member) error = %v, want ErrNotFound", err)
+	}
+	if ok, _ := b.IsUserMember(ctx, conv.ID, "erin"); ok {
+		t.Error("erin was added by a failed commit")
+	}
+	_, _, err = b.CommitMembershipChange(ctx, conv.ID, "alice", []byte("commit"), []string{"bob"}, nil)
+	if !errors.Is(err, store.ErrConflict) {
+		t.Errorf("CommitMembershipChange(adding member) error = %v, want ErrConflict", err)
+	}
+	if msgs, _ := b.GetMessagesByGroup(ctx, conv.ID, "", 10, false); len(msgs) != 1 {
+		t.Errorf("stored %d commits, want 1", len(msgs))
+	}
+}
+
+func testMemberUpdates(t *testing.T, b store.Backend) {
+	ctx := context.Background()
+	newUsers(t, b, "alice", "bob")
+	conv, err := b.CreateConversation(ctx, "Group", "alice", []string{"bob"})
+	if err != nil {
+		t.Fatalf("CreateConversation: %v", err)
+	}
+	staleBefore := time.Now().Unix() + 60
+
+	updates, err := b.ClaimStaleMembers(ctx, "alice", staleBefore)
+	if err != nil || len(updates) != 1 || updates[0].GroupID != conv.ID {
+		t.Fatalf("ClaimStaleMembers = %+v, %v; want the group", updates, err)
+	}
+
+	epoch := int64(4)
+	if err := b.RecordMemberUpdate(ctx, conv.ID, "bob", &epoch); err != nil {
+		t.Fatalf("RecordMemberUpdate: %v", err)
+	}
+	if err := b.RecordMemberUpdate(ctx, conv.ID, "bob", nil); err != nil {
+		t.Fatalf("RecordMemberUpdate(nil epoch): %v", err)
+	}
+	updates, err = b.GetMemberUpdates(ctx, conv.ID)
+	if err != nil {
+		t.Fatalf("GetMemberUpdates: %v", err)
+	}
+	for _, u := range updates {
+		switch u.UserID {
+		case "alice":
+			if u.LastUpdateAt != nil || u.UpdateRequestedAt == nil {
+				t.Errorf("alice = %+v, want requested and not updated", u)
+			}
+		case "bob":
+			if u.LastUpdateAt == nil || u.LastUpdateEpoch == nil || *u.LastUpdateEpoch != 4 {
+				t.Errorf("bob = %+v, want updated at epoch 4", u)
+			}
+		}
+	}
+}
+
+func testGroupState(t *testing.T, b store.Backend) {
+	ctx := context.Background()
+	newUsers(t, b, "alice")
+	conv, err := b.CreateConversation(ctx, "Group", "alice", nil)
+	if err != nil {
+		t.Fatalf("CreateConversation: %v", err)
+	}
+	other, err := b.CreateConversation(ctx, "Other", "alice", nil)
+	if err != nil {
+		t.Fatalf("CreateConversation: %v", err)
+	}
+
+	gs := &store.MLSGroupState{ConversationID: conv.ID, GroupID: []byte("g1"), Epoch: 2, GroupInfo: []byte("info-2"), UpdatedBy: "alice"}
+	if err := b.PutMLSGroupState(ctx, gs); err != nil {
+		t.Fatalf("PutMLSGroupState: %v", err)
+	}
+	stale := *gs
+	stale.Epoch = 1
+	if err := b.PutMLSGroupState(ctx, &stale); !errors.Is(err, store.ErrStale) {
+		t.Errorf("PutMLSGroupState(older epoch) error = %v, want ErrStale", err)
+	}
+	moved := *gs
+	moved.GroupID = []byte("g2")
+	if err := b.PutMLSGroupState(ctx, &moved); !errors.Is(err, store.ErrConflict) {
+		t.Errorf("PutMLSGroupState(other group ID) error = %v, want ErrConflict", err)
+	}
+	reused := *gs
+	reused.ConversationID = other.ID
+	if err := b.PutMLSGroupState(ctx, &reused); !errors.Is(err, store.ErrConflict) {
+		t.Errorf("PutMLSGroupState(group ID of another conversation) error = %v, want ErrConflict", err)
+	}
+
+	next := *gs
+	next.Epoch = 3
+	next.GroupInfo = []byte("info-3")
+	if err := b.PutMLSGroupState(ctx, &next); err != nil {
+		t.Fatalf("PutMLSGroupState(next epoch): %v", err)
+	}
+	got, err := b.GetMLSGroupState(ctx, conv.ID)
+	if err != nil {
+		t.Fatalf("GetMLSGroupState: %v", err)
+	}
+	if got.Epoch != 3 || !bytes.Equal(got.GroupInfo, []byte("info-3")) {
+		t.Errorf("GetMLSGroupState = %+v, want epoch 3", got)
+	}
+	if _, err := b.GetMLSGroupState(ctx, other.ID); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("GetMLSGroupState(none) error = %v, want ErrNotFound", err)
+	}
+}
+
+func testMessages(t *testing.T, b store.Backend) {
+	ctx := context.Background()
+	newUsers(t, b, "alice", "bob", "carol")
+	conv, err := b.CreateConversation(ctx, "Group", "alice", []string{"bob"})
+	if err != nil {
+		t.Fatalf("CreateConversation: %v", err)
+	}
+
+	var ids []string
+	for i := range 5 {
+		id, ts, err := b.InsertMessage(ctx, conv.ID, "alice", []byte(fmt.Sprintf("msg-%d", i)), store.MsgTypeApplication, i)
+		if err != nil {
+			t.Fatalf("InsertMessage: %v", err)
+		}
+		if ts <= 0 {
+			t.Errorf("server timestamp = %d, want positive", ts)
+		}
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+
+	latest, err := b.GetMessagesByGroup(ctx, conv.ID, "", 3, false)
+	if err != nil {
+		t.Fatalf("GetMessagesByGroup: %v", err)
+	}
+	if got := messageIDs(latest); !slices.Equal(got, []string{ids[4], ids[3], ids[2]}) {
+		t.Errorf("latest 3 messages = %v, want %v", got, []string{ids[4], ids[3], ids[2]})
+	}
+	after, err := b.GetMessagesByGroup(ctx, conv.ID, ids[1], 10, true)
+	if err != nil {
+		t.Fatalf("GetMessagesByGroup(forward): %v", err)
+	}
+	if got := messageIDs(after); !slices.Equal(got, ids[2:]) {
+		t.Errorf("messages after %s = %v, want %v", ids[1], got, ids[2:])
+	}
+	before, err := b.GetMessagesByGroup(ctx, conv.ID, ids[2], 10, false)
+	if err != nil {
+		t.Fatalf("GetMessagesByGroup(backward): %v", err)
+	}
+	if got := messageIDs(before); !slices.Equal(got, []string{ids[1], ids[0]}) {
+		t.Errorf("messages before %s = %v, want %v", ids[2], got, []string{ids[1], ids[0]})
+	}
+
+	pending, err := b.GetPendingMessages(ctx, "bob")
+	if err != nil || len(pending) != 5 {
+		t.Fatalf("GetPendingMessages(bob) = %d, %v; want 5", len(pending), err)
+	}
+	if pending, _ := b.GetPendingMessages(ctx, "alice"); len(pending) != 0 {
+		t.Errorf("GetPendingMessages(sender) = %d, want 0", len(pending))
+	}
+
+	// Paging through pending messages visits each once, in order.
+	var paged []*store.Message
+	var afterTS int64
+	var afterID string
+	for {
+		page, err := b.GetPendingMessagesAfter(ctx, "bob", afterTS, afterID, 2)
+		if err != nil {
+			t.Fatalf("GetPendingMessagesAfter: %v", err)
+		}
+		paged = append(paged, page...)
+		if len(page) < 2 {
+			break
+		}
+		afterTS, afterID = page[len(page)-1].ServerTimestamp, page[len(page)-1].ID
+	}
+	if got, want := messageIDs(paged), messageIDs(pending); !slices.Equal(got, want) {
+		t.Errorf("paged pending messages = %v, want %v", got, want)
+	}
+	if got, err := b.GetMessageSenderID(ctx, ids[0]); err != nil || got != "alice" {
+		t.Errorf("GetMessageSenderID = %q, %v; want alice", got, err)
+	}
+
+	if err := b.UpdateDeliveryStatus(ctx, ids[0], "bob", store.DeliveryRead); err != nil {
+		t.Fatalf("UpdateDeliveryStatus: %v", err)
+	}
+	rec, err := b.GetDeliveryStatus(ctx, ids[0], "bob")
+	if err != nil {
+		t.Fatalf("GetDeliveryStatus: %v", err)
+	}
+	if rec.Status != store.DeliveryRead || rec.DeliveredAt == nil || rec.ReadAt == nil {
+		t.Errorf("GetDeliveryStatus = %+v, want read", rec)
+	}
+	if err := b.UpdateDeliveryStatus(ctx, ids[0], "carol", store.DeliveryRead); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("UpdateDeliveryStatus(non-recipient) error = %v, want ErrNotFound", err)
+	}
+	if pending, _ := b.GetPendingMessages(ctx, "bob"); len(pending) != 4 {
+		t.Errorf("GetPendingMessages(bob) after read = %d, want 4", len(pending))
+	}
+
+	// MarkDelivered leaves read deliveries alone, ignores messages the
+	// user was not sent, and returns only the deliveries it changed.
+	delivered, err := b.MarkDelivered(ctx, "bob", []string{ids[0], ids[1], ids[2], "no-such-message"})
+	if err != nil {
+		t.Fatalf("MarkDelivered: %v", err)
+	}
+	byID := func(a, b store.DeliveredMessage) int { return strings.Compare(a.MessageID, b.MessageID) }
+	want := []store.DeliveredMessage{{MessageID: ids[1], SenderID: "alice"}, {MessageID: ids[2], SenderID: "alice"}}
+	slices.SortFunc(delivered, byID)
+	slices.SortFunc(want, byID)
+	if !slices.Equal(delivered, want) {
+		t.Errorf("MarkDelivered = %+v, want %+v", delivered, want)
+	}
+	if delivered, err := b.MarkDelivered(ctx, "bob", []string{ids[1]}); err != nil || len(delivered) != 0 {
+		t.Errorf("MarkDelivered(again) = %+v, %v; want none", delivered, err)
+	}
+	if rec, _ := b.GetDeliveryStatus(ctx, ids[0], "bob"); rec == nil || rec.Status != store.DeliveryRead {
+		t.Errorf("GetDeliveryStatus(read) after MarkDelivered = %+v, want read", rec)
+	}
+	if rec, _ := b.GetDeliveryStatus(ctx, ids[1], "bob"); rec == nil || rec.Status != store.DeliveryDelivered || rec.DeliveredAt == nil {
+		t.Errorf("GetDeliveryStatus(pending) after MarkDelivered = %+v, want delivered", rec)
+	}
+	if pending, _ := b.GetPendingMessages(ctx, "bob"); len(pending) != 2 {
+		t.Errorf("GetPendingMessages(bob) after MarkDelivered = %d, want 2", len(pending))
+	}
+	if _, err := b.MarkDelivered(ctx, "bob", nil); err != nil {
+		t.Errorf("MarkDelivered(none): %v", err)
+	}
+	if err := b.MarkMessageDelivered(ctx, ids[3], []string{"bob", "carol"}); err != nil {
+		t.Fatalf("MarkMessageDelivered: %v", err)
+	}
+	if pending, _ := b.GetPendingMessages(ctx, "bob"); len(pending) != 1 || pending[0].ID != ids[4] {
+		t.Errorf("GetPendingMessages(bob) after MarkMessageDelivered = %v, want [%s]", messageIDs(pending), ids[4])
+	}
+
+	// A direct message is only pending for its recipient.
+	welcomeID, _, err := b.InsertDirectMessage(ctx, conv.ID, "alice", "bob", []byte("welcome"), store.MsgTypeWelcome)
+	if err != nil {
+		t.Fatalf("InsertDirectMessage: %v", err)
+	}
+	if pending, _ := b.GetPendingMessages(ctx, "bob"); len(pending) != 2 || pending[1].ID != welcomeID ||
+		pending[1].MessageType != store.MsgTypeWelcome || string(pending[1].Payload) != "welcome" {
+		t.Errorf("GetPendingMessages(bob) after InsertDirectMessage = %+v, want the welcome last", pending)
+	}
+	if _, err := b.MarkDelivered(ctx, "bob", []string{welcomeID}); err != nil {
+		t.Fatalf("MarkDelivered(welcome): %v", err)
+	}
+
+	// Sealed-sender messages are stored without a sender.
+	if _, _, err := b.InsertMessage(ctx, conv.ID, "", []byte("sealed"), store.MsgTypeApplication, 0); err != nil {
+		t.Fatalf("InsertMessage(no sender): %v", err)
+	}
+	if pending, _ := b.GetPendingMessages(ctx, "alice"); len(pending) != 1 || pending[0].SenderID != "" {
+		t.Errorf("GetPendingMessages(alice) = %+v, want the sealed message", pending)
+	}
+
+	n, err := b.DeleteExpiredMessages(ctx, time.Now().Unix()+1)
+	if err != nil || n != 7 {
+		t.Errorf("DeleteExpiredMessages = %d, %v; want 7", n, err)
+	}
+	if _, err := b.GetDeliveryStatus(ctx, ids[1], "bob"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("delivery status of deleted message: error = %v, want ErrNotFound", err)
+	}
+}
+
+func messageIDs(msgs []*store.Message) []string {
+	var ids []string
+	for _, m := range msgs {
+		ids = append(ids, m.ID)
+	}
+	return ids
+}
+
+func testKeyPackages(t *testing.T, b store.Backend) {
+	ctx := context.Background()
+	newUsers(t, b, "alice", "bob")
+	future := time.Now().Add(24 * time.Hour).Unix()
+	past := time.Now().Add(-time.Hour).Unix()
+
+	err := b.StoreKeyPackages(ctx, "alice", []*store.KeyPackage{
+		{KeyPackageData: []byte("kp-1-a"), ExpiresAt: future, CipherSuite: 1, CredentialType: 1},
+		{KeyPackageData: []byte("kp-1-b"), ExpiresAt: future, CipherSuite: 1, CredentialType: 1},
+		{KeyPackageData: []byte("kp-2"), ExpiresAt: future, CipherSuite: 2, CredentialType: 1},
+		{KeyPackageData: []byte("kp-1-lr"), ExpiresAt: future, CipherSuite: 1, LastResort: true},
+	})
+	if err != nil {
+		t.Fatalf("StoreKeyPackages: %v", err)
+	}
+	if _, err := b.StoreKeyPackage(ctx, "alice", []byte("kp-expired"), past); err != nil {
+		t.Fatalf("StoreKeyPackage(expired): %v", err)
+	}
+	// A new last-resort key package replaces the previous one for its suite.
+	if _, err := b.StoreLastResortKeyPackage(ctx, "alice", []byte("kp-0-lr"), future); err != nil {
+		t.Fatalf("StoreLastResortKeyPackage: %v", err)
+	}
+	if _, err := b.StoreLastResortKeyPackage(ctx, "alice", []byte("kp-0-lr2"), future); err != nil {
+		t.Fatalf("StoreLastResortKeyPackage(replace): %v", err)
+	}
+
+	if n, err := b.CountKeyPackages(ctx, "alice"); err != nil || n != 3 {
+		t.Errorf("CountKeyPackages = %d, %v; want 3", n, err)
+	}
+	inv, err := b.KeyPackageInventory(ctx, "alice")
+	if err != nil {
+		t.Fatalf("KeyPackageInventory: %v", err)
+	}
+	want := []store.KeyPackageSuiteCount{
+		{CipherSuite: 0, Available: 0, HasLastResort: true},
+		{CipherSuite: 1, Available: 2, HasLastResort: true},
+		{CipherSuite: 2, Available: 1, HasLastResort: false},
+	}
+	if !slices.Equal(inv, want) {
+		t.Errorf("KeyPackageInventory = %+v, want %+v", inv, want)
+	}
+
+	// Regular key packages are consumed oldest first, then the last-resort
+	// one is returned without being consumed.
+	var got []string
+	for range 4 {
+		kp, err := b.ConsumeKeyPackage(ctx, "alice", 1)
+		if err != nil {
+			t.Fatalf("ConsumeKeyPackage: %v", err)
+		}
+		if kp.CipherSuite != 1 {
+			t.Errorf("consumed suite %d, want 1", kp.CipherSuite)
+		}
+		got = append(got, string(kp.KeyPackageData))
+	}
+	slices.Sort(got[:2]) // stored in the same second
+	if want := []string{"kp-1-a", "kp-1-b", "kp-1-lr", "kp-1-lr"}; !slices.Equal(got, want) {
+		t.Errorf("consumed %v, want %v", got, want)
+	}
+	if _, err := b.ConsumeKeyPackage(ctx, "bob", 0); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("ConsumeKeyPackage(no key packages) error = %v, want ErrNotFound", err)
+	}
+
+	kps, err := b.ConsumeKeyPackages(ctx, []string{"alice", "bob", "alice"}, 2)
+	if err != nil {
+		t.Fatalf("ConsumeKeyPackages: %v", err)
+	}
+	if len(kps) != 1 || string(kps["alice"].KeyPackageData) != "kp-2" {
+		t.Errorf("ConsumeKeyPackages = %v, want kp-2 for alice only", kps)
+	}
+
+	if n, err := b.DeleteExpiredKeyPackages(ctx); err != nil || n != 1 {
+		t.Errorf("DeleteExpiredKeyPackages = %d, %v; want 1", n, err)
+	}
+}
+
+// testConcurrentKeyPackageConsumers checks that a key package is handed
+// out at most once when several fetches for the same user race.
+func testConcurrentKeyPackageConsumers(t *testing.T, b store.Backend) {
+	ctx := context.Background()
+	newUsers(t, b, "alice")
+	const stored, consumers = 20, 8
+	future := time.Now().Add(24 * time.Hour).Unix()
+	for i := range stored {
+		if _, err := b.StoreKeyPackage(ctx, "alice", []byte(fmt.Sprintf("kp-%d", i)), future); err != nil {
+			t.Fatalf("StoreKeyPackage: %v", err)
+		}
+	}
+
+	var (
+		mu   sync.Mutex
+		seen = make(map[string]int)
+		wg   sync.WaitGroup
+	)
+	for range consumers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				kp, err := b.ConsumeKeyPackage(ctx, "alice", 0)
+				if errors.Is(err, store.ErrNotFound) {
+					return
+				}
+				if err != nil {
+					t.Errorf("ConsumeKeyPackage: %v", err)
+					return
+				}
+				mu.Lock()
+				seen[kp.ID]++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(seen) != stored {
+		t.Errorf("consumed %d distinct key packages, want %d", len(seen), stored)
+	}
+	for id, n := range seen {
+		if n != 1 {
+			t.Errorf("key package %s consumed %d times", id, n)
+		}
+	}
+}
+
+func testServerSecrets(t *testing.T, b store.Backend) {
+	ctx := context.Background()
+	first, err := b.ServerSecret(ctx, "a", 32)
+	if err != nil || len(first) != 32 {
+		t.Fatalf("ServerSecret(a) = %x, %v; want 32 bytes", first, err)
+	}
+	if again, err := b.ServerSecret(ctx, "a", 32); err != nil || !bytes.Equal(again, first) {
+		t.Errorf("ServerSecret(a) again = %x, %v; want %x", again, err, first)
+	}
+	if other, err := b.ServerSecret(ctx, "b", 16); err != nil || len(other) != 16 || bytes.Equal(other, first[:16]) {
+		t.Errorf("ServerSecret(b) = %x, %v; want a new 16-byte secret", other, err)
+	}
+}
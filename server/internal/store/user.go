@@ -4,25 +4,31 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"strings"
 )
 
 // User represents a registered user on this Sovereign server.
 type User struct {
-	ID          string
-	Username    string
-	DisplayName string
-	Role        string
-	Enabled     bool
-	CreatedAt   int64
-	UpdatedAt   int64
+	ID             string
+	Username       string
+	UsernameFolded string // canonical form for uniqueness and lookups; CreateUser defaults it to lower(Username)
+	DisplayName    string
+	Role           string
+	Enabled        bool
+	CreatedAt      int64
+	UpdatedAt      int64
 }
 
-// CreateUser inserts a new user. Returns ErrConflict if the username is taken.
+// CreateUser inserts a new user. Returns ErrConflict if the username or its
+// folded form is taken.
 func (s *Store) CreateUser(ctx context.Context, u *User) error {
+	if u.UsernameFolded == "" {
+		u.UsernameFolded = strings.ToLower(u.Username)
+	}
 	_, err := s.db.ExecContext(ctx,
-		`INSERT INTO user (id, username, display_name, role, enabled, created_at, updated_at)
-		 VALUES (?, ?, ?, ?, ?, ?, ?)`,
-		u.ID, u.Username, u.DisplayName, u.Role, u.Enabled, u.CreatedAt, u.UpdatedAt,
+		`INSERT INTO "user" (id, username, username_folded, display_name, role, enabled, created_at, updated_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		u.ID, u.Username, u.UsernameFolded, u.DisplayName, u.Role, u.Enabled, u.CreatedAt, u.UpdatedAt,
 	)
 	if err != nil {
 		if isUniqueConstraintError(err) {
@@ -36,10 +42,10 @@ func (s *Store) CreateUser(ctx context.Context, u *User) error {
 // GetUserByID returns a user by ID. Returns ErrNotFound if not found.
 func (s *Store) GetUserByID(ctx context.Context, id string) (*User, error) {
 	u := &User{}
-	err := s.db.QueryRowContext(ctx,
-		`SELECT id, username, display_name, role, enabled, created_at, updated_at
-		 FROM user WHERE id = ?`, id,
-	).Scan(&u.ID, &u.Username, &u.DisplayName, &u.Role, &u.Enabled, &u.CreatedAt, &u.UpdatedAt)
+	err := s.readDB.QueryRowContext(ctx,
+		`SELECT id, username, username_folded, display_name, role, enabled, created_at, updated_at
+		 FROM "user" WHERE id = ?`, id,
+	).Scan(&u.ID, &u.Username, &u.UsernameFolded, &u.DisplayName, &u.Role, &u.Enabled, &u.CreatedAt, &u.UpdatedAt)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, ErrNotFound
@@ -52,10 +58,10 @@ func (s *Store) GetUserByID(ctx context.Context, id string) (*User, error) {
 // GetUserByUsername returns a user by username. Returns ErrNotFound if not found.
 func (s *Store) GetUserByUsername(ctx context.Context, username string) (*User, error) {
 	u := &User{}
-	err := s.db.QueryRowContext(ctx,
-		`SELECT id, username, display_name, role, enabled, created_at, updated_at
-		 FROM user WHERE username = ?`, username,
-	).Scan(&u.ID, &u.Username, &u.DisplayName, &u.Role, &u.Enabled, &u.CreatedAt, &u.UpdatedAt)
+	err := s.readDB.QueryRowContext(ctx,
+		`SELECT id, username, username_folded, display_name, role, enabled, created_at, updated_at
+		 FROM "user" WHERE username = ?`, username,
+	).Scan(&u.ID, &u.Username, &u.UsernameFolded, &u.DisplayName, &u.Role, &u.Enabled, &u.CreatedAt, &u.UpdatedAt)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, ErrNotFound
@@ -65,11 +71,28 @@ func (s *Store) GetUserByUsername(ctx context.Context, username string) (*User,
 	return u, nil
 }
 
+// GetUserByFoldedUsername returns a user by the folded form of their
+// username. Returns ErrNotFound if not found.
+func (s *Store) GetUserByFoldedUsername(ctx context.Context, folded string) (*User, error) {
+	u := &User{}
+	err := s.readDB.QueryRowContext(ctx,
+		`SELECT id, username, username_folded, display_name, role, enabled, created_at, updated_at
+		 FROM "user" WHERE username_folded = ?`, folded,
+	).Scan(&u.ID, &u.Username, &u.UsernameFolded, &u.DisplayName, &u.Role, &u.Enabled, &u.CreatedAt, &u.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("get user by folded username: %w", err)
+	}
+	return u, nil
+}
+
 // UpdateUser updates a user's display_name, role, enabled, and updated_at fields.
 // Returns ErrNotFound if the user does not exist.
 func (s *Store) UpdateUser(ctx context.Context, u *User) error {
 	result, err := s.db.ExecContext(ctx,
-		`UPDATE user SET display_name = ?, role = ?, enabled = ?, updated_at = ?
+		`UPDATE "user" SET display_name = ?, role = ?, enabled = ?, updated_at = ?
 		 WHERE id = ?`,
 		u.DisplayName, u.Role, u.Enabled, u.UpdatedAt, u.ID,
 	)
@@ -88,9 +111,9 @@ func (s *Store) UpdateUser(ctx context.Context, u *User) error {
 
 // ListUsers returns all users ordered by username.
 func (s *Store) ListUsers(ctx context.Context) ([]*User, error) {
-	rows, err := s.db.QueryContext(ctx,
-		`SELECT id, username, display_name, role, enabled, created_at, updated_at
-		 FROM user ORDER BY username`)
+	rows, err := s.readDB.QueryContext(ctx,
+		`SELECT id, username, username_folded, display_name, role, enabled, created_at, updated_at
+		 FROM "user" ORDER BY username`)
 	if err != nil {
 		return nil, fmt.Errorf("list users: %w", err)
 	}
@@ -99,7 +122,7 @@ func (s *Store) ListUsers(ctx context.Context) ([]*User, error) {
 	var users []*User
 	for rows.Next() {
 		u := &User{}
-		if err := rows.Scan(&u.ID, &u.Username, &u.DisplayName, &u.Role, &u.Enabled, &u.CreatedAt, &u.UpdatedAt); err != nil {
+		if err := rows.Scan(&u.ID, &u.Username, &u.UsernameFolded, &u.DisplayName, &u.Role, &u.Enabled, &u.CreatedAt, &u.UpdatedAt); err != nil {
 			return nil, fmt.Errorf("scan user: %w", err)
 		}
 		users = append(users, u)
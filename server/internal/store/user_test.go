@@ -38,6 +38,14 @@ func TestCreateUser(t *testing.T) {
 			},
 			wantErr: ErrConflict,
 		},
+		{
+			name: "username differing only in case returns ErrConflict",
+			users: []*User{
+				makeUser("u1", "alice"),
+				makeUser("u2", "Alice"),
+			},
+			wantErr: ErrConflict,
+		},
 	}
 
 	for _, tt := range tests {
@@ -157,6 +165,73 @@ func TestGetUserByUsername(t *testing.T) {
 	}
 }
 
+func TestGetUserByFoldedUsername(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	u := makeUser("u1", "Alice")
+	if err := s.CreateUser(ctx, u); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+
+	got, err := s.GetUserByFoldedUsername(ctx, "alice")
+	if err != nil {
+		t.Fatalf("GetUserByFoldedUsername: %v", err)
+	}
+	if got.ID != "u1" || got.Username != "Alice" || got.UsernameFolded != "alice" {
+		t.Errorf("got ID=%q Username=%q UsernameFolded=%q, want u1, Alice, alice", got.ID, got.Username, got.UsernameFolded)
+	}
+
+	if _, err := s.GetUserByFoldedUsername(ctx, "bob"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("missing user: error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestMigrateV6CaseDuplicates(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	// Simulate users created before migrateV6 whose names differ only in
+	// case, then re-run the backfill.
+	for _, stmt := range []string{
+		`DROP INDEX idx_user_username_folded`,
+		`INSERT INTO user (id, username, display_name, created_at, updated_at) VALUES ('u1', 'alice', 'A', 1, 1)`,
+		`INSERT INTO user (id, username, display_name, created_at, updated_at) VALUES ('u2', 'Alice', 'B', 2, 2)`,
+		`ALTER TABLE user DROP COLUMN username_folded`,
+	} {
+		if _, err := s.db.ExecContext(ctx, stmt); err != nil {
+			t.Fatalf("exec %q: %v", stmt, err)
+		}
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("BeginTx: %v", err)
+	}
+	if err := migrateV6(tx); err != nil {
+		tx.Rollback()
+		t.Fatalf("migrateV6: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+
+	got, err := s.GetUserByFoldedUsername(ctx, "alice")
+	if err != nil {
+		t.Fatalf("GetUserByFoldedUsername: %v", err)
+	}
+	if got.ID != "u1" {
+		t.Errorf("folded owner = %q, want oldest user u1", got.ID)
+	}
+	newer, err := s.GetUserByUsername(ctx, "Alice")
+	if err != nil {
+		t.Fatalf("GetUserByUsername: %v", err)
+	}
+	if newer.UsernameFolded != "" {
+		t.Errorf("newer duplicate UsernameFolded = %q, want empty", newer.UsernameFolded)
+	}
+}
+
 func TestUpdateUser(t *testing.T) {
 	tests := []struct {
 		name    string
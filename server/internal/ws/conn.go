@@ -5,12 +5,13 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"math"
 	"sync"
 	"sync/atomic"
 	"time"
 
-	"nhooyr.io/websocket"
 	"google.golang.org/protobuf/proto"
+	"nhooyr.io/websocket"
 
 	"github.com/sovereign-im/sovereign/server/internal/auth"
 	"github.com/sovereign-im/sovereign/server/internal/mls"
@@ -28,14 +29,23 @@ const (
 // Auth timeout before connection is closed.
 const authTimeout = 10 * time.Second
 
+// sessionRetryInterval is how long to wait before re-checking a session
+// after a transient store error.
+const sessionRetryInterval = time.Minute
+
+// slowConsumerTimeout is how long a connection's send buffer may stay
+// saturated, dropping frames, before the connection is closed.
+const slowConsumerTimeout = 5 * time.Second
+
 // Conn wraps a WebSocket connection with read/write pumps and auth state.
 type Conn struct {
-	id     string
-	ws     *websocket.Conn
-	hub    *Hub
-	send   chan []byte
-	once   sync.Once
-	cancel context.CancelFunc
+	id       string
+	remoteIP string
+	ws       *websocket.Conn
+	hub      *Hub
+	send     chan []byte
+	once     sync.Once
+	cancel   context.CancelFunc
 
 	maxMessageSize int64
 
@@ -47,15 +57,40 @@ type Conn struct {
 	challengeID string
 	authTimer   *time.Timer
 
+	// Session state, set once authenticated. sessionTimer fires when the
+	// session is due to expire; lastActivity (Unix seconds) lets a busy
+	// connection keep its session from idling out.
+	sessionID    string
+	sessionTimer atomic.Pointer[time.Timer]
+	lastActivity atomic.Int64
+
 	// Messaging dependencies.
-	store      *store.Store
+	store      store.Backend
 	mlsService *mls.Service
+
+	// Slow-consumer state; see enqueue. saturatedSince is when a frame was
+	// first dropped since the send buffer last drained (Unix nanoseconds,
+	// 0 if none), and lostStored whether a stored message or notice was
+	// among them.
+	droppedEphemeral atomic.Uint64
+	droppedStored    atomic.Uint64
+	saturatedSince   atomic.Int64
+	lostStored       atomic.Bool
+	slowClosing      atomic.Bool
+
+	// Catch-up state: syncing is set while deliverPendingMessages streams
+	// stored messages, and syncMissed once a live stored message is
+	// deferred to it or dropped in the meantime.
+	syncMu     sync.Mutex
+	syncing    bool
+	syncMissed bool
 }
 
 // NewConn creates a new Conn.
-func NewConn(id string, ws *websocket.Conn, hub *Hub, maxMessageSize int, authService *auth.Service, st *store.Store, mlsSvc *mls.Service) *Conn {
+func NewConn(id, remoteIP string, ws *websocket.Conn, hub *Hub, maxMessageSize int, authService *auth.Service, st store.Backend, mlsSvc *mls.Service) *Conn {
 	c := &Conn{
 		id:             id,
+		remoteIP:       remoteIP,
 		ws:             ws,
 		hub:            hub,
 		send:           make(chan []byte, 256),
@@ -130,6 +165,8 @@ func (c *Conn) readPump(ctx context.Context) {
 			return
 		}
 
+		c.lastActivity.Store(time.Now().Unix())
+
 		var env protocol.Envelope
 		if err := proto.Unmarshal(data, &env); err != nil {
 			log.Printf("[%s] Failed to unmarshal envelope: %v", c.id, err)
@@ -155,6 +192,9 @@ func (c *Conn) writePump(ctx context.Context) {
 				log.Printf("[%s] Write error: %v", c.id, err)
 				return
 			}
+			if len(c.send) == 0 && c.saturatedSince.Load() != 0 {
+				c.drained()
+			}
 		case <-ctx.Done():
 			return
 		}
@@ -186,6 +226,8 @@ func (c *Conn) handleAuthMessage(ctx context.Context, env *protocol.Envelope) {
 		c.handleAuthRegisterRequest(ctx, env)
 	case protocol.MessageType_AUTH_REGISTER_RESPONSE:
 		c.handleAuthRegisterResponse(ctx, env)
+	case protocol.MessageType_AUTH_RECOVER_REQUEST:
+		c.handleAuthRecoverRequest(ctx, env)
 	case protocol.MessageType_PING:
 		c.handlePing(env)
 	default:
@@ -206,6 +248,8 @@ func (c *Conn) handleReadyMessage(ctx context.Context, env *protocol.Envelope) {
 		c.handleMessageSend(ctx, env)
 	case protocol.MessageType_MESSAGE_ACK:
 		c.handleMessageAck(ctx, env)
+	case protocol.MessageType_DELIVERY_TOKEN_REQUEST:
+		c.handleDeliveryTokenRequest(ctx, env)
 
 	// Groups
 	case protocol.MessageType_GROUP_CREATE:
@@ -224,6 +268,16 @@ func (c *Conn) handleReadyMessage(ctx context.Context, env *protocol.Envelope) {
 		c.handleMLSWelcome(ctx, env)
 	case protocol.MessageType_MLS_COMMIT:
 		c.handleMLSCommit(ctx, env)
+	case protocol.MessageType_MLS_PROPOSAL:
+		c.handleMLSProposal(ctx, env)
+	case protocol.MessageType_MLS_GROUP_INFO_UPLOAD:
+		c.handleMLSGroupInfoUpload(ctx, env)
+	case protocol.MessageType_MLS_GROUP_INFO_FETCH:
+		c.handleMLSGroupInfoFetch(ctx, env)
+
+	// Profile
+	case protocol.MessageType_PROFILE_UPDATE:
+		c.handleProfileUpdate(ctx, env)
 
 	default:
 		c.sendError(env, 3001, "Unknown message type", false)
@@ -241,6 +295,21 @@ func (c *Conn) handleMessageSend(ctx context.Context, env *protocol.Envelope) {
 		return
 	}
 
+	conv, err := c.store.GetConversation(ctx, msg.ConversationId)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			c.sendError(env, 4001, "Not a member of this conversation", false)
+			return
+		}
+		log.Printf("[%s] get conversation error: %v", c.id, err)
+		c.sendError(env, 9001, "Internal error", false)
+		return
+	}
+	if conv.SealedSender {
+		c.handleSealedMessageSend(ctx, env, &msg)
+		return
+	}
+
 	// Validate membership.
 	isMember, err := c.store.IsUserMember(ctx, msg.ConversationId, c.userID)
 	if err != nil {
@@ -297,55 +366,170 @@ func (c *Conn) handleMessageSend(ctx context.Context, env *protocol.Envelope) {
 		log.Printf("[%s] get members error: %v", c.id, err)
 		return
 	}
+	// The message stays pending for each recipient until they acknowledge
+	// it, so one that is not sent now is sent when they next connect.
 	for _, m := range members {
 		if m.UserID == c.userID {
 			continue
 		}
-		if c.hub.SendToUser(m.UserID, receiveEnv) {
-			// Mark delivered for online recipients.
-			if err := c.store.UpdateDeliveryStatus(ctx, messageID, m.UserID, store.DeliveryDelivered); err != nil {
-				log.Printf("[%s] update delivery status error: %v", c.id, err)
-			}
-		}
+		c.hub.SendStoredToUser(m.UserID, receiveEnv)
 	}
 }
 
-func (c *Conn) handleMessageAck(ctx context.Context, env *protocol.Envelope) {
-	var msg protocol.MessageAck
-	if err := proto.Unmarshal(env.Payload, &msg); err != nil {
-		c.sendError(env, 3001, "Invalid message.ack payload", false)
+// handleSealedMessageSend stores and delivers a message in a sealed-sender
+// conversation. The delivery token stands in for the sender's identity: the
+// message is stored without a sender and queued for every member, sender
+// included, so neither the stored message nor its delivery records reveal
+// who sent it.
+func (c *Conn) handleSealedMessageSend(ctx context.Context, env *protocol.Envelope, msg *protocol.MessageSend) {
+	if err := c.mlsService.VerifyDeliveryToken(ctx, msg.ConversationId, msg.DeliveryToken); err != nil {
+		if errors.Is(err, mls.ErrInvalidDeliveryToken) {
+			c.sendError(env, 4006, "Invalid or expired delivery token", false)
+			return
+		}
+		log.Printf("[%s] verify delivery token error: %v", c.id, err)
+		c.sendError(env, 9001, "Internal error", false)
 		return
 	}
 
-	// Update delivery status to DELIVERED.
-	if err := c.store.UpdateDeliveryStatus(ctx, msg.MessageId, c.userID, store.DeliveryDelivered); err != nil {
-		if !errors.Is(err, store.ErrNotFound) {
-			log.Printf("[%s] update delivery status error: %v", c.id, err)
+	messageID, serverTS, err := c.store.InsertMessage(ctx, msg.ConversationId, "", msg.EncryptedPayload, store.MsgTypeApplication, 0)
+	if err != nil {
+		log.Printf("[%s] insert message error: %v", c.id, err)
+		c.sendError(env, 9001, "Failed to store message", false)
+		return
+	}
+
+	receivePayload, err := proto.Marshal(&protocol.MessageReceive{
+		MessageId:        messageID,
+		ConversationId:   msg.ConversationId,
+		EncryptedPayload: msg.EncryptedPayload,
+		ServerTimestamp:  serverTS,
+		MessageType:      msg.MessageType,
+	})
+	if err != nil {
+		log.Printf("[%s] marshal message receive error: %v", c.id, err)
+		return
+	}
+
+	members, err := c.store.GetMembers(ctx, msg.ConversationId)
+	if err != nil {
+		log.Printf("[%s] get members error: %v", c.id, err)
+		return
+	}
+	for _, m := range members {
+		if m.UserID == c.userID {
+			// The sender's copy carries the request_id as confirmation.
+			// Its delivery stays pending until acknowledged, like every
+			// other member's, so it does not stand out.
+			c.sendEnvelope(&protocol.Envelope{
+				Type:      protocol.MessageType_MESSAGE_RECEIVE,
+				RequestId: env.RequestId,
+				Payload:   receivePayload,
+			})
+			continue
 		}
+		c.hub.SendStoredToUser(m.UserID, &protocol.Envelope{
+			Type:    protocol.MessageType_MESSAGE_RECEIVE,
+			Payload: receivePayload,
+		})
+	}
+}
+
+// handleDeliveryTokenRequest issues a delivery token for a sealed-sender
+// conversation the user is a member of.
+func (c *Conn) handleDeliveryTokenRequest(ctx context.Context, env *protocol.Envelope) {
+	var msg protocol.DeliveryTokenRequest
+	if err := proto.Unmarshal(env.Payload, &msg); err != nil {
+		c.sendError(env, 3001, "Invalid delivery_token.request payload", false)
 		return
 	}
 
-	// Notify sender that message was delivered.
-	senderID, err := c.store.GetMessageSenderID(ctx, msg.MessageId)
+	token, expiresAt, err := c.mlsService.IssueDeliveryToken(ctx, msg.ConversationId, c.userID)
 	if err != nil {
-		log.Printf("[%s] get message sender error: %v", c.id, err)
+		switch {
+		case errors.Is(err, mls.ErrNotMember):
+			c.sendError(env, 4001, "Not a member of this conversation", false)
+		case errors.Is(err, mls.ErrSealedSenderDisabled):
+			c.sendError(env, 4007, "Conversation does not use sealed sender", false)
+		default:
+			log.Printf("[%s] issue delivery token error: %v", c.id, err)
+			c.sendError(env, 9001, "Failed to issue delivery token", false)
+		}
 		return
 	}
 
-	deliveredMsg := &protocol.MessageDelivered{
-		MessageId:   msg.MessageId,
-		DeliveredTo: c.userID,
+	c.sendTypedResponse(env, protocol.MessageType_DELIVERY_TOKEN, &protocol.DeliveryToken{
+		ConversationId: msg.ConversationId,
+		Token:          token,
+		ExpiresAt:      expiresAt,
+	})
+}
+
+// maxAckIDs bounds the number of messages one MESSAGE_ACK acknowledges.
+const maxAckIDs = 1000
+
+func (c *Conn) handleMessageAck(ctx context.Context, env *protocol.Envelope) {
+	var msg protocol.MessageAck
+	if err := proto.Unmarshal(env.Payload, &msg); err != nil {
+		c.sendError(env, 3001, "Invalid message.ack payload", false)
+		return
+	}
+	ids := msg.MessageIds
+	if msg.MessageId != "" {
+		ids = append([]string{msg.MessageId}, ids...)
 	}
-	deliveredPayload, err := proto.Marshal(deliveredMsg)
+	if len(ids) > maxAckIDs {
+		c.sendError(env, 3001, fmt.Sprintf("Too many message IDs in one message.ack (max %d)", maxAckIDs), false)
+		return
+	}
+	if len(ids) == 0 {
+		return
+	}
+
+	// Update delivery status to DELIVERED, in one transaction however
+	// many messages are acknowledged.
+	delivered, err := c.store.MarkDelivered(ctx, c.userID, ids)
 	if err != nil {
-		log.Printf("[%s] marshal delivered error: %v", c.id, err)
+		log.Printf("[%s] update delivery status error: %v", c.id, err)
 		return
 	}
-	deliveredEnv := &protocol.Envelope{
-		Type:    protocol.MessageType_MESSAGE_DELIVERED,
-		Payload: deliveredPayload,
+	c.notifyDelivered(delivered)
+}
+
+// notifyDelivered tells the senders of messages the user acknowledged that
+// they were delivered, with one MESSAGE_DELIVERED per sender. Only
+// deliveries the acknowledgement changed are reported, so acknowledging a
+// message again does not notify its sender again. Sealed-sender messages
+// have no sender to notify, and users are not told about their own.
+func (c *Conn) notifyDelivered(delivered []store.DeliveredMessage) {
+	var senders []string
+	bySender := make(map[string][]string)
+	for _, d := range delivered {
+		if d.SenderID == "" || d.SenderID == c.userID {
+			continue
+		}
+		if bySender[d.SenderID] == nil {
+			senders = append(senders, d.SenderID)
+		}
+		bySender[d.SenderID] = append(bySender[d.SenderID], d.MessageID)
+	}
+
+	for _, senderID := range senders {
+		ids := bySender[senderID]
+		deliveredPayload, err := proto.Marshal(&protocol.MessageDelivered{
+			MessageId:   ids[0],
+			DeliveredTo: c.userID,
+			MessageIds:  ids[1:],
+		})
+		if err != nil {
+			log.Printf("[%s] marshal delivered error: %v", c.id, err)
+			return
+		}
+		c.hub.SendToUser(senderID, &protocol.Envelope{
+			Type:    protocol.MessageType_MESSAGE_DELIVERED,
+			Payload: deliveredPayload,
+		})
 	}
-	c.hub.SendToUser(senderID, deliveredEnv)
 }
 
 // ============================================================================
@@ -359,7 +543,9 @@ func (c *Conn) handleGroupCreate(ctx context.Context, env *protocol.Envelope) {
 		return
 	}
 
-	conv, err := c.store.CreateConversation(ctx, msg.Title, c.userID, msg.MemberIds)
+	conv, err := c.store.CreateConversationWithOptions(ctx, msg.Title, c.userID, msg.MemberIds, store.ConversationOptions{
+		SealedSender: msg.SealedSender,
+	})
 	if err != nil {
 		log.Printf("[%s] create conversation error: %v", c.id, err)
 		c.sendError(env, 9001, "Failed to create group", false)
@@ -394,11 +580,21 @@ func (c *Conn) handleGroupCreate(ctx context.Context, env *protocol.Envelope) {
 		ConversationId: conv.ID,
 		Title:          msg.Title,
 		Members:        pbMembers,
+		SealedSender:   conv.SealedSender,
 	}
 	c.sendTypedResponse(env, protocol.MessageType_GROUP_CREATED, created)
 
-	// Notify all members with GROUP_MEMBER_ADDED.
+	// Notify the other members with GROUP_MEMBER_ADDED for each member the
+	// creator added. These are notices, like those for group.invite, so a
+	// member who cannot take one is disconnected rather than left without it.
+	memberIDs := make([]string, len(members))
+	for i, m := range members {
+		memberIDs[i] = m.UserID
+	}
 	for _, m := range members {
+		if m.UserID == c.userID {
+			continue
+		}
 		added := &protocol.GroupMemberAdded{
 			ConversationId: conv.ID,
 			UserId:         m.UserID,
@@ -412,9 +608,7 @@ func (c *Conn) handleGroupCreate(ctx context.Context, env *protocol.Envelope) {
 			Type:    protocol.MessageType_GROUP_MEMBER_ADDED,
 			Payload: addedPayload,
 		}
-		if m.UserID != c.userID {
-			c.hub.SendToUser(m.UserID, addedEnv)
-		}
+		c.hub.BroadcastNoticeToGroup(memberIDs, addedEnv, c.userID)
 	}
 }
 
@@ -475,7 +669,7 @@ func (c *Conn) handleGroupInvite(ctx context.Context, env *protocol.Envelope) {
 	for i, m := range members {
 		memberIDs[i] = m.UserID
 	}
-	c.hub.BroadcastToGroup(memberIDs, addedEnv, "")
+	c.hub.BroadcastNoticeToGroup(memberIDs, addedEnv, "")
 }
 
 func (c *Conn) handleGroupLeave(ctx context.Context, env *protocol.Envelope) {
@@ -533,7 +727,57 @@ func (c *Conn) handleGroupLeave(ctx context.Context, env *protocol.Envelope) {
 	for i, m := range members {
 		memberIDs[i] = m.UserID
 	}
-	c.hub.BroadcastToGroup(memberIDs, removedEnv, "")
+	c.hub.BroadcastNoticeToGroup(memberIDs, removedEnv, "")
+}
+
+// ============================================================================
+// Profile Handlers
+// ============================================================================
+
+func (c *Conn) handleProfileUpdate(ctx context.Context, env *protocol.Envelope) {
+	var msg protocol.ProfileUpdate
+	if err := proto.Unmarshal(env.Payload, &msg); err != nil {
+		c.sendError(env, 3001, "Invalid profile.update payload", false)
+		return
+	}
+
+	user, err := c.authService.UpdateDisplayName(ctx, c.userID, msg.DisplayName)
+	if err != nil {
+		switch {
+		case errors.Is(err, auth.ErrInvalidDisplayName):
+			c.sendError(env, 1008, err.Error(), false)
+		case errors.Is(err, auth.ErrAccountDisabled):
+			c.sendError(env, 2004, "Account disabled", true)
+			c.ws.Close(websocket.StatusCode(4005), "Account Disabled")
+			c.close()
+		default:
+			log.Printf("[%s] update display name error: %v", c.id, err)
+			c.sendError(env, 9001, "Internal error", false)
+		}
+		return
+	}
+
+	notify := &protocol.ProfileNotify{
+		UserId:      user.ID,
+		Username:    user.Username,
+		DisplayName: user.DisplayName,
+	}
+	c.sendTypedResponse(env, protocol.MessageType_PROFILE_NOTIFY, notify)
+
+	// Fan out to everyone who shares a conversation with this user.
+	contactIDs, err := c.store.GetContactIDs(ctx, c.userID)
+	if err != nil {
+		log.Printf("[%s] get contacts error: %v", c.id, err)
+		return
+	}
+	payload, err := proto.Marshal(notify)
+	if err != nil {
+		return
+	}
+	c.hub.BroadcastToGroup(contactIDs, &protocol.Envelope{
+		Type:    protocol.MessageType_PROFILE_NOTIFY,
+		Payload: payload,
+	}, c.userID)
 }
 
 // ============================================================================
@@ -547,10 +791,21 @@ func (c *Conn) handleMLSKeyPackageUpload(ctx context.Context, env *protocol.Enve
 		return
 	}
 
-	if err := c.mlsService.UploadKeyPackage(ctx, c.userID, msg.KeyPackageData); err != nil {
-		if errors.Is(err, mls.ErrInvalidPayload) {
-			c.sendError(env, 5001, "Invalid key package data", false)
-		} else {
+	packages := msg.KeyPackages
+	var lastResort []byte
+	if msg.LastResort {
+		lastResort = msg.KeyPackageData
+	} else if len(msg.KeyPackageData) > 0 || len(packages) == 0 {
+		packages = append([][]byte{msg.KeyPackageData}, packages...)
+	}
+
+	if err := c.mlsService.UploadKeyPackages(ctx, c.userID, packages, lastResort); err != nil {
+		switch {
+		case errors.Is(err, mls.ErrInvalidPayload):
+			c.sendError(env, 5001, "Invalid key package data: "+err.Error(), false)
+		case errors.Is(err, mls.ErrBatchTooLarge):
+			c.sendError(env, 3001, "Too many key packages in one request", false)
+		default:
 			log.Printf("[%s] upload key package error: %v", c.id, err)
 			c.sendError(env, 9001, "Failed to store key package", false)
 		}
@@ -566,7 +821,18 @@ func (c *Conn) handleMLSKeyPackageFetch(ctx context.Context, env *protocol.Envel
 		return
 	}
 
-	data, err := c.mlsService.FetchKeyPackage(ctx, msg.UserId)
+	if msg.CipherSuite > math.MaxUint16 {
+		c.sendError(env, 3001, "Invalid cipher suite", false)
+		return
+	}
+	suite := uint16(msg.CipherSuite)
+
+	if len(msg.UserIds) > 0 {
+		c.handleMLSKeyPackageBatchFetch(ctx, env, msg.UserIds, suite)
+		return
+	}
+
+	data, err := c.mlsService.FetchKeyPackage(ctx, msg.UserId, suite)
 	if err != nil {
 		if errors.Is(err, mls.ErrNoKeyPackage) {
 			c.sendError(env, 5005, "No key package available for user", false)
@@ -582,6 +848,100 @@ func (c *Conn) handleMLSKeyPackageFetch(ctx context.Context, env *protocol.Envel
 		KeyPackageData: data,
 	}
 	c.sendTypedResponse(env, protocol.MessageType_MLS_KEY_PACKAGE_RESPONSE, resp)
+
+	c.notifyKeyPackagesLow(ctx, msg.UserId)
+}
+
+// handleMLSKeyPackageBatchFetch consumes one key package for each
+// distinct user in userIDs and replies with a result per user.
+func (c *Conn) handleMLSKeyPackageBatchFetch(ctx context.Context, env *protocol.Envelope, userIDs []string, suite uint16) {
+	seen := make(map[string]bool, len(userIDs))
+	distinct := make([]string, 0, len(userIDs))
+	for _, id := range userIDs {
+		if !seen[id] {
+			seen[id] = true
+			distinct = append(distinct, id)
+		}
+	}
+
+	data, err := c.mlsService.FetchKeyPackages(ctx, distinct, suite)
+	if err != nil {
+		if errors.Is(err, mls.ErrBatchTooLarge) {
+			c.sendError(env, 3001, "Too many users in one request", false)
+		} else {
+			log.Printf("[%s] fetch key packages error: %v", c.id, err)
+			c.sendError(env, 9001, "Failed to fetch key packages", false)
+		}
+		return
+	}
+
+	resp := &protocol.MLSKeyPackageResponse{}
+	for _, id := range distinct {
+		kp, ok := data[id]
+		resp.Results = append(resp.Results, &protocol.MLSKeyPackageResult{
+			UserId:         id,
+			KeyPackageData: kp,
+			Available:      ok,
+		})
+	}
+	c.sendTypedResponse(env, protocol.MessageType_MLS_KEY_PACKAGE_RESPONSE, resp)
+
+	for id := range data {
+		c.notifyKeyPackagesLow(ctx, id)
+	}
+}
+
+// notifyKeyPackagesLow sends MLS_KEY_PACKAGE_LOW to userID if they are
+// online and have fewer key packages than the server's minimum.
+func (c *Conn) notifyKeyPackagesLow(ctx context.Context, userID string) {
+	available, low, err := c.mlsService.KeyPackagesLow(ctx, userID)
+	if err != nil {
+		log.Printf("[%s] count key packages error: %v", c.id, err)
+		return
+	}
+	if !low {
+		return
+	}
+	payload, err := proto.Marshal(&protocol.MLSKeyPackageLow{
+		Available: int32(available),
+		Minimum:   int32(c.mlsService.MinKeyPackages()),
+	})
+	if err != nil {
+		return
+	}
+	c.hub.SendToUser(userID, &protocol.Envelope{
+		Type:    protocol.MessageType_MLS_KEY_PACKAGE_LOW,
+		Payload: payload,
+	})
+}
+
+// requestLeafUpdates sends MLS_UPDATE_REQUESTED to an online user for each
+// conversation they have not updated their leaf key in for the update
+// interval, at most once per interval.
+func requestLeafUpdates(ctx context.Context, hub *Hub, mlsSvc *mls.Service, userID string) {
+	updates, err := mlsSvc.ClaimStaleUpdates(ctx, userID)
+	if err != nil {
+		log.Printf("claim stale updates for %s error: %v", userID, err)
+		return
+	}
+	for _, u := range updates {
+		req := &protocol.MLSUpdateRequested{
+			ConversationId: u.GroupID,
+			LastUpdateAt:   u.UpdatedAt(),
+		}
+		if u.LastUpdateEpoch != nil {
+			req.LastUpdateEpoch = uint64(*u.LastUpdateEpoch)
+		}
+		payload, err := proto.Marshal(req)
+		if err != nil {
+			log.Printf("marshal update requested error: %v", err)
+			return
+		}
+		hub.SendToUser(userID, &protocol.Envelope{
+			Type:    protocol.MessageType_MLS_UPDATE_REQUESTED,
+			Payload: payload,
+		})
+	}
 }
 
 func (c *Conn) handleMLSWelcome(ctx context.Context, env *protocol.Envelope) {
@@ -591,11 +951,46 @@ func (c *Conn) handleMLSWelcome(ctx context.Context, env *protocol.Envelope) {
 		return
 	}
 
-	// Forward the Welcome to the recipient.
+	// Only members who may add others, the group admins, can send a
+	// Welcome, and only to a user already added to the conversation.
+	role, err := c.store.GetMemberRole(ctx, msg.ConversationId, c.userID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			c.sendError(env, 4001, "Not a member of this conversation", false)
+		} else {
+			log.Printf("[%s] get member role error: %v", c.id, err)
+			c.sendError(env, 9001, "Internal error", false)
+		}
+		return
+	}
+	if role != "admin" {
+		c.sendError(env, 4003, "Only admins can send Welcome messages", false)
+		return
+	}
+	isMember, err := c.store.IsUserMember(ctx, msg.ConversationId, msg.RecipientId)
+	if err != nil {
+		log.Printf("[%s] membership check error: %v", c.id, err)
+		c.sendError(env, 9001, "Internal error", false)
+		return
+	}
+	if !isMember {
+		c.sendError(env, 4003, "Recipient is not a member of this conversation", false)
+		return
+	}
+
+	// Store the Welcome for the recipient, so that it reaches them even if
+	// they are offline, and forward it.
+	messageID, _, err := c.store.InsertDirectMessage(ctx, msg.ConversationId, c.userID, msg.RecipientId, msg.WelcomeData, store.MsgTypeWelcome)
+	if err != nil {
+		log.Printf("[%s] insert welcome error: %v", c.id, err)
+		c.sendError(env, 9001, "Failed to store Welcome", false)
+		return
+	}
 	welcomeReceive := &protocol.MLSWelcomeReceive{
 		ConversationId: msg.ConversationId,
 		SenderId:       c.userID,
 		WelcomeData:    msg.WelcomeData,
+		MessageId:      messageID,
 	}
 	receivePayload, err := proto.Marshal(welcomeReceive)
 	if err != nil {
@@ -606,7 +1001,7 @@ func (c *Conn) handleMLSWelcome(ctx context.Context, env *protocol.Envelope) {
 		Type:    protocol.MessageType_MLS_WELCOME_RECEIVE,
 		Payload: receivePayload,
 	}
-	c.hub.SendToUser(msg.RecipientId, welcomeEnv)
+	c.hub.SendStoredToUser(msg.RecipientId, welcomeEnv)
 }
 
 func (c *Conn) handleMLSCommit(ctx context.Context, env *protocol.Envelope) {
@@ -616,6 +1011,11 @@ func (c *Conn) handleMLSCommit(ctx context.Context, env *protocol.Envelope) {
 		return
 	}
 
+	if len(msg.AddedUserIds) > 0 || len(msg.RemovedUserIds) > 0 {
+		c.handleMLSMembershipCommit(ctx, env, &msg)
+		return
+	}
+
 	// Validate membership.
 	isMember, err := c.store.IsUserMember(ctx, msg.ConversationId, c.userID)
 	if err != nil {
@@ -628,11 +1028,21 @@ func (c *Conn) handleMLSCommit(ctx context.Context, env *protocol.Envelope) {
 		return
 	}
 
+	// Like a Proposal, the Commit is stored so that members who are offline
+	// or miss it receive it when they reconnect.
+	messageID, _, err := c.store.InsertMessage(ctx, msg.ConversationId, c.userID, msg.CommitData, store.MsgTypeCommit, 0)
+	if err != nil {
+		log.Printf("[%s] insert commit error: %v", c.id, err)
+		c.sendError(env, 9001, "Failed to store Commit", false)
+		return
+	}
+
 	// Broadcast to all group members except sender.
 	commitBroadcast := &protocol.MLSCommitBroadcast{
 		ConversationId: msg.ConversationId,
 		SenderId:       c.userID,
 		CommitData:     msg.CommitData,
+		MessageId:      messageID,
 	}
 	broadcastPayload, err := proto.Marshal(commitBroadcast)
 	if err != nil {
@@ -644,6 +1054,196 @@ func (c *Conn) handleMLSCommit(ctx context.Context, env *protocol.Envelope) {
 		Payload: broadcastPayload,
 	}
 
+	members, err := c.store.GetMembers(ctx, msg.ConversationId)
+	if err != nil {
+		log.Printf("[%s] get members error: %v", c.id, err)
+		return
+	}
+	for _, m := range members {
+		if m.UserID == c.userID {
+			continue
+		}
+		c.hub.SendStoredToUser(m.UserID, broadcastEnv)
+	}
+
+	c.recordLeafUpdate(ctx, msg.ConversationId, msg.CommitData)
+}
+
+func (c *Conn) handleMLSGroupInfoUpload(ctx context.Context, env *protocol.Envelope) {
+	var msg protocol.MLSGroupInfoUpload
+	if err := proto.Unmarshal(env.Payload, &msg); err != nil {
+		c.sendError(env, 3001, "Invalid mls.group_info.upload payload", false)
+		return
+	}
+
+	if _, err := c.mlsService.PublishGroupInfo(ctx, msg.ConversationId, c.userID, msg.GroupInfoData); err != nil {
+		switch {
+		case errors.Is(err, mls.ErrNotMember):
+			c.sendError(env, 4001, "Not a member of this conversation", false)
+		case errors.Is(err, mls.ErrInvalidGroupInfo):
+			c.sendError(env, 5006, "Invalid group info: "+err.Error(), false)
+		case errors.Is(err, mls.ErrStaleGroupInfo):
+			c.sendError(env, 5004, "Group info is older than the stored epoch", false)
+		default:
+			log.Printf("[%s] publish group info error: %v", c.id, err)
+			c.sendError(env, 9001, "Failed to store group info", false)
+		}
+		return
+	}
+	// No explicit response, as for key package uploads.
+}
+
+func (c *Conn) handleMLSGroupInfoFetch(ctx context.Context, env *protocol.Envelope) {
+	var msg protocol.MLSGroupInfoFetch
+	if err := proto.Unmarshal(env.Payload, &msg); err != nil {
+		c.sendError(env, 3001, "Invalid mls.group_info.fetch payload", false)
+		return
+	}
+
+	data, epoch, err := c.mlsService.GroupInfo(ctx, msg.ConversationId, c.userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, mls.ErrNotMember):
+			c.sendError(env, 4001, "Not a member of this conversation", false)
+		case errors.Is(err, mls.ErrNoGroupInfo):
+			c.sendError(env, 5007, "No group info available for conversation", false)
+		default:
+			log.Printf("[%s] fetch group info error: %v", c.id, err)
+			c.sendError(env, 9001, "Failed to fetch group info", false)
+		}
+		return
+	}
+
+	resp := &protocol.MLSGroupInfoResponse{
+		ConversationId: msg.ConversationId,
+		GroupInfoData:  data,
+		Epoch:          epoch,
+	}
+	c.sendTypedResponse(env, protocol.MessageType_MLS_GROUP_INFO_RESPONSE, resp)
+}
+
+// handleMLSProposal stores a by-reference Proposal and relays it to the
+// other members, so that a later Commit from any member can include it.
+// Members who are offline receive it when they reconnect.
+func (c *Conn) handleMLSProposal(ctx context.Context, env *protocol.Envelope) {
+	var msg protocol.MLSProposal
+	if err := proto.Unmarshal(env.Payload, &msg); err != nil {
+		c.sendError(env, 3001, "Invalid mls.proposal payload", false)
+		return
+	}
+	if len(msg.ProposalData) == 0 {
+		c.sendError(env, 3001, "Missing proposal data", false)
+		return
+	}
+
+	// Validate membership.
+	isMember, err := c.store.IsUserMember(ctx, msg.ConversationId, c.userID)
+	if err != nil {
+		log.Printf("[%s] membership check error: %v", c.id, err)
+		c.sendError(env, 9001, "Internal error", false)
+		return
+	}
+	if !isMember {
+		c.sendError(env, 4001, "Not a member of this conversation", false)
+		return
+	}
+
+	messageID, serverTS, err := c.store.InsertMessage(ctx, msg.ConversationId, c.userID, msg.ProposalData, store.MsgTypeProposal, 0)
+	if err != nil {
+		log.Printf("[%s] insert proposal error: %v", c.id, err)
+		c.sendError(env, 9001, "Failed to store proposal", false)
+		return
+	}
+	c.recordLeafUpdate(ctx, msg.ConversationId, msg.ProposalData)
+
+	broadcast := &protocol.MLSProposalBroadcast{
+		MessageId:       messageID,
+		ConversationId:  msg.ConversationId,
+		SenderId:        c.userID,
+		ProposalData:    msg.ProposalData,
+		ServerTimestamp: serverTS,
+	}
+	broadcastPayload, err := proto.Marshal(broadcast)
+	if err != nil {
+		log.Printf("[%s] marshal proposal broadcast error: %v", c.id, err)
+		return
+	}
+	broadcastEnv := &protocol.Envelope{
+		Type:    protocol.MessageType_MLS_PROPOSAL_BROADCAST,
+		Payload: broadcastPayload,
+	}
+
+	members, err := c.store.GetMembers(ctx, msg.ConversationId)
+	if err != nil {
+		log.Printf("[%s] get members error: %v", c.id, err)
+		return
+	}
+	for _, m := range members {
+		if m.UserID == c.userID {
+			continue
+		}
+		c.hub.SendStoredToUser(m.UserID, broadcastEnv)
+	}
+}
+
+// recordLeafUpdate records that the user updated their leaf key in a
+// conversation by sending a Commit or Proposal.
+func (c *Conn) recordLeafUpdate(ctx context.Context, conversationID string, message []byte) {
+	if err := c.mlsService.RecordLeafUpdate(ctx, conversationID, c.userID, message); err != nil {
+		log.Printf("[%s] record leaf update error: %v", c.id, err)
+	}
+}
+
+// handleMLSMembershipCommit stores a Commit that adds or removes members
+// and applies the same change to the server's group membership in one
+// transaction, so the two cannot drift apart. It then broadcasts the Commit
+// and the membership notifications.
+func (c *Conn) handleMLSMembershipCommit(ctx context.Context, env *protocol.Envelope, msg *protocol.MLSCommit) {
+	seen := make(map[string]bool, len(msg.AddedUserIds)+len(msg.RemovedUserIds))
+	for _, id := range append(append([]string(nil), msg.AddedUserIds...), msg.RemovedUserIds...) {
+		if id == "" || seen[id] {
+			c.sendError(env, 3001, "Invalid membership change: empty or repeated user ID", false)
+			return
+		}
+		seen[id] = true
+	}
+	if seen[c.userID] {
+		c.sendError(env, 4004, "Use group.leave to leave a group", false)
+		return
+	}
+
+	// Only admins can change membership, as for group.invite.
+	role, err := c.store.GetMemberRole(ctx, msg.ConversationId, c.userID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			c.sendError(env, 4001, "Not a member of this conversation", false)
+		} else {
+			log.Printf("[%s] get member role error: %v", c.id, err)
+			c.sendError(env, 9001, "Internal error", false)
+		}
+		return
+	}
+	if role != "admin" {
+		c.sendError(env, 4003, "Only admins can add or remove members", false)
+		return
+	}
+
+	messageID, _, err := c.store.CommitMembershipChange(ctx, msg.ConversationId, c.userID,
+		msg.CommitData, msg.AddedUserIds, msg.RemovedUserIds)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrConflict):
+			c.sendError(env, 4002, "User is already a member", false)
+		case errors.Is(err, store.ErrNotFound):
+			c.sendError(env, 4003, "User is not a member of this conversation", false)
+		default:
+			log.Printf("[%s] commit membership change error: %v", c.id, err)
+			c.sendError(env, 9001, "Failed to apply membership change", false)
+		}
+		return
+	}
+	c.recordLeafUpdate(ctx, msg.ConversationId, msg.CommitData)
+
 	members, err := c.store.GetMembers(ctx, msg.ConversationId)
 	if err != nil {
 		log.Printf("[%s] get members error: %v", c.id, err)
@@ -653,40 +1253,209 @@ func (c *Conn) handleMLSCommit(ctx context.Context, env *protocol.Envelope) {
 	for i, m := range members {
 		memberIDs[i] = m.UserID
 	}
-	c.hub.BroadcastToGroup(memberIDs, broadcastEnv, c.userID)
+
+	// The Commit goes to the members before the change: removed members
+	// need it, added members join from their Welcome instead.
+	commitBroadcast := &protocol.MLSCommitBroadcast{
+		ConversationId: msg.ConversationId,
+		SenderId:       c.userID,
+		CommitData:     msg.CommitData,
+		AddedUserIds:   msg.AddedUserIds,
+		RemovedUserIds: msg.RemovedUserIds,
+		MessageId:      messageID,
+	}
+	broadcastPayload, err := proto.Marshal(commitBroadcast)
+	if err != nil {
+		log.Printf("[%s] marshal commit broadcast error: %v", c.id, err)
+		return
+	}
+	broadcastEnv := &protocol.Envelope{
+		Type:    protocol.MessageType_MLS_COMMIT_BROADCAST,
+		Payload: broadcastPayload,
+	}
+	added := make(map[string]bool, len(msg.AddedUserIds))
+	for _, id := range msg.AddedUserIds {
+		added[id] = true
+	}
+	for _, id := range append(memberIDs, msg.RemovedUserIds...) {
+		if id == c.userID || added[id] {
+			continue
+		}
+		c.hub.SendStoredToUser(id, broadcastEnv)
+	}
+
+	for _, id := range msg.AddedUserIds {
+		addedPayload, err := proto.Marshal(&protocol.GroupMemberAdded{
+			ConversationId: msg.ConversationId,
+			UserId:         id,
+			AddedBy:        c.userID,
+		})
+		if err != nil {
+			return
+		}
+		c.hub.BroadcastNoticeToGroup(memberIDs, &protocol.Envelope{
+			Type:    protocol.MessageType_GROUP_MEMBER_ADDED,
+			Payload: addedPayload,
+		}, "")
+	}
+	for _, id := range msg.RemovedUserIds {
+		removedPayload, err := proto.Marshal(&protocol.GroupMemberRemoved{
+			ConversationId: msg.ConversationId,
+			UserId:         id,
+			RemovedBy:      c.userID,
+		})
+		if err != nil {
+			return
+		}
+		// The removed member is told too.
+		c.hub.BroadcastNoticeToGroup(append(memberIDs, id), &protocol.Envelope{
+			Type:    protocol.MessageType_GROUP_MEMBER_REMOVED,
+			Payload: removedPayload,
+		}, "")
+	}
 }
 
 // ============================================================================
 // Offline Delivery
 // ============================================================================
 
-// deliverPendingMessages sends all pending messages to the user on connect.
+// pendingPageSize is how many pending messages are loaded from the store at
+// a time during catch-up.
+const pendingPageSize = 100
+
+// deliverPendingMessages streams the user's pending messages on connect, a
+// page at a time, then sends SYNC_COMPLETE. Unlike other sends it waits for
+// room in the send buffer rather than dropping, so a long backlog is paced
+// by the client. The messages stay pending until the client acknowledges
+// them with MESSAGE_ACK; any it has not acknowledged when the connection
+// drops are sent again on the next one.
 func (c *Conn) deliverPendingMessages(ctx context.Context) {
-	msgs, err := c.store.GetPendingMessages(ctx, c.userID)
+	defer func() {
+		c.syncMu.Lock()
+		c.syncing, c.syncMissed = false, false
+		c.syncMu.Unlock()
+	}()
+	var afterTS int64
+	var afterID string
+	var sent uint32
+	for {
+		msgs, err := c.store.GetPendingMessagesAfter(ctx, c.userID, afterTS, afterID, pendingPageSize)
+		if err != nil {
+			log.Printf("[%s] get pending messages error: %v", c.id, err)
+			return
+		}
+		for _, m := range msgs {
+			env, err := pendingEnvelope(m)
+			if err != nil {
+				log.Printf("[%s] marshal pending message %s error: %v", c.id, m.ID, err)
+				continue
+			}
+			if err := c.sendEnvelopeWait(ctx, env); err != nil {
+				return // the connection closed
+			}
+			sent++
+		}
+		if len(msgs) > 0 {
+			last := msgs[len(msgs)-1]
+			afterTS, afterID = last.ServerTimestamp, last.ID
+		}
+		if len(msgs) < pendingPageSize && c.endSync() {
+			break
+		}
+	}
+
+	payload, err := proto.Marshal(&protocol.SyncComplete{MessageCount: sent})
 	if err != nil {
-		log.Printf("[%s] get pending messages error: %v", c.id, err)
+		log.Printf("[%s] marshal sync complete error: %v", c.id, err)
+		return
+	}
+	if err := c.sendEnvelopeWait(ctx, &protocol.Envelope{
+		Type:    protocol.MessageType_SYNC_COMPLETE,
+		Payload: payload,
+	}); err != nil {
 		return
 	}
+	if sent > 0 {
+		log.Printf("[%s] Sent %d pending messages to user %s", c.id, sent, c.userID)
+	}
+}
+
+// deferToSync reports whether catch-up is running, in which case a live
+// stored message is left to it: the message is already stored, and
+// catch-up sends it in order when it reads on. Sending it live as well
+// would deliver it twice.
+func (c *Conn) deferToSync() bool {
+	c.syncMu.Lock()
+	defer c.syncMu.Unlock()
+	if c.syncing {
+		c.syncMissed = true
+	}
+	return c.syncing
+}
 
-	for _, m := range msgs {
-		receiveMsg := &protocol.MessageReceive{
+// endSync ends catch-up and reports true, unless a live stored message was
+// deferred to it or dropped while it ran. The message may have been stored
+// after the last page was read, so then it reports false and catch-up
+// reads on.
+func (c *Conn) endSync() bool {
+	c.syncMu.Lock()
+	defer c.syncMu.Unlock()
+	if c.syncMissed {
+		c.syncMissed = false
+		return false
+	}
+	c.syncing = false
+	return true
+}
+
+// pendingEnvelope returns the envelope a stored message is delivered in,
+// according to its type.
+func pendingEnvelope(m *store.Message) (*protocol.Envelope, error) {
+	var msgType protocol.MessageType
+	var msg proto.Message
+	switch m.MessageType {
+	case store.MsgTypeCommit:
+		msgType = protocol.MessageType_MLS_COMMIT_BROADCAST
+		msg = &protocol.MLSCommitBroadcast{
+			MessageId:      m.ID,
+			ConversationId: m.GroupID,
+			SenderId:       m.SenderID,
+			CommitData:     m.Payload,
+			AddedUserIds:   m.AddedUserIDs,
+			RemovedUserIds: m.RemovedUserIDs,
+		}
+	case store.MsgTypeWelcome:
+		msgType = protocol.MessageType_MLS_WELCOME_RECEIVE
+		msg = &protocol.MLSWelcomeReceive{
+			MessageId:      m.ID,
+			ConversationId: m.GroupID,
+			SenderId:       m.SenderID,
+			WelcomeData:    m.Payload,
+		}
+	case store.MsgTypeProposal:
+		msgType = protocol.MessageType_MLS_PROPOSAL_BROADCAST
+		msg = &protocol.MLSProposalBroadcast{
+			MessageId:       m.ID,
+			ConversationId:  m.GroupID,
+			SenderId:        m.SenderID,
+			ProposalData:    m.Payload,
+			ServerTimestamp: m.ServerTimestamp,
+		}
+	default:
+		msgType = protocol.MessageType_MESSAGE_RECEIVE
+		msg = &protocol.MessageReceive{
 			MessageId:        m.ID,
 			ConversationId:   m.GroupID,
 			SenderId:         m.SenderID,
 			EncryptedPayload: m.Payload,
 			ServerTimestamp:  m.ServerTimestamp,
 		}
-		c.sendTypedResponse(nil, protocol.MessageType_MESSAGE_RECEIVE, receiveMsg)
-
-		// Mark as delivered.
-		if err := c.store.UpdateDeliveryStatus(ctx, m.ID, c.userID, store.DeliveryDelivered); err != nil {
-			log.Printf("[%s] update delivery status for %s error: %v", c.id, m.ID, err)
-		}
 	}
-
-	if len(msgs) > 0 {
-		log.Printf("[%s] Delivered %d pending messages to user %s", c.id, len(msgs), c.userID)
+	payload, err := proto.Marshal(msg)
+	if err != nil {
+		return nil, err
 	}
+	return &protocol.Envelope{Type: msgType, Payload: payload}, nil
 }
 
 // ============================================================================
@@ -703,19 +1472,24 @@ func (c *Conn) handleAuthRequest(ctx context.Context, env *protocol.Envelope) {
 
 	// Try session token reconnection: the client may send a session token
 	// in the username field for reconnection without a WebAuthn ceremony.
-	info, err := c.authService.ValidateSession(ctx, req.Username)
+	resumed, err := c.authService.ResumeSession(ctx, req.Username)
 	if err == nil {
-		// Valid session token — skip WebAuthn ceremony.
-		if !c.transitionToReady(ctx, info.UserID, info.Username) {
+		// Valid session token — skip WebAuthn ceremony. Token is only set
+		// if the session was rotated.
+		if !c.transitionToReady(ctx, resumed) {
 			return // auth timer already fired
 		}
-		c.sendAuthSuccess(env, "", info.UserID, info.Username, info.DisplayName)
-		log.Printf("[%s] Session token reconnection for user %s", c.id, info.Username)
+		c.sendAuthSuccess(env, resumed.Token, resumed.UserID, resumed.Username, resumed.DisplayName)
+		log.Printf("[%s] Session token reconnection for user %s", c.id, resumed.Username)
+		return
+	}
+	if errors.Is(err, auth.ErrSessionExpired) {
+		c.handleAuthError(env, err)
 		return
 	}
 
 	// Not a valid session token — proceed with normal WebAuthn login.
-	challenge, err := c.authService.BeginLogin(ctx, req.Username)
+	challenge, err := c.authService.BeginLogin(ctx, c.clientInfo(), req.Username)
 	if err != nil {
 		c.handleAuthError(env, err)
 		return
@@ -751,14 +1525,14 @@ func (c *Conn) handleAuthResponse(ctx context.Context, env *protocol.Envelope) {
 		Signature:         resp.Signature,
 	}
 
-	result, err := c.authService.FinishLogin(ctx, c.challengeID, assertion)
+	result, err := c.authService.FinishLogin(ctx, c.clientInfo(), c.challengeID, assertion)
 	c.challengeID = ""
 	if err != nil {
 		c.handleAuthError(env, err)
 		return
 	}
 
-	if !c.transitionToReady(ctx, result.UserID, result.Username) {
+	if !c.transitionToReady(ctx, result) {
 		return
 	}
 	c.sendAuthSuccess(env, result.Token, result.UserID, result.Username, result.DisplayName)
@@ -773,7 +1547,7 @@ func (c *Conn) handleAuthRegisterRequest(ctx context.Context, env *protocol.Enve
 		return
 	}
 
-	challenge, err := c.authService.BeginRegistration(ctx, req.Username, req.DisplayName)
+	challenge, err := c.authService.BeginRegistration(ctx, c.clientInfo(), req.Username, req.DisplayName, req.RecoveryCodes)
 	if err != nil {
 		c.handleAuthError(env, err)
 		return
@@ -816,44 +1590,138 @@ func (c *Conn) handleAuthRegisterResponse(ctx context.Context, env *protocol.Env
 		return
 	}
 
-	if !c.transitionToReady(ctx, result.UserID, result.Username) {
+	if !c.transitionToReady(ctx, result) {
 		return
 	}
 
+	// Account recovery revokes every other session; drop their live connections.
+	if result.Recovered {
+		n := c.hub.DisconnectUser(result.UserID, c, websocket.StatusCode(4004), "Session Revoked")
+		log.Printf("[%s] Account recovered for user %s, closed %d other connections", c.id, result.Username, n)
+	}
+
 	// Send AUTH_REGISTER_SUCCESS.
 	success := &protocol.AuthRegisterSuccess{
-		UserId:       result.UserID,
-		SessionToken: result.Token,
+		UserId:        result.UserID,
+		SessionToken:  result.Token,
+		RecoveryCodes: result.RecoveryCodes,
 	}
 	c.sendTypedResponse(env, protocol.MessageType_AUTH_REGISTER_SUCCESS, success)
 	log.Printf("[%s] Registration successful for user %s", c.id, result.Username)
 }
 
+func (c *Conn) handleAuthRecoverRequest(ctx context.Context, env *protocol.Envelope) {
+	var req protocol.AuthRecoverRequest
+	if err := proto.Unmarshal(env.Payload, &req); err != nil {
+		log.Printf("[%s] Failed to unmarshal recover request: %v", c.id, err)
+		c.sendAuthError(env, 3001, "Invalid recover request payload")
+		return
+	}
+
+	challenge, err := c.authService.BeginRecovery(ctx, c.clientInfo(), req.Username, req.RecoveryCode)
+	if err != nil {
+		c.handleAuthError(env, err)
+		return
+	}
+
+	c.challengeID = challenge.ChallengeID
+
+	// Recovery completes through the regular registration response.
+	challengeMsg := &protocol.AuthRegisterChallenge{
+		Challenge:                 challenge.CredentialCreationOptions,
+		CredentialCreationOptions: challenge.CredentialCreationOptions,
+	}
+	c.sendTypedResponse(env, protocol.MessageType_AUTH_REGISTER_CHALLENGE, challengeMsg)
+}
+
 // ============================================================================
 // Auth Helpers
 // ============================================================================
 
-// transitionToReady atomically transitions from authenticating to ready.
+// transitionToReady atomically transitions from authenticating to ready
+// and starts watching the session for expiry.
 // Returns false if the transition failed (e.g., auth timer already fired).
-func (c *Conn) transitionToReady(ctx context.Context, userID, username string) bool {
+func (c *Conn) transitionToReady(ctx context.Context, session *auth.SessionResult) bool {
 	if !c.state.CompareAndSwap(stateAuthenticating, stateReady) {
 		return false
 	}
 	c.authTimer.Stop()
-	c.userID = userID
-	c.username = username
-	c.hub.SetAuthenticated(c, userID)
-
-	// Deliver pending messages after successful authentication.
-	go c.deliverPendingMessages(ctx)
+	c.userID = session.UserID
+	c.username = session.Username
+	c.sessionID = session.SessionID
+	c.syncMu.Lock()
+	c.syncing = true
+	c.syncMu.Unlock()
+	c.hub.SetAuthenticated(c, session.UserID)
+	c.startSessionTimer(session.SessionExpiresAt)
+
+	// Deliver pending messages after successful authentication, then ask
+	// for key packages if the user is running low and for leaf key updates
+	// in groups they have not updated in for a while.
+	go func() {
+		c.deliverPendingMessages(ctx)
+		c.notifyKeyPackagesLow(ctx, session.UserID)
+		requestLeafUpdates(ctx, c.hub, c.mlsService, session.UserID)
+	}()
 
 	return true
 }
 
+// startSessionTimer schedules checkSession for when the session expires.
+func (c *Conn) startSessionTimer(expiresAt time.Time) {
+	t := time.AfterFunc(time.Until(expiresAt), c.checkSession)
+	if old := c.sessionTimer.Swap(t); old != nil {
+		old.Stop()
+	}
+	// close may have run before the timer was published.
+	if c.state.Load() == stateDisconnected {
+		t.Stop()
+	}
+}
+
+// checkSession runs when the session is due to expire. If the connection
+// has been active, the session is extended and the check rescheduled;
+// otherwise the connection is closed with 4004.
+func (c *Conn) checkSession() {
+	if c.state.Load() != stateReady {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	expiresAt, err := c.authService.RefreshSession(ctx, c.sessionID, time.Unix(c.lastActivity.Load(), 0))
+	switch {
+	case err == nil:
+		c.startSessionTimer(expiresAt)
+	case errors.Is(err, auth.ErrSessionExpired):
+		log.Printf("[%s] Session expired for user %s", c.id, c.username)
+		c.sendAuthError(nil, 1002, "Session expired")
+		c.ws.Close(websocket.StatusCode(4004), "Session Expired")
+		c.close()
+	case errors.Is(err, auth.ErrInvalidCredential):
+		log.Printf("[%s] Session revoked for user %s", c.id, c.username)
+		c.sendAuthError(nil, 1005, "Session revoked")
+		c.ws.Close(websocket.StatusCode(4004), "Session Revoked")
+		c.close()
+	default:
+		log.Printf("[%s] Refresh session: %v", c.id, err)
+		c.startSessionTimer(time.Now().Add(sessionRetryInterval))
+	}
+}
+
+// clientInfo identifies this connection to the auth service for throttling.
+func (c *Conn) clientInfo() auth.ClientInfo {
+	return auth.ClientInfo{IP: c.remoteIP, ConnID: c.id}
+}
+
 // handleAuthError sends an appropriate AUTH_ERROR based on the error type.
 // Fatal errors also close the WebSocket connection.
 func (c *Conn) handleAuthError(env *protocol.Envelope, err error) {
+	var throttleErr *auth.ThrottleError
 	switch {
+	case errors.As(err, &throttleErr):
+		c.sendAuthError(env, 1006, fmt.Sprintf("Too many attempts; retry_after_ms=%d", throttleErr.RetryAfter.Milliseconds()))
 	case errors.Is(err, auth.ErrAccountDisabled):
 		c.sendAuthError(env, 2004, "Account disabled")
 		c.ws.Close(websocket.StatusCode(4005), "Account Disabled")
@@ -876,6 +1744,12 @@ func (c *Conn) handleAuthError(env *protocol.Envelope, err error) {
 		c.close()
 	case errors.Is(err, auth.ErrRegistrationFailed):
 		c.sendAuthError(env, 1003, "Registration failed")
+	case errors.Is(err, auth.ErrInvalidRecoveryCode):
+		c.sendAuthError(env, 1001, "Invalid recovery code")
+	case errors.Is(err, auth.ErrInvalidUsername):
+		c.sendAuthError(env, 1007, err.Error())
+	case errors.Is(err, auth.ErrInvalidDisplayName):
+		c.sendAuthError(env, 1008, err.Error())
 	default:
 		log.Printf("[%s] Auth error: %v", c.id, err)
 		c.sendAuthError(env, 9001, "Internal error")
@@ -947,11 +1821,122 @@ func (c *Conn) sendEnvelope(env *protocol.Envelope) {
 		return
 	}
 
+	c.enqueue(data, frameEphemeral)
+}
+
+// sendEnvelopeWait queues an envelope like sendEnvelope, but waits for room
+// in the send buffer instead of dropping it. It fails only when ctx is
+// done, as it is once the connection closes.
+func (c *Conn) sendEnvelopeWait(ctx context.Context, env *protocol.Envelope) error {
+	data, err := proto.Marshal(env)
+	if err != nil {
+		return fmt.Errorf("marshal envelope: %w", err)
+	}
+
 	select {
 	case c.send <- data:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// frameKind says what becomes of a frame dropped from a full send buffer.
+type frameKind int
+
+const (
+	// frameEphemeral frames are lost.
+	frameEphemeral frameKind = iota
+	// frameStored frames are messages that stay pending until the client
+	// acknowledges them, so a dropped one is sent from the store.
+	frameStored
+	// frameNotice frames are not stored, but report changes the client
+	// reloads when it connects, such as membership changes.
+	frameNotice
+)
+
+// enqueue queues a serialized frame without blocking, and reports whether
+// it fit in the send buffer. Frames that do not fit are dropped and
+// counted. The connection is closed with code 4008 once the buffer has
+// stayed saturated for slowConsumerTimeout, or when it drains after
+// dropping a stored frame or notice, so that the client reconnects and
+// catches up.
+func (c *Conn) enqueue(data []byte, kind frameKind) bool {
+	if kind == frameStored && c.deferToSync() {
+		return true
+	}
+	select {
+	case c.send <- data:
+		return true
 	default:
-		log.Printf("[%s] Send buffer full, dropping message", c.id)
 	}
+
+	if kind == frameEphemeral {
+		c.droppedEphemeral.Add(1)
+	} else {
+		c.droppedStored.Add(1)
+	}
+	// Catch-up fills the buffer on purpose, and picks up stored messages
+	// dropped meanwhile itself. Only a dropped notice still needs the
+	// client to reconnect.
+	c.syncMu.Lock()
+	syncing := c.syncing
+	if syncing && kind == frameStored {
+		c.syncMissed = true
+	}
+	c.syncMu.Unlock()
+	if kind == frameNotice || (kind == frameStored && !syncing) {
+		c.lostStored.Store(true)
+	}
+	now := time.Now().UnixNano()
+	if syncing {
+		if kind == frameNotice {
+			c.saturatedSince.CompareAndSwap(0, now)
+		}
+		return false
+	}
+
+	if !c.saturatedSince.CompareAndSwap(0, now) &&
+		time.Duration(now-c.saturatedSince.Load()) >= slowConsumerTimeout {
+		c.closeSlowConsumer()
+	}
+	return false
+}
+
+// drained is called when the send buffer empties after dropping frames.
+func (c *Conn) drained() {
+	c.saturatedSince.Store(0)
+	if c.lostStored.Swap(false) {
+		c.closeSlowConsumer()
+	}
+}
+
+// closeSlowConsumer closes a connection that cannot keep up with the frames
+// sent to it. It does not wait for the close handshake, as it is called
+// from other connections' handlers.
+func (c *Conn) closeSlowConsumer() {
+	if !c.slowClosing.CompareAndSwap(false, true) {
+		return
+	}
+	dropped := c.Dropped()
+	log.Printf("[%s] Closing slow consumer %s after dropping %d ephemeral and %d stored frames",
+		c.id, c.userID, dropped.Ephemeral, dropped.Stored)
+	go func() {
+		c.ws.Close(websocket.StatusCode(4008), "Slow Consumer")
+		c.close()
+	}()
+}
+
+// DropStats counts the frames a connection dropped because its send buffer
+// was full.
+type DropStats struct {
+	Ephemeral uint64 // frames that were lost
+	Stored    uint64 // stored messages, still pending delivery, and notices
+}
+
+// Dropped returns the connection's dropped frame counts.
+func (c *Conn) Dropped() DropStats {
+	return DropStats{Ephemeral: c.droppedEphemeral.Load(), Stored: c.droppedStored.Load()}
 }
 
 // sendError sends an error envelope to the client.
@@ -968,6 +1953,9 @@ func (c *Conn) sendError(origEnv *protocol.Envelope, code int32, message string,
 func (c *Conn) close() {
 	c.once.Do(func() {
 		c.state.Store(stateDisconnected)
+		if t := c.sessionTimer.Load(); t != nil {
+			t.Stop()
+		}
 		c.cancel()
 	})
 }
@@ -3,6 +3,8 @@ package ws
 import (
 	"context"
 	"crypto/sha256"
+	"fmt"
+	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
@@ -45,13 +47,34 @@ func setupTestServer(t *testing.T, maxMessageSize int) (string, func()) {
 // can seed users/sessions.
 func setupTestServerWithAuth(t *testing.T, maxMessageSize int) (string, func(), *store.Store) {
 	t.Helper()
+	return setupTestServerWithSessions(t, maxMessageSize, auth.SessionLifetime{})
+}
+
+// setupTestServerWithSessions is setupTestServerWithAuth with a custom
+// session lifetime.
+func setupTestServerWithSessions(t *testing.T, maxMessageSize int, lifetime auth.SessionLifetime) (string, func(), *store.Store) {
+	t.Helper()
+	return setupTestServerWithOptions(t, maxMessageSize, testServerOptions{lifetime: lifetime})
+}
+
+// testServerOptions configures setupTestServerWithOptions.
+type testServerOptions struct {
+	lifetime       auth.SessionLifetime
+	minKeyPackages int
+	updateInterval time.Duration
+}
+
+// setupTestServerWithOptions is setupTestServerWithAuth with custom service
+// settings.
+func setupTestServerWithOptions(t *testing.T, maxMessageSize int, opts testServerOptions) (string, func(), *store.Store) {
+	t.Helper()
 
 	s, err := store.New(":memory:")
 	if err != nil {
 		t.Fatalf("store.New: %v", err)
 	}
 
-	authSvc, err := auth.NewService(s, "Test Server", "localhost", []string{"http://localhost:8080"})
+	authSvc, err := auth.NewService(s, "Test Server", "localhost", []string{"http://localhost:8080"}, auth.Policy{}, opts.lifetime)
 	if err != nil {
 		s.Close()
 		t.Fatalf("auth.NewService: %v", err)
@@ -60,7 +83,11 @@ func setupTestServerWithAuth(t *testing.T, maxMessageSize int) (string, func(),
 	hub := NewHub()
 	go hub.Run()
 
-	mlsSvc := mls.NewService(s)
+	mlsSvc, err := mls.NewService(s, opts.minKeyPackages, opts.updateInterval)
+	if err != nil {
+		s.Close()
+		t.Fatalf("mls.NewService: %v", err)
+	}
 	handler := UpgradeHandler(hub, maxMessageSize, authSvc, s, mlsSvc)
 	server := httptest.NewServer(handler)
 
@@ -108,7 +135,8 @@ func seedTestUser(t *testing.T, s *store.Store) {
 	}
 }
 
-// authenticateConn sends a session token auth request and reads the success response.
+// authenticateConn sends a session token auth request and reads the success
+// response and the SYNC_COMPLETE that follows it.
 func authenticateConn(t *testing.T, ctx context.Context, conn *websocket.Conn) {
 	t.Helper()
 
@@ -132,6 +160,7 @@ func authenticateConn(t *testing.T, ctx context.Context, conn *websocket.Conn) {
 	if resp.Type != protocol.MessageType_AUTH_SUCCESS {
 		t.Fatalf("Expected AUTH_SUCCESS, got %v", resp.Type)
 	}
+	readSyncComplete(t, ctx, conn)
 }
 
 // dialTestServer connects to the test server with the sovereign.v1 subprotocol.
@@ -442,6 +471,141 @@ func TestSendBufferFull(t *testing.T) {
 	default:
 		// Buffer is empty — overflow was dropped
 	}
+	if d := c.Dropped(); d.Ephemeral != 1 || d.Stored != 0 {
+		t.Errorf("Dropped() = %+v, want 1 ephemeral", d)
+	}
+}
+
+// newSlowConsumerConn returns a Conn with a one-frame send buffer and no
+// write pump, over a real WebSocket, and the client end of the WebSocket.
+func newSlowConsumerConn(t *testing.T) (*Conn, *websocket.Conn) {
+	t.Helper()
+	accepted := make(chan *websocket.Conn, 1)
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws, err := websocket.Accept(w, r, nil)
+		if err != nil {
+			return
+		}
+		accepted <- ws
+		<-done
+	}))
+	t.Cleanup(func() {
+		close(done)
+		server.Close()
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+	client, _, err := websocket.Dial(ctx, "ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	t.Cleanup(func() { client.CloseNow() })
+
+	c := &Conn{id: "test-slow", ws: <-accepted, send: make(chan []byte, 1), cancel: cancel}
+	c.state.Store(stateReady)
+	return c, client
+}
+
+// readCloseStatus reads from the client end of a WebSocket until the
+// server closes it, and returns the close code.
+func readCloseStatus(t *testing.T, client *websocket.Conn) websocket.StatusCode {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, _, err := client.Read(ctx)
+	return websocket.CloseStatus(err)
+}
+
+func TestSlowConsumer(t *testing.T) {
+	frame := []byte("frame")
+
+	t.Run("ephemeral drops are tolerated", func(t *testing.T) {
+		c, _ := newSlowConsumerConn(t)
+		c.enqueue(frame, frameEphemeral)
+		for i := 0; i < 3; i++ {
+			if c.enqueue(frame, frameEphemeral) {
+				t.Fatal("enqueue to a full buffer succeeded")
+			}
+		}
+		<-c.send
+		c.drained()
+		if c.slowClosing.Load() || c.saturatedSince.Load() != 0 {
+			t.Error("connection closed after its buffer drained")
+		}
+		if d := c.Dropped(); d.Ephemeral != 3 || d.Stored != 0 {
+			t.Errorf("Dropped() = %+v, want 3 ephemeral", d)
+		}
+	})
+
+	t.Run("stored drop closes once drained", func(t *testing.T) {
+		c, client := newSlowConsumerConn(t)
+		c.enqueue(frame, frameEphemeral)
+		c.enqueue(frame, frameStored)
+		if c.slowClosing.Load() {
+			t.Fatal("connection closed before its buffer drained")
+		}
+		<-c.send
+		c.drained()
+		if got := readCloseStatus(t, client); got != 4008 {
+			t.Errorf("close status = %d, want 4008", got)
+		}
+		if d := c.Dropped(); d.Ephemeral != 0 || d.Stored != 1 {
+			t.Errorf("Dropped() = %+v, want 1 stored", d)
+		}
+	})
+
+	t.Run("saturated too long closes", func(t *testing.T) {
+		c, client := newSlowConsumerConn(t)
+		c.enqueue(frame, frameEphemeral)
+		c.enqueue(frame, frameEphemeral)
+		c.saturatedSince.Add(-int64(slowConsumerTimeout))
+		c.enqueue(frame, frameEphemeral)
+		if got := readCloseStatus(t, client); got != 4008 {
+			t.Errorf("close status = %d, want 4008", got)
+		}
+	})
+
+	t.Run("catch-up sends live stored messages itself", func(t *testing.T) {
+		c, _ := newSlowConsumerConn(t)
+		c.syncing = true
+		c.enqueue(frame, frameEphemeral)
+		if !c.enqueue(frame, frameStored) {
+			t.Error("stored message during catch-up not left to catch-up")
+		}
+		if c.saturatedSince.Load() != 0 || c.lostStored.Load() || c.Dropped().Stored != 0 {
+			t.Error("stored message during catch-up counted against the connection")
+		}
+		if c.endSync() {
+			t.Error("endSync() = true after a stored message was deferred, want false")
+		}
+		if !c.endSync() {
+			t.Error("endSync() = false, want true")
+		}
+		c.enqueue(frame, frameStored)
+		if !c.lostStored.Load() {
+			t.Error("stored drop after catch-up not recorded")
+		}
+	})
+
+	t.Run("notice dropped during catch-up closes once drained", func(t *testing.T) {
+		c, client := newSlowConsumerConn(t)
+		c.syncing = true
+		c.enqueue(frame, frameEphemeral)
+		c.enqueue(frame, frameNotice)
+		if c.slowClosing.Load() {
+			t.Fatal("connection closed before its buffer drained")
+		}
+		if !c.endSync() {
+			t.Error("endSync() = false after a notice was dropped, want true")
+		}
+		<-c.send
+		c.drained()
+		if got := readCloseStatus(t, client); got != 4008 {
+			t.Errorf("close status = %d, want 4008", got)
+		}
+	})
 }
 
 // --- Auth Lifecycle Tests ---
@@ -519,6 +683,60 @@ func TestSessionTokenReconnection(t *testing.T) {
 	}
 }
 
+func TestSessionTokenRotation(t *testing.T) {
+	url, cleanup, s := setupTestServerWithSessions(t, 65536, auth.SessionLifetime{RotateOnResume: true})
+	defer cleanup()
+	seedTestUser(t, s)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	conn := dialTestServer(t, ctx, url)
+	defer conn.Close(websocket.StatusNormalClosure, "")
+
+	payload, _ := proto.Marshal(&protocol.AuthRequest{Username: testSessionToken})
+	sendEnvelope(t, ctx, conn, &protocol.Envelope{
+		Type:      protocol.MessageType_AUTH_REQUEST,
+		RequestId: "session-recon",
+		Payload:   payload,
+	})
+
+	resp := readEnvelope(t, ctx, conn)
+	if resp.Type != protocol.MessageType_AUTH_SUCCESS {
+		t.Fatalf("Type = %v, want AUTH_SUCCESS", resp.Type)
+	}
+	var success protocol.AuthSuccess
+	if err := proto.Unmarshal(resp.Payload, &success); err != nil {
+		t.Fatalf("Failed to unmarshal AuthSuccess: %v", err)
+	}
+	if success.SessionToken == "" || success.SessionToken == testSessionToken {
+		t.Errorf("SessionToken = %q, want a rotated token", success.SessionToken)
+	}
+}
+
+func TestSessionExpiryClosesConnection(t *testing.T) {
+	url, cleanup, s := setupTestServerWithSessions(t, 65536, auth.SessionLifetime{IdleTimeout: time.Second})
+	defer cleanup()
+	seedTestUser(t, s)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	conn := dialTestServer(t, ctx, url)
+	defer conn.Close(websocket.StatusNormalClosure, "")
+	authenticateConn(t, ctx, conn)
+
+	// Stay idle until the server closes the connection. The AUTH_ERROR
+	// sent just before the close may or may not arrive first.
+	var err error
+	for err == nil {
+		_, _, err = conn.Read(ctx)
+	}
+	if status := websocket.CloseStatus(err); status != 4004 {
+		t.Errorf("Close status = %d, want 4004 (Session Expired); err = %v", status, err)
+	}
+}
+
 func TestMessageBeforeAuth(t *testing.T) {
 	url, cleanup, _ := setupTestServerWithAuth(t, 65536)
 	defer cleanup()
@@ -562,7 +780,8 @@ func TestAuthLoginBeginForNonExistentUser(t *testing.T) {
 	conn := dialTestServer(t, ctx, url)
 	defer conn.Close(websocket.StatusNormalClosure, "")
 
-	// Send AUTH_REQUEST for a user that doesn't exist
+	// Send AUTH_REQUEST for a user that doesn't exist. The server must not
+	// reveal that the username is unknown, so it answers with a challenge.
 	authReq := &protocol.AuthRequest{
 		Username: "nonexistent",
 	}
@@ -570,22 +789,64 @@ func TestAuthLoginBeginForNonExistentUser(t *testing.T) {
 
 	env := &protocol.Envelope{
 		Type:      protocol.MessageType_AUTH_REQUEST,
-		RequestId: "login-fail",
+		RequestId: "login-unknown",
 		Payload:   payload,
 	}
 	sendEnvelope(t, ctx, conn, env)
 
 	resp := readEnvelope(t, ctx, conn)
-	if resp.Type != protocol.MessageType_AUTH_ERROR {
-		t.Fatalf("Type = %v, want AUTH_ERROR", resp.Type)
+	if resp.Type != protocol.MessageType_AUTH_CHALLENGE {
+		t.Fatalf("Type = %v, want AUTH_CHALLENGE", resp.Type)
 	}
 
-	var authErr protocol.AuthError
-	if err := proto.Unmarshal(resp.Payload, &authErr); err != nil {
-		t.Fatalf("Failed to unmarshal AuthError: %v", err)
+	var challenge protocol.AuthChallenge
+	if err := proto.Unmarshal(resp.Payload, &challenge); err != nil {
+		t.Fatalf("Failed to unmarshal AuthChallenge: %v", err)
 	}
-	if authErr.ErrorCode != 1001 {
-		t.Errorf("ErrorCode = %d, want 1001 (Invalid credential)", authErr.ErrorCode)
+	if len(challenge.CredentialRequestOptions) == 0 {
+		t.Error("CredentialRequestOptions is empty")
+	}
+}
+
+func TestAuthChallengeLimitPerConnection(t *testing.T) {
+	url, cleanup, _ := setupTestServerWithAuth(t, 65536)
+	defer cleanup()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	conn := dialTestServer(t, ctx, url)
+	defer conn.Close(websocket.StatusNormalClosure, "")
+
+	payload, _ := proto.Marshal(&protocol.AuthRequest{Username: "someone"})
+	for i := 0; i <= auth.MaxChallengesPerConn; i++ {
+		sendEnvelope(t, ctx, conn, &protocol.Envelope{
+			Type:      protocol.MessageType_AUTH_REQUEST,
+			RequestId: fmt.Sprintf("login-%d", i),
+			Payload:   payload,
+		})
+		resp := readEnvelope(t, ctx, conn)
+
+		if i < auth.MaxChallengesPerConn {
+			if resp.Type != protocol.MessageType_AUTH_CHALLENGE {
+				t.Fatalf("request %d: Type = %v, want AUTH_CHALLENGE", i, resp.Type)
+			}
+			continue
+		}
+
+		if resp.Type != protocol.MessageType_AUTH_ERROR {
+			t.Fatalf("request %d: Type = %v, want AUTH_ERROR", i, resp.Type)
+		}
+		var authErr protocol.AuthError
+		if err := proto.Unmarshal(resp.Payload, &authErr); err != nil {
+			t.Fatalf("Failed to unmarshal AuthError: %v", err)
+		}
+		if authErr.ErrorCode != 1006 {
+			t.Errorf("ErrorCode = %d, want 1006 (Too many attempts)", authErr.ErrorCode)
+		}
+		if !strings.Contains(authErr.Message, "retry_after_ms=") {
+			t.Errorf("Message = %q, want retry_after_ms", authErr.Message)
+		}
 	}
 }
 
@@ -666,3 +927,97 @@ func TestAuthRegisterDuplicateUsername(t *testing.T) {
 		t.Errorf("ErrorCode = %d, want 1003 (Registration failed)", authErr.ErrorCode)
 	}
 }
+
+func TestAuthRecoverRequest(t *testing.T) {
+	tests := []struct {
+		name      string
+		username  string
+		validCode bool
+		wantType  protocol.MessageType
+		wantCode  int32
+	}{
+		{
+			name:      "valid code returns registration challenge",
+			username:  "testuser",
+			validCode: true,
+			wantType:  protocol.MessageType_AUTH_REGISTER_CHALLENGE,
+		},
+		{
+			name:     "invalid code returns auth error",
+			username: "testuser",
+			wantType: protocol.MessageType_AUTH_ERROR,
+			wantCode: 1001,
+		},
+		{
+			name:      "unknown user returns auth error",
+			username:  "nobody",
+			validCode: true,
+			wantType:  protocol.MessageType_AUTH_ERROR,
+			wantCode:  1001,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			url, cleanup, s := setupTestServerWithAuth(t, 65536)
+			defer cleanup()
+			seedTestUser(t, s)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			authSvc, err := auth.NewService(s, "Test Server", "localhost", []string{"http://localhost:8080"}, auth.Policy{}, auth.SessionLifetime{})
+			if err != nil {
+				t.Fatalf("auth.NewService: %v", err)
+			}
+			codes, err := authSvc.GenerateRecoveryCodes(ctx, "test-user-id")
+			if err != nil {
+				t.Fatalf("GenerateRecoveryCodes: %v", err)
+			}
+			code := "AAAA-BBBB-CCCC-DDDD"
+			if tt.validCode {
+				code = codes[0]
+			}
+
+			conn := dialTestServer(t, ctx, url)
+			defer conn.Close(websocket.StatusNormalClosure, "")
+
+			payload, _ := proto.Marshal(&protocol.AuthRecoverRequest{
+				Username:     tt.username,
+				RecoveryCode: code,
+			})
+			sendEnvelope(t, ctx, conn, &protocol.Envelope{
+				Type:      protocol.MessageType_AUTH_RECOVER_REQUEST,
+				RequestId: "recover-1",
+				Payload:   payload,
+			})
+
+			resp := readEnvelope(t, ctx, conn)
+			if resp.Type != tt.wantType {
+				t.Fatalf("Type = %v, want %v", resp.Type, tt.wantType)
+			}
+			if resp.RequestId != "recover-1" {
+				t.Errorf("RequestId = %q, want %q", resp.RequestId, "recover-1")
+			}
+
+			if tt.wantType == protocol.MessageType_AUTH_ERROR {
+				var authErr protocol.AuthError
+				if err := proto.Unmarshal(resp.Payload, &authErr); err != nil {
+					t.Fatalf("Failed to unmarshal AuthError: %v", err)
+				}
+				if authErr.ErrorCode != tt.wantCode {
+					t.Errorf("ErrorCode = %d, want %d", authErr.ErrorCode, tt.wantCode)
+				}
+				return
+			}
+
+			var challenge protocol.AuthRegisterChallenge
+			if err := proto.Unmarshal(resp.Payload, &challenge); err != nil {
+				t.Fatalf("Failed to unmarshal AuthRegisterChallenge: %v", err)
+			}
+			if len(challenge.CredentialCreationOptions) == 0 {
+				t.Error("CredentialCreationOptions is empty")
+			}
+		})
+	}
+}
@@ -1,19 +1,24 @@
 package ws
 
 import (
+	"context"
 	"log"
 	"sync"
+	"time"
 
 	"google.golang.org/protobuf/proto"
+	"nhooyr.io/websocket"
 
+	"github.com/sovereign-im/sovereign/server/internal/mls"
 	"github.com/sovereign-im/sovereign/server/internal/protocol"
 )
 
 // Hub manages active WebSocket connections and message routing.
 type Hub struct {
-	mu    sync.RWMutex
-	conns map[string]*Conn
-	users map[string]*Conn // userID -> authenticated connection
+	mu     sync.RWMutex
+	conns  map[string]*Conn
+	users  map[string]*Conn // userID -> authenticated connection
+	authed map[*Conn]string // every authenticated connection -> userID
 
 	register   chan *Conn
 	unregister chan *Conn
@@ -25,6 +30,7 @@ func NewHub() *Hub {
 	return &Hub{
 		conns:      make(map[string]*Conn),
 		users:      make(map[string]*Conn),
+		authed:     make(map[*Conn]string),
 		register:   make(chan *Conn),
 		unregister: make(chan *Conn),
 		done:       make(chan struct{}),
@@ -45,12 +51,19 @@ func (h *Hub) Run() {
 			h.mu.Lock()
 			if _, ok := h.conns[conn.id]; ok {
 				delete(h.conns, conn.id)
-				if conn.userID != "" {
-					delete(h.users, conn.userID)
+				// Only drop the user mapping if it still points at this
+				// connection; a newer connection may have replaced it.
+				if userID, ok := h.authed[conn]; ok && h.users[userID] == conn {
+					delete(h.users, userID)
 				}
 			}
+			delete(h.authed, conn)
 			h.mu.Unlock()
-			log.Printf("Connection unregistered: %s", conn.id)
+			if d := conn.Dropped(); d.Ephemeral > 0 || d.Stored > 0 {
+				log.Printf("Connection unregistered: %s (dropped %d ephemeral and %d stored frames)", conn.id, d.Ephemeral, d.Stored)
+			} else {
+				log.Printf("Connection unregistered: %s", conn.id)
+			}
 
 		case <-h.done:
 			return
@@ -78,6 +91,7 @@ func (h *Hub) SetAuthenticated(conn *Conn, userID string) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 	h.users[userID] = conn
+	h.authed[conn] = userID
 }
 
 // GetConnByUserID returns the authenticated connection for a user, or nil.
@@ -88,8 +102,20 @@ func (h *Hub) GetConnByUserID(userID string) *Conn {
 }
 
 // SendToUser sends a serialized envelope to a specific user if they are online.
-// Returns true if the user was online and the message was queued.
+// Returns true if the user was online and the message was queued. If the
+// user's send buffer is full the envelope is dropped.
 func (h *Hub) SendToUser(userID string, env *protocol.Envelope) bool {
+	return h.sendToUser(userID, env, frameEphemeral)
+}
+
+// SendStoredToUser is SendToUser for a stored message that stays pending
+// until the user acknowledges it. If the user is offline or their send
+// buffer is full, it is sent from the store when they next connect.
+func (h *Hub) SendStoredToUser(userID string, env *protocol.Envelope) bool {
+	return h.sendToUser(userID, env, frameStored)
+}
+
+func (h *Hub) sendToUser(userID string, env *protocol.Envelope, kind frameKind) bool {
 	data, err := proto.Marshal(env)
 	if err != nil {
 		log.Printf("Hub.SendToUser: marshal error: %v", err)
@@ -101,18 +127,25 @@ func (h *Hub) SendToUser(userID string, env *protocol.Envelope) bool {
 	if conn == nil {
 		return false
 	}
-	select {
-	case conn.send <- data:
-		return true
-	default:
-		log.Printf("Hub.SendToUser: send buffer full for user %s", userID)
-		return false
-	}
+	return conn.enqueue(data, kind)
 }
 
 // BroadcastToGroup sends an envelope to all online members of a group,
-// optionally excluding one user (typically the sender).
+// optionally excluding one user (typically the sender). Members whose send
+// buffer is full miss it.
 func (h *Hub) BroadcastToGroup(memberIDs []string, env *protocol.Envelope, excludeUserID string) {
+	h.broadcastToGroup(memberIDs, env, excludeUserID, frameEphemeral)
+}
+
+// BroadcastNoticeToGroup is BroadcastToGroup for a notice that members
+// must not miss, such as a membership change. A member whose send buffer is
+// full is disconnected as for a dropped stored message, so that their
+// client reconnects and reloads the group.
+func (h *Hub) BroadcastNoticeToGroup(memberIDs []string, env *protocol.Envelope, excludeUserID string) {
+	h.broadcastToGroup(memberIDs, env, excludeUserID, frameNotice)
+}
+
+func (h *Hub) broadcastToGroup(memberIDs []string, env *protocol.Envelope, excludeUserID string, kind frameKind) {
 	data, err := proto.Marshal(env)
 	if err != nil {
 		log.Printf("Hub.BroadcastToGroup: marshal error: %v", err)
@@ -128,10 +161,56 @@ func (h *Hub) BroadcastToGroup(memberIDs []string, env *protocol.Envelope, exclu
 		if conn == nil {
 			continue
 		}
+		conn.enqueue(data, kind)
+	}
+}
+
+// DisconnectUser closes every connection authenticated as userID except
+// keep, using the given WebSocket close code. Returns the number closed.
+func (h *Hub) DisconnectUser(userID string, keep *Conn, code websocket.StatusCode, reason string) int {
+	h.mu.RLock()
+	var targets []*Conn
+	for conn, uid := range h.authed {
+		if conn != keep && conn.state.Load() == stateReady && uid == userID {
+			targets = append(targets, conn)
+		}
+	}
+	h.mu.RUnlock()
+
+	// The close handshake can wait for the peer, so it is not done in the
+	// caller's handler, as with a slow consumer.
+	for _, conn := range targets {
+		go func() {
+			conn.ws.Close(code, reason)
+			conn.close()
+		}()
+	}
+	return len(targets)
+}
+
+// RunUpdateReminders asks online users to update their MLS leaf keys in
+// groups where they have gone too long without, checking every interval.
+// New connections are checked when they authenticate. It returns when the
+// hub is stopped and should be called in a goroutine.
+func (h *Hub) RunUpdateReminders(mlsSvc *mls.Service, every time.Duration) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for {
 		select {
-		case conn.send <- data:
-		default:
-			log.Printf("Hub.BroadcastToGroup: send buffer full for user %s", uid)
+		case <-ticker.C:
+			h.mu.RLock()
+			userIDs := make([]string, 0, len(h.users))
+			for userID := range h.users {
+				userIDs = append(userIDs, userID)
+			}
+			h.mu.RUnlock()
+
+			for _, userID := range userIDs {
+				requestLeafUpdates(context.Background(), h, mlsSvc, userID)
+			}
+
+		case <-h.done:
+			return
 		}
 	}
 }
@@ -3,6 +3,8 @@ package ws
 import (
 	"testing"
 	"time"
+
+	"nhooyr.io/websocket"
 )
 
 func TestHubRegisterUnregister(t *testing.T) {
@@ -91,3 +93,32 @@ func TestHubStop(t *testing.T) {
 		t.Fatal("Hub.Run() did not terminate after Stop()")
 	}
 }
+
+func TestHubDisconnectUser(t *testing.T) {
+	hub := NewHub()
+	keep, _ := newSlowConsumerConn(t)
+	other, otherClient := newSlowConsumerConn(t)
+	third, thirdClient := newSlowConsumerConn(t)
+	for _, c := range []*Conn{keep, other, third} {
+		hub.SetAuthenticated(c, "user-1")
+	}
+
+	// The clients are not reading, so each close handshake waits for its
+	// timeout; the caller must not.
+	start := time.Now()
+	if n := hub.DisconnectUser("user-1", keep, websocket.StatusCode(4004), "Session Revoked"); n != 2 {
+		t.Errorf("DisconnectUser() = %d, want 2", n)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("DisconnectUser took %v, want it not to wait for close handshakes", elapsed)
+	}
+
+	for _, client := range []*websocket.Conn{otherClient, thirdClient} {
+		if got := readCloseStatus(t, client); got != 4004 {
+			t.Errorf("close status = %d, want 4004", got)
+		}
+	}
+	if keep.state.Load() != stateReady {
+		t.Error("kept connection was closed")
+	}
+}
@@ -1,14 +1,19 @@
 package ws
 
 import (
+	"bytes"
 	"context"
 	"crypto/sha256"
+	"fmt"
+	"slices"
 	"testing"
 	"time"
 
 	"google.golang.org/protobuf/proto"
 	"nhooyr.io/websocket"
 
+	"github.com/sovereign-im/sovereign/server/internal/mls"
+	"github.com/sovereign-im/sovereign/server/internal/mls/mlstest"
 	"github.com/sovereign-im/sovereign/server/internal/protocol"
 	"github.com/sovereign-im/sovereign/server/internal/store"
 )
@@ -43,7 +48,19 @@ func seedTwoUsers(t *testing.T, s *store.Store) {
 	}
 }
 
+// authenticateAs signs in with a session token and waits for the pending
+// message catch-up to finish, expecting no pending messages.
 func authenticateAs(t *testing.T, ctx context.Context, conn *websocket.Conn, sessionToken string) {
+	t.Helper()
+	authenticateAsWithPending(t, ctx, conn, sessionToken)
+	if sync := readSyncComplete(t, ctx, conn); sync.MessageCount != 0 {
+		t.Fatalf("SyncComplete.MessageCount = %d, want 0", sync.MessageCount)
+	}
+}
+
+// authenticateAsWithPending signs in with a session token, leaving the
+// pending messages and SYNC_COMPLETE to the caller.
+func authenticateAsWithPending(t *testing.T, ctx context.Context, conn *websocket.Conn, sessionToken string) {
 	t.Helper()
 	authReq := &protocol.AuthRequest{Username: sessionToken}
 	payload, _ := proto.Marshal(authReq)
@@ -56,6 +73,21 @@ func authenticateAs(t *testing.T, ctx context.Context, conn *websocket.Conn, ses
 	}
 }
 
+// readSyncComplete reads the SYNC_COMPLETE that ends the pending message
+// catch-up.
+func readSyncComplete(t *testing.T, ctx context.Context, conn *websocket.Conn) *protocol.SyncComplete {
+	t.Helper()
+	resp := readEnvelope(t, ctx, conn)
+	if resp.Type != protocol.MessageType_SYNC_COMPLETE {
+		t.Fatalf("Type = %v, want SYNC_COMPLETE", resp.Type)
+	}
+	var sync protocol.SyncComplete
+	if err := proto.Unmarshal(resp.Payload, &sync); err != nil {
+		t.Fatalf("Unmarshal SyncComplete: %v", err)
+	}
+	return &sync
+}
+
 func TestGroupCreateFlow(t *testing.T) {
 	url, cleanup, s := setupTestServerWithAuth(t, 65536)
 	defer cleanup()
@@ -98,6 +130,34 @@ func TestGroupCreateFlow(t *testing.T) {
 	}
 }
 
+func TestGroupCreateNoticeToSlowConsumer(t *testing.T) {
+	s, err := store.New(":memory:")
+	if err != nil {
+		t.Fatalf("store.New: %v", err)
+	}
+	defer s.Close()
+	seedTwoUsers(t, s)
+	hub := NewHub()
+
+	alice, _ := newSlowConsumerConn(t)
+	alice.store, alice.hub, alice.userID = s, hub, "alice-id"
+	bob, bobClient := newSlowConsumerConn(t)
+	hub.SetAuthenticated(bob, "bob-id")
+	bob.send <- []byte("queued")
+
+	// Bob cannot take the notice that he was added, so he is disconnected
+	// once his buffer drains, and reloads his groups when he reconnects.
+	payload, _ := proto.Marshal(&protocol.GroupCreate{Title: "DM", MemberIds: []string{"bob-id"}})
+	alice.handleGroupCreate(context.Background(), &protocol.Envelope{
+		Type: protocol.MessageType_GROUP_CREATE, RequestId: "gc", Payload: payload,
+	})
+	<-bob.send
+	bob.drained()
+	if got := readCloseStatus(t, bobClient); got != 4008 {
+		t.Errorf("close status = %d, want 4008", got)
+	}
+}
+
 func TestMessageSendToOnlineRecipient(t *testing.T) {
 	url, cleanup, s := setupTestServerWithAuth(t, 65536)
 	defer cleanup()
@@ -183,6 +243,132 @@ func TestMessageSendToOnlineRecipient(t *testing.T) {
 	}
 }
 
+func TestSealedSenderMessage(t *testing.T) {
+	url, cleanup, s := setupTestServerWithAuth(t, 65536)
+	defer cleanup()
+	seedTwoUsers(t, s)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	aliceConn := dialTestServer(t, ctx, url)
+	defer aliceConn.Close(websocket.StatusNormalClosure, "")
+	authenticateAs(t, ctx, aliceConn, "alice-session-token")
+
+	bobConn := dialTestServer(t, ctx, url)
+	defer bobConn.Close(websocket.StatusNormalClosure, "")
+	authenticateAs(t, ctx, bobConn, "bob-session-token")
+
+	createPayload, _ := proto.Marshal(&protocol.GroupCreate{Title: "Sealed", MemberIds: []string{"bob-id"}, SealedSender: true})
+	sendEnvelope(t, ctx, aliceConn, &protocol.Envelope{
+		Type: protocol.MessageType_GROUP_CREATE, RequestId: "gc", Payload: createPayload,
+	})
+	var created protocol.GroupCreated
+	proto.Unmarshal(readEnvelope(t, ctx, aliceConn).Payload, &created)
+	if !created.SealedSender {
+		t.Fatal("GroupCreated.SealedSender = false, want true")
+	}
+	readEnvelope(t, ctx, bobConn) // GROUP_MEMBER_ADDED
+
+	expectError := func(conn *websocket.Conn, wantCode int32) {
+		t.Helper()
+		resp := readEnvelope(t, ctx, conn)
+		var errMsg protocol.Error
+		proto.Unmarshal(resp.Payload, &errMsg)
+		if resp.Type != protocol.MessageType_ERROR || errMsg.Code != wantCode {
+			t.Fatalf("got %v code %d, want ERROR %d", resp.Type, errMsg.Code, wantCode)
+		}
+	}
+	send := func(token []byte) {
+		payload, _ := proto.Marshal(&protocol.MessageSend{
+			ConversationId:   created.ConversationId,
+			EncryptedPayload: []byte("sealed hello"),
+			MessageType:      "text",
+			DeliveryToken:    token,
+		})
+		sendEnvelope(t, ctx, aliceConn, &protocol.Envelope{
+			Type: protocol.MessageType_MESSAGE_SEND, RequestId: "ms-1", Payload: payload,
+		})
+	}
+
+	// Messages without a valid delivery token are rejected.
+	send(nil)
+	expectError(aliceConn, 4006)
+
+	tokenPayload, _ := proto.Marshal(&protocol.DeliveryTokenRequest{ConversationId: created.ConversationId})
+	sendEnvelope(t, ctx, aliceConn, &protocol.Envelope{
+		Type: protocol.MessageType_DELIVERY_TOKEN_REQUEST, RequestId: "dt", Payload: tokenPayload,
+	})
+	resp := readEnvelope(t, ctx, aliceConn)
+	if resp.Type != protocol.MessageType_DELIVERY_TOKEN {
+		t.Fatalf("Type = %v, want DELIVERY_TOKEN", resp.Type)
+	}
+	var token protocol.DeliveryToken
+	if err := proto.Unmarshal(resp.Payload, &token); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if len(token.Token) == 0 || token.ExpiresAt <= time.Now().Unix() {
+		t.Fatalf("DeliveryToken = %+v, want an unexpired token", &token)
+	}
+
+	// Neither the sender's confirmation nor the recipient's copy names the
+	// sender, and the stored message has no sender either.
+	send(token.Token)
+	for _, tc := range []struct {
+		name          string
+		conn          *websocket.Conn
+		wantRequestID string
+	}{
+		{name: "alice", conn: aliceConn, wantRequestID: "ms-1"},
+		{name: "bob", conn: bobConn},
+	} {
+		resp := readEnvelope(t, ctx, tc.conn)
+		if resp.Type != protocol.MessageType_MESSAGE_RECEIVE || resp.RequestId != tc.wantRequestID {
+			t.Fatalf("%s: got %v request %q, want MESSAGE_RECEIVE request %q", tc.name, resp.Type, resp.RequestId, tc.wantRequestID)
+		}
+		var m protocol.MessageReceive
+		if err := proto.Unmarshal(resp.Payload, &m); err != nil {
+			t.Fatalf("Unmarshal: %v", err)
+		}
+		if m.SenderId != "" || string(m.EncryptedPayload) != "sealed hello" {
+			t.Errorf("%s: MessageReceive = %+v, want sealed hello without sender", tc.name, &m)
+		}
+	}
+
+	msgs, err := s.GetMessagesByGroup(ctx, created.ConversationId, "", 10, false)
+	if err != nil {
+		t.Fatalf("GetMessagesByGroup: %v", err)
+	}
+	if len(msgs) != 1 || msgs[0].SenderID != "" {
+		t.Fatalf("stored messages = %d, want 1 without sender", len(msgs))
+	}
+
+	// The sender's delivery record looks like the recipient's.
+	var records []store.DeliveryRecord
+	for _, userID := range []string{"alice-id", "bob-id"} {
+		dr, err := s.GetDeliveryStatus(ctx, msgs[0].ID, userID)
+		if err != nil {
+			t.Fatalf("GetDeliveryStatus(%s): %v", userID, err)
+		}
+		dr.RecipientID = ""
+		records = append(records, *dr)
+	}
+	if records[0].Status != store.DeliveryPending || records[0].DeliveredAt != nil || records[0].ReadAt != nil || records[0] != records[1] {
+		t.Errorf("delivery records = %+v, want identical pending records", records)
+	}
+
+	// Tokens are only issued for sealed-sender conversations.
+	plain, err := s.CreateConversation(ctx, "Plain", "alice-id", nil)
+	if err != nil {
+		t.Fatalf("CreateConversation: %v", err)
+	}
+	tokenPayload, _ = proto.Marshal(&protocol.DeliveryTokenRequest{ConversationId: plain.ID})
+	sendEnvelope(t, ctx, aliceConn, &protocol.Envelope{
+		Type: protocol.MessageType_DELIVERY_TOKEN_REQUEST, RequestId: "dt", Payload: tokenPayload,
+	})
+	expectError(aliceConn, 4007)
+}
+
 func TestMessageAckUpdatesDeliveryAndNotifiesSender(t *testing.T) {
 	url, cleanup, s := setupTestServerWithAuth(t, 65536)
 	defer cleanup()
@@ -224,8 +410,11 @@ func TestMessageAckUpdatesDeliveryAndNotifiesSender(t *testing.T) {
 	var echoMsg protocol.MessageReceive
 	proto.Unmarshal(aliceEcho.Payload, &echoMsg)
 
-	// Bob receives message.
+	// Bob receives message, which stays pending until he acknowledges it.
 	readEnvelope(t, ctx, bobConn)
+	if pending, err := s.GetPendingMessages(ctx, "bob-id"); err != nil || len(pending) != 1 {
+		t.Fatalf("GetPendingMessages before ACK = %d, %v; want 1", len(pending), err)
+	}
 
 	// Bob sends ACK.
 	ackPayload, _ := proto.Marshal(&protocol.MessageAck{MessageId: echoMsg.MessageId})
@@ -249,6 +438,132 @@ func TestMessageAckUpdatesDeliveryAndNotifiesSender(t *testing.T) {
 	if delivered.DeliveredTo != "bob-id" {
 		t.Errorf("DeliveredTo = %s, want bob-id", delivered.DeliveredTo)
 	}
+
+	// One ACK of several messages notifies the sender once, and only of
+	// the messages it delivered: the first one is not reported again.
+	send := func() string {
+		t.Helper()
+		sendEnvelope(t, ctx, aliceConn, &protocol.Envelope{
+			Type: protocol.MessageType_MESSAGE_SEND, RequestId: "ms", Payload: msgPayload,
+		})
+		var echo protocol.MessageReceive
+		resp := readEnvelope(t, ctx, aliceConn)
+		if resp.Type != protocol.MessageType_MESSAGE_RECEIVE {
+			t.Fatalf("Type = %v, want MESSAGE_RECEIVE", resp.Type)
+		}
+		proto.Unmarshal(resp.Payload, &echo)
+		readEnvelope(t, ctx, bobConn)
+		return echo.MessageId
+	}
+	second, third := send(), send()
+	ackPayload, _ = proto.Marshal(&protocol.MessageAck{MessageId: echoMsg.MessageId, MessageIds: []string{second, third}})
+	sendEnvelope(t, ctx, bobConn, &protocol.Envelope{
+		Type: protocol.MessageType_MESSAGE_ACK, RequestId: "ack", Payload: ackPayload,
+	})
+	deliveredResp = readEnvelope(t, ctx, aliceConn)
+	if deliveredResp.Type != protocol.MessageType_MESSAGE_DELIVERED {
+		t.Fatalf("Type = %v, want MESSAGE_DELIVERED", deliveredResp.Type)
+	}
+	delivered.Reset()
+	proto.Unmarshal(deliveredResp.Payload, &delivered)
+	got := append([]string{delivered.MessageId}, delivered.MessageIds...)
+	slices.Sort(got)
+	want := []string{second, third}
+	slices.Sort(want)
+	if !slices.Equal(got, want) || delivered.DeliveredTo != "bob-id" {
+		t.Errorf("MessageDelivered = %+v, want %v delivered to bob-id", &delivered, want)
+	}
+	// The next frame alice receives is her own echo, not another notice.
+	send()
+}
+
+func TestPendingMessagesStreamedUntilAcked(t *testing.T) {
+	url, cleanup, s := setupTestServerWithAuth(t, 65536)
+	defer cleanup()
+	seedTwoUsers(t, s)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// More pending messages than fit in the send buffer, across several pages.
+	conv, err := s.CreateConversation(ctx, "DM", "alice-id", []string{"bob-id"})
+	if err != nil {
+		t.Fatalf("CreateConversation: %v", err)
+	}
+	const backlog = 300
+	want := make(map[string]bool)
+	for i := 0; i < backlog; i++ {
+		id, _, err := s.InsertMessage(ctx, conv.ID, "alice-id", []byte(fmt.Sprintf("msg-%d", i)), store.MsgTypeApplication, 0)
+		if err != nil {
+			t.Fatalf("InsertMessage: %v", err)
+		}
+		want[id] = true
+	}
+
+	// catchUp connects as bob and returns the IDs of the pending messages.
+	catchUp := func() (*websocket.Conn, []string) {
+		conn := dialTestServer(t, ctx, url)
+		authenticateAsWithPending(t, ctx, conn, "bob-session-token")
+		var ids []string
+		for {
+			resp := readEnvelope(t, ctx, conn)
+			if resp.Type == protocol.MessageType_SYNC_COMPLETE {
+				var sync protocol.SyncComplete
+				proto.Unmarshal(resp.Payload, &sync)
+				if int(sync.MessageCount) != len(ids) {
+					t.Errorf("SyncComplete.MessageCount = %d, want %d", sync.MessageCount, len(ids))
+				}
+				return conn, ids
+			}
+			if resp.Type != protocol.MessageType_MESSAGE_RECEIVE {
+				t.Fatalf("Type = %v, want MESSAGE_RECEIVE", resp.Type)
+			}
+			var msg protocol.MessageReceive
+			proto.Unmarshal(resp.Payload, &msg)
+			ids = append(ids, msg.MessageId)
+		}
+	}
+	checkIDs := func(ids []string) {
+		t.Helper()
+		seen := make(map[string]bool)
+		for _, id := range ids {
+			if !want[id] || seen[id] {
+				t.Fatalf("unexpected or repeated message %s", id)
+			}
+			seen[id] = true
+		}
+		if len(seen) != backlog {
+			t.Fatalf("received %d pending messages, want %d", len(seen), backlog)
+		}
+	}
+
+	// Without an ACK the messages stay pending and are sent again.
+	conn, ids := catchUp()
+	checkIDs(ids)
+	conn.Close(websocket.StatusNormalClosure, "")
+
+	conn, ids = catchUp()
+	defer conn.Close(websocket.StatusNormalClosure, "")
+	checkIDs(ids)
+
+	ackPayload, _ := proto.Marshal(&protocol.MessageAck{MessageIds: ids})
+	sendEnvelope(t, ctx, conn, &protocol.Envelope{
+		Type: protocol.MessageType_MESSAGE_ACK, RequestId: "ack", Payload: ackPayload,
+	})
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		pending, err := s.GetPendingMessages(ctx, "bob-id")
+		if err != nil {
+			t.Fatalf("GetPendingMessages: %v", err)
+		}
+		if len(pending) == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d messages still pending after ACK", len(pending))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
 }
 
 func TestMessageSendUnauthorized(t *testing.T) {
@@ -296,7 +611,8 @@ func TestMLSKeyPackageUploadAndFetch(t *testing.T) {
 	authenticateAs(t, ctx, aliceConn, "alice-session-token")
 
 	// Upload a key package.
-	uploadPayload, _ := proto.Marshal(&protocol.MLSKeyPackageUpload{KeyPackageData: []byte("alice-kp")})
+	aliceKP := mlstest.NewKeyPackage(t, mlstest.KeyPackageOptions{Identity: "alice"})
+	uploadPayload, _ := proto.Marshal(&protocol.MLSKeyPackageUpload{KeyPackageData: aliceKP})
 	sendEnvelope(t, ctx, aliceConn, &protocol.Envelope{
 		Type: protocol.MessageType_MLS_KEY_PACKAGE_UPLOAD, RequestId: "up-1", Payload: uploadPayload,
 	})
@@ -321,8 +637,8 @@ func TestMLSKeyPackageUploadAndFetch(t *testing.T) {
 	if err := proto.Unmarshal(resp.Payload, &kpResp); err != nil {
 		t.Fatalf("Unmarshal: %v", err)
 	}
-	if string(kpResp.KeyPackageData) != "alice-kp" {
-		t.Errorf("KeyPackageData = %q, want alice-kp", kpResp.KeyPackageData)
+	if !bytes.Equal(kpResp.KeyPackageData, aliceKP) {
+		t.Errorf("KeyPackageData = %x, want alice's key package", kpResp.KeyPackageData)
 	}
 	if kpResp.UserId != "alice-id" {
 		t.Errorf("UserId = %q, want alice-id", kpResp.UserId)
@@ -350,20 +666,158 @@ func TestMLSKeyPackageUploadInvalidData(t *testing.T) {
 	defer conn.Close(websocket.StatusNormalClosure, "")
 	authenticateAs(t, ctx, conn, "alice-session-token")
 
-	// Upload empty key package.
-	uploadPayload, _ := proto.Marshal(&protocol.MLSKeyPackageUpload{KeyPackageData: []byte{}})
-	sendEnvelope(t, ctx, conn, &protocol.Envelope{
-		Type: protocol.MessageType_MLS_KEY_PACKAGE_UPLOAD, RequestId: "up-bad", Payload: uploadPayload,
+	// Upload an empty key package, then one that does not parse.
+	for _, data := range [][]byte{{}, []byte("alice-kp")} {
+		uploadPayload, _ := proto.Marshal(&protocol.MLSKeyPackageUpload{KeyPackageData: data})
+		sendEnvelope(t, ctx, conn, &protocol.Envelope{
+			Type: protocol.MessageType_MLS_KEY_PACKAGE_UPLOAD, RequestId: "up-bad", Payload: uploadPayload,
+		})
+
+		resp := readEnvelope(t, ctx, conn)
+		if resp.Type != protocol.MessageType_ERROR {
+			t.Fatalf("Type = %v, want ERROR", resp.Type)
+		}
+		var errMsg protocol.Error
+		proto.Unmarshal(resp.Payload, &errMsg)
+		if errMsg.Code != 5001 {
+			t.Errorf("Code = %d, want 5001 (invalid key package)", errMsg.Code)
+		}
+	}
+}
+
+func TestMLSKeyPackageBatchUploadAndFetch(t *testing.T) {
+	url, cleanup, s := setupTestServerWithAuth(t, 65536)
+	defer cleanup()
+	seedTwoUsers(t, s)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	aliceConn := dialTestServer(t, ctx, url)
+	defer aliceConn.Close(websocket.StatusNormalClosure, "")
+	authenticateAs(t, ctx, aliceConn, "alice-session-token")
+
+	// Alice uploads two key packages in one envelope.
+	first := mlstest.NewKeyPackage(t, mlstest.KeyPackageOptions{Identity: "alice"})
+	second := mlstest.NewKeyPackage(t, mlstest.KeyPackageOptions{Identity: "alice"})
+	uploadPayload, _ := proto.Marshal(&protocol.MLSKeyPackageUpload{KeyPackages: [][]byte{first, second}})
+	sendEnvelope(t, ctx, aliceConn, &protocol.Envelope{
+		Type: protocol.MessageType_MLS_KEY_PACKAGE_UPLOAD, RequestId: "up-batch", Payload: uploadPayload,
 	})
 
-	resp := readEnvelope(t, ctx, conn)
-	if resp.Type != protocol.MessageType_ERROR {
-		t.Fatalf("Type = %v, want ERROR", resp.Type)
+	bobConn := dialTestServer(t, ctx, url)
+	defer bobConn.Close(websocket.StatusNormalClosure, "")
+	authenticateAs(t, ctx, bobConn, "bob-session-token")
+
+	// Bob fetches for alice (twice) and himself; he has none.
+	fetchPayload, _ := proto.Marshal(&protocol.MLSKeyPackageFetch{UserIds: []string{"alice-id", "bob-id", "alice-id"}})
+	sendEnvelope(t, ctx, bobConn, &protocol.Envelope{
+		Type: protocol.MessageType_MLS_KEY_PACKAGE_FETCH, RequestId: "fetch-batch", Payload: fetchPayload,
+	})
+
+	resp := readEnvelope(t, ctx, bobConn)
+	if resp.Type != protocol.MessageType_MLS_KEY_PACKAGE_RESPONSE {
+		t.Fatalf("Type = %v, want MLS_KEY_PACKAGE_RESPONSE", resp.Type)
 	}
-	var errMsg protocol.Error
-	proto.Unmarshal(resp.Payload, &errMsg)
-	if errMsg.Code != 5001 {
-		t.Errorf("Code = %d, want 5001 (invalid key package)", errMsg.Code)
+	if resp.RequestId != "fetch-batch" {
+		t.Errorf("RequestId = %q, want fetch-batch", resp.RequestId)
+	}
+	var kpResp protocol.MLSKeyPackageResponse
+	if err := proto.Unmarshal(resp.Payload, &kpResp); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if len(kpResp.Results) != 2 {
+		t.Fatalf("len(Results) = %d, want 2", len(kpResp.Results))
+	}
+	alice, bob := kpResp.Results[0], kpResp.Results[1]
+	if alice.UserId != "alice-id" || !alice.Available || !bytes.Equal(alice.KeyPackageData, first) {
+		t.Errorf("alice result = %v, want alice's first key package", alice)
+	}
+	if bob.UserId != "bob-id" || bob.Available || len(bob.KeyPackageData) != 0 {
+		t.Errorf("bob result = %v, want unavailable", bob)
+	}
+
+	count, err := s.CountKeyPackages(ctx, "alice-id")
+	if err != nil {
+		t.Fatalf("CountKeyPackages: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("alice count = %d, want 1", count)
+	}
+}
+
+func TestMLSKeyPackageLow(t *testing.T) {
+	url, cleanup, s := setupTestServerWithOptions(t, 65536, testServerOptions{minKeyPackages: 2})
+	defer cleanup()
+	seedTwoUsers(t, s)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// Give bob enough key packages that he is not asked for more.
+	for i := 0; i < 2; i++ {
+		if _, err := s.StoreKeyPackage(ctx, "bob-id", []byte("bob-kp"), time.Now().Add(time.Hour).Unix()); err != nil {
+			t.Fatalf("StoreKeyPackage: %v", err)
+		}
+	}
+
+	readLow := func(conn *websocket.Conn, wantAvailable int32) {
+		t.Helper()
+		env := readEnvelope(t, ctx, conn)
+		if env.Type != protocol.MessageType_MLS_KEY_PACKAGE_LOW {
+			t.Fatalf("Type = %v, want MLS_KEY_PACKAGE_LOW", env.Type)
+		}
+		var low protocol.MLSKeyPackageLow
+		if err := proto.Unmarshal(env.Payload, &low); err != nil {
+			t.Fatalf("Unmarshal: %v", err)
+		}
+		if low.Available != wantAvailable || low.Minimum != 2 {
+			t.Errorf("Available, Minimum = %d, %d, want %d, 2", low.Available, low.Minimum, wantAvailable)
+		}
+	}
+
+	// Alice has no key packages, so she is asked for more on login.
+	aliceConn := dialTestServer(t, ctx, url)
+	defer aliceConn.Close(websocket.StatusNormalClosure, "")
+	authenticateAs(t, ctx, aliceConn, "alice-session-token")
+	readLow(aliceConn, 0)
+
+	lastResort := mlstest.NewKeyPackage(t, mlstest.KeyPackageOptions{Identity: "alice"})
+	uploads := []*protocol.MLSKeyPackageUpload{
+		{KeyPackageData: mlstest.NewKeyPackage(t, mlstest.KeyPackageOptions{Identity: "alice"})},
+		{KeyPackageData: mlstest.NewKeyPackage(t, mlstest.KeyPackageOptions{Identity: "alice"})},
+		{KeyPackageData: lastResort, LastResort: true},
+	}
+	for _, up := range uploads {
+		payload, _ := proto.Marshal(up)
+		sendEnvelope(t, ctx, aliceConn, &protocol.Envelope{
+			Type: protocol.MessageType_MLS_KEY_PACKAGE_UPLOAD, RequestId: "up", Payload: payload,
+		})
+	}
+
+	bobConn := dialTestServer(t, ctx, url)
+	defer bobConn.Close(websocket.StatusNormalClosure, "")
+	authenticateAs(t, ctx, bobConn, "bob-session-token")
+
+	// Each fetch leaves alice below the minimum. Once her regular key
+	// packages are gone, the last-resort one keeps being returned.
+	fetchPayload, _ := proto.Marshal(&protocol.MLSKeyPackageFetch{UserId: "alice-id"})
+	for i, wantAvailable := range []int32{1, 0, 0} {
+		sendEnvelope(t, ctx, bobConn, &protocol.Envelope{
+			Type: protocol.MessageType_MLS_KEY_PACKAGE_FETCH, RequestId: "fetch", Payload: fetchPayload,
+		})
+		resp := readEnvelope(t, ctx, bobConn)
+		if resp.Type != protocol.MessageType_MLS_KEY_PACKAGE_RESPONSE {
+			t.Fatalf("fetch %d: Type = %v, want MLS_KEY_PACKAGE_RESPONSE", i, resp.Type)
+		}
+		var kpResp protocol.MLSKeyPackageResponse
+		if err := proto.Unmarshal(resp.Payload, &kpResp); err != nil {
+			t.Fatalf("Unmarshal: %v", err)
+		}
+		if i == 2 && !bytes.Equal(kpResp.KeyPackageData, lastResort) {
+			t.Errorf("fetch %d did not return the last-resort key package", i)
+		}
+		readLow(aliceConn, wantAvailable)
 	}
 }
 
@@ -383,9 +837,14 @@ func TestMLSWelcomeForwarding(t *testing.T) {
 	defer bobConn.Close(websocket.StatusNormalClosure, "")
 	authenticateAs(t, ctx, bobConn, "bob-session-token")
 
-	// Alice sends Welcome to bob.
+	conv, err := s.CreateConversation(ctx, "Group", "alice-id", []string{"bob-id"})
+	if err != nil {
+		t.Fatalf("CreateConversation: %v", err)
+	}
+
+	// Alice, the group admin, sends Welcome to bob.
 	welcomePayload, _ := proto.Marshal(&protocol.MLSWelcome{
-		ConversationId: "conv-1",
+		ConversationId: conv.ID,
 		RecipientId:    "bob-id",
 		WelcomeData:    []byte("welcome-data"),
 	})
@@ -403,8 +862,8 @@ func TestMLSWelcomeForwarding(t *testing.T) {
 	if err := proto.Unmarshal(resp.Payload, &received); err != nil {
 		t.Fatalf("Unmarshal: %v", err)
 	}
-	if received.ConversationId != "conv-1" {
-		t.Errorf("ConversationId = %q, want conv-1", received.ConversationId)
+	if received.ConversationId != conv.ID {
+		t.Errorf("ConversationId = %q, want %q", received.ConversationId, conv.ID)
 	}
 	if received.SenderId != "alice-id" {
 		t.Errorf("SenderId = %q, want alice-id", received.SenderId)
@@ -412,6 +871,104 @@ func TestMLSWelcomeForwarding(t *testing.T) {
 	if string(received.WelcomeData) != "welcome-data" {
 		t.Errorf("WelcomeData = %q, want welcome-data", received.WelcomeData)
 	}
+	if received.MessageId == "" {
+		t.Error("MessageId is empty")
+	}
+
+	// The Welcome is stored, so bob gets it again after reconnecting until
+	// he acknowledges it.
+	bobConn.Close(websocket.StatusNormalClosure, "")
+	bobConn = dialTestServer(t, ctx, url)
+	defer bobConn.Close(websocket.StatusNormalClosure, "")
+	authenticateAsWithPending(t, ctx, bobConn, "bob-session-token")
+	resp = readEnvelope(t, ctx, bobConn)
+	if resp.Type != protocol.MessageType_MLS_WELCOME_RECEIVE {
+		t.Fatalf("Type = %v, want MLS_WELCOME_RECEIVE", resp.Type)
+	}
+	var pending protocol.MLSWelcomeReceive
+	proto.Unmarshal(resp.Payload, &pending)
+	if pending.MessageId != received.MessageId || pending.SenderId != "alice-id" || string(pending.WelcomeData) != "welcome-data" {
+		t.Errorf("pending Welcome = %+v, want %+v", &pending, &received)
+	}
+	readSyncComplete(t, ctx, bobConn)
+}
+
+func TestMLSWelcomeRejected(t *testing.T) {
+	url, cleanup, s := setupTestServerWithAuth(t, 65536)
+	defer cleanup()
+	seedTwoUsers(t, s)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	group, err := s.CreateConversation(ctx, "Group", "alice-id", []string{"bob-id"})
+	if err != nil {
+		t.Fatalf("CreateConversation: %v", err)
+	}
+	private, err := s.CreateConversation(ctx, "Notes", "alice-id", nil)
+	if err != nil {
+		t.Fatalf("CreateConversation: %v", err)
+	}
+
+	aliceConn := dialTestServer(t, ctx, url)
+	defer aliceConn.Close(websocket.StatusNormalClosure, "")
+	authenticateAs(t, ctx, aliceConn, "alice-session-token")
+
+	bobConn := dialTestServer(t, ctx, url)
+	defer bobConn.Close(websocket.StatusNormalClosure, "")
+	authenticateAs(t, ctx, bobConn, "bob-session-token")
+
+	tests := []struct {
+		name        string
+		conn        *websocket.Conn
+		convID      string
+		recipientID string
+		wantCode    int32
+	}{
+		{name: "sender not a member", conn: aliceConn, convID: "nonexistent-conv", recipientID: "bob-id", wantCode: 4001},
+		{name: "sender not an admin", conn: bobConn, convID: group.ID, recipientID: "alice-id", wantCode: 4003},
+		{name: "recipient not a member", conn: aliceConn, convID: private.ID, recipientID: "bob-id", wantCode: 4003},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload, _ := proto.Marshal(&protocol.MLSWelcome{
+				ConversationId: tt.convID,
+				RecipientId:    tt.recipientID,
+				WelcomeData:    []byte("welcome-data"),
+			})
+			sendEnvelope(t, ctx, tt.conn, &protocol.Envelope{
+				Type: protocol.MessageType_MLS_WELCOME, RequestId: "w-bad", Payload: payload,
+			})
+
+			resp := readEnvelope(t, ctx, tt.conn)
+			if resp.Type != protocol.MessageType_ERROR {
+				t.Fatalf("Type = %v, want ERROR", resp.Type)
+			}
+			var errMsg protocol.Error
+			proto.Unmarshal(resp.Payload, &errMsg)
+			if errMsg.Code != tt.wantCode {
+				t.Errorf("Code = %d, want %d", errMsg.Code, tt.wantCode)
+			}
+		})
+	}
+
+	// None of the rejected Welcomes reached bob: the next thing he receives
+	// is a valid one.
+	payload, _ := proto.Marshal(&protocol.MLSWelcome{
+		ConversationId: group.ID,
+		RecipientId:    "bob-id",
+		WelcomeData:    []byte("valid"),
+	})
+	sendEnvelope(t, ctx, aliceConn, &protocol.Envelope{
+		Type: protocol.MessageType_MLS_WELCOME, RequestId: "w-ok", Payload: payload,
+	})
+	resp := readEnvelope(t, ctx, bobConn)
+	var received protocol.MLSWelcomeReceive
+	proto.Unmarshal(resp.Payload, &received)
+	if resp.Type != protocol.MessageType_MLS_WELCOME_RECEIVE || string(received.WelcomeData) != "valid" {
+		t.Errorf("bob received %v %q, want the valid Welcome", resp.Type, received.WelcomeData)
+	}
 }
 
 func TestMLSCommitBroadcast(t *testing.T) {
@@ -470,6 +1027,353 @@ func TestMLSCommitBroadcast(t *testing.T) {
 	if string(broadcast.CommitData) != "commit-data" {
 		t.Errorf("CommitData = %q, want commit-data", broadcast.CommitData)
 	}
+	if broadcast.MessageId == "" {
+		t.Error("MessageId is empty, want the stored Commit's ID")
+	}
+
+	// The Commit stays pending until bob acknowledges it, so a member who
+	// missed it receives it when they reconnect.
+	bobConn.Close(websocket.StatusNormalClosure, "")
+	bobConn = dialTestServer(t, ctx, url)
+	defer bobConn.Close(websocket.StatusNormalClosure, "")
+	authenticateAsWithPending(t, ctx, bobConn, "bob-session-token")
+	resp = readEnvelope(t, ctx, bobConn)
+	var pending protocol.MLSCommitBroadcast
+	proto.Unmarshal(resp.Payload, &pending)
+	if resp.Type != protocol.MessageType_MLS_COMMIT_BROADCAST || pending.MessageId != broadcast.MessageId || string(pending.CommitData) != "commit-data" {
+		t.Fatalf("pending = %v %+v, want the Commit", resp.Type, &pending)
+	}
+	readSyncComplete(t, ctx, bobConn)
+}
+
+func TestMLSCommitWithMembershipChange(t *testing.T) {
+	url, cleanup, s := setupTestServerWithAuth(t, 65536)
+	defer cleanup()
+	seedTwoUsers(t, s)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// Carol is a member who is offline during the commit.
+	now := time.Now().Unix()
+	if err := s.CreateUser(ctx, &store.User{
+		ID: "carol-id", Username: "carol", DisplayName: "carol",
+		Role: "member", Enabled: true, CreatedAt: now, UpdatedAt: now,
+	}); err != nil {
+		t.Fatalf("CreateUser(carol): %v", err)
+	}
+	if err := s.CreateUser(ctx, &store.User{
+		ID: "dave-id", Username: "dave", DisplayName: "dave",
+		Role: "member", Enabled: true, CreatedAt: now, UpdatedAt: now,
+	}); err != nil {
+		t.Fatalf("CreateUser(dave): %v", err)
+	}
+	h := sha256.Sum256([]byte("carol-session-token"))
+	if err := s.CreateSession(ctx, &store.Session{
+		ID: "sess-carol-id", UserID: "carol-id", TokenHash: h[:],
+		CreatedAt: now, ExpiresAt: now + 86400, LastSeenAt: now,
+	}); err != nil {
+		t.Fatalf("CreateSession(carol): %v", err)
+	}
+
+	conv, err := s.CreateConversation(ctx, "Group", "alice-id", []string{"bob-id", "carol-id"})
+	if err != nil {
+		t.Fatalf("CreateConversation: %v", err)
+	}
+
+	aliceConn := dialTestServer(t, ctx, url)
+	defer aliceConn.Close(websocket.StatusNormalClosure, "")
+	authenticateAs(t, ctx, aliceConn, "alice-session-token")
+
+	bobConn := dialTestServer(t, ctx, url)
+	defer bobConn.Close(websocket.StatusNormalClosure, "")
+	authenticateAs(t, ctx, bobConn, "bob-session-token")
+
+	commit := func(conn *websocket.Conn, added, removed []string) {
+		payload, _ := proto.Marshal(&protocol.MLSCommit{
+			ConversationId: conv.ID,
+			CommitData:     []byte("commit-data"),
+			AddedUserIds:   added,
+			RemovedUserIds: removed,
+		})
+		sendEnvelope(t, ctx, conn, &protocol.Envelope{
+			Type: protocol.MessageType_MLS_COMMIT, RequestId: "commit", Payload: payload,
+		})
+	}
+	wantError := func(conn *websocket.Conn, code int32) {
+		t.Helper()
+		resp := readEnvelope(t, ctx, conn)
+		if resp.Type != protocol.MessageType_ERROR {
+			t.Fatalf("Type = %v, want ERROR", resp.Type)
+		}
+		var errMsg protocol.Error
+		proto.Unmarshal(resp.Payload, &errMsg)
+		if errMsg.Code != code {
+			t.Errorf("Code = %d, want %d (%s)", errMsg.Code, code, errMsg.Message)
+		}
+	}
+
+	// Rejected changes leave membership alone.
+	commit(bobConn, nil, []string{"carol-id"})
+	wantError(bobConn, 4003) // not an admin
+	commit(aliceConn, []string{"carol-id"}, nil)
+	wantError(aliceConn, 4002) // already a member
+	commit(aliceConn, []string{"dave-id"}, []string{"erin-id"})
+	wantError(aliceConn, 4003) // not a member
+	commit(aliceConn, nil, []string{"alice-id"})
+	wantError(aliceConn, 4004)
+	commit(aliceConn, []string{"dave-id"}, []string{"dave-id"})
+	wantError(aliceConn, 3001)
+
+	members, err := s.GetMembers(ctx, conv.ID)
+	if err != nil {
+		t.Fatalf("GetMembers: %v", err)
+	}
+	if len(members) != 3 {
+		t.Fatalf("members after rejected commits = %d, want 3", len(members))
+	}
+
+	// Alice adds dave and removes bob.
+	commit(aliceConn, []string{"dave-id"}, []string{"bob-id"})
+
+	// Bob gets the Commit, then his removal.
+	resp := readEnvelope(t, ctx, bobConn)
+	if resp.Type != protocol.MessageType_MLS_COMMIT_BROADCAST {
+		t.Fatalf("Type = %v, want MLS_COMMIT_BROADCAST", resp.Type)
+	}
+	var broadcast protocol.MLSCommitBroadcast
+	proto.Unmarshal(resp.Payload, &broadcast)
+	if len(broadcast.AddedUserIds) != 1 || broadcast.AddedUserIds[0] != "dave-id" ||
+		len(broadcast.RemovedUserIds) != 1 || broadcast.RemovedUserIds[0] != "bob-id" {
+		t.Errorf("broadcast delta = +%v -%v, want +[dave-id] -[bob-id]", broadcast.AddedUserIds, broadcast.RemovedUserIds)
+	}
+	resp = readEnvelope(t, ctx, bobConn)
+	if resp.Type != protocol.MessageType_GROUP_MEMBER_REMOVED {
+		t.Fatalf("Type = %v, want GROUP_MEMBER_REMOVED", resp.Type)
+	}
+
+	// Alice is told about both changes.
+	for _, want := range []protocol.MessageType{protocol.MessageType_GROUP_MEMBER_ADDED, protocol.MessageType_GROUP_MEMBER_REMOVED} {
+		if resp := readEnvelope(t, ctx, aliceConn); resp.Type != want {
+			t.Fatalf("Type = %v, want %v", resp.Type, want)
+		}
+	}
+
+	members, err = s.GetMembers(ctx, conv.ID)
+	if err != nil {
+		t.Fatalf("GetMembers: %v", err)
+	}
+	got := make(map[string]bool)
+	for _, m := range members {
+		got[m.UserID] = true
+	}
+	if len(got) != 3 || !got["alice-id"] || !got["carol-id"] || !got["dave-id"] {
+		t.Errorf("members = %v, want alice, carol and dave", got)
+	}
+
+	// Carol receives the stored Commit when she connects.
+	carolConn := dialTestServer(t, ctx, url)
+	defer carolConn.Close(websocket.StatusNormalClosure, "")
+	authenticateAsWithPending(t, ctx, carolConn, "carol-session-token")
+	resp = readEnvelope(t, ctx, carolConn)
+	if resp.Type != protocol.MessageType_MLS_COMMIT_BROADCAST {
+		t.Fatalf("Type = %v, want MLS_COMMIT_BROADCAST", resp.Type)
+	}
+	proto.Unmarshal(resp.Payload, &broadcast)
+	if broadcast.ConversationId != conv.ID || string(broadcast.CommitData) != "commit-data" || broadcast.MessageId == "" {
+		t.Errorf("pending commit = %q %q %q, want %q commit-data with an ID",
+			broadcast.MessageId, broadcast.ConversationId, broadcast.CommitData, conv.ID)
+	}
+	// She is told what it changed, as the members online were.
+	if len(broadcast.AddedUserIds) != 1 || broadcast.AddedUserIds[0] != "dave-id" ||
+		len(broadcast.RemovedUserIds) != 1 || broadcast.RemovedUserIds[0] != "bob-id" {
+		t.Errorf("pending commit delta = +%v -%v, want +[dave-id] -[bob-id]", broadcast.AddedUserIds, broadcast.RemovedUserIds)
+	}
+	if sync := readSyncComplete(t, ctx, carolConn); sync.MessageCount != 1 {
+		t.Errorf("SyncComplete.MessageCount = %d, want 1", sync.MessageCount)
+	}
+}
+
+func TestMLSProposalRelay(t *testing.T) {
+	url, cleanup, s := setupTestServerWithAuth(t, 65536)
+	defer cleanup()
+	seedTwoUsers(t, s)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	conv, err := s.CreateConversation(ctx, "Group", "alice-id", []string{"bob-id"})
+	if err != nil {
+		t.Fatalf("CreateConversation: %v", err)
+	}
+
+	aliceConn := dialTestServer(t, ctx, url)
+	defer aliceConn.Close(websocket.StatusNormalClosure, "")
+	authenticateAs(t, ctx, aliceConn, "alice-session-token")
+
+	propose := func(conn *websocket.Conn, convID, data string) {
+		payload, _ := proto.Marshal(&protocol.MLSProposal{ConversationId: convID, ProposalData: []byte(data)})
+		sendEnvelope(t, ctx, conn, &protocol.Envelope{
+			Type: protocol.MessageType_MLS_PROPOSAL, RequestId: "proposal", Payload: payload,
+		})
+	}
+	readProposal := func(conn *websocket.Conn) *protocol.MLSProposalBroadcast {
+		t.Helper()
+		resp := readEnvelope(t, ctx, conn)
+		if resp.Type != protocol.MessageType_MLS_PROPOSAL_BROADCAST {
+			t.Fatalf("Type = %v, want MLS_PROPOSAL_BROADCAST", resp.Type)
+		}
+		var p protocol.MLSProposalBroadcast
+		if err := proto.Unmarshal(resp.Payload, &p); err != nil {
+			t.Fatalf("Unmarshal: %v", err)
+		}
+		return &p
+	}
+
+	// Bob is offline for the first Proposal. The rejected Proposal that
+	// follows makes sure alice's connection has handled the first one.
+	propose(aliceConn, conv.ID, "update-1")
+	propose(aliceConn, "nonexistent-conv", "update-x")
+	resp := readEnvelope(t, ctx, aliceConn)
+	var errMsg protocol.Error
+	proto.Unmarshal(resp.Payload, &errMsg)
+	if resp.Type != protocol.MessageType_ERROR || errMsg.Code != 4001 {
+		t.Fatalf("non-member proposal: got %v code %d, want ERROR 4001", resp.Type, errMsg.Code)
+	}
+
+	bobConn := dialTestServer(t, ctx, url)
+	defer bobConn.Close(websocket.StatusNormalClosure, "")
+	authenticateAsWithPending(t, ctx, bobConn, "bob-session-token")
+
+	p := readProposal(bobConn)
+	if p.ConversationId != conv.ID || p.SenderId != "alice-id" || string(p.ProposalData) != "update-1" || p.MessageId == "" {
+		t.Errorf("pending proposal = %+v, want update-1 from alice-id", p)
+	}
+	readSyncComplete(t, ctx, bobConn)
+
+	// Online members receive Proposals as they are sent.
+	propose(bobConn, conv.ID, "update-2")
+	p = readProposal(aliceConn)
+	if p.SenderId != "bob-id" || string(p.ProposalData) != "update-2" {
+		t.Errorf("proposal = %+v, want update-2 from bob-id", p)
+	}
+
+	msgs, err := s.GetMessagesByGroup(ctx, conv.ID, "", 10, false)
+	if err != nil {
+		t.Fatalf("GetMessagesByGroup: %v", err)
+	}
+	if len(msgs) != 2 {
+		t.Fatalf("stored messages = %d, want 2", len(msgs))
+	}
+	for _, m := range msgs {
+		if m.MessageType != store.MsgTypeProposal {
+			t.Errorf("MessageType = %d, want MsgTypeProposal", m.MessageType)
+		}
+	}
+}
+
+func TestMLSUpdateRequested(t *testing.T) {
+	url, cleanup, s := setupTestServerWithOptions(t, 65536, testServerOptions{updateInterval: time.Hour})
+	defer cleanup()
+	seedTwoUsers(t, s)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	conv, err := s.CreateConversation(ctx, "Group", "alice-id", []string{"bob-id"})
+	if err != nil {
+		t.Fatalf("CreateConversation: %v", err)
+	}
+	joinedAt := time.Now().Add(-2 * time.Hour).Unix()
+	if _, err := s.DB().ExecContext(ctx, `UPDATE group_members SET joined_at = ?`, joinedAt); err != nil {
+		t.Fatalf("backdate joined_at: %v", err)
+	}
+
+	// Alice has not updated her leaf key since joining two hours ago.
+	aliceConn := dialTestServer(t, ctx, url)
+	defer aliceConn.Close(websocket.StatusNormalClosure, "")
+	authenticateAs(t, ctx, aliceConn, "alice-session-token")
+
+	resp := readEnvelope(t, ctx, aliceConn)
+	if resp.Type != protocol.MessageType_MLS_UPDATE_REQUESTED {
+		t.Fatalf("Type = %v, want MLS_UPDATE_REQUESTED", resp.Type)
+	}
+	var req protocol.MLSUpdateRequested
+	if err := proto.Unmarshal(resp.Payload, &req); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if req.ConversationId != conv.ID || req.LastUpdateAt != joinedAt || req.LastUpdateEpoch != 0 {
+		t.Errorf("update requested = %+v, want %s last updated at %d", &req, conv.ID, joinedAt)
+	}
+
+	// Each message is followed by a rejected Commit, to make sure it has
+	// been handled.
+	commit := func(convID string, data []byte) {
+		payload, _ := proto.Marshal(&protocol.MLSCommit{ConversationId: convID, CommitData: data})
+		sendEnvelope(t, ctx, aliceConn, &protocol.Envelope{
+			Type: protocol.MessageType_MLS_COMMIT, RequestId: "commit", Payload: payload,
+		})
+	}
+	handled := func() {
+		t.Helper()
+		commit("nonexistent-conv", []byte("commit-data"))
+		if resp := readEnvelope(t, ctx, aliceConn); resp.Type != protocol.MessageType_ERROR {
+			t.Fatalf("Type = %v, want ERROR", resp.Type)
+		}
+	}
+	aliceUpdate := func() *store.MemberUpdate {
+		t.Helper()
+		updates, err := s.GetMemberUpdates(ctx, conv.ID)
+		if err != nil {
+			t.Fatalf("GetMemberUpdates: %v", err)
+		}
+		for _, u := range updates {
+			if u.UserID == "alice-id" {
+				return u
+			}
+		}
+		t.Fatal("no update record for alice-id")
+		return nil
+	}
+
+	// Add and Remove proposals, a Commit without an UpdatePath and an
+	// encrypted Commit leave her leaf key as it was.
+	for _, data := range [][]byte{
+		mlstest.NewPublicMessage(t, mlstest.PublicMessageOptions{GroupID: []byte("group-1"), Epoch: 4, ProposalType: mls.ProposalTypeAdd}),
+		mlstest.NewPublicMessage(t, mlstest.PublicMessageOptions{GroupID: []byte("group-1"), Epoch: 4, ProposalType: mls.ProposalTypeRemove}),
+	} {
+		payload, _ := proto.Marshal(&protocol.MLSProposal{ConversationId: conv.ID, ProposalData: data})
+		sendEnvelope(t, ctx, aliceConn, &protocol.Envelope{
+			Type: protocol.MessageType_MLS_PROPOSAL, RequestId: "proposal", Payload: payload,
+		})
+	}
+	commit(conv.ID, mlstest.NewPublicMessage(t, mlstest.PublicMessageOptions{GroupID: []byte("group-1"), Epoch: 4}))
+	commit(conv.ID, mlstest.NewPrivateMessage(t, mlstest.MessageOptions{GroupID: []byte("group-1"), Epoch: 4}))
+	handled()
+	if u := aliceUpdate(); u.LastUpdateAt != nil {
+		t.Errorf("alice = %+v, want not updated", u)
+	}
+
+	// Her Commit with an UpdatePath records the update and its epoch.
+	commit(conv.ID, mlstest.NewPublicMessage(t, mlstest.PublicMessageOptions{GroupID: []byte("group-1"), Epoch: 5, UpdatePath: true}))
+	handled()
+
+	updates, err := s.GetMemberUpdates(ctx, conv.ID)
+	if err != nil {
+		t.Fatalf("GetMemberUpdates: %v", err)
+	}
+	for _, u := range updates {
+		switch u.UserID {
+		case "alice-id":
+			if u.LastUpdateAt == nil || u.LastUpdateEpoch == nil || *u.LastUpdateEpoch != 5 || u.UpdateRequestedAt != nil {
+				t.Errorf("alice = %+v, want updated at epoch 5 with no request outstanding", u)
+			}
+		case "bob-id":
+			if u.LastUpdateAt != nil || u.UpdateRequestedAt != nil {
+				t.Errorf("bob = %+v, want not updated and not yet asked", u)
+			}
+		}
+	}
 }
 
 func TestMLSCommitNonMemberRejected(t *testing.T) {
@@ -503,3 +1407,172 @@ func TestMLSCommitNonMemberRejected(t *testing.T) {
 		t.Errorf("Code = %d, want 4001", errMsg.Code)
 	}
 }
+
+func TestMLSGroupInfoUploadAndFetch(t *testing.T) {
+	url, cleanup, s := setupTestServerWithAuth(t, 65536)
+	defer cleanup()
+	seedTwoUsers(t, s)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	conv, err := s.CreateConversation(ctx, "Group", "alice-id", []string{"bob-id"})
+	if err != nil {
+		t.Fatalf("CreateConversation: %v", err)
+	}
+	private, err := s.CreateConversation(ctx, "Notes", "alice-id", nil)
+	if err != nil {
+		t.Fatalf("CreateConversation: %v", err)
+	}
+
+	aliceConn := dialTestServer(t, ctx, url)
+	defer aliceConn.Close(websocket.StatusNormalClosure, "")
+	authenticateAs(t, ctx, aliceConn, "alice-session-token")
+
+	bobConn := dialTestServer(t, ctx, url)
+	defer bobConn.Close(websocket.StatusNormalClosure, "")
+	authenticateAs(t, ctx, bobConn, "bob-session-token")
+
+	groupID := []byte("group-1")
+	upload := func(conn *websocket.Conn, convID string, epoch uint64) {
+		payload, _ := proto.Marshal(&protocol.MLSGroupInfoUpload{
+			ConversationId: convID,
+			GroupInfoData:  mlstest.NewGroupInfo(t, mlstest.GroupInfoOptions{GroupID: groupID, Epoch: epoch}),
+		})
+		sendEnvelope(t, ctx, conn, &protocol.Envelope{
+			Type: protocol.MessageType_MLS_GROUP_INFO_UPLOAD, RequestId: "gi-upload", Payload: payload,
+		})
+	}
+	fetch := func(conn *websocket.Conn, convID string) *protocol.Envelope {
+		payload, _ := proto.Marshal(&protocol.MLSGroupInfoFetch{ConversationId: convID})
+		sendEnvelope(t, ctx, conn, &protocol.Envelope{
+			Type: protocol.MessageType_MLS_GROUP_INFO_FETCH, RequestId: "gi-fetch", Payload: payload,
+		})
+		return readEnvelope(t, ctx, conn)
+	}
+	wantError := func(env *protocol.Envelope, code int32) {
+		t.Helper()
+		if env.Type != protocol.MessageType_ERROR {
+			t.Fatalf("Type = %v, want ERROR", env.Type)
+		}
+		var errMsg protocol.Error
+		proto.Unmarshal(env.Payload, &errMsg)
+		if errMsg.Code != code {
+			t.Errorf("Code = %d, want %d", errMsg.Code, code)
+		}
+	}
+
+	// Nothing published yet.
+	wantError(fetch(bobConn, conv.ID), 5007)
+
+	// Alice publishes epoch 4; bob, who lost his state, fetches it.
+	// Uploads have no response; alice's own fetch orders the upload before
+	// bob's.
+	upload(aliceConn, conv.ID, 4)
+	if resp := fetch(aliceConn, conv.ID); resp.Type != protocol.MessageType_MLS_GROUP_INFO_RESPONSE {
+		t.Fatalf("Type = %v, want MLS_GROUP_INFO_RESPONSE", resp.Type)
+	}
+	resp := fetch(bobConn, conv.ID)
+	if resp.Type != protocol.MessageType_MLS_GROUP_INFO_RESPONSE {
+		t.Fatalf("Type = %v, want MLS_GROUP_INFO_RESPONSE", resp.Type)
+	}
+	var gi protocol.MLSGroupInfoResponse
+	if err := proto.Unmarshal(resp.Payload, &gi); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if gi.ConversationId != conv.ID || gi.Epoch != 4 || len(gi.GroupInfoData) == 0 {
+		t.Errorf("response = conversation %q epoch %d, want %q epoch 4", gi.ConversationId, gi.Epoch, conv.ID)
+	}
+
+	// An earlier epoch is rejected.
+	upload(bobConn, conv.ID, 3)
+	wantError(readEnvelope(t, ctx, bobConn), 5004)
+
+	// Malformed GroupInfo is rejected.
+	payload, _ := proto.Marshal(&protocol.MLSGroupInfoUpload{ConversationId: conv.ID, GroupInfoData: []byte("garbage")})
+	sendEnvelope(t, ctx, bobConn, &protocol.Envelope{
+		Type: protocol.MessageType_MLS_GROUP_INFO_UPLOAD, RequestId: "gi-bad", Payload: payload,
+	})
+	wantError(readEnvelope(t, ctx, bobConn), 5006)
+
+	// Bob is not in alice's private conversation.
+	upload(bobConn, private.ID, 1)
+	wantError(readEnvelope(t, ctx, bobConn), 4001)
+	wantError(fetch(bobConn, private.ID), 4001)
+}
+
+func TestProfileUpdate(t *testing.T) {
+	url, cleanup, s := setupTestServerWithAuth(t, 65536)
+	defer cleanup()
+	seedTwoUsers(t, s)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := s.CreateConversation(ctx, "DM", "alice-id", []string{"bob-id"}); err != nil {
+		t.Fatalf("CreateConversation: %v", err)
+	}
+
+	aliceConn := dialTestServer(t, ctx, url)
+	defer aliceConn.Close(websocket.StatusNormalClosure, "")
+	authenticateAs(t, ctx, aliceConn, "alice-session-token")
+
+	bobConn := dialTestServer(t, ctx, url)
+	defer bobConn.Close(websocket.StatusNormalClosure, "")
+	authenticateAs(t, ctx, bobConn, "bob-session-token")
+
+	// An invalid display name is rejected.
+	payload, _ := proto.Marshal(&protocol.ProfileUpdate{DisplayName: "  "})
+	sendEnvelope(t, ctx, aliceConn, &protocol.Envelope{
+		Type: protocol.MessageType_PROFILE_UPDATE, RequestId: "pu-1", Payload: payload,
+	})
+	resp := readEnvelope(t, ctx, aliceConn)
+	if resp.Type != protocol.MessageType_ERROR {
+		t.Fatalf("Type = %v, want ERROR", resp.Type)
+	}
+	var errMsg protocol.Error
+	if err := proto.Unmarshal(resp.Payload, &errMsg); err != nil {
+		t.Fatalf("Unmarshal Error: %v", err)
+	}
+	if errMsg.Code != 1008 {
+		t.Errorf("Code = %d, want 1008", errMsg.Code)
+	}
+
+	// A valid update is confirmed to alice and fanned out to bob.
+	payload, _ = proto.Marshal(&protocol.ProfileUpdate{DisplayName: " Alice Liddell "})
+	sendEnvelope(t, ctx, aliceConn, &protocol.Envelope{
+		Type: protocol.MessageType_PROFILE_UPDATE, RequestId: "pu-2", Payload: payload,
+	})
+
+	for _, tc := range []struct {
+		who           string
+		conn          *websocket.Conn
+		wantRequestID string
+	}{
+		{"alice", aliceConn, "pu-2"},
+		{"bob", bobConn, ""},
+	} {
+		resp := readEnvelope(t, ctx, tc.conn)
+		if resp.Type != protocol.MessageType_PROFILE_NOTIFY {
+			t.Fatalf("%s: Type = %v, want PROFILE_NOTIFY", tc.who, resp.Type)
+		}
+		if resp.RequestId != tc.wantRequestID {
+			t.Errorf("%s: RequestId = %q, want %q", tc.who, resp.RequestId, tc.wantRequestID)
+		}
+		var notify protocol.ProfileNotify
+		if err := proto.Unmarshal(resp.Payload, &notify); err != nil {
+			t.Fatalf("%s: Unmarshal ProfileNotify: %v", tc.who, err)
+		}
+		if notify.UserId != "alice-id" || notify.Username != "alice" || notify.DisplayName != "Alice Liddell" {
+			t.Errorf("%s: got %+v", tc.who, &notify)
+		}
+	}
+
+	u, err := s.GetUserByID(ctx, "alice-id")
+	if err != nil {
+		t.Fatalf("GetUserByID: %v", err)
+	}
+	if u.DisplayName != "Alice Liddell" {
+		t.Errorf("stored DisplayName = %q, want %q", u.DisplayName, "Alice Liddell")
+	}
+}
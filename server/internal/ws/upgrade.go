@@ -2,6 +2,7 @@ package ws
 
 import (
 	"log"
+	"net"
 	"net/http"
 
 	"nhooyr.io/websocket"
@@ -12,11 +13,11 @@ import (
 )
 
 // UpgradeHandler returns an HTTP handler that upgrades connections to WebSocket.
-func UpgradeHandler(hub *Hub, maxMessageSize int, authService *auth.Service, st *store.Store, mlsSvc *mls.Service) http.HandlerFunc {
+func UpgradeHandler(hub *Hub, maxMessageSize int, authService *auth.Service, st store.Backend, mlsSvc *mls.Service) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		conn, err := websocket.Accept(w, r, &websocket.AcceptOptions{
-			Subprotocols:         []string{"sovereign.v1"},
-			InsecureSkipVerify:   true,
+			Subprotocols:       []string{"sovereign.v1"},
+			InsecureSkipVerify: true,
 		})
 		if err != nil {
 			log.Printf("WebSocket upgrade failed: %v", err)
@@ -30,7 +31,7 @@ func UpgradeHandler(hub *Hub, maxMessageSize int, authService *auth.Service, st
 		}
 
 		id := connID()
-		c := NewConn(id, conn, hub, maxMessageSize, authService, st, mlsSvc)
+		c := NewConn(id, remoteIP(r), conn, hub, maxMessageSize, authService, st, mlsSvc)
 
 		log.Printf("New WebSocket connection: %s from %s", id, r.RemoteAddr)
 
@@ -38,3 +39,12 @@ func UpgradeHandler(hub *Hub, maxMessageSize int, authService *auth.Service, st
 		c.Run(r.Context())
 	}
 }
+
+// remoteIP returns the IP address of the HTTP client, without the port.
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}